@@ -38,6 +38,7 @@ export function bagPut(body: {
   number?: string;
   holder_uid?: UID;
   color?: string;
+  note?: string;
 }) {
   return axios.put("/v2/bag", body);
 }
@@ -1,11 +1,34 @@
 import axios from "./axios";
 import type { UID } from "./types";
-import type { BulkyItem } from "./typex2";
+import type {
+  BulkyItem,
+  BulkyItemCategory,
+  BulkyItemGivenRequest,
+  BulkyItemReserveRequest,
+  BulkyItemSubscriptionsRequest,
+} from "./typex2";
 
-export function bulkyItemGetAllByChain(chainUID: UID, userUID: UID) {
-  return axios.get<BulkyItem[]>("/v2/bulky-item/all", {
-    params: { chain_uid: chainUID, user_uid: userUID },
-  });
+interface RequestBulkyItemGetAllParams {
+  // Without status all items except expired ones are returned
+  status?: BulkyItem["status"];
+  category?: BulkyItemCategory;
+  tag?: string;
+  search?: string;
+}
+
+export function bulkyItemGetAllByChain(
+  chainUID: UID,
+  userUID: UID,
+  filter?: RequestBulkyItemGetAllParams,
+) {
+  let params: { chain_uid: UID; user_uid: UID } & RequestBulkyItemGetAllParams =
+    { chain_uid: chainUID, user_uid: userUID };
+  if (filter?.status) params.status = filter.status;
+  if (filter?.category) params.category = filter.category;
+  if (filter?.tag) params.tag = filter.tag;
+  if (filter?.search) params.search = filter.search;
+
+  return axios.get<BulkyItem[]>("/v2/bulky-item/all", { params });
 }
 
 export function bulkyItemPut(body: {
@@ -15,6 +38,10 @@ export function bulkyItemPut(body: {
   title?: string;
   message?: string;
   image_url?: string;
+  category?: BulkyItemCategory;
+  tags?: string[];
+  sizes?: string[];
+  genders?: string[];
 }) {
   return axios.put("/v2/bulky-item", body);
 }
@@ -24,3 +51,42 @@ export function bulkyItemRemove(chainUID: UID, userUID: UID, id: number) {
     params: { chain_uid: chainUID, user_uid: userUID, id },
   });
 }
+
+export function bulkyItemReserve(chainUID: UID, id: number) {
+  return axios.post<never>("/v2/bulky-item/reserve", {
+    chain_uid: chainUID,
+    id,
+  } satisfies BulkyItemReserveRequest);
+}
+
+export function bulkyItemUnreserve(chainUID: UID, id: number) {
+  return axios.delete<never>("/v2/bulky-item/reserve", {
+    params: { chain_uid: chainUID, id },
+  });
+}
+
+// Without receiverUID the item is given to the member that reserved it
+export function bulkyItemGiven(chainUID: UID, id: number, receiverUID?: UID) {
+  return axios.patch<never>("/v2/bulky-item/given", {
+    chain_uid: chainUID,
+    id,
+    receiver_uid: receiverUID,
+  } satisfies BulkyItemGivenRequest);
+}
+
+export function bulkySubscriptionsGet(chainUID: UID) {
+  return axios.get<BulkyItemCategory[]>("/v2/bulky-item/subscriptions", {
+    params: { chain_uid: chainUID },
+  });
+}
+
+// An empty list subscribes to all categories
+export function bulkySubscriptionsPut(
+  chainUID: UID,
+  categories: BulkyItemCategory[],
+) {
+  return axios.put<never>("/v2/bulky-item/subscriptions", {
+    chain_uid: chainUID,
+    categories,
+  } satisfies BulkyItemSubscriptionsRequest);
+}
@@ -4,8 +4,15 @@ import {
   ChatCreateChannelResponse,
   ChatDeleteChannelRequest,
   ChatJoinChannelsRequest,
+  ChatMessage,
+  ChatMessageCreateRequest,
+  ChatMessageReport,
+  ChatMessageReportRequest,
+  ChatMute,
+  ChatMuteRequest,
   ChatPatchUserRequest,
   ChatPatchUserResponse,
+  ChatRetentionRequest,
 } from "./typex2";
 
 export function chatPatchUser(chain_uid: string) {
@@ -37,3 +44,84 @@ export function chatJoinChannels(chain_uid: string) {
     chain_uid,
   } satisfies ChatJoinChannelsRequest);
 }
+
+// Only for the built-in chat, newest first
+export function chatMessageGetAll(
+  chain_uid: string,
+  channel_id: string,
+  before_id?: number,
+) {
+  return axios.get<ChatMessage[]>(`/v2/chat/channel/messages`, {
+    params: { chain_uid, channel_id, before_id },
+  });
+}
+
+export function chatMessageCreate(
+  chain_uid: string,
+  channel_id: string,
+  message: string,
+) {
+  return axios.post<ChatMessage>(`/v2/chat/channel/message`, {
+    chain_uid,
+    channel_id,
+    message,
+  } satisfies ChatMessageCreateRequest);
+}
+
+export function chatMessageReport(
+  chain_uid: string,
+  channel_id: string,
+  message_id: string,
+  reason: string,
+) {
+  return axios.post<never>(`/v2/chat/message/report`, {
+    chain_uid,
+    channel_id,
+    message_id,
+    reason,
+  } satisfies ChatMessageReportRequest);
+}
+
+export function chatReportGetAll(chain_uid: string) {
+  return axios.get<ChatMessageReport[]>(`/v2/chat/reports`, {
+    params: { chain_uid },
+  });
+}
+
+export function chatReportDelete(chain_uid: string, id: number) {
+  return axios.delete<never>(`/v2/chat/report`, {
+    params: { chain_uid, id },
+  });
+}
+
+export function chatMuteGetAll(chain_uid: string) {
+  return axios.get<ChatMute[]>(`/v2/chat/mutes`, {
+    params: { chain_uid },
+  });
+}
+
+export function chatMutePut(
+  chain_uid: string,
+  user_uid: string,
+  minutes: number,
+) {
+  return axios.put<never>(`/v2/chat/mute`, {
+    chain_uid,
+    user_uid,
+    minutes,
+  } satisfies ChatMuteRequest);
+}
+
+export function chatMuteDelete(chain_uid: string, user_uid: string) {
+  return axios.delete<never>(`/v2/chat/mute`, {
+    params: { chain_uid, user_uid },
+  });
+}
+
+// Zero keeps messages forever
+export function chatRetentionUpdate(chain_uid: string, days: number) {
+  return axios.patch<never>(`/v2/chat/retention`, {
+    chain_uid,
+    days,
+  } satisfies ChatRetentionRequest);
+}
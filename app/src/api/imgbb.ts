@@ -35,6 +35,7 @@ export async function uploadImage(
   });
 }
 
-export function deleteImage(url: string) {
-  return axios.delete<never>("/v2/image", { params: { url } });
+// Only the uploader of the image is allowed to delete it
+export function deleteImage(id: number) {
+  return axios.delete<never>("/v2/image", { params: { id } });
 }
@@ -1,6 +1,12 @@
 import type { UID } from "./types";
 import axios from "./axios";
-import { User, UserUpdateRequest } from "./typex2";
+import {
+  NotificationPreference,
+  NotificationPreferenceUpdateRequest,
+  User,
+  UserNotificationListResponse,
+  UserUpdateRequest,
+} from "./typex2";
 
 export function userGetByUID(
   chainUID: string | undefined,
@@ -71,3 +77,30 @@ export function userCheckEmailExists(email: string) {
     params: { email },
   });
 }
+
+export function userNotificationPreferencesGet(chainUID?: UID) {
+  return axios.get<NotificationPreference[]>(
+    "/v2/user/notification-preferences",
+    { params: chainUID ? { chain_uid: chainUID } : {} },
+  );
+}
+
+export function userNotificationPreferenceUpdate(
+  body: NotificationPreferenceUpdateRequest,
+) {
+  return axios.patch<never>("/v2/user/notification-preferences", body);
+}
+
+export function userNotificationGetAll(page = 0, pageSize?: number) {
+  return axios.get<UserNotificationListResponse>("/v2/user/notification/all", {
+    params: { page, page_size: pageSize },
+  });
+}
+
+export function userNotificationMarkRead(ids: number[]) {
+  return axios.patch<never>("/v2/user/notification/read", { ids });
+}
+
+export function userNotificationMarkAllRead() {
+  return axios.patch<never>("/v2/user/notification/read-all");
+}
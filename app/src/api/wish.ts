@@ -0,0 +1,22 @@
+import axios from "./axios";
+import type { UID } from "./types";
+import type { Wish, WishPutRequest } from "./typex2";
+
+export function wishGetAll(chainUID: UID, includeFulfilled = false) {
+  let params: { chain_uid: UID; include_fulfilled?: boolean } = {
+    chain_uid: chainUID,
+  };
+  if (includeFulfilled) params.include_fulfilled = true;
+  return axios.get<Wish[]>("/v2/wish/all", { params });
+}
+
+// Creates a new wish without an id
+export function wishPut(body: WishPutRequest) {
+  return axios.put<{ id: number }>("/v2/wish", body);
+}
+
+export function wishRemove(chainUID: UID, id: number) {
+  return axios.delete<never>("/v2/wish", {
+    params: { chain_uid: chainUID, id },
+  });
+}
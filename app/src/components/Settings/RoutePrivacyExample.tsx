@@ -33,6 +33,8 @@ export default function RoutePrivacyExample(props: {
       sizes: [],
       is_root_admin: false,
       paused_until: i === pausedIndex - 1 ? "2104-06-12T15:23:23Z" : null,
+      purge_at: null,
+      email_bounced_at: null,
       i18n: "en",
       chat_id: "",
       chat_user_name: "",
@@ -51,6 +53,8 @@ export default function RoutePrivacyExample(props: {
       sizes: [],
       is_root_admin: false,
       paused_until: null,
+      purge_at: null,
+      email_bounced_at: null,
       i18n: "en",
       chat_id: "",
       chat_user_name: "",
@@ -9,7 +9,7 @@ import { Client4 } from "../utils/client4";
 type State = {
   chat_team: string;
   chat_user: string;
-  chat_pass: string;
+  chat_token: string;
   client: Client4;
   socket: WebSocketClient;
   user_profiles: Record<string, UserProfile>;
@@ -34,14 +34,10 @@ export function ChatProvider({ children }: PropsWithChildren) {
       const client = new Client4();
       client.setUrl(VITE_CHAT_URL);
       client.setIncludeCookies(false);
-      const { token } = await client.login2(
-        data.chat_user_name + "@example.com",
-        data.chat_pass,
-      );
-      console.log("1user profile token: ", token);
+      // session token of the single sign-on user, created by the server
+      const token = data.chat_token;
       client.setToken(token);
       client.setHeader("Token", token);
-      console.log("user profile token: ", token);
       const socket = new WebSocketClient();
       const url = client.getWebSocketUrl().replace("http", "ws");
       socket.initialize(url, token);
@@ -51,7 +47,7 @@ export function ChatProvider({ children }: PropsWithChildren) {
       setState({
         chat_team: data.chat_team,
         chat_user: data.chat_user_id,
-        chat_pass: data.chat_pass,
+        chat_token: data.chat_token,
         client,
         socket,
         user_profiles: {},
@@ -0,0 +1,18 @@
+// Shows the push notifications sent by the server through Web Push
+self.addEventListener("push", function (event) {
+  let data = { title: "The Clothing Loop" };
+  try {
+    data = event.data.json();
+  } catch (_) {}
+  event.waitUntil(
+    self.registration.showNotification(data.title, {
+      body: data.content || "",
+      icon: "/logo192.png",
+    }),
+  );
+});
+
+self.addEventListener("notificationclick", function (event) {
+  event.notification.close();
+  event.waitUntil(self.clients.openWindow("/"));
+});
@@ -19,3 +19,9 @@ export function contactMailSend(name: string, email: string, message: string) {
     message,
   });
 }
+
+export function mailUnsubscribe(email: string, category: string, sig: string) {
+  return axios.post<never>("/v2/mail/unsubscribe", undefined, {
+    params: { email, category, sig },
+  });
+}
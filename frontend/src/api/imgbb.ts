@@ -1,8 +1,9 @@
 import axios from "./axios";
 export interface UploadImageBody {
-  delete: string;
+  id: number;
+  thumbnail: string;
   image: string;
-  thumb: string;
+  original: string;
 }
 
 function getBase64(file: File) {
@@ -39,6 +40,7 @@ export async function uploadImage(
   });
 }
 
-export function deleteImage(url: string) {
-  return axios.delete<never>("/v2/image", { params: { url } });
+// Only the uploader of the image is allowed to delete it
+export function deleteImage(id: number) {
+  return axios.delete<never>("/v2/image", { params: { id } });
 }
@@ -2,6 +2,7 @@ export interface Bag {
 	id: number
 	number: string
 	color: string
+	note: string
 	chain_uid: string
 	user_uid: string
 	updated_at: string
@@ -14,7 +15,41 @@ export interface BulkyItem {
 	image_url: string
 	chain_uid: string
 	user_uid: string
+	status: "available" | "reserved" | "given" | "expired"
+	category: BulkyItemCategory
+	tags: string[] | null
+	sizes: string[] | null
+	genders: string[] | null
+	receiver_uid?: string
 	created_at: string
+	updated_at: string
+}
+
+export type BulkyItemCategory =
+	| "furniture"
+	| "kids"
+	| "kitchen"
+	| "sports"
+	| "electronics"
+	| "garden"
+	| "books"
+	| "household"
+	| "other"
+
+export interface BulkyItemGivenRequest {
+	chain_uid: string
+	id: number
+	receiver_uid?: string
+}
+
+export interface BulkyItemReserveRequest {
+	chain_uid: string
+	id: number
+}
+
+export interface BulkyItemSubscriptionsRequest {
+	chain_uid: string
+	categories: BulkyItemCategory[]
 }
 
 export interface ChainAddUserRequest {
@@ -28,6 +63,12 @@ export interface ChainApproveUserRequest {
 	chain_uid: string
 }
 
+export interface ChainChangeUserRoleRequest {
+	user_uid: string
+	chain_uid: string
+	role: string
+}
+
 export interface ChainChangeUserWardenRequest {
 	user_uid: string
 	chain_uid: string
@@ -75,6 +116,20 @@ export interface ChainResponse {
 	route_privacy?: (number | null)
 	allow_map?: (boolean | null)
 	chat_room_ids?: string[]
+	chat_retention_days?: number
+	bulky_item_expiry_days?: number
+}
+
+export interface ChainRole {
+	name: string
+	actions: string[]
+	is_built_in: boolean
+}
+
+export interface ChainRolePutRequest {
+	chain_uid: string
+	name: string
+	actions: string[]
 }
 
 export interface ChainUpdateRequest {
@@ -97,6 +152,7 @@ export interface ChainUpdateRequest {
 	route_privacy: (number | null)
 	allow_map?: (boolean | null)
 	is_app_disabled?: (boolean | null)
+	bulky_item_expiry_days?: (number | null)
 }
 
 export interface ChatCreateChannelRequest {
@@ -118,17 +174,87 @@ export interface ChatJoinChannelsRequest {
 	chain_uid: string
 }
 
+export interface ChatMessage {
+	id: number
+	channel_id: string
+	user_uid: string
+	user_name: string
+	message: string
+	created_at: string
+}
+
+export interface ChatMessageCreateRequest {
+	chain_uid: string
+	channel_id: string
+	message: string
+}
+
+export interface ChatMessageReportRequest {
+	chain_uid: string
+	channel_id: string
+	message_id: string
+	reason: string
+}
+
+export interface ChatMessageReport {
+	id: number
+	channel_id: string
+	message_id: string
+	message: string
+	author_uid: string
+	author_name: string
+	reporter_uid: string
+	reporter_name: string
+	reason: string
+	created_at: string
+}
+
+export interface ChatMuteRequest {
+	chain_uid: string
+	user_uid: string
+	minutes: number
+}
+
+export interface ChatMute {
+	user_uid: string
+	user_name: string
+	until: string
+	created_at: string
+}
+
 export interface ChatPatchUserRequest {
 	chain_uid: string
 }
 
 export interface ChatPatchUserResponse {
+	chat_provider: string
 	chat_team: string
 	chat_user_id: string
-	chat_pass: string
+	chat_token: string
 	chat_user_name: string
 }
 
+export interface ChatSyncChange {
+	chain_uid: string
+	channel_id: string
+	chat_user_id: string
+	user_uid: string
+	action: "remove" | "set_admin" | "unset_admin"
+	error?: string
+}
+
+export interface ChatSyncReport {
+	dry_run: boolean
+	chains: number
+	changes: ChatSyncChange[]
+	errors: string[]
+}
+
+export interface ChatRetentionRequest {
+	chain_uid: string
+	days: number
+}
+
 export interface ContactMailRequest {
 	name: string
 	email: string
@@ -212,10 +338,19 @@ export interface EventUpdateRequest {
 	chain_uid?: (string | null)
 }
 
+export interface ImageGCReport {
+	dry_run: boolean
+	kept: number
+	removed: string[]
+	bytes: number
+	errors: string[]
+}
+
 export interface ImageUploadResponse {
-	delete: string
+	id: number
 	thumbnail: string
 	image: string
+	original: string
 }
 
 export interface Info {
@@ -243,6 +378,20 @@ export interface LoginSuperAsGenerateLinkRequest {
 
 export type NewNullEventPriceType = unknown
 
+export interface NotificationPreference {
+	kind: string
+	default: string
+	channels: string[]
+	channel: string
+	is_chain_override: boolean
+}
+
+export interface NotificationPreferenceUpdateRequest {
+	chain_uid?: string
+	kind: string
+	channel: string
+}
+
 export interface NullEventPriceType {
 	EventPriceType: EventPriceType
 	Valid: boolean
@@ -290,6 +439,8 @@ export interface User {
 	is_email_verified: boolean
 	is_root_admin: boolean
 	paused_until: (string | null)
+	purge_at: (string | null)
+	email_bounced_at: (string | null)
 	name: string
 	phone_number: string
 	address: string
@@ -299,6 +450,7 @@ export interface User {
 	accepted_toh?: (boolean | null)
 	accepted_dpa?: (boolean | null)
 	notification_chain_uids?: string[]
+	notification_unread?: (number | null)
 	chat_id: (string | null)
 	chat_user_name: (string | null)
 }
@@ -308,6 +460,7 @@ export interface UserChain {
 	chain_uid: string
 	is_chain_admin: boolean
 	is_chain_warden: boolean
+	role: string
 	created_at: string
 	is_approved: boolean
 	is_paused: boolean
@@ -324,6 +477,39 @@ export interface UserCreateRequest {
 	longitude: number
 }
 
+export interface UserDataExportRequest {
+	user_uid: string
+}
+
+export interface UserEmailChangeRequest {
+	user_uid: string
+	new_email: string
+}
+
+export interface UserEmailChangeVerifyRequest {
+	user_uid: string
+	otp: string
+}
+
+export interface UserNotification {
+	id: number
+	kind: string
+	chain_uid?: (string | null)
+	title: string
+	content: string
+	read_at: (string | null)
+	created_at: string
+}
+
+export interface UserNotificationListResponse {
+	notifications: UserNotification[]
+	unread: number
+}
+
+export interface UserNotificationMarkReadRequest {
+	ids: number[]
+}
+
 export interface UserOnesignal {
 	ID: number
 	PlayerID: string
@@ -331,6 +517,10 @@ export interface UserOnesignal {
 	UserID: number
 }
 
+export interface UserPurgeCancelRequest {
+	token: string
+}
+
 export interface UserToken {
 	ID: number
 	Token: string
@@ -362,6 +552,45 @@ export interface UserUpdateRequest {
 	accepted_legal?: (boolean | null)
 }
 
+export interface WebPushPublicKeyResponse {
+	public_key: string
+}
+
+export interface WebPushSubscriptionDeleteRequest {
+	endpoint: string
+}
+
+export interface WebPushSubscriptionRequest {
+	endpoint: string
+	p256dh: string
+	auth: string
+}
+
+export interface Wish {
+	id: number
+	user_uid: string
+	user_name: string
+	title: string
+	message: string
+	category: BulkyItemCategory | ""
+	sizes: string[]
+	genders: string[]
+	fulfilled: boolean
+	created_at: string
+	updated_at: string
+}
+
+export interface WishPutRequest {
+	chain_uid: string
+	id?: number
+	title?: string
+	message?: string
+	category?: BulkyItemCategory | ""
+	sizes?: string[]
+	genders?: string[]
+	fulfilled?: boolean
+}
+
 type _EventPriceTypeValue = Record<string, EventPriceType>
 
 type errEventPriceTypeNilPtr = Record<string, any>
@@ -1,6 +1,13 @@
 import type { UID, User } from "./types";
 import axios from "./axios";
-import type { UserUpdateRequest } from "./typex2";
+import type {
+  NotificationPreference,
+  NotificationPreferenceUpdateRequest,
+  UserPurgeCancelRequest,
+  UserUpdateRequest,
+  WebPushPublicKeyResponse,
+  WebPushSubscriptionRequest,
+} from "./typex2";
 
 export function userGetByUID(
   chainUID: string | undefined,
@@ -60,6 +67,10 @@ export function userPurge(
   });
 }
 
+export function userPurgeCancel(body: UserPurgeCancelRequest) {
+  return axios.post<never>("/v2/user/purge/cancel", body);
+}
+
 export function userHasNewsletter(
   chainUID: string | undefined,
   userUID: string,
@@ -88,3 +99,32 @@ export function userCheckEmailExists(email: string) {
     params: { email },
   });
 }
+
+export function userNotificationPreferencesGet(chainUID?: UID) {
+  return axios.get<NotificationPreference[]>(
+    "/v2/user/notification-preferences",
+    { params: chainUID ? { chain_uid: chainUID } : {} },
+  );
+}
+
+export function userNotificationPreferenceUpdate(
+  body: NotificationPreferenceUpdateRequest,
+) {
+  return axios.patch<never>("/v2/user/notification-preferences", body);
+}
+
+export function userWebPushPublicKeyGet() {
+  return axios.get<WebPushPublicKeyResponse>(
+    "/v2/user/web-push-subscription/public-key",
+  );
+}
+
+export function userWebPushSubscriptionPut(body: WebPushSubscriptionRequest) {
+  return axios.put<never>("/v2/user/web-push-subscription", body);
+}
+
+export function userWebPushSubscriptionDelete(endpoint: string) {
+  return axios.delete<never>("/v2/user/web-push-subscription", {
+    data: { endpoint },
+  });
+}
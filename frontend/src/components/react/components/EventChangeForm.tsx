@@ -118,7 +118,7 @@ export default function EventChangeForm(props: {
       dayjs(values.date).add(2, "hour").minute(0).second(0).format(),
   );
   const sepDateEnd = useSepDateTime(dateEnd, setDateEnd);
-  const [deleteImageUrl, setDeleteImageUrl] = useState("");
+  const [deleteImageID, setDeleteImageID] = useState(0);
   const [eventPriceValue, setEventPriceValue] = useState(
     () => values.price_value || 0,
   );
@@ -183,7 +183,7 @@ export default function EventChangeForm(props: {
       const res = await uploadImageFile(file, 800, EVENT_IMAGE_EXPIRATION);
       console.log(res.data);
       setValue("image_url", res.data.image);
-      setDeleteImageUrl(res.data.delete);
+      setDeleteImageID(res.data.id);
     } catch (err: any) {
       console.error("Unable to upload image", err);
       addToastError(GinParseErrors(t, err), err?.status);
@@ -191,8 +191,9 @@ export default function EventChangeForm(props: {
   }
 
   async function onImageDelete() {
-    if (deleteImageUrl) {
-      deleteImage(deleteImageUrl);
+    if (deleteImageID) {
+      deleteImage(deleteImageID);
+      setDeleteImageID(0);
     }
     setValue("image_url", "");
   }
@@ -450,7 +451,7 @@ export default function EventChangeForm(props: {
             />
             <div className="relative w-full aspect-[4/3] flex justify-end items-top">
               <div className="absolute z-10 flex flex-row">
-                {deleteImageUrl ? (
+                {deleteImageID ? (
                   <button
                     key="delete"
                     type="button"
@@ -0,0 +1,127 @@
+import { useEffect, useState } from "react";
+import { useTranslation } from "react-i18next";
+
+import type { UID } from "../../../api/types";
+import type { NotificationPreference } from "../../../api/typex2";
+import {
+  userNotificationPreferenceUpdate,
+  userNotificationPreferencesGet,
+} from "../../../api/user";
+import { addToastError } from "../../../stores/toast";
+import { GinParseErrors } from "../util/gin-errors";
+import {
+  webPushGetSubscription,
+  webPushIsSupported,
+  webPushSubscribe,
+  webPushUnsubscribe,
+} from "../util/web-push";
+
+export default function NotificationPreferences(props: {
+  chainUID?: UID;
+  classes?: string;
+}) {
+  const { t } = useTranslation();
+  const [preferences, setPreferences] = useState<NotificationPreference[]>([]);
+  const [isWebPushSubscribed, setIsWebPushSubscribed] = useState<
+    boolean | null
+  >(null);
+
+  useEffect(() => {
+    if (!webPushIsSupported()) return;
+    webPushGetSubscription().then((s) => setIsWebPushSubscribed(!!s));
+  }, []);
+
+  useEffect(() => {
+    (async () => {
+      try {
+        const res = await userNotificationPreferencesGet(props.chainUID);
+        setPreferences(res.data);
+      } catch (err: any) {
+        console.warn(err);
+      }
+    })();
+  }, [props.chainUID]);
+
+  async function onChange(kind: string, channel: string) {
+    try {
+      await userNotificationPreferenceUpdate({
+        chain_uid: props.chainUID,
+        kind,
+        channel,
+      });
+      setPreferences((s) =>
+        s.map((p) => {
+          if (p.kind === kind) {
+            return {
+              ...p,
+              channel: channel || p.default,
+              is_chain_override: !!props.chainUID && channel !== "",
+            };
+          }
+          // the server subscribes to the digest when a kind is collected in it
+          if (p.kind === "host_digest" && channel === "digest") {
+            return { ...p, channel: "email" };
+          }
+          return p;
+        }),
+      );
+    } catch (err: any) {
+      addToastError(GinParseErrors(t, err), err?.status);
+    }
+  }
+
+  async function onToggleWebPush() {
+    try {
+      if (isWebPushSubscribed) {
+        await webPushUnsubscribe();
+        setIsWebPushSubscribed(false);
+      } else {
+        const ok = await webPushSubscribe();
+        if (!ok) addToastError(t("webPushUnavailable"), 400);
+        setIsWebPushSubscribed(ok);
+      }
+    } catch (err: any) {
+      addToastError(GinParseErrors(t, err), err?.status);
+    }
+  }
+
+  if (!preferences.length) return null;
+  return (
+    <div className={props.classes}>
+      <h2 className="font-sans font-semibold text-xl text-secondary mb-3">
+        {t("notificationPreferences")}
+      </h2>
+      <table className="table table-compact w-full">
+        <tbody>
+          {preferences.map((p) => (
+            <tr key={p.kind}>
+              <td>{t("notificationKind_" + p.kind)}</td>
+              <td>
+                <select
+                  className="select select-sm select-bordered w-full"
+                  value={p.channel}
+                  onChange={(e) => onChange(p.kind, e.target.value)}
+                >
+                  {p.channels.map((channel) => (
+                    <option key={channel} value={channel}>
+                      {t("notificationChannel_" + channel)}
+                    </option>
+                  ))}
+                </select>
+              </td>
+            </tr>
+          ))}
+        </tbody>
+      </table>
+      {isWebPushSubscribed !== null ? (
+        <button
+          type="button"
+          className="btn btn-sm btn-secondary btn-outline mt-3"
+          onClick={onToggleWebPush}
+        >
+          {isWebPushSubscribed ? t("webPushDisable") : t("webPushEnable")}
+        </button>
+      ) : null}
+    </div>
+  );
+}
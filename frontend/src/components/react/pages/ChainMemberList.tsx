@@ -1511,6 +1511,13 @@ function ParticipantsTable(props: {
                           {u.email}
                         </a>
                       ) : null}
+                      {u.email_bounced_at ? (
+                        <span
+                          className="ms-1 tooltip tooltip-top text-red icon-alert-triangle"
+                          data-tip={t("emailBounced")}
+                          aria-label={t("emailBounced")}
+                        />
+                      ) : null}
                     </td>
                     <td className="text-sm leading-relaxed">
                       {u.phone_number ? (
@@ -92,7 +92,6 @@ export default function EventDetails() {
         await eventUpdate({
           uid: event.uid,
           image_url: res.data.image,
-          image_delete_url: res.data.delete,
         });
         await load();
       } catch (err: any) {
@@ -3,6 +3,7 @@ import { Trans, useTranslation } from "react-i18next";
 
 import { TwoColumnLayout } from "../components/Layouts";
 import { loginEmail, loginEmailAndAddToChain } from "../../../api/login";
+import axios from "../../../api/axios";
 
 import FormJup from "../util/form-jup";
 
@@ -31,7 +32,11 @@ export default function Login() {
   const [active, setActive] = useState(false);
   const [loading, setLoading] = useState(false);
 
-  const [chainUID, defaultEmail] = getQuery("chain", "email");
+  const [chainUID, defaultEmail, redirect] = getQuery(
+    "chain",
+    "email",
+    "redirect",
+  );
 
   function onSubmit(e: FormEvent<HTMLFormElement>) {
     e.preventDefault();
@@ -96,7 +101,10 @@ export default function Login() {
       message: t("userIsLoggedIn"),
     });
 
-    if (globalThis.window) {
+    if (globalThis.window && redirect && isApiUrl(redirect)) {
+      // continue the single sign-on flow of an external tool like the chat
+      window.location.href = redirect;
+    } else if (globalThis.window) {
       //@ts-ignore
       var browserLang = navigator.language || navigator.userLanguage;
       let lang = $authUser.get()?.i18n || browserLang || "en";
@@ -179,3 +187,12 @@ export default function Login() {
     </>
   );
 }
+
+function isApiUrl(url: string): boolean {
+  try {
+    const apiBase = new URL(axios.defaults.baseURL!, window.location.origin);
+    return new URL(url).href.startsWith(apiBase.href);
+  } catch (err) {
+    return false;
+  }
+}
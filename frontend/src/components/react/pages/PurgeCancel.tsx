@@ -0,0 +1,53 @@
+import { useState } from "react";
+import { useTranslation } from "react-i18next";
+import { userPurgeCancel } from "../../../api/user";
+import { addToast, addToastError } from "../../../stores/toast";
+import { GinParseErrors } from "../util/gin-errors";
+import getQuery from "../util/query";
+import useLocalizePath from "../util/localize_path.hooks";
+
+export default function PurgeCancel() {
+  const { t, i18n } = useTranslation();
+  const localizePath = useLocalizePath(i18n);
+  const [loading, setLoading] = useState(false);
+
+  // only cancelled by pressing the button, opening the link from the email is not enough
+  function onCancel() {
+    const [token] = getQuery("token");
+    setLoading(true);
+    userPurgeCancel({ token })
+      .then(() => {
+        addToast({
+          message: t("accountDeletionCancelled"),
+          type: "success",
+        });
+        window.location.href = localizePath("/users/login/");
+      })
+      .catch((err: any) => {
+        addToastError(GinParseErrors(t, err), err?.status);
+        setLoading(false);
+      });
+  }
+
+  return (
+    <main className="container px-1 md:px-20 pt-10 mx-auto">
+      <h1 className="font-serif font-bold text-secondary text-6xl mb-6">
+        {t("cancelAccountDeletion")}
+      </h1>
+      <p className="mb-6">{t("cancelAccountDeletionInfo")}</p>
+      <div className="flex flex-row">
+        <button
+          type="button"
+          className="btn btn-primary mr-4"
+          disabled={loading}
+          onClick={onCancel}
+        >
+          {t("keepMyAccount")}
+        </button>
+        <a className="btn btn-secondary btn-outline" href={localizePath("/")}>
+          {t("home")}
+        </a>
+      </div>
+    </main>
+  );
+}
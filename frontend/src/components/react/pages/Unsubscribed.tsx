@@ -0,0 +1,67 @@
+import { useState } from "react";
+import { useTranslation } from "react-i18next";
+import { mailUnsubscribe } from "../../../api/contact";
+import { addToastError } from "../../../stores/toast";
+import { GinParseErrors } from "../util/gin-errors";
+import getQuery from "../util/query";
+import useHydrated from "../util/hydrated.hooks";
+import useLocalizePath from "../util/localize_path.hooks";
+
+export default function Unsubscribed() {
+  const { t, i18n } = useTranslation();
+  const localizePath = useLocalizePath(i18n);
+  const query = useHydrated(() => getQuery("email", "category", "sig"));
+  const [unsubscribed, setUnsubscribed] = useState(false);
+  const [loading, setLoading] = useState(false);
+
+  // only unsubscribed by pressing the button, opening the link from the email is not enough
+  function onUnsubscribe() {
+    if (!query) return;
+    const [email, category, sig] = query;
+    setLoading(true);
+    mailUnsubscribe(email, category, sig)
+      .then(() => setUnsubscribed(true))
+      .catch((err: any) => {
+        addToastError(GinParseErrors(t, err), err?.status);
+        setLoading(false);
+      });
+  }
+
+  if (query?.[2] && !unsubscribed) {
+    return (
+      <main className="container px-1 md:px-20 pt-10 mx-auto">
+        <h1 className="font-serif font-bold text-secondary text-6xl mb-6">
+          {t("unsubscribe")}
+        </h1>
+        <p className="mb-6">{t("unsubscribeFromTheseEmails")}</p>
+        <div className="flex flex-row">
+          <button
+            type="button"
+            className="btn btn-primary mr-4"
+            disabled={loading}
+            onClick={onUnsubscribe}
+          >
+            {t("unsubscribe")}
+          </button>
+          <a className="btn btn-secondary btn-outline" href={localizePath("/")}>
+            {t("home")}
+          </a>
+        </div>
+      </main>
+    );
+  }
+
+  return (
+    <main className="container px-1 md:px-20 pt-10 mx-auto">
+      <h1 className="font-serif font-bold text-secondary text-6xl mb-6">
+        {t("unsubscribed")}
+      </h1>
+      <p className="mb-6">{t("noLongerReceiveTheseEmails")}</p>
+      <div className="flex flex-row">
+        <a className="btn btn-secondary btn-outline" href={localizePath("/")}>
+          {t("home")}
+        </a>
+      </div>
+    </main>
+  );
+}
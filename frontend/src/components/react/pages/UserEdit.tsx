@@ -8,6 +8,7 @@ import {
 } from "../../../api/user";
 import { GinParseErrors } from "../util/gin-errors";
 import AddressForm, { type ValuesForm } from "../components/AddressForm";
+import NotificationPreferences from "../components/NotificationPreferences";
 import { useTranslation } from "react-i18next";
 import { addToastError } from "../../../stores/toast";
 import { useStore } from "@nanostores/react";
@@ -95,6 +96,10 @@ export default function UserEdit() {
             isNewsletterRequired={userIsAnyChainAdmin && !user.is_root_admin}
           />
 
+          {isMe ? (
+            <NotificationPreferences chainUID={chainUID} classes="mb-6" />
+          ) : null}
+
           <div className="flex">
             <button
               type="button"
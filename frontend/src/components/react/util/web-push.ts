@@ -0,0 +1,65 @@
+import {
+  userWebPushPublicKeyGet,
+  userWebPushSubscriptionDelete,
+  userWebPushSubscriptionPut,
+} from "../../../api/user";
+
+// Registered next to sw.js, which removes the old service workers of the root scope
+const SW_URL = "/push-sw.js";
+const SW_SCOPE = "/push/";
+
+export function webPushIsSupported(): boolean {
+  return (
+    typeof window !== "undefined" &&
+    "serviceWorker" in navigator &&
+    "PushManager" in window &&
+    "Notification" in window
+  );
+}
+
+async function webPushRegistration() {
+  return navigator.serviceWorker.register(SW_URL, { scope: SW_SCOPE });
+}
+
+export async function webPushGetSubscription() {
+  if (!webPushIsSupported()) return null;
+  const registration = await navigator.serviceWorker.getRegistration(SW_SCOPE);
+  if (!registration) return null;
+  return registration.pushManager.getSubscription();
+}
+
+function urlBase64ToUint8Array(base64: string) {
+  const padding = "=".repeat((4 - (base64.length % 4)) % 4);
+  const raw = atob((base64 + padding).replace(/-/g, "+").replace(/_/g, "/"));
+  return Uint8Array.from(raw, (c) => c.charCodeAt(0));
+}
+
+// Returns false when the server has Web Push disabled or the user denied permission
+export async function webPushSubscribe(): Promise<boolean> {
+  if (!webPushIsSupported()) return false;
+  const publicKey = (await userWebPushPublicKeyGet()).data.public_key;
+  if (!publicKey) return false;
+
+  const permission = await Notification.requestPermission();
+  if (permission !== "granted") return false;
+
+  const registration = await webPushRegistration();
+  const subscription = await registration.pushManager.subscribe({
+    userVisibleOnly: true,
+    applicationServerKey: urlBase64ToUint8Array(publicKey),
+  });
+  const json = subscription.toJSON();
+  await userWebPushSubscriptionPut({
+    endpoint: subscription.endpoint,
+    p256dh: json.keys?.p256dh || "",
+    auth: json.keys?.auth || "",
+  });
+  return true;
+}
+
+export async function webPushUnsubscribe() {
+  const subscription = await webPushGetSubscription();
+  if (!subscription) return;
+  await userWebPushSubscriptionDelete(subscription.endpoint);
+  await subscription.unsubscribe();
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Emails for the same user and channel within this window are sent as one push notification
+const burstWindow = 30 * time.Second
+
+type burstKey struct {
+	UserUID string
+	ChainID uint
+	Channel string
+}
+
+type burst struct {
+	// The latest email of the burst
+	email chatEmail
+	count int
+}
+
+// Collapses bursts of emails into one push notification per channel, with the latest message as preview
+type burstCollapser struct {
+	window time.Duration
+	send   func(key burstKey, email chatEmail, count int)
+
+	mu      sync.Mutex
+	pending map[burstKey]*burst
+}
+
+func newBurstCollapser(window time.Duration, send func(key burstKey, email chatEmail, count int)) *burstCollapser {
+	return &burstCollapser{
+		window:  window,
+		send:    send,
+		pending: map[burstKey]*burst{},
+	}
+}
+
+// The first email of a burst starts the window, the notification is sent when it ends
+func (c *burstCollapser) Add(key burstKey, email chatEmail) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if b, ok := c.pending[key]; ok {
+		b.count++
+		b.email = email
+		return
+	}
+	c.pending[key] = &burst{email: email, count: 1}
+	time.AfterFunc(c.window, func() {
+		c.mu.Lock()
+		b := c.pending[key]
+		delete(c.pending, key)
+		c.mu.Unlock()
+
+		c.send(key, b.email, b.count)
+	})
+}
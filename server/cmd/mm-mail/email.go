@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/the-clothing-loop/website/server/pkg/htmltotext"
+)
+
+// The parts of a Mattermost notification email used in the push notification, empty when not found
+type chatEmail struct {
+	// Mattermost username of the sender, without the @
+	SenderUserName string
+	// The display name of the channel
+	Channel string
+	// The loop the channel belongs to, parsed from the channel name in a link
+	ChainID uint
+	Preview string
+}
+
+var (
+	// Direct messages: "New Direct Message from @username on ..."
+	regexpSubjectSender = regexp.MustCompile(`from @([\w.-]+)`)
+	// Group and channel messages: "New Group Message in Channel on ..."
+	regexpSubjectChannel = regexp.MustCompile(`(?:Message|Notification) in (.+?) on `)
+	regexpBodySender     = regexp.MustCompile(`@([\w.-]+)`)
+	// Channel names are "<chain id>r<random>", see app.ChatProvider.ChannelCreate.
+	// Channels created before were named "<chain id>r<number><random>".
+	regexpBodyChannelLink = regexp.MustCompile(`/channels/(\d+)r[a-z0-9]+\b`)
+	// Lines of the email that are not the message itself
+	regexpBodyBoilerplate = regexp.MustCompile(`(?i)https?://|mentioned you|sent you|new (direct |group )?message|notification|view message|unsubscribe|preferences|receiving this|clothing loop chat|^\[|\]$|^\d{1,2}:\d{2}`)
+	regexpWhitespace      = regexp.MustCompile(`\s+`)
+)
+
+func parseChatEmail(msg *mail.Message) (chatEmail, error) {
+	e := chatEmail{}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	if m := regexpSubjectSender.FindStringSubmatch(subject); m != nil {
+		e.SenderUserName = m[1]
+	}
+	if m := regexpSubjectChannel.FindStringSubmatch(subject); m != nil {
+		e.Channel = m[1]
+	}
+
+	body, err := readTextBody(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return e, err
+	}
+
+	if m := regexpBodyChannelLink.FindStringSubmatch(body); m != nil {
+		id, _ := strconv.ParseUint(m[1], 10, 64)
+		e.ChainID = uint(id)
+	}
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(regexpWhitespace.ReplaceAllString(line, " "))
+		if line == "" || regexpBodyBoilerplate.MatchString(line) || line == e.Channel {
+			continue
+		}
+		// the line with the sender comes right before the message
+		if m := regexpBodySender.FindStringSubmatch(line); m != nil && strings.HasPrefix(line, "@") {
+			if e.SenderUserName == "" {
+				e.SenderUserName = m[1]
+			}
+			continue
+		}
+		if e.Preview == "" {
+			e.Preview = line
+		}
+	}
+
+	return e, nil
+}
+
+// Returns the plain text of the email, html is converted when there is no plain text part
+func readTextBody(contentType, transferEncoding string, r io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		html := ""
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			// the multipart reader already decodes quoted-printable parts
+			text, err := readTextBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", err
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if partType == "text/plain" && text != "" {
+				return text, nil
+			}
+			if html == "" {
+				html = text
+			}
+		}
+		return html, nil
+	}
+
+	switch strings.ToLower(transferEncoding) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if mediaType == "text/html" {
+		return htmltotext.Convert(string(b)), nil
+	}
+	if mediaType != "text/plain" {
+		return "", nil
+	}
+	return string(b), nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readTestEmail(t *testing.T, raw string) *mail.Message {
+	msg, err := mail.ReadMessage(strings.NewReader(strings.ReplaceAll(raw, "\n", "\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestParseChatEmailMultipart(t *testing.T) {
+	msg := readTestEmail(t, `Subject: [Clothing Loop Chat] New Group Message in Swap tips on March 3, 2024
+Content-Type: multipart/alternative; boundary="b1"
+
+--b1
+Content-Type: text/plain; charset=UTF-8
+Content-Transfer-Encoding: quoted-printable
+
+Anna sent you a new message
+
+Swap tips
+@ufh2k3j0a1  10:33 AM
+Who has a pair of winter boots in size 38? I can bring them to the next me=
+etup.
+
+View Message: https://chat.example.com/loops/channels/12rabcdefgh
+You're receiving this notification because of your preferences.
+--b1
+Content-Type: text/html; charset=UTF-8
+
+<p>Anna sent you a new message</p>
+--b1--
+`)
+	e, err := parseChatEmail(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "ufh2k3j0a1", e.SenderUserName)
+	assert.Equal(t, "Swap tips", e.Channel)
+	assert.Equal(t, uint(12), e.ChainID)
+	assert.Equal(t, "Who has a pair of winter boots in size 38? I can bring them to the next meetup.", e.Preview)
+}
+
+func TestParseChatEmailLegacyChannelName(t *testing.T) {
+	msg := readTestEmail(t, `Subject: [Clothing Loop Chat] New Group Message in Swap tips on March 3, 2024
+Content-Type: text/plain; charset=UTF-8
+
+Anna sent you a new message
+
+Swap tips
+@ufh2k3j0a1  10:33 AM
+Anyone going to the meetup?
+
+View Message: https://chat.example.com/loops/channels/12r3abcde
+`)
+	e, err := parseChatEmail(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(12), e.ChainID)
+	assert.Equal(t, "Anyone going to the meetup?", e.Preview)
+}
+
+func TestParseChatEmailHTML(t *testing.T) {
+	msg := readTestEmail(t, `Subject: [Clothing Loop Chat] New Direct Message from @uq8x.7 on March 3, 2024
+Content-Type: text/html; charset=UTF-8
+
+<html><body>
+<p>Bob sent you a new message</p>
+<p>Thanks for the bag!</p>
+<a href="https://chat.example.com/loops/pl/abc">View Message</a>
+</body></html>
+`)
+	e, err := parseChatEmail(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "uq8x.7", e.SenderUserName)
+	assert.Empty(t, e.Channel)
+	assert.Zero(t, e.ChainID)
+	assert.Equal(t, "Thanks for the bag!", e.Preview)
+}
+
+func TestBurstCollapser(t *testing.T) {
+	var mu sync.Mutex
+	sent := map[burstKey]int{}
+	previews := map[burstKey]string{}
+	c := newBurstCollapser(50*time.Millisecond, func(key burstKey, email chatEmail, count int) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent[key] += count
+		previews[key] = email.Preview
+	})
+
+	general := burstKey{UserUID: "a", ChainID: 1, Channel: "General"}
+	other := burstKey{UserUID: "a", ChainID: 2, Channel: "General"}
+	c.Add(general, chatEmail{Preview: "one"})
+	c.Add(general, chatEmail{Preview: "two"})
+	c.Add(other, chatEmail{Preview: "three"})
+	c.Add(general, chatEmail{Preview: "four"})
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 3, sent[general])
+	assert.Equal(t, "four", previews[general], "the latest message is the preview")
+	assert.Equal(t, 1, sent[other])
+}
@@ -7,10 +7,11 @@ import (
 	"net"
 	"net/mail"
 	"os"
-	"strings"
+	"regexp"
 
-	"github.com/OneSignal/onesignal-go-api"
+	"github.com/gin-gonic/gin"
 	"github.com/mhale/smtpd"
+	"github.com/samber/lo"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/models"
 	"github.com/the-clothing-loop/website/server/internal/views"
@@ -19,6 +20,11 @@ import (
 
 var db *gorm.DB
 
+var bursts = newBurstCollapser(burstWindow, sendChatNotification)
+
+// Subjects of Mattermost emails about new messages, other emails like password resets are ignored
+var regexpChatSubject = regexp.MustCompile(`New Notification|New (Direct |Group )?Message|Notification in`)
+
 func main() {
 	os.Setenv("SERVER_NO_MIGRATE", "true")
 	app.ConfigInit(".")
@@ -29,7 +35,7 @@ func main() {
 		slog.SetLogLoggerLevel(slog.LevelWarn)
 	}
 	fmt.Printf("env: %s\n", app.Config.ENV)
-	app.OneSignalInit()
+	app.PushInit()
 	db = app.DatabaseInit()
 
 	addr := fmt.Sprintf("%s:%s", app.Config.MM_SMTP_HOST, app.Config.MM_SMTP_PORT)
@@ -37,28 +43,83 @@ func main() {
 }
 
 func mailHandler(origin net.Addr, from string, to []string, data []byte) error {
-	msg, _ := mail.ReadMessage(bytes.NewReader(data))
-	subject := msg.Header.Get("Subject")
 	if len(to) == 0 {
 		return nil
 	}
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		slog.Error("Unable to read email", "err", err)
+		return nil
+	}
+	subject := msg.Header.Get("Subject")
 	slog.Debug("Email received", "to", to, "subject", subject)
-	if strings.Contains(subject, "New Notification") {
-		username, err := models.UserChatEmailToChatUserName(to[0])
-		if err != nil {
-			slog.Error("Unable to find user account by email", "err", err)
-			return nil
+	if !regexpChatSubject.MatchString(subject) {
+		return nil
+	}
+
+	username, err := models.UserChatEmailToChatUserName(to[0])
+	if err != nil {
+		slog.Error("Unable to find user account by email", "err", err)
+		return nil
+	}
+	user, err := models.UserGetByChatUserName(db, *username)
+	if err != nil {
+		slog.Error("Unable to find user account by email", "err", err)
+		return nil
+	}
+
+	email, err := parseChatEmail(msg)
+	if err != nil {
+		slog.Warn("Unable to read email body, only the subject is used", "err", err)
+	}
+	email.ChainID = chatEmailChainID(user, email.ChainID)
+
+	bursts.Add(burstKey{
+		UserUID: user.UID,
+		ChainID: email.ChainID,
+		Channel: email.Channel,
+	}, email)
+	return nil
+}
+
+// Returns the loop of the channel if the user is a member, otherwise the only loop of the user with chat
+func chatEmailChainID(user *models.User, chainID uint) uint {
+	chainIDs, err := models.ChatChainIDsOfUser(db, user.ID)
+	if err != nil {
+		slog.Error("Unable to find loops of user", "err", err)
+		return 0
+	}
+	if chainID != 0 && lo.Contains(chainIDs, chainID) {
+		return chainID
+	}
+	if len(chainIDs) == 1 {
+		return chainIDs[0]
+	}
+	return 0
+}
+
+func sendChatNotification(key burstKey, email chatEmail, count int) {
+	data := gin.H{
+		"Channel": email.Channel,
+		"Preview": email.Preview,
+	}
+	if key.ChainID != 0 {
+		if names, err := models.ChainGetNamesByIDs(db, key.ChainID); err == nil && len(names) == 1 {
+			data["Channel"] = names[0]
 		}
-		user, err := models.UserGetByChatUserName(db, *username)
-		if err != nil {
-			slog.Error("Unable to find user account by email", "err", err)
-			return nil
+	}
+	if email.SenderUserName != "" {
+		data["Sender"] = email.SenderUserName
+		if sender, err := models.UserGetByChatUserName(db, email.SenderUserName); err == nil {
+			data["Sender"] = sender.Name
 		}
+	}
+	if count > 1 {
+		data["Count"] = count
+	}
 
-		err = app.OneSignalCreateNotification(db, []string{user.UID}, *views.Notifications[views.NotificationEnumTitleChatMessage], onesignal.StringMap{})
-		if err != nil {
-			slog.Error("Unable to send notification", "err", err)
-		}
+	err := views.NotificationSend(db, models.NotificationKindChatMessage, key.ChainID, []string{key.UserUID}, data)
+	if err != nil {
+		slog.Error("Unable to send notification", "err", err)
 	}
-	return nil
 }
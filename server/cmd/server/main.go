@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	server "github.com/the-clothing-loop/website/server/internal"
 	"github.com/the-clothing-loop/website/server/internal/app"
@@ -33,12 +37,34 @@ func main() {
 	fmt.Printf("Calling database at: %s:%d\n", app.Config.DB_HOST, app.Config.DB_PORT)
 	router := server.Routes()
 
-	err := http.ListenAndServe(fmt.Sprintf("%s:%d", app.Config.HOST, app.Config.PORT), router)
+	srv := &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", app.Config.HOST, app.Config.PORT),
+		Handler: router,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		err := srv.ListenAndServe()
+		if err != http.ErrServerClosed {
+			slog.Error("error listen and serve", "err", err)
+			os.Exit(1)
+		}
+	}()
+	<-ctx.Done()
+	fmt.Println("Shutting down")
+
+	// finish open requests and emails that are being sent
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctxShutdown); err != nil {
+		slog.Error("Unable to shut down server", "err", err)
+	}
 	if server.Scheduler != nil {
 		server.Scheduler.Stop()
 	}
-	if err != http.ErrServerClosed {
-		slog.Error("error listen and serve", "err", err)
-		os.Exit(1)
+	if err := app.MailQueueStop(ctxShutdown); err != nil {
+		slog.Error("Unable to finish sending emails", "err", err)
 	}
 }
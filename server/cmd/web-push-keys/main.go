@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/the-clothing-loop/website/server/pkg/webpush"
+)
+
+// Prints a new VAPID key pair for the web_push_vapid_* config values
+//
+// `go run ./cmd/web-push-keys`
+func main() {
+	publicKey, privateKey, err := webpush.GenerateVAPIDKeys()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("web_push_vapid_public_key: %q\n", publicKey)
+	fmt.Printf("web_push_vapid_private_key: %q\n", privateKey)
+}
@@ -10,28 +10,13 @@ import (
 	"gorm.io/gorm"
 )
 
-const (
-	AuthState0Guest          = 0
-	AuthState1AnyUser        = 1
-	AuthState2UserOfChain    = 2
-	AuthState3AdminChainUser = 3
-	AuthState4RootUser       = 4
-)
-
-// if the minimumAuthState is higher than 1AnyUser, AddUserChainsToObject is called
+// Authorize authenticates the request and checks if the user is allowed to
+// perform the action on the given chain, see the permission table in permissions.go
 //
-// There are 4 different states to authenticate
-// 0. Guest - this middleware is then not required
-// 1. User of a different/unknown chain
-// 2. User connected to the chain in question
-// 3. Admin User of the chain in question
-// 4. Root User
-func Authenticate(c *gin.Context, db *gorm.DB, minimumAuthState int, chainUID string) (ok bool, authUser *models.User, chain *models.Chain) {
-	// 0. Guest - this middleware is then not required
-	if minimumAuthState == AuthState0Guest {
-		return true, nil, nil
-	}
-
+// For actions that are connected to a chain, AddUserChainsToObject is called
+// and the chain is returned.
+// Root admins are allowed to perform any action.
+func Authorize(c *gin.Context, db *gorm.DB, action Action, chainUID string) (ok bool, authUser *models.User, chain *models.Chain) {
 	token, ok := TokenReadFromRequest(c)
 	if !ok {
 		c.String(http.StatusUnauthorized, "Token not received")
@@ -55,14 +40,13 @@ func Authenticate(c *gin.Context, db *gorm.DB, minimumAuthState int, chainUID st
 		CookieSet(c, authUser.UID, token)
 	}
 
-	// 1. User of a different/unknown chain
-	if minimumAuthState == AuthState1AnyUser && chainUID == "" {
+	if action == ActionAnyUser && chainUID == "" {
 		return true, authUser, nil
 	}
 
-	if chainUID == "" && minimumAuthState != AuthState4RootUser {
-		slog.Error("ChainUID must not be empty _and_ require a minimum auth state of AuthState2UserOfChain (2), this is should never happen")
-		c.String(http.StatusNotImplemented, "ChainUID must not be empty _and_ require a minimum auth state of AuthState2UserOfChain (2), this is should never happen")
+	if chainUID == "" && action != ActionRootAdmin {
+		slog.Error("ChainUID must not be empty for a loop action, this is should never happen", "action", action)
+		c.String(http.StatusNotImplemented, "ChainUID must not be empty for a loop action, this is should never happen")
 		return false, nil, nil
 	}
 
@@ -82,32 +66,7 @@ func Authenticate(c *gin.Context, db *gorm.DB, minimumAuthState int, chainUID st
 		return false, nil, nil
 	}
 
-	// 4. Root User
-	isRootUser := authUser.IsRootAdmin
-
-	// 1. User of a different/unknown chain
-	isUserOfUnknownChain := minimumAuthState == AuthState1AnyUser
-
-	isUserParticipantOfChain := false
-	isUserAdminOfChain := false
-	if chainUID != "" {
-		if minimumAuthState == AuthState2UserOfChain || minimumAuthState == AuthState3AdminChainUser {
-			for _, userChain := range authUser.Chains {
-				if userChain.ChainID == chain.ID {
-					if minimumAuthState == AuthState2UserOfChain {
-						// 2. User connected to the chain in question
-						isUserParticipantOfChain = true
-					} else if minimumAuthState == AuthState3AdminChainUser && userChain.IsChainAdmin {
-						// 3. Admin User of the chain in question
-						isUserAdminOfChain = true
-					}
-					break
-				}
-			}
-		}
-	}
-
-	if !(isRootUser || isUserOfUnknownChain || isUserParticipantOfChain || isUserAdminOfChain) {
+	if !HasPermission(db, authUser, chain, action) {
 		c.String(http.StatusUnauthorized, "User role not high enough")
 		return false, nil, nil
 	}
@@ -115,11 +74,11 @@ func Authenticate(c *gin.Context, db *gorm.DB, minimumAuthState int, chainUID st
 	return true, authUser, chain
 }
 
-// This runs Authenticate and defines minimumAuthState depending on the input
+// This runs Authorize and checks the action against the chain of the given user
 // Any of the following rules pass authentication
 //
 // 1. authUser UID is the same as the given userUID
-// 2. authUser is a chain admin of chain and user is part that same chain
+// 2. authUser is allowed to manage members of chain and user is part that same chain
 // 3. authUser is a root admin
 func AuthenticateUserOfChain(c *gin.Context, db *gorm.DB, chainUID, userUID string) (ok bool, user, authUser *models.User, chain *models.Chain) {
 	if chainUID != "" && userUID == "" {
@@ -127,7 +86,7 @@ func AuthenticateUserOfChain(c *gin.Context, db *gorm.DB, chainUID, userUID stri
 		return false, nil, nil, nil
 	}
 
-	ok, authUser, chain = Authenticate(c, db, AuthState1AnyUser, chainUID)
+	ok, authUser, chain = Authorize(c, db, ActionAnyUser, chainUID)
 	if !ok {
 		return ok, nil, nil, nil
 	}
@@ -154,11 +113,9 @@ func AuthenticateUserOfChain(c *gin.Context, db *gorm.DB, chainUID, userUID stri
 		return true, user, authUser, chain
 	}
 
-	_, isAuthUserChainAdmin := authUser.IsPartOfChain(chainUID)
 	isUserPartOfChain, _ := user.IsPartOfChain(chainUID)
-
-	//	2. authUser is a chain admin of chain and user is part of chain
-	if isAuthUserChainAdmin && isUserPartOfChain {
+	//	2. authUser is allowed to manage members of chain and user is part of chain
+	if chainUID != "" && isUserPartOfChain && HasPermission(db, authUser, chain, ActionMembersManage) {
 		return true, user, authUser, chain
 	}
 
@@ -167,7 +124,7 @@ func AuthenticateUserOfChain(c *gin.Context, db *gorm.DB, chainUID, userUID stri
 }
 
 func AuthenticateEvent(c *gin.Context, db *gorm.DB, eventUID string) (ok bool, authUser *models.User, event *models.Event) {
-	ok, authUser, _ = Authenticate(c, db, AuthState1AnyUser, "")
+	ok, authUser, _ = Authorize(c, db, ActionAnyUser, "")
 	if !ok {
 		return false, nil, nil
 	}
@@ -189,8 +146,9 @@ func AuthenticateEvent(c *gin.Context, db *gorm.DB, eventUID string) (ok bool, a
 			return false, nil, nil
 		}
 
-		_, isChainAdmin := authUser.IsPartOfChain(*event.ChainUID)
-		if isChainAdmin {
+		chain := &models.Chain{}
+		err = db.Raw(`SELECT * FROM chains WHERE chains.uid = ? AND chains.deleted_at IS NULL LIMIT 1`, *event.ChainUID).Scan(chain).Error
+		if err == nil && HasPermission(db, authUser, chain, ActionEventManage) {
 			return true, authUser, event
 		}
 	}
@@ -12,11 +12,21 @@ import (
 	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
 )
 
-func TestAuthenticate(t *testing.T) {
+func TestAuthorize(t *testing.T) {
 	type Sut struct {
 		MockOptions mocks.MockChainAndUserOptions
-		// for each minimumAuthState 0 to 4
-		ExpectedResults [5]bool
+		// for each action in actions
+		ExpectedResults []bool
+	}
+
+	actions := []auth.Action{
+		auth.ActionAnyUser,
+		auth.ActionChainRead,
+		auth.ActionBagPassOn,
+		auth.ActionBagEdit,
+		auth.ActionChainUpdate,
+		auth.ActionChainDelete,
+		auth.ActionRootAdmin,
 	}
 
 	suts := []Sut{
@@ -25,58 +35,101 @@ func TestAuthenticate(t *testing.T) {
 				IsChainAdmin: false,
 				IsRootAdmin:  false,
 			},
-			ExpectedResults: [5]bool{true, true, true, false, false},
+			ExpectedResults: []bool{true, true, true, false, false, false, false},
+		},
+		{
+			MockOptions: mocks.MockChainAndUserOptions{
+				IsChainWarden: true,
+			},
+			ExpectedResults: []bool{true, true, true, true, false, false, false},
+		},
+		{
+			MockOptions: mocks.MockChainAndUserOptions{
+				IsChainAdmin: true,
+				ChainRole:    string(auth.RoleCoHost),
+			},
+			ExpectedResults: []bool{true, true, true, true, true, false, false},
 		},
 		{
 			MockOptions: mocks.MockChainAndUserOptions{
 				IsChainAdmin: true,
 				IsRootAdmin:  false,
 			},
-			ExpectedResults: [5]bool{true, true, true, true, false},
+			ExpectedResults: []bool{true, true, true, true, true, true, false},
 		},
 		{
 			MockOptions: mocks.MockChainAndUserOptions{
 				IsChainAdmin: false,
 				IsRootAdmin:  true,
 			},
-			ExpectedResults: [5]bool{true, true, true, true, true},
+			ExpectedResults: []bool{true, true, true, true, true, true, true},
 		},
 	}
 
 	for _, sut := range suts {
 		chain, user, token := mocks.MockChainAndUser(t, db, sut.MockOptions)
 
-		for i := 0; i < 5; i++ {
+		for i, action := range actions {
 			c, _ := mocks.MockGinContext(db, http.MethodGet, "/", nil, token)
 
-			// to stop the i am a teapot error
-			// AuthState1AnyUser should never need specific chain data
+			// ActionAnyUser and ActionRootAdmin never need specific chain data
 			chainUID := ""
-			if i > auth.AuthState1AnyUser {
+			isChainAction := action != auth.ActionAnyUser && action != auth.ActionRootAdmin
+			if isChainAction {
 				chainUID = chain.UID
 			}
-			ok, resultUser, resultChain := auth.Authenticate(c, db, i, chainUID)
+			ok, resultUser, resultChain := auth.Authorize(c, db, action, chainUID)
 
 			expectedOk := sut.ExpectedResults[i]
-			assert.Equalf(t, expectedOk, ok, "minimumAuthState: %d\nchain.ID: %d user.ID: %d\noptions: %+v", i, chain.ID, user.ID, sut.MockOptions)
+			assert.Equalf(t, expectedOk, ok, "action: %s\nchain.ID: %d user.ID: %d\noptions: %+v", action, chain.ID, user.ID, sut.MockOptions)
 
-			if expectedOk && i >= auth.AuthState1AnyUser {
+			if expectedOk {
 				assert.NotNil(t, resultUser)
 				assert.Equal(t, user.ID, resultUser.ID)
 			} else {
 				assert.Nil(t, resultUser)
 			}
 
-			if expectedOk && i >= auth.AuthState2UserOfChain {
+			if expectedOk && isChainAction {
 				assert.NotNil(t, resultChain)
 				assert.Equal(t, chain.ID, resultChain.ID)
-			} else {
+			} else if !expectedOk {
 				assert.Nil(t, resultChain)
 			}
 		}
 	}
 }
 
+func TestAuthorizeCustomRole(t *testing.T) {
+	chain, _, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		ChainRole: "bag-keeper",
+	})
+	role := &models.ChainRole{
+		ChainID: chain.ID,
+		Name:    "bag-keeper",
+		Actions: []string{string(auth.ActionBagEdit)},
+	}
+	err := role.Save(db)
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Exec(`DELETE FROM chain_roles WHERE id = ?`, role.ID) })
+
+	c, _ := mocks.MockGinContext(db, http.MethodGet, "/", nil, token)
+	ok, _, _ := auth.Authorize(c, db, auth.ActionBagEdit, chain.UID)
+	assert.True(t, ok)
+
+	c, _ = mocks.MockGinContext(db, http.MethodGet, "/", nil, token)
+	ok, _, _ = auth.Authorize(c, db, auth.ActionChainUpdate, chain.UID)
+	assert.False(t, ok)
+
+	c, _ = mocks.MockGinContext(db, http.MethodGet, "/", nil, token)
+	ok, _, _ = auth.Authorize(c, db, auth.ActionChainRead, chain.UID)
+	assert.True(t, ok, "custom roles extend the participant role")
+
+	c, _ = mocks.MockGinContext(db, http.MethodGet, "/", nil, token)
+	ok, _, _ = auth.Authorize(c, db, auth.ActionBagPassOn, chain.UID)
+	assert.True(t, ok, "custom roles extend the participant role")
+}
+
 func TestAuthenticateUserOfChain(t *testing.T) {
 	type Sut struct {
 		MockAuthOptions mocks.MockChainAndUserOptions
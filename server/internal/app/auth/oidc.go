@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+)
+
+const OidcAccessTokenDuration = time.Hour
+
+type OidcIDTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name,omitempty"`
+}
+
+type OidcAccessTokenClaims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope"`
+}
+
+// Both the standard OpenID Connect claims and the fields of the GitLab user api are returned,
+// as the GitLab login of Mattermost is used for single sign-on.
+type OidcUserInfo struct {
+	Sub               string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Locale            string `json:"locale,omitempty"`
+
+	// GitLab
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+func OidcUserInfoGet(user *models.User, client *app.OidcClient) OidcUserInfo {
+	username := user.UID
+	if user.ChatUserName != nil {
+		username = *user.ChatUserName
+	}
+	email := ""
+	if client.HideEmail {
+		email = username + "@example.com"
+	} else if user.Email != nil {
+		email = *user.Email
+	}
+	return OidcUserInfo{
+		Sub:               user.UID,
+		Email:             email,
+		EmailVerified:     user.IsEmailVerified,
+		Name:              user.Name,
+		PreferredUsername: username,
+		Locale:            user.I18n,
+		ID:                user.ID,
+		Username:          username,
+	}
+}
+
+func OidcIDTokenGenerate(user *models.User, client *app.OidcClient, nonce string) (string, error) {
+	info := OidcUserInfoGet(user, client)
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, OidcIDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    app.OidcIssuer(),
+			Subject:   user.UID,
+			Audience:  jwt.ClaimStrings{client.ClientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(OidcAccessTokenDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Nonce:         nonce,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		Name:          info.Name,
+	})
+	token.Header["kid"] = app.OidcKeyID
+	return token.SignedString(app.OidcPrivateKey)
+}
+
+func OidcAccessTokenGenerate(user *models.User, clientID, scope string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, OidcAccessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    app.OidcIssuer(),
+			Subject:   user.UID,
+			Audience:  jwt.ClaimStrings{clientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(OidcAccessTokenDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Scope: scope,
+	})
+	token.Header["kid"] = app.OidcKeyID
+	return token.SignedString(app.OidcPrivateKey)
+}
+
+func OidcAccessTokenVerify(tokenString string) (*OidcAccessTokenClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &OidcAccessTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return &app.OidcPrivateKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithIssuer(app.OidcIssuer()))
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*OidcAccessTokenClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid claims")
+	}
+	return claims, nil
+}
+
+// Validates the PKCE code verifier, plain is only allowed when no method is given
+func OidcCodeChallengeVerify(challenge, method, verifier string) bool {
+	if challenge == "" {
+		return true
+	}
+	switch method {
+	case "S256":
+		h := sha256.Sum256([]byte(verifier))
+		return base64.RawURLEncoding.EncodeToString(h[:]) == challenge
+	case "", "plain":
+		return verifier == challenge
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package auth_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+)
+
+func oidcTestInit(t *testing.T) {
+	app.Config.SITE_BASE_URL_API = "https://api.example.com/"
+	err := app.OidcInit()
+	assert.NoError(t, err)
+}
+
+func TestOidcAccessToken(t *testing.T) {
+	oidcTestInit(t)
+	user := &models.User{ID: 1, UID: faker.UUID().V4(), Name: "Test"}
+
+	token, err := auth.OidcAccessTokenGenerate(user, "mattermost", "openid email")
+	assert.NoError(t, err)
+
+	claims, err := auth.OidcAccessTokenVerify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, user.UID, claims.Subject)
+	assert.Equal(t, "https://api.example.com", claims.Issuer)
+	assert.Equal(t, "openid email", claims.Scope)
+	assert.Equal(t, []string{"mattermost"}, []string(claims.Audience))
+
+	// tamper with the payload
+	parts := strings.Split(token, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"someone-else"}`))
+	_, err = auth.OidcAccessTokenVerify(strings.Join(parts, "."))
+	assert.Error(t, err)
+}
+
+func TestOidcJwks(t *testing.T) {
+	oidcTestInit(t)
+
+	keys := app.OidcJwks()
+	assert.Len(t, keys, 1)
+	assert.Equal(t, app.OidcKeyID, keys[0].Kid)
+	assert.Equal(t, "RS256", keys[0].Alg)
+}
+
+func TestOidcUserInfoHideEmail(t *testing.T) {
+	email := faker.Internet().Email()
+	chatUserName := "u1234"
+	user := &models.User{ID: 1, UID: faker.UUID().V4(), Email: &email, ChatUserName: &chatUserName}
+
+	info := auth.OidcUserInfoGet(user, &app.OidcClient{HideEmail: true})
+	assert.Equal(t, "u1234@example.com", info.Email)
+	assert.Equal(t, "u1234", info.Username)
+
+	info = auth.OidcUserInfoGet(user, &app.OidcClient{})
+	assert.Equal(t, email, info.Email)
+}
+
+func TestOidcCodeChallengeVerify(t *testing.T) {
+	verifier := faker.RandomStringWithLength(43)
+	h := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(h[:])
+
+	tests := []struct {
+		name      string
+		challenge string
+		method    string
+		verifier  string
+		expected  bool
+	}{
+		{"no challenge", "", "", "", true},
+		{"S256", challenge, "S256", verifier, true},
+		{"S256 wrong verifier", challenge, "S256", verifier + "x", false},
+		{"plain", verifier, "plain", verifier, true},
+		{"plain wrong verifier", verifier, "plain", "x", false},
+		{"unknown method", verifier, "S512", verifier, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, auth.OidcCodeChallengeVerify(test.challenge, test.method, test.verifier))
+		})
+	}
+}
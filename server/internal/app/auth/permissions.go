@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+type Action string
+
+const (
+	// Not connected to a loop
+	ActionAnyUser   Action = "any_user"
+	ActionRootAdmin Action = "root_admin"
+
+	ActionChainRead   Action = "chain.read"
+	ActionChainUpdate Action = "chain.update"
+	ActionChainDelete Action = "chain.delete"
+
+	// Contact details of every member, regardless of the route privacy
+	ActionMembersReadPrivate Action = "members.read_private"
+	// Approve, deny, remove, transfer and edit other members
+	ActionMembersManage    Action = "members.manage"
+	ActionMembersSetHost   Action = "members.set_host"
+	ActionMembersSetWarden Action = "members.set_warden"
+
+	ActionBagPassOn  Action = "bag.pass_on"
+	ActionBagEdit    Action = "bag.edit"
+	ActionBagDelete  Action = "bag.delete"
+	ActionBagHistory Action = "bag.history"
+
+	ActionBulkyWrite Action = "bulky.write"
+
+	ActionRouteWrite Action = "route.write"
+
+	ActionChatJoin   Action = "chat.join"
+	ActionChatManage Action = "chat.manage"
+
+	ActionEventManage Action = "event.manage"
+
+	ActionRolesManage Action = "roles.manage"
+)
+
+type Role string
+
+const (
+	RoleHost        Role = "host"
+	RoleCoHost      Role = "co-host"
+	RoleWarden      Role = "warden"
+	RoleParticipant Role = "participant"
+)
+
+var rolesBuiltIn = map[Role][]Action{
+	RoleHost: {
+		ActionChainRead,
+		ActionChainUpdate,
+		ActionChainDelete,
+		ActionMembersReadPrivate,
+		ActionMembersManage,
+		ActionMembersSetHost,
+		ActionMembersSetWarden,
+		ActionBagPassOn,
+		ActionBagEdit,
+		ActionBagDelete,
+		ActionBagHistory,
+		ActionBulkyWrite,
+		ActionRouteWrite,
+		ActionChatJoin,
+		ActionChatManage,
+		ActionEventManage,
+		ActionRolesManage,
+	},
+	RoleCoHost: {
+		ActionChainRead,
+		ActionChainUpdate,
+		ActionMembersReadPrivate,
+		ActionMembersManage,
+		ActionMembersSetWarden,
+		ActionBagPassOn,
+		ActionBagEdit,
+		ActionBagDelete,
+		ActionBagHistory,
+		ActionBulkyWrite,
+		ActionRouteWrite,
+		ActionChatJoin,
+		ActionChatManage,
+		ActionEventManage,
+	},
+	RoleWarden: {
+		ActionChainRead,
+		ActionBagPassOn,
+		ActionBagEdit,
+		ActionBagHistory,
+		ActionBulkyWrite,
+		ActionChatJoin,
+	},
+	RoleParticipant: {
+		ActionChainRead,
+		ActionBagPassOn,
+		ActionBulkyWrite,
+		ActionChatJoin,
+	},
+}
+
+// Actions that can not be given to a custom role
+var actionsHostOnly = []Action{
+	ActionChainDelete,
+	ActionMembersSetHost,
+	ActionRolesManage,
+}
+
+var ErrRoleNameReserved = errors.New("Role name is reserved")
+var ErrRoleActionInvalid = errors.New("Invalid action for a custom role")
+
+func RoleIsBuiltIn(role Role) bool {
+	_, ok := rolesBuiltIn[role]
+	return ok
+}
+
+func RoleBuiltInActions(role Role) []Action {
+	return rolesBuiltIn[role]
+}
+
+func RoleBuiltInAll() map[Role][]Action {
+	return rolesBuiltIn
+}
+
+// Returns the role of a member, the role column is only set for co-hosts and custom roles
+func RoleOfUserChain(uc *sharedtypes.UserChain) Role {
+	if uc.Role != "" {
+		return Role(uc.Role)
+	}
+	if uc.IsChainAdmin {
+		return RoleHost
+	}
+	if uc.IsChainWarden {
+		return RoleWarden
+	}
+	return RoleParticipant
+}
+
+func ValidateCustomRole(name string, actions []string) error {
+	if RoleIsBuiltIn(Role(name)) {
+		return ErrRoleNameReserved
+	}
+	for _, a := range actions {
+		action := Action(a)
+		if !lo.Contains(rolesBuiltIn[RoleHost], action) || lo.Contains(actionsHostOnly, action) {
+			return ErrRoleActionInvalid
+		}
+	}
+	return nil
+}
+
+// Checks the permission table without authenticating the request.
+// This requires user to have run AddUserChainsToObject before this.
+func HasPermission(db *gorm.DB, user *models.User, chain *models.Chain, action Action) bool {
+	if user.IsRootAdmin || action == ActionAnyUser {
+		return true
+	}
+	if action == ActionRootAdmin || chain == nil {
+		return false
+	}
+
+	uc, ok := lo.Find(user.Chains, func(uc sharedtypes.UserChain) bool {
+		return uc.ChainID == chain.ID
+	})
+	if !ok {
+		return false
+	}
+
+	return RoleHasPermission(db, chain.ID, RoleOfUserChain(&uc), action)
+}
+
+// Checks if the built-in or custom role of the loop contains the action,
+// custom roles extend the participant role with the actions they list
+func RoleHasPermission(db *gorm.DB, chainID uint, role Role, action Action) bool {
+	if actions, ok := rolesBuiltIn[role]; ok {
+		return lo.Contains(actions, action)
+	}
+	if lo.Contains(rolesBuiltIn[RoleParticipant], action) {
+		return true
+	}
+
+	customRole, err := models.ChainRoleGetByName(db, chainID, string(role))
+	if err != nil {
+		// a role that has been removed in the meantime falls back to the participant role
+		if !errors.Is(err, models.ErrChainRoleNotFound) {
+			slog.Error("Unable to retrieve loop role", "err", err, "role", role)
+		}
+		return false
+	}
+	return lo.Contains(customRole.Actions, string(action))
+}
@@ -0,0 +1,154 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+// Every authenticated route and the action it is authorized with
+var routeActions = []struct {
+	Route  string
+	Action auth.Action
+	// Roles that are allowed, root admins are always allowed
+	Allowed []auth.Role
+}{
+	{"PATCH /v2/user (other user)", auth.ActionMembersManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"GET /v2/user (with chain_uid)", auth.ActionMembersReadPrivate, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"GET /v2/user/all-chain", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/user/transfer-chain", auth.ActionMembersManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"PATCH /v2/chain", auth.ActionChainUpdate, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"DELETE /v2/chain", auth.ActionChainDelete, []auth.Role{auth.RoleHost}},
+	{"POST /v2/chain/add-user (as host)", auth.ActionMembersSetHost, []auth.Role{auth.RoleHost}},
+	{"POST /v2/chain/remove-user (other user)", auth.ActionMembersManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"PATCH /v2/chain/approve-user", auth.ActionMembersManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"DELETE /v2/chain/unapproved-user", auth.ActionMembersManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"PATCH /v2/chain/user/note", auth.ActionMembersManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"GET /v2/chain/user/note", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PATCH /v2/chain/user/warden", auth.ActionMembersSetWarden, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"PATCH /v2/chain/user/role", auth.ActionRolesManage, []auth.Role{auth.RoleHost}},
+	{"GET /v2/chain/roles", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PUT /v2/chain/role", auth.ActionRolesManage, []auth.Role{auth.RoleHost}},
+	{"DELETE /v2/chain/role", auth.ActionRolesManage, []auth.Role{auth.RoleHost}},
+	{"PATCH /v2/chat/user", auth.ActionChatJoin, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/chat/channel/create", auth.ActionChatManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"POST /v2/chat/channel/join", auth.ActionChatJoin, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/chat/channel/delete", auth.ActionChatManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"GET /v2/chat/channel/messages", auth.ActionChatJoin, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/chat/channel/message", auth.ActionChatJoin, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/chat/message/report", auth.ActionChatJoin, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"GET /v2/chat/reports", auth.ActionChatManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"DELETE /v2/chat/report", auth.ActionChatManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"GET /v2/chat/mutes", auth.ActionChatManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"PUT /v2/chat/mute", auth.ActionChatManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"DELETE /v2/chat/mute", auth.ActionChatManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"PATCH /v2/chat/retention", auth.ActionChatManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"GET /v2/bag/all (other user)", auth.ActionMembersManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"PUT /v2/bag (holder only)", auth.ActionBagPassOn, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PUT /v2/bag (number, color or new)", auth.ActionBagEdit, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden}},
+	{"DELETE /v2/bag", auth.ActionBagDelete, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"GET /v2/bag/history", auth.ActionBagHistory, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden}},
+	{"GET /v2/bulky-item/all", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PUT /v2/bulky-item", auth.ActionBulkyWrite, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"DELETE /v2/bulky-item", auth.ActionBulkyWrite, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/bulky-item/reserve", auth.ActionBulkyWrite, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"DELETE /v2/bulky-item/reserve", auth.ActionBulkyWrite, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PATCH /v2/bulky-item/given", auth.ActionBulkyWrite, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"GET /v2/bulky-item/subscriptions", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PUT /v2/bulky-item/subscriptions", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"GET /v2/wish/all", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PUT /v2/wish", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"DELETE /v2/wish", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"GET /v2/route/order", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/route/order", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"GET /v2/route/optimize", auth.ActionRouteWrite, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"GET /v2/route/coordinates (all)", auth.ActionMembersReadPrivate, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"POST /v2/event (with chain_uid)", auth.ActionEventManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"PATCH /v2/event", auth.ActionEventManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"DELETE /v2/event/:uid", auth.ActionEventManage, []auth.Role{auth.RoleHost, auth.RoleCoHost}},
+	{"GET /v2/user/notification-preferences (with chain_uid)", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PATCH /v2/user/notification-preferences (with chain_uid)", auth.ActionChainRead, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/login/super/as", auth.ActionRootAdmin, []auth.Role{}},
+	{"GET /v2/mail/health", auth.ActionRootAdmin, []auth.Role{}},
+	{"GET /v2/chat/sync", auth.ActionRootAdmin, []auth.Role{}},
+	{"GET /v2/image/gc", auth.ActionRootAdmin, []auth.Role{}},
+	{"POST /v2/refresh-token", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/chain", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"GET /v2/user/notification-preferences", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PATCH /v2/user/notification-preferences", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/chain/poke", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/image", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"DELETE /v2/image", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"DELETE /v2/user/purge", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PUT /v2/user/web-push-subscription", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"DELETE /v2/user/web-push-subscription", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"GET /v2/user/notification/all", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PATCH /v2/user/notification/read", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"PATCH /v2/user/notification/read-all", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"GET /v2/chat/ws", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+	{"POST /v2/user/export", auth.ActionAnyUser, []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}},
+}
+
+func mockUserWithRole(chain *models.Chain, role auth.Role) *models.User {
+	uc := sharedtypes.UserChain{ChainID: chain.ID}
+	switch role {
+	case auth.RoleHost:
+		uc.IsChainAdmin = true
+	case auth.RoleCoHost:
+		uc.IsChainAdmin = true
+		uc.Role = string(auth.RoleCoHost)
+	case auth.RoleWarden:
+		uc.IsChainWarden = true
+	}
+	return &models.User{ID: 2, Chains: []sharedtypes.UserChain{uc}}
+}
+
+func TestHasPermissionEveryRoute(t *testing.T) {
+	chain := &models.Chain{ID: 1}
+	otherChain := &models.Chain{ID: 3}
+	roles := []auth.Role{auth.RoleHost, auth.RoleCoHost, auth.RoleWarden, auth.RoleParticipant}
+
+	for _, ra := range routeActions {
+		for _, role := range roles {
+			user := mockUserWithRole(chain, role)
+			expected := ra.Action == auth.ActionAnyUser
+			for _, r := range ra.Allowed {
+				if r == role {
+					expected = true
+				}
+			}
+
+			// built-in roles never query the database
+			result := auth.HasPermission(nil, user, chain, ra.Action)
+			assert.Equalf(t, expected, result, "route: %s role: %s", ra.Route, role)
+
+			if ra.Action != auth.ActionAnyUser {
+				result = auth.HasPermission(nil, user, otherChain, ra.Action)
+				assert.Falsef(t, result, "route: %s role: %s in a different loop", ra.Route, role)
+			}
+		}
+
+		rootAdmin := &models.User{ID: 4, IsRootAdmin: true}
+		assert.Truef(t, auth.HasPermission(nil, rootAdmin, chain, ra.Action), "route: %s root admin", ra.Route)
+	}
+}
+
+func TestRoleOfUserChain(t *testing.T) {
+	assert.Equal(t, auth.RoleHost, auth.RoleOfUserChain(&sharedtypes.UserChain{IsChainAdmin: true}))
+	assert.Equal(t, auth.RoleCoHost, auth.RoleOfUserChain(&sharedtypes.UserChain{IsChainAdmin: true, Role: "co-host"}))
+	assert.Equal(t, auth.RoleWarden, auth.RoleOfUserChain(&sharedtypes.UserChain{IsChainWarden: true}))
+	assert.Equal(t, auth.RoleParticipant, auth.RoleOfUserChain(&sharedtypes.UserChain{}))
+	assert.Equal(t, auth.Role("bag-keeper"), auth.RoleOfUserChain(&sharedtypes.UserChain{Role: "bag-keeper"}))
+}
+
+func TestValidateCustomRole(t *testing.T) {
+	assert.ErrorIs(t, auth.ValidateCustomRole("host", nil), auth.ErrRoleNameReserved)
+	assert.ErrorIs(t, auth.ValidateCustomRole("co-host", nil), auth.ErrRoleNameReserved)
+	assert.ErrorIs(t, auth.ValidateCustomRole("bag-keeper", []string{string(auth.ActionChainDelete)}), auth.ErrRoleActionInvalid)
+	assert.ErrorIs(t, auth.ValidateCustomRole("bag-keeper", []string{string(auth.ActionRootAdmin)}), auth.ErrRoleActionInvalid)
+	assert.ErrorIs(t, auth.ValidateCustomRole("bag-keeper", []string{"unknown"}), auth.ErrRoleActionInvalid)
+	assert.NoError(t, auth.ValidateCustomRole("bag-keeper", []string{string(auth.ActionBagEdit), string(auth.ActionBagHistory)}))
+}
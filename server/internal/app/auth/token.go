@@ -62,6 +62,27 @@ func OtpCreate(db *gorm.DB, userID uint) (string, error) {
 	return token, nil
 }
 
+// Replaces any previous pending email change of the user
+func OtpEmailChangeCreate(db *gorm.DB, userID uint, newEmail string) (string, error) {
+	tokenB, err := atoll.NewPassword(8, []atoll.Level{atoll.Digit})
+	if err != nil {
+		return "", err
+	}
+	token := string(tokenB)
+
+	db.Exec(`DELETE FROM user_email_changes WHERE user_id = ?`, userID)
+	res := db.Create(&models.UserEmailChange{
+		UserID:   userID,
+		NewEmail: newEmail,
+		Token:    token,
+	})
+	if res.Error != nil {
+		return "", res.Error
+	}
+
+	return token, nil
+}
+
 // Returns the user before it was verified
 func OtpVerify(db *gorm.DB, userEmail, otp string) (*models.User, string, error) {
 	// check if otp is valid
@@ -218,4 +239,5 @@ DELETE FROM user_tokens
 WHERE created_at < (NOW() - INTERVAL 2 DAY)
 	AND verified = FALSE
 	`)
+	models.UserEmailChangeDeleteOld(db)
 }
@@ -2,7 +2,10 @@ package app
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"strings"
 
 	lib "github.com/getbrevo/brevo-go/lib"
 	"github.com/gin-gonic/gin"
@@ -75,3 +78,30 @@ func (sib *brevo) WebhookUnsubscribed(c *gin.Context) (email string, err error)
 
 	return body.Email, nil
 }
+
+var ErrBrevoWebhookUnauthorized = errors.New("Invalid webhook token")
+
+// Transactional email event, see https://developers.brevo.com/docs/transactional-webhooks
+type BrevoWebhookEvent struct {
+	Event     string `json:"event" binding:"required"` // "delivered", "hard_bounce", "soft_bounce", "spam", ...
+	Email     string `json:"email" binding:"required"` // recipient email
+	ID        uint   `json:"id"`                       // internal id of webhook
+	MessageID string `json:"message-id"`               // message id returned when sending the email
+	Subject   string `json:"subject"`                  // subject of the email
+	Reason    string `json:"reason"`                   // reason of a bounce or block
+	TsEvent   int64  `json:"ts_event"`                 // timestamp in seconds of when event occurred
+}
+
+// Checks the bearer token configured on the webhook and reads the event
+func BrevoWebhookTransactional(c *gin.Context) (*BrevoWebhookEvent, error) {
+	token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if Config.BREVO_WEBHOOK_TOKEN == "" || subtle.ConstantTimeCompare([]byte(token), []byte(Config.BREVO_WEBHOOK_TOKEN)) != 1 {
+		return nil, ErrBrevoWebhookUnauthorized
+	}
+
+	body := &BrevoWebhookEvent{}
+	if err := c.ShouldBindJSON(body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
@@ -2,123 +2,87 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
-	"github.com/mattermost/mattermost/server/public/model"
-	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"gorm.io/gorm"
 )
 
-var ChatClient *model.Client4
-var ChatTeamId string
-
-func ChatInit(url, token string) {
-	client := model.NewAPIv4Client(url)
-	client.SetToken(token)
+const (
+	ChatProviderMattermost = "mattermost"
+	ChatProviderBuiltin    = "builtin"
+)
 
-	ChatClient = client
+// A chat server for the loops, the channel ids of a loop are stored in chains.chat_room_ids
+type ChatProvider interface {
+	Name() string
+	// The team the app connects to, empty when the provider has no teams
+	TeamID() string
+	// Returns the chat user of the user, a new one is created when user.ChatUserID is not set or no longer exists
+	UserPatch(ctx context.Context, user *models.User) (chatUserID, chatUserName string, err error)
+	// Returns the session token for the app, previousToken is returned again while it is still valid.
+	// Empty when the app uses its api token.
+	UserToken(ctx context.Context, chatUserID, previousToken string) (string, error)
+	// Revokes the session tokens of the app, called when the user logs out
+	UserTokenRevoke(ctx context.Context, chatUserID string) error
+	ChannelCreate(ctx context.Context, chainID uint, name, color string) (channelID string, err error)
+	ChannelDelete(ctx context.Context, channelID string) error
+	// Adds the user to the channel or updates the admin role when already a member
+	ChannelJoin(ctx context.Context, channelID, chatUserID string, isAdmin bool) error
+	ChannelLeave(ctx context.Context, channelID, chatUserID string) error
+	// Returns the members of the channel, without the account the server itself uses
+	ChannelMembers(ctx context.Context, channelID string) ([]ChatChannelMember, error)
+	// Prevents the chat user from logging in again, called after the user is deleted
+	UserDeactivate(ctx context.Context, chatUserID string) error
+	// Returns ErrChatNotFound when the message is not in the channel
+	MessageGet(ctx context.Context, channelID, messageID string) (*ChatMessageInfo, error)
+	// Stops or allows the member sending messages in the channel,
+	// returns true when the member was removed from the channel to mute them
+	MemberMute(ctx context.Context, channelID, chatUserID string, muted bool) (removed bool, err error)
+	// Removes the messages sent before the given time and returns how many were removed
+	ChannelDeleteMessagesBefore(ctx context.Context, channelID string, before time.Time) (int, error)
 }
 
-func ChatSetDefaultSettings(client *model.Client4) {
-	team := &model.Team{
-		Name:        "loops",
-		DisplayName: "Loops",
-		Type:        model.TeamInvite,
-	}
-	if Config.ENV == EnvEnumAcceptance {
-		team.Name += "acc"
-		team.DisplayName += " Acc"
-	} else if Config.ENV == EnvEnumDevelopment {
-		team.Name += "dev"
-		team.DisplayName += " Dev"
-	}
+// Returned when the channel or chat user no longer exists on the chat server
+var ErrChatNotFound = errors.New("Not found on the chat server")
 
-	ctx := context.TODO()
-	_, _, err := client.GetPing(ctx)
-	if err != nil {
-		slog.Error("unable to ping mattermost", "err", err)
-		panic(err)
-	} else {
-		slog.Info("ping mattermost received")
-	}
-
-	mmTeam, _, err := client.GetTeamByName(ctx, team.Name, "")
-	if err != nil {
-		fmt.Println(err.Error())
-		mmTeam, _, err = client.CreateTeam(ctx, team)
-		if err != nil {
-			panic(err)
-		}
-	}
+type ChatChannelMember struct {
+	ChatUserID string
+	IsAdmin    bool
+}
 
-	emailBatchingInterval := 5 * 60 // seconds
-	if Config.ENV == EnvEnumDevelopment {
-		emailBatchingInterval = 60
-	}
+type ChatMessageInfo struct {
+	ChatUserID string
+	Message    string
+}
 
-	config := &model.Config{
-		ServiceSettings: model.ServiceSettings{
-			EnableUserAccessTokens:            lo.ToPtr(true),
-			EnableOutgoingWebhooks:            lo.ToPtr(true),
-			AllowCorsFrom:                     lo.ToPtr("*"),
-			CorsAllowCredentials:              lo.ToPtr(true),
-			EnableInsecureOutgoingConnections: lo.ToPtr(true),
-			ExtendSessionLengthWithActivity:   lo.ToPtr(true),
-		},
-		TeamSettings: model.TeamSettings{
-			SiteName:              lo.ToPtr("Clothing Loop Chat"),
-			CustomDescriptionText: lo.ToPtr("Open the MyClothingLoop app"),
-			MaxUsersPerTeam:       lo.ToPtr(99_999),
-			MaxChannelsPerTeam:    lo.ToPtr[int64](999_999),
-		},
-		EmailSettings: model.EmailSettings{
-			EnableSignUpWithEmail:    lo.ToPtr(false),
-			RequireEmailVerification: lo.ToPtr(false),
-			SendEmailNotifications:   lo.ToPtr(true),
-			FeedbackName:             lo.ToPtr("The Clothing Loop"),
-			FeedbackEmail:            lo.ToPtr("noreply@clothingloop.org"),
-			EnableEmailBatching:      lo.ToPtr(true),
-			EmailBatchingInterval:    lo.ToPtr(emailBatchingInterval),
-			ReplyToAddress:           lo.ToPtr("hello@clothingloop.org"),
-		},
-		PluginSettings: model.PluginSettings{
-			PluginStates: map[string]*model.PluginState{
-				"com+mattermost+nps": {
-					Enable: false,
-				},
-				"com+mattermost+calls": {
-					Enable: false,
-				},
-			},
-		},
-		SupportSettings: model.SupportSettings{
-			SupportEmail: lo.ToPtr("hello@clothingloop.org"),
-		},
-		PrivacySettings: model.PrivacySettings{
-			ShowEmailAddress: lo.ToPtr(false),
-			ShowFullName:     lo.ToPtr(false),
-		},
-		NativeAppSettings: model.NativeAppSettings{
-			AppDownloadLink:        lo.ToPtr("https://www.clothingloop.org/en/"),
-			IosAppDownloadLink:     lo.ToPtr("https://apps.apple.com/us/app/my-clothing-loop/id6451443500"),
-			AndroidAppDownloadLink: lo.ToPtr("https://play.google.com/store/apps/details?id=org.clothingloop.app"),
-		},
-	}
+// Set by ChatInit, nil when chat is disabled
+var Chat ChatProvider
 
-	if Config.MM_SMTP_HOST != "" {
-		config.EmailSettings.SMTPUsername = lo.ToPtr("")
-		config.EmailSettings.SMTPPassword = lo.ToPtr("")
-		config.EmailSettings.SMTPServer = &Config.MM_SMTP_HOST
-		config.EmailSettings.SMTPPort = &Config.MM_SMTP_PORT
-		config.EmailSettings.EnableSMTPAuth = lo.ToPtr(false)
-		config.EmailSettings.ConnectionSecurity = lo.ToPtr(model.ConnSecurityNone)
+// Selects the provider of chat_provider, when empty Mattermost is used if mattermost_url is set.
+// Mattermost being unreachable is not an error, it is set up on first use.
+func ChatInit(db *gorm.DB) error {
+	provider := Config.CHAT_PROVIDER
+	if provider == "" && Config.MM_URL != "" {
+		provider = ChatProviderMattermost
 	}
-	_, _, err = client.PatchConfig(context.TODO(), config)
 
-	if err != nil {
-		slog.Error("unable to set mattermost configuration")
-		panic(err)
+	switch provider {
+	case ChatProviderMattermost:
+		m := newMattermostChat(Config.MM_URL, Config.MM_TOKEN)
+		if err := m.ensureSetup(context.Background()); err != nil {
+			slog.Error("Mattermost is not available, trying again on first use", "err", err)
+		}
+		Chat = m
+	case ChatProviderBuiltin:
+		Chat = newBuiltinChat(db)
+	case "", "none":
+		Chat = nil
+	default:
+		return fmt.Errorf("Unknown chat provider: %s", provider)
 	}
-
-	ChatTeamId = mmTeam.Id
+	return nil
 }
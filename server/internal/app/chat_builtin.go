@@ -0,0 +1,195 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// Messages waiting to be written to a connection, newer messages are dropped when it is full
+const chatSubscriberBuffer = 32
+
+// Chat stored in our own database, messages are delivered over WebSocket connections.
+// Connections are kept in memory, so this only supports running a single server.
+type BuiltinChat struct {
+	db *gorm.DB
+
+	mu   sync.Mutex
+	subs map[uint]map[chan sharedtypes.ChatMessage]struct{}
+}
+
+func newBuiltinChat(db *gorm.DB) *BuiltinChat {
+	return &BuiltinChat{
+		db:   db,
+		subs: map[uint]map[chan sharedtypes.ChatMessage]struct{}{},
+	}
+}
+
+func (*BuiltinChat) Name() string { return ChatProviderBuiltin }
+
+func (*BuiltinChat) TeamID() string { return "" }
+
+// The chat user is the user itself
+func (*BuiltinChat) UserPatch(ctx context.Context, user *models.User) (string, string, error) {
+	return user.UID, user.Name, nil
+}
+
+func (*BuiltinChat) UserToken(ctx context.Context, chatUserID, previousToken string) (string, error) {
+	return "", nil
+}
+
+func (*BuiltinChat) UserTokenRevoke(ctx context.Context, chatUserID string) error {
+	return nil
+}
+
+func (b *BuiltinChat) ChannelCreate(ctx context.Context, chainID uint, name, color string) (string, error) {
+	channel := &models.ChatChannel{
+		UID:     uuid.NewV4().String(),
+		ChainID: chainID,
+		Name:    name,
+		Color:   color,
+	}
+	err := b.db.WithContext(ctx).Create(channel).Error
+	if err != nil {
+		return "", err
+	}
+	return channel.UID, nil
+}
+
+func (b *BuiltinChat) ChannelDelete(ctx context.Context, channelID string) error {
+	channel, err := b.channel(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	return models.ChatChannelDelete(b.db.WithContext(ctx), channel.ID)
+}
+
+func (b *BuiltinChat) ChannelJoin(ctx context.Context, channelID, chatUserID string, isAdmin bool) error {
+	channel, err := b.channel(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	return models.ChatChannelMemberPut(b.db.WithContext(ctx), channel.ID, chatUserID, isAdmin)
+}
+
+func (b *BuiltinChat) ChannelLeave(ctx context.Context, channelID, chatUserID string) error {
+	channel, err := b.channel(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	return models.ChatChannelMemberDelete(b.db.WithContext(ctx), channel.ID, chatUserID)
+}
+
+func (b *BuiltinChat) ChannelMembers(ctx context.Context, channelID string) ([]ChatChannelMember, error) {
+	channel, err := b.channel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	members := []ChatChannelMember{}
+	err = b.db.WithContext(ctx).Raw(`
+SELECT u.uid AS chat_user_id, m.is_admin
+FROM chat_channel_members AS m
+JOIN users AS u ON u.id = m.user_id
+WHERE m.chat_channel_id = ?
+	`, channel.ID).Scan(&members).Error
+	return members, err
+}
+
+// Members and messages are removed together with the user
+func (*BuiltinChat) UserDeactivate(ctx context.Context, chatUserID string) error {
+	return nil
+}
+
+func (b *BuiltinChat) MessageGet(ctx context.Context, channelID, messageID string) (*ChatMessageInfo, error) {
+	channel, err := b.channel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	info := &ChatMessageInfo{}
+	err = b.db.WithContext(ctx).Raw(`
+SELECT u.uid AS chat_user_id, m.message
+FROM chat_messages AS m
+JOIN users AS u ON u.id = m.user_id
+WHERE m.chat_channel_id = ? AND m.id = ?
+	`, channel.ID, messageID).Scan(info).Error
+	if err != nil {
+		return nil, err
+	}
+	if info.ChatUserID == "" {
+		return nil, ErrChatNotFound
+	}
+	return info, nil
+}
+
+// Muted members stay in the channel, their messages are refused when sending
+func (*BuiltinChat) MemberMute(ctx context.Context, channelID, chatUserID string, muted bool) (bool, error) {
+	return false, nil
+}
+
+func (b *BuiltinChat) ChannelDeleteMessagesBefore(ctx context.Context, channelID string, before time.Time) (int, error) {
+	channel, err := b.channel(ctx, channelID)
+	if err != nil {
+		return 0, err
+	}
+	res := b.db.WithContext(ctx).Exec(`DELETE FROM chat_messages WHERE chat_channel_id = ? AND created_at < ?`, channel.ID, before)
+	return int(res.RowsAffected), res.Error
+}
+
+func (b *BuiltinChat) channel(ctx context.Context, channelID string) (*models.ChatChannel, error) {
+	channel, err := models.ChatChannelGetByUID(b.db.WithContext(ctx), channelID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrChatNotFound
+	}
+	return channel, err
+}
+
+// Receives the messages of all channels the user is a member of until unsubscribe is called
+func (b *BuiltinChat) Subscribe(userID uint) (messages <-chan sharedtypes.ChatMessage, unsubscribe func()) {
+	ch := make(chan sharedtypes.ChatMessage, chatSubscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = map[chan sharedtypes.ChatMessage]struct{}{}
+	}
+	b.subs[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[userID][ch]; !ok {
+			return
+		}
+		delete(b.subs[userID], ch)
+		if len(b.subs[userID]) == 0 {
+			delete(b.subs, userID)
+		}
+		close(ch)
+	}
+}
+
+// Delivers the message to the connections of the users
+func (b *BuiltinChat) Publish(userIDs []uint, message sharedtypes.ChatMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, userID := range userIDs {
+		for ch := range b.subs[userID] {
+			select {
+			case ch <- message:
+			default:
+			}
+		}
+	}
+}
+
+// Returns the built-in chat, false when another provider is used
+func ChatBuiltin() (*BuiltinChat, bool) {
+	b, ok := Chat.(*BuiltinChat)
+	return b, ok
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestBuiltinChatPublish(t *testing.T) {
+	b := newBuiltinChat(nil)
+
+	messagesA, unsubscribeA := b.Subscribe(1)
+	messagesA2, unsubscribeA2 := b.Subscribe(1)
+	messagesB, unsubscribeB := b.Subscribe(2)
+	defer unsubscribeA2()
+	defer unsubscribeB()
+
+	b.Publish([]uint{1, 3}, sharedtypes.ChatMessage{ID: 10, Message: "hello"})
+
+	assert.Equal(t, uint(10), (<-messagesA).ID)
+	assert.Equal(t, uint(10), (<-messagesA2).ID, "every connection of the user receives the message")
+	assert.Empty(t, messagesB, "only members receive the message")
+
+	unsubscribeA()
+	unsubscribeA()
+	_, ok := <-messagesA
+	assert.False(t, ok, "closed after unsubscribe")
+
+	for i := 0; i < chatSubscriberBuffer+5; i++ {
+		b.Publish([]uint{2}, sharedtypes.ChatMessage{ID: uint(i)})
+	}
+	assert.Len(t, messagesB, chatSubscriberBuffer, "a slow connection does not block publishing")
+}
@@ -0,0 +1,443 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GGP1/atoll"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/models"
+)
+
+const chatAccessTokenDescription = "clothingloop-app"
+
+// Chat through a Mattermost server, users log in with single sign-on through our OpenID Connect provider
+type mattermostChat struct {
+	Client *model.Client4
+
+	mu     sync.Mutex
+	teamID string
+	// The account of MM_TOKEN, it is a member of every channel it created
+	selfUserID string
+}
+
+func newMattermostChat(url, token string) *mattermostChat {
+	client := model.NewAPIv4Client(url)
+	client.SetToken(token)
+	return &mattermostChat{Client: client}
+}
+
+func (*mattermostChat) Name() string { return ChatProviderMattermost }
+
+func (m *mattermostChat) TeamID() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.teamID
+}
+
+// Sets up the team and server settings once, until it succeeds it is tried again on each use
+func (m *mattermostChat) ensureSetup(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.teamID != "" {
+		return nil
+	}
+	teamID, err := mattermostSetDefaultSettings(ctx, m.Client)
+	if err != nil {
+		return err
+	}
+	me, _, err := m.Client.GetMe(ctx, "")
+	if err != nil {
+		return fmt.Errorf("unable to get mattermost token user: %w", err)
+	}
+	m.teamID = teamID
+	m.selfUserID = me.Id
+	return nil
+}
+
+func mattermostSetDefaultSettings(ctx context.Context, client *model.Client4) (string, error) {
+	team := &model.Team{
+		Name:        "loops",
+		DisplayName: "Loops",
+		Type:        model.TeamInvite,
+	}
+	if Config.ENV == EnvEnumAcceptance {
+		team.Name += "acc"
+		team.DisplayName += " Acc"
+	} else if Config.ENV == EnvEnumDevelopment {
+		team.Name += "dev"
+		team.DisplayName += " Dev"
+	}
+
+	_, _, err := client.GetPing(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to ping mattermost: %w", err)
+	}
+	slog.Info("ping mattermost received")
+
+	mmTeam, _, err := client.GetTeamByName(ctx, team.Name, "")
+	if err != nil {
+		mmTeam, _, err = client.CreateTeam(ctx, team)
+		if err != nil {
+			return "", fmt.Errorf("unable to create mattermost team: %w", err)
+		}
+	}
+
+	emailBatchingInterval := 5 * 60 // seconds
+	if Config.ENV == EnvEnumDevelopment {
+		emailBatchingInterval = 60
+	}
+
+	config := &model.Config{
+		ServiceSettings: model.ServiceSettings{
+			EnableUserAccessTokens:            lo.ToPtr(true),
+			EnableOutgoingWebhooks:            lo.ToPtr(true),
+			AllowCorsFrom:                     lo.ToPtr("*"),
+			CorsAllowCredentials:              lo.ToPtr(true),
+			EnableInsecureOutgoingConnections: lo.ToPtr(true),
+			ExtendSessionLengthWithActivity:   lo.ToPtr(true),
+		},
+		TeamSettings: model.TeamSettings{
+			SiteName:              lo.ToPtr("Clothing Loop Chat"),
+			CustomDescriptionText: lo.ToPtr("Open the MyClothingLoop app"),
+			MaxUsersPerTeam:       lo.ToPtr(99_999),
+			MaxChannelsPerTeam:    lo.ToPtr[int64](999_999),
+		},
+		EmailSettings: model.EmailSettings{
+			EnableSignUpWithEmail:    lo.ToPtr(false),
+			RequireEmailVerification: lo.ToPtr(false),
+			SendEmailNotifications:   lo.ToPtr(true),
+			FeedbackName:             lo.ToPtr("The Clothing Loop"),
+			FeedbackEmail:            lo.ToPtr("noreply@clothingloop.org"),
+			EnableEmailBatching:      lo.ToPtr(true),
+			EmailBatchingInterval:    lo.ToPtr(emailBatchingInterval),
+			ReplyToAddress:           lo.ToPtr("hello@clothingloop.org"),
+		},
+		PluginSettings: model.PluginSettings{
+			PluginStates: map[string]*model.PluginState{
+				"com+mattermost+nps": {
+					Enable: false,
+				},
+				"com+mattermost+calls": {
+					Enable: false,
+				},
+			},
+		},
+		SupportSettings: model.SupportSettings{
+			SupportEmail: lo.ToPtr("hello@clothingloop.org"),
+		},
+		PrivacySettings: model.PrivacySettings{
+			ShowEmailAddress: lo.ToPtr(false),
+			ShowFullName:     lo.ToPtr(false),
+		},
+		NativeAppSettings: model.NativeAppSettings{
+			AppDownloadLink:        lo.ToPtr("https://www.clothingloop.org/en/"),
+			IosAppDownloadLink:     lo.ToPtr("https://apps.apple.com/us/app/my-clothing-loop/id6451443500"),
+			AndroidAppDownloadLink: lo.ToPtr("https://play.google.com/store/apps/details?id=org.clothingloop.app"),
+		},
+	}
+
+	if Config.MM_SMTP_HOST != "" {
+		config.EmailSettings.SMTPUsername = lo.ToPtr("")
+		config.EmailSettings.SMTPPassword = lo.ToPtr("")
+		config.EmailSettings.SMTPServer = &Config.MM_SMTP_HOST
+		config.EmailSettings.SMTPPort = &Config.MM_SMTP_PORT
+		config.EmailSettings.EnableSMTPAuth = lo.ToPtr(false)
+		config.EmailSettings.ConnectionSecurity = lo.ToPtr(model.ConnSecurityNone)
+	}
+	// login via single sign-on, mattermost uses its gitlab integration for this
+	if Config.MM_OIDC_CLIENT_SECRET != "" {
+		issuer := OidcIssuer()
+		config.GitLabSettings = model.SSOSettings{
+			Enable:          lo.ToPtr(true),
+			Id:              lo.ToPtr(OidcMattermostClientID),
+			Secret:          &Config.MM_OIDC_CLIENT_SECRET,
+			Scope:           lo.ToPtr("openid profile email"),
+			AuthEndpoint:    lo.ToPtr(issuer + "/v2/oidc/authorize"),
+			TokenEndpoint:   lo.ToPtr(issuer + "/v2/oidc/token"),
+			UserAPIEndpoint: lo.ToPtr(issuer + "/v2/oidc/userinfo"),
+			ButtonText:      lo.ToPtr("Clothing Loop"),
+		}
+	}
+	_, _, err = client.PatchConfig(ctx, config)
+	if err != nil {
+		return "", fmt.Errorf("unable to set mattermost configuration: %w", err)
+	}
+
+	return mmTeam.Id, nil
+}
+
+func (m *mattermostChat) UserPatch(ctx context.Context, user *models.User) (string, string, error) {
+	if err := m.ensureSetup(ctx); err != nil {
+		return "", "", err
+	}
+
+	var mmUser *model.User
+	var err error
+	authData := fmt.Sprint(user.ID)
+	// check if user exists properly
+	if user.ChatUserID != nil {
+		// get the chat user
+		mmUser, _, err = m.Client.GetUser(ctx, *user.ChatUserID, "")
+		if err == nil {
+			slog.Info("chat user exists", "id", mmUser.Id, "err", err)
+		}
+	}
+
+	if mmUser == nil {
+		slog.Info("create Chat user if does not exist")
+
+		username := func() string {
+			p, _ := atoll.NewPassword(10, []atoll.Level{atoll.Digit, atoll.Lower, atoll.Level("-_.")})
+			return "u" + string(p)
+		}()
+		if user.Email == nil {
+			return "", "", fmt.Errorf("Email is required")
+		}
+		mmUser, _, err = m.Client.CreateUser(ctx, &model.User{
+			Nickname:    user.Name,
+			Username:    username,
+			Email:       username + "@example.com",
+			AuthService: model.UserAuthServiceGitlab,
+			AuthData:    &authData,
+		})
+		if err != nil {
+			return "", "", err
+		}
+
+		_, _, err = m.Client.AddTeamMember(ctx, m.TeamID(), mmUser.Id)
+		if err != nil {
+			return "", "", err
+		}
+	} else if mmUser.AuthService != model.UserAuthServiceGitlab {
+		// users created with a password are moved over to single sign-on
+		_, _, err = m.Client.UpdateUserAuth(ctx, mmUser.Id, &model.UserAuth{
+			AuthService: model.UserAuthServiceGitlab,
+			AuthData:    &authData,
+		})
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	return mmUser.Id, mmUser.Username, nil
+}
+
+// Every device of the user shares one token, a new one is only created when the previous token is no longer valid
+func (m *mattermostChat) UserToken(ctx context.Context, chatUserID, previousToken string) (string, error) {
+	if previousToken != "" {
+		client := model.NewAPIv4Client(m.Client.URL)
+		client.SetToken(previousToken)
+		me, _, err := client.GetMe(ctx, "")
+		if err == nil && me.Id == chatUserID {
+			return previousToken, nil
+		}
+	}
+
+	mmToken, _, err := m.Client.CreateUserAccessToken(ctx, chatUserID, chatAccessTokenDescription)
+	if err != nil {
+		return "", err
+	}
+	return mmToken.Token, nil
+}
+
+func (m *mattermostChat) UserTokenRevoke(ctx context.Context, chatUserID string) error {
+	mmTokens, resp, err := m.Client.GetUserAccessTokensForUser(ctx, chatUserID, 0, 100)
+	if err != nil {
+		return mattermostError(resp, err)
+	}
+	errs := []error{}
+	for _, mmToken := range mmTokens {
+		if mmToken.Description != chatAccessTokenDescription {
+			continue
+		}
+		_, err = m.Client.RevokeUserAccessToken(ctx, mmToken.Id)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *mattermostChat) ChannelCreate(ctx context.Context, chainID uint, name, color string) (string, error) {
+	if err := m.ensureSetup(ctx); err != nil {
+		return "", err
+	}
+
+	newChannel, _, err := m.Client.CreateChannel(ctx, &model.Channel{
+		TeamId:      m.TeamID(),
+		Name:        fmt.Sprintf("%dr%s", chainID, lo.RandomString(8, lo.LowerCaseLettersCharset)),
+		DisplayName: name,
+		Type:        model.ChannelTypePrivate,
+		Header:      color,
+	})
+	if err != nil {
+		return "", err
+	}
+	return newChannel.Id, nil
+}
+
+// Archives the channel
+func (m *mattermostChat) ChannelDelete(ctx context.Context, channelID string) error {
+	resp, err := m.Client.DeleteChannel(ctx, channelID)
+	return mattermostError(resp, err)
+}
+
+func (m *mattermostChat) ChannelJoin(ctx context.Context, channelID, chatUserID string, isAdmin bool) error {
+	// Check if room already contains user
+	mmChannelMembers, _, _ := m.Client.GetChannelMembersByIds(ctx, channelID, []string{chatUserID})
+	member, ok := lo.Find(mmChannelMembers, func(member model.ChannelMember) bool {
+		return member.UserId == chatUserID
+	})
+	if !ok {
+		newMember, _, err := m.Client.AddChannelMember(ctx, channelID, chatUserID)
+		if err != nil {
+			return err
+		}
+		member = *newMember
+	}
+
+	return m.channelSetMemberRole(ctx, channelID, &member, isAdmin)
+}
+
+func (m *mattermostChat) ChannelLeave(ctx context.Context, channelID, chatUserID string) error {
+	resp, err := m.Client.RemoveUserFromChannel(ctx, channelID, chatUserID)
+	return mattermostError(resp, err)
+}
+
+// Largest page size allowed by Mattermost
+const mattermostPerPage = 200
+
+func (m *mattermostChat) ChannelMembers(ctx context.Context, channelID string) ([]ChatChannelMember, error) {
+	if err := m.ensureSetup(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	selfUserID := m.selfUserID
+	m.mu.Unlock()
+
+	members := []ChatChannelMember{}
+	for page := 0; ; page++ {
+		mmMembers, _, err := m.Client.GetChannelMembers(ctx, channelID, page, mattermostPerPage, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range mmMembers {
+			if member.UserId == selfUserID {
+				continue
+			}
+			members = append(members, ChatChannelMember{
+				ChatUserID: member.UserId,
+				IsAdmin:    lo.Contains(strings.Split(member.Roles, " "), model.ChannelAdminRoleId),
+			})
+		}
+		if len(mmMembers) < mattermostPerPage {
+			return members, nil
+		}
+	}
+}
+
+// Deactivates the chat user, which also ends their sessions
+func (m *mattermostChat) UserDeactivate(ctx context.Context, chatUserID string) error {
+	resp, err := m.Client.DeleteUser(ctx, chatUserID)
+	return mattermostError(resp, err)
+}
+
+func (m *mattermostChat) MessageGet(ctx context.Context, channelID, messageID string) (*ChatMessageInfo, error) {
+	post, resp, err := m.Client.GetPost(ctx, messageID, "")
+	if err != nil {
+		return nil, mattermostError(resp, err)
+	}
+	if post.ChannelId != channelID {
+		return nil, ErrChatNotFound
+	}
+	return &ChatMessageInfo{
+		ChatUserID: post.UserId,
+		Message:    post.Message,
+	}, nil
+}
+
+// Mattermost has no read-only members, muted members are removed from the channel until the mute ends
+func (m *mattermostChat) MemberMute(ctx context.Context, channelID, chatUserID string, muted bool) (bool, error) {
+	if !muted {
+		return false, m.ChannelJoin(ctx, channelID, chatUserID, false)
+	}
+
+	// channels the member never joined must not be joined when the mute ends
+	mmChannelMembers, _, err := m.Client.GetChannelMembersByIds(ctx, channelID, []string{chatUserID})
+	if err != nil {
+		return false, err
+	}
+	if !lo.ContainsBy(mmChannelMembers, func(member model.ChannelMember) bool {
+		return member.UserId == chatUserID
+	}) {
+		return false, nil
+	}
+
+	err = m.ChannelLeave(ctx, channelID, chatUserID)
+	if errors.Is(err, ErrChatNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (m *mattermostChat) ChannelDeleteMessagesBefore(ctx context.Context, channelID string, before time.Time) (int, error) {
+	postIDs := []string{}
+	for page := 0; ; page++ {
+		posts, resp, err := m.Client.GetPostsForChannel(ctx, channelID, page, mattermostPerPage, "", false, false)
+		if err != nil {
+			return 0, mattermostError(resp, err)
+		}
+		for _, id := range posts.Order {
+			if post, ok := posts.Posts[id]; ok && post.CreateAt < before.UnixMilli() {
+				postIDs = append(postIDs, id)
+			}
+		}
+		if len(posts.Order) < mattermostPerPage {
+			break
+		}
+	}
+
+	for i, id := range postIDs {
+		resp, err := m.Client.DeletePost(ctx, id)
+		if err := mattermostError(resp, err); err != nil && !errors.Is(err, ErrChatNotFound) {
+			return i, err
+		}
+	}
+	return len(postIDs), nil
+}
+
+func mattermostError(resp *model.Response, err error) error {
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		return ErrChatNotFound
+	}
+	return err
+}
+
+func (m *mattermostChat) channelSetMemberRole(ctx context.Context, channelID string, member *model.ChannelMember, setRoleAdmin bool) error {
+	roles := strings.Split(member.Roles, " ")
+
+	isRolesContainsAdmin := lo.Contains(roles, model.ChannelAdminRoleId)
+	shouldUpdateRoles := isRolesContainsAdmin != setRoleAdmin
+	if shouldUpdateRoles {
+		if setRoleAdmin {
+			roles = append(roles, model.ChannelAdminRoleId)
+		} else {
+			roles = lo.Filter(roles, func(r string, i int) bool {
+				return r != model.ChannelAdminRoleId
+			})
+		}
+		_, err := m.Client.UpdateChannelRoles(ctx, channelID, member.UserId, strings.Join(roles, " "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -18,39 +18,55 @@ const (
 )
 
 var Config struct {
-	ENV                     string `yaml:"-" env:"ENV"`
-	HOST                    string `yaml:"host" env:"HOST"`
-	PORT                    int    `yaml:"port" env:"PORT"`
-	SITE_BASE_URL_API       string `yaml:"site_base_url_api" env:"SITE_BASE_URL_API"`
-	SITE_BASE_URL_FE        string `yaml:"site_base_url_fe" env:"SITE_BASE_URL_FE"`
-	COOKIE_DOMAIN           string `yaml:"cookie_domain" env:"COOKIE_DOMAIN"`
-	COOKIE_HTTPS_ONLY       bool   `yaml:"cookie_https_only" env:"COOKIE_HTTPS_ONLY"`
-	JWT_SECRET              string `yaml:"jwt_secret" env:"-"`
-	JWT_SECRET_BASE64       string `yaml:"-" env:"JWT_SECRET"`
-	STRIPE_SECRET_KEY       string `yaml:"stripe_secret_key" env:"STRIPE_SECRET_KEY"`
-	STRIPE_WEBHOOK          string `yaml:"stripe_webhook" env:"STRIPE_WEBHOOK"`
-	DB_HOST                 string `yaml:"db_host" env:"DB_HOST"`
-	DB_PORT                 int    `yaml:"db_port" env:"DB_PORT"`
-	DB_NAME                 string `yaml:"db_name" env:"DB_NAME"`
-	DB_USER                 string `yaml:"db_user" env:"DB_USER"`
-	DB_PASS                 string `yaml:"db_pass" env:"DB_PASS"`
-	SMTP_HOST               string `yaml:"smtp_host" env:"SMTP_HOST"`
-	SMTP_PORT               int    `yaml:"smtp_port" env:"SMTP_PORT"`
-	SMTP_SENDER             string `yaml:"smtp_sender" env:"SMTP_SENDER"`
-	SMTP_USER               string `yaml:"smtp_user" env:"SMTP_USER"`
-	SMTP_PASS               string `yaml:"smtp_pass" env:"SMTP_PASS"`
-	GOSCOPE2_USER           string `yaml:"goscope2_user" env:"GOSCOPE2_USER"`
-	GOSCOPE2_PASS           string `yaml:"goscope2_pass" env:"GOSCOPE2_PASS"`
-	SENDINBLUE_API_KEY      string `yaml:"sendinblue_api_key" env:"SENDINBLUE_API_KEY"`
-	IMGBB_KEY               string `yaml:"imgbb_key" env:"IMGBB_KEY"`
-	ONESIGNAL_APP_ID        string `yaml:"onesignal_app_id" env:"ONESIGNAL_APP_ID"`
-	ONESIGNAL_REST_API_KEY  string `yaml:"onesignal_rest_api_key" env:"ONESIGNAL_REST_API_KEY"`
-	APPSTORE_REVIEWER_EMAIL string `yaml:"appstore_reviewer_email" env:"APPSTORE_REVIEWER_EMAIL"`
-	IMAGES_DIR              string `yaml:"images_dir" env:"IMAGES_DIR"`
-	MM_URL                  string `yaml:"mattermost_url" env:"MM_URL"`
-	MM_TOKEN                string `yaml:"mattermost_token" env:"MM_TOKEN"`
-	MM_SMTP_HOST            string `yaml:"mattermost_smtp_host" env:"MM_SMTP_HOST"`
-	MM_SMTP_PORT            string `yaml:"mattermost_smtp_port" env:"MM_SMTP_PORT"`
+	ENV                        string `yaml:"-" env:"ENV"`
+	HOST                       string `yaml:"host" env:"HOST"`
+	PORT                       int    `yaml:"port" env:"PORT"`
+	SITE_BASE_URL_API          string `yaml:"site_base_url_api" env:"SITE_BASE_URL_API"`
+	SITE_BASE_URL_FE           string `yaml:"site_base_url_fe" env:"SITE_BASE_URL_FE"`
+	COOKIE_DOMAIN              string `yaml:"cookie_domain" env:"COOKIE_DOMAIN"`
+	COOKIE_HTTPS_ONLY          bool   `yaml:"cookie_https_only" env:"COOKIE_HTTPS_ONLY"`
+	JWT_SECRET                 string `yaml:"jwt_secret" env:"-"`
+	JWT_SECRET_BASE64          string `yaml:"-" env:"JWT_SECRET"`
+	STRIPE_SECRET_KEY          string `yaml:"stripe_secret_key" env:"STRIPE_SECRET_KEY"`
+	STRIPE_WEBHOOK             string `yaml:"stripe_webhook" env:"STRIPE_WEBHOOK"`
+	DB_HOST                    string `yaml:"db_host" env:"DB_HOST"`
+	DB_PORT                    int    `yaml:"db_port" env:"DB_PORT"`
+	DB_NAME                    string `yaml:"db_name" env:"DB_NAME"`
+	DB_USER                    string `yaml:"db_user" env:"DB_USER"`
+	DB_PASS                    string `yaml:"db_pass" env:"DB_PASS"`
+	SMTP_HOST                  string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTP_PORT                  int    `yaml:"smtp_port" env:"SMTP_PORT"`
+	SMTP_SENDER                string `yaml:"smtp_sender" env:"SMTP_SENDER"`
+	SMTP_USER                  string `yaml:"smtp_user" env:"SMTP_USER"`
+	SMTP_PASS                  string `yaml:"smtp_pass" env:"SMTP_PASS"`
+	GOSCOPE2_USER              string `yaml:"goscope2_user" env:"GOSCOPE2_USER"`
+	GOSCOPE2_PASS              string `yaml:"goscope2_pass" env:"GOSCOPE2_PASS"`
+	SENDINBLUE_API_KEY         string `yaml:"sendinblue_api_key" env:"SENDINBLUE_API_KEY"`
+	MAIL_PROVIDERS             string `yaml:"mail_providers" env:"MAIL_PROVIDERS"`
+	MAIL_FILE_DIR              string `yaml:"mail_file_dir" env:"MAIL_FILE_DIR"`
+	MAIL_QUEUE_WORKERS         int    `yaml:"mail_queue_workers" env:"MAIL_QUEUE_WORKERS"`
+	BREVO_WEBHOOK_TOKEN        string `yaml:"brevo_webhook_token" env:"BREVO_WEBHOOK_TOKEN"`
+	DKIM_DOMAIN                string `yaml:"dkim_domain" env:"DKIM_DOMAIN"`
+	DKIM_SELECTOR              string `yaml:"dkim_selector" env:"DKIM_SELECTOR"`
+	DKIM_PRIVATE_KEY           string `yaml:"dkim_private_key" env:"DKIM_PRIVATE_KEY"`
+	IMGBB_KEY                  string `yaml:"imgbb_key" env:"IMGBB_KEY"`
+	ONESIGNAL_APP_ID           string `yaml:"onesignal_app_id" env:"ONESIGNAL_APP_ID"`
+	ONESIGNAL_REST_API_KEY     string `yaml:"onesignal_rest_api_key" env:"ONESIGNAL_REST_API_KEY"`
+	WEB_PUSH_VAPID_PUBLIC_KEY  string `yaml:"web_push_vapid_public_key" env:"WEB_PUSH_VAPID_PUBLIC_KEY"`
+	WEB_PUSH_VAPID_PRIVATE_KEY string `yaml:"web_push_vapid_private_key" env:"WEB_PUSH_VAPID_PRIVATE_KEY"`
+	WEB_PUSH_SUBJECT           string `yaml:"web_push_subject" env:"WEB_PUSH_SUBJECT"`
+	APPSTORE_REVIEWER_EMAIL    string `yaml:"appstore_reviewer_email" env:"APPSTORE_REVIEWER_EMAIL"`
+	IMAGES_DIR                 string `yaml:"images_dir" env:"IMAGES_DIR"`
+	EXPORTS_DIR                string `yaml:"exports_dir" env:"EXPORTS_DIR"`
+	CHAT_PROVIDER              string `yaml:"chat_provider" env:"CHAT_PROVIDER"`
+	MM_URL                     string `yaml:"mattermost_url" env:"MM_URL"`
+	MM_TOKEN                   string `yaml:"mattermost_token" env:"MM_TOKEN"`
+	MM_SMTP_HOST               string `yaml:"mattermost_smtp_host" env:"MM_SMTP_HOST"`
+	MM_SMTP_PORT               string `yaml:"mattermost_smtp_port" env:"MM_SMTP_PORT"`
+	MM_OIDC_CLIENT_SECRET      string `yaml:"mattermost_oidc_client_secret" env:"MM_OIDC_CLIENT_SECRET"`
+	OIDC_PRIVATE_KEY           string `yaml:"oidc_private_key" env:"OIDC_PRIVATE_KEY"`
+
+	OIDC_CLIENTS []OidcClient `yaml:"oidc_clients" env:"-"`
 }
 
 func ConfigInit(pwd string, files ...string) {
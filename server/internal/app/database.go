@@ -1,6 +1,7 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -56,6 +57,7 @@ func DatabaseAutoMigrate(db *gorm.DB) {
 	hadIsApprovedColumn := db.Migrator().HasColumn(&sharedtypes.UserChain{}, "is_approved")
 	hadEventPriceTypeColumn := db.Migrator().HasColumn(&models.Event{}, "price_type")
 	hadAllowMapColumn := db.Migrator().HasColumn(&models.Chain{}, "allow_map")
+	hadBulkyItemUpdatedAtColumn := db.Migrator().HasColumn(&models.BulkyItem{}, "updated_at")
 
 	// User Tokens
 	if db.Migrator().HasTable("user_tokens") {
@@ -116,6 +118,9 @@ func DatabaseAutoMigrate(db *gorm.DB) {
 	if db.Migrator().HasTable("mails") {
 		db.Exec(`DROP TABLE mails`)
 	}
+	hadMailRetriesTable := db.Migrator().HasTable("mail_retries")
+	hadMailUnsubscribesTable := db.Migrator().HasTable("mail_unsubscribes")
+	hadImageReferencesTable := db.Migrator().HasTable("image_references")
 
 	db.AutoMigrate(
 		&models.Chain{},
@@ -127,9 +132,30 @@ func DatabaseAutoMigrate(db *gorm.DB) {
 		&models.UserOnesignal{},
 		&models.Bag{},
 		&models.BulkyItem{},
+		&models.BulkyItemSubscription{},
+		&models.Wish{},
+		&models.Image{},
+		&models.ImageReference{},
 		&models.Payment{},
 		&models.Mail{},
 		&models.DeletedUser{},
+		&models.ChainRole{},
+		&models.UserEmailChange{},
+		&models.OidcAuthCode{},
+		&models.UserDataExport{},
+		&models.UserPurgeSchedule{},
+		&models.MailSuppression{},
+		&models.NotificationPreference{},
+		&models.ChainLeave{},
+		&models.UserWebPushSubscription{},
+		&models.PushDelivery{},
+		&models.UserNotification{},
+		&models.ChatChannel{},
+		&models.ChatChannelMember{},
+		&models.ChatMessage{},
+		&models.ChatCleanup{},
+		&models.ChatMessageReport{},
+		&models.ChatMute{},
 	)
 
 	if !db.Migrator().HasConstraint("user_chains", "uci_user_id_chain_id") {
@@ -170,8 +196,63 @@ UPDATE user_chains SET is_approved = FALSE WHERE id IN (
 		slog.Info("Migration run: set new allow_map column to true")
 		db.Exec("UPDATE chains SET allow_map = 1")
 	}
+	if !hadBulkyItemUpdatedAtColumn {
+		slog.Info("Migration run: set new bulky_items updated_at column to created_at")
+		db.Exec("UPDATE bulky_items SET updated_at = created_at WHERE updated_at IS NULL")
+	}
+	if !hadImageReferencesTable {
+		slog.Info("Migration run: add references of images in use")
+		err := models.ImageReferenceAddExisting(db)
+		if err != nil {
+			slog.Error("Unable to add references of images in use", "err", err)
+		}
+	}
+	if hadMailRetriesTable {
+		slog.Info("Migration run: move emails waiting for a retry to the mail queue")
+		err := db.Exec(`
+INSERT INTO mail_queue (sender_name, sender_address, to_name, to_address, subject, body, err, max_retry_attempts, status, attempts, next_attempt_at, created_at, updated_at)
+SELECT sender_name, sender_address, to_name, to_address, subject, body, err, max_retry_attempts, ?, next_retry_attempt, NOW(), created_at, NOW()
+FROM mail_retries
+WHERE next_retry_attempt > 0
+		`, models.MailStatusFailed).Error
+		if err == nil {
+			db.Exec(`DROP TABLE mail_retries`)
+		}
+	}
+
+	if hadMailUnsubscribesTable {
+		slog.Info("Migration run: move email unsubscribes to notification preferences")
+		kindsByCategory := map[string][]string{
+			"host_notifications": {
+				models.NotificationKindJoinRequest,
+				models.NotificationKindPoke,
+				models.NotificationKindApproveReminder,
+				models.NotificationKindMemberLeft,
+				models.NotificationKindLoopInactive,
+			},
+			"host_recruitment": {models.NotificationKindHostRecruitment},
+		}
+		var err error
+		for category, kinds := range kindsByCategory {
+			for _, kind := range kinds {
+				err = errors.Join(err, db.Exec(`
+INSERT IGNORE INTO notification_preferences (user_id, chain_id, kind, channel, updated_at)
+SELECT u.id, 0, ?, ?, NOW()
+FROM mail_unsubscribes AS mu
+JOIN users AS u ON u.email = mu.email
+WHERE mu.category = ?
+				`, kind, models.NotificationChannelNone, category).Error)
+			}
+		}
+		if err == nil {
+			db.Exec(`DROP TABLE mail_unsubscribes`)
+		}
+	}
 
 	if db.Migrator().HasColumn(&models.User{}, "chat_user") {
 		db.Migrator().DropColumn(&models.User{}, "chat_user")
 	}
+	if db.Migrator().HasColumn(&models.User{}, "chat_pass") {
+		db.Migrator().DropColumn(&models.User{}, "chat_pass")
+	}
 }
@@ -1,36 +1,94 @@
 package app
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/mail"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/pkg/dkim"
 	"gorm.io/gorm"
 
 	gomail "github.com/wneessen/go-mail"
 )
 
-var smtpClient *gomail.Client
-
+// Sets up the mail providers in order of priority, see mail_providers in config.example.yml
 func MailInit() {
-	var err error
-	smtpClient, err = gomail.NewClient(Config.SMTP_HOST, gomail.WithPort(Config.SMTP_PORT))
-	if err != nil {
-		panic(err)
+	mailBackends = []*mailBackend{}
+	for i, provider := range mailProviders() {
+		var mailer Mailer
+		var perSecond int
+		var err error
+		switch provider {
+		case MailProviderBrevo:
+			if Config.SENDINBLUE_API_KEY == "" {
+				slog.Warn("Brevo mail provider is skipped, sendinblue_api_key is not set")
+				continue
+			}
+			mailer = newBrevoMailer(Config.SENDINBLUE_API_KEY)
+			perSecond = 10
+		case MailProviderSmtp:
+			var signer *dkim.Signer
+			signer, err = mailDkimSigner()
+			if err == nil {
+				mailer, err = newSmtpMailer(Config.SMTP_HOST, Config.SMTP_PORT, Config.SMTP_USER, Config.SMTP_PASS, signer)
+			}
+			perSecond = 5
+		case MailProviderFile:
+			dir := Config.MAIL_FILE_DIR
+			if dir == "" {
+				dir = filepath.Join(os.TempDir(), "clothingloop-mail")
+			}
+			mailer, err = newFileMailer(dir)
+			perSecond = 100
+		default:
+			err = fmt.Errorf("unknown mail provider: %s", provider)
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		mailBackends = append(mailBackends, newMailBackend(provider, i, mailer, perSecond))
 	}
+}
 
-	if Config.SMTP_PASS == "" {
-		smtpClient.SetTLSPolicy(gomail.NoTLS)
-	} else {
-		smtpClient.SetSMTPAuth(gomail.SMTPAuthPlain)
-		smtpClient.SetUsername(Config.SMTP_USER)
-		smtpClient.SetPassword(Config.SMTP_PASS)
+// Without configuration only Brevo is used when an api key is set, otherwise only SMTP
+func mailProviders() []string {
+	if Config.MAIL_PROVIDERS != "" {
+		providers := []string{}
+		for _, p := range strings.Split(Config.MAIL_PROVIDERS, ",") {
+			p = strings.TrimSpace(p)
+			if p != "" {
+				providers = append(providers, p)
+			}
+		}
+		return providers
+	}
+
+	if Config.SENDINBLUE_API_KEY != "" {
+		return []string{MailProviderBrevo}
+	}
+	return []string{MailProviderSmtp}
+}
+
+// Returns nil when DKIM signing is not configured
+func mailDkimSigner() (*dkim.Signer, error) {
+	if Config.DKIM_PRIVATE_KEY == "" {
+		return nil, nil
+	}
+	if Config.DKIM_DOMAIN == "" || Config.DKIM_SELECTOR == "" {
+		return nil, fmt.Errorf("dkim_domain and dkim_selector must be set to sign emails")
 	}
+	return dkim.NewSigner(Config.DKIM_DOMAIN, Config.DKIM_SELECTOR, Config.DKIM_PRIVATE_KEY)
 }
 
 func MailCreate() *models.Mail {
@@ -41,30 +99,34 @@ func MailCreate() *models.Mail {
 	return m
 }
 
+// Adds the email to the outgoing queue, from where it is sent by the mail queue workers.
+// When the queue is not started, for example in tests and scripts, the email is sent before returning.
 func MailSend(db *gorm.DB, m *models.Mail) error {
 	// if Config.ENV == EnvEnumAcceptance && strings.HasSuffix(m.ToAddress, "@example.com") {
 	// 	return nil
 	// }
 
-	var err error
-	if Config.SENDINBLUE_API_KEY != "" {
-		err = mailSendByBrevoApi(m)
-	} else {
-		err = mailSendBySmtp(m)
+	if m.Category != "" {
+		channel, err := models.NotificationPreferenceChannelByEmail(db, m.ToAddress, m.ChainID, m.Category)
+		if err != nil {
+			slog.Error("Unable to get notification preference", "err", err)
+		} else if channel != models.NotificationChannelEmail {
+			slog.Info("Not sending email, receiver chose another channel", "kind", m.Category, "channel", channel)
+			return nil
+		}
 	}
-	if err != nil {
-		slog.Error("Unable to send email", "err", err)
 
-		// Ensure that the email is not sent infinitely by the retry mechanism
-		if m.NextRetryAttempt == 0 && m.MaxRetryAttempts > models.MAIL_RETRY_NEVER {
-			m.NextRetryAttempt = 1
-			m.AddToQueue(db)
-			fmt.Printf("Adding email to resend queue: %s to: %s", m.Subject, m.ToAddress)
-		}
+	err := m.Enqueue(db)
+	if err != nil {
+		slog.Error("Unable to queue email", "err", err)
 		return err
 	}
 
-	return nil
+	if mailQueue != nil {
+		mailQueue.wake()
+		return nil
+	}
+	return mailProcess(context.Background(), db, m)
 }
 
 func MailpitRemoveAllEmails() {
@@ -78,40 +140,8 @@ func MailpitRemoveAllEmails() {
 	}
 }
 
-func mailSendByBrevoApi(m *models.Mail) error {
-	postBody, _ := json.Marshal(map[string]any{
-		"sender": map[string]any{
-			"name":  m.SenderName,
-			"email": m.SenderAddress,
-		},
-		"to": []map[string]any{{
-			"name":  m.ToName,
-			"email": m.ToAddress,
-		}},
-		"subject":     m.Subject,
-		"htmlContent": m.Body,
-	})
-	responseBody := bytes.NewBuffer(postBody)
-	req, err := http.NewRequest(http.MethodPost, "https://api.brevo.com/v3/smtp/email", responseBody)
-	if err != nil {
-		return err
-	}
-
-	req.Header = http.Header{
-		"accept":       {"application/json"},
-		"api-key":      {Config.SENDINBLUE_API_KEY},
-		"content-type": {"application/json"},
-	}
-
-	_, err = http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func mailSendBySmtp(m *models.Mail) error {
+// Creates the MIME message used by the SMTP and file providers
+func mailBuildMsg(m *models.Mail) (*gomail.Msg, error) {
 	gm := gomail.NewMsg()
 
 	from := mail.Address{
@@ -123,10 +153,65 @@ func mailSendBySmtp(m *models.Mail) error {
 		Address: m.ToAddress,
 	}
 
-	gm.From(from.String())
-	gm.AddTo(to.String())
+	if err := gm.From(from.String()); err != nil {
+		return nil, err
+	}
+	if err := gm.AddTo(to.String()); err != nil {
+		return nil, err
+	}
+	if m.ReplyToAddress != "" {
+		replyTo := mail.Address{
+			Name:    m.ReplyToName,
+			Address: m.ReplyToAddress,
+		}
+		if err := gm.ReplyTo(replyTo.String()); err != nil {
+			return nil, err
+		}
+	}
 	gm.Subject(m.Subject)
-	gm.SetBodyString(gomail.TypeTextHTML, m.Body)
+	if m.BodyText != "" {
+		gm.SetBodyString(gomail.TypeTextPlain, m.BodyText)
+		gm.AddAlternativeString(gomail.TypeTextHTML, m.Body)
+	} else {
+		gm.SetBodyString(gomail.TypeTextHTML, m.Body)
+	}
+	for key, value := range mailHeaders(m) {
+		gm.SetGenHeader(gomail.Header(key), value)
+	}
+	gm.SetMessageID()
+	gm.SetDate()
+
+	return gm, nil
+}
+
+// Headers added by every mail provider
+func mailHeaders(m *models.Mail) map[string]string {
+	headers := map[string]string{}
+	if m.Category != "" {
+		// one-click unsubscribe, see RFC 8058
+		headers[string(gomail.HeaderListUnsubscribe)] = "<" + MailUnsubscribeURL(m.ToAddress, m.Category) + ">"
+		headers[string(gomail.HeaderListUnsubscribePost)] = "List-Unsubscribe=One-Click"
+	}
+	return headers
+}
+
+// Link to unsubscribe from a kind of notification emails without logging in,
+// the signature prevents unsubscribing someone else
+func MailUnsubscribeURL(email, category string) string {
+	query := url.Values{
+		"email":    {email},
+		"category": {category},
+		"sig":      {mailUnsubscribeSig(email, category)},
+	}
+	return fmt.Sprintf("%s/v2/mail/unsubscribe?%s", Config.SITE_BASE_URL_API, query.Encode())
+}
+
+func MailUnsubscribeVerify(email, category, sig string) bool {
+	return hmac.Equal([]byte(sig), []byte(mailUnsubscribeSig(email, category)))
+}
 
-	return smtpClient.DialAndSend(gm)
+func mailUnsubscribeSig(email, category string) string {
+	h := hmac.New(sha256.New, []byte(Config.JWT_SECRET))
+	h.Write([]byte("mail_unsubscribe\n" + strings.ToLower(email) + "\n" + category))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
 }
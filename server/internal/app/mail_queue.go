@@ -0,0 +1,240 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	mailQueuePollInterval = 15 * time.Second
+	// A claimed email is picked up again after this time, in case the server stopped while sending it
+	mailQueueLockDuration = 5 * time.Minute
+
+	mailBackoffBase = 30 * time.Second
+	mailBackoffMax  = 6 * time.Hour
+)
+
+var ErrMailSuppressed = errors.New("Address is suppressed after a hard bounce")
+
+// Called once an email is given up on, set by the server to notify the root admin
+var MailOnDead func(db *gorm.DB, m *models.Mail)
+
+var mailQueue *mailQueueRunner
+
+type mailQueueRunner struct {
+	db     *gorm.DB
+	jobs   chan *models.Mail
+	wakeup chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Starts the workers that send queued emails in the background
+func MailQueueStart(db *gorm.DB, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &mailQueueRunner{
+		db:     db,
+		jobs:   make(chan *models.Mail, workers),
+		wakeup: make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	q.wg.Add(workers)
+	for range workers {
+		go q.work()
+	}
+	go q.dispatch()
+
+	mailQueue = q
+}
+
+// Stops claiming new emails and waits for the emails that are being sent.
+// Emails queued after this are sent the next time the queue is started.
+func MailQueueStop(ctx context.Context) error {
+	q := mailQueue
+	if q == nil {
+		return nil
+	}
+	q.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *mailQueueRunner) wake() {
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (q *mailQueueRunner) dispatch() {
+	defer close(q.jobs)
+
+	ticker := time.NewTicker(mailQueuePollInterval)
+	defer ticker.Stop()
+	for {
+		q.claim()
+
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-q.wakeup:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Hands out due emails until the workers are busy or the queue is empty
+func (q *mailQueueRunner) claim() {
+	for q.ctx.Err() == nil {
+		free := cap(q.jobs) - len(q.jobs)
+		if free <= 0 {
+			return
+		}
+
+		mails, err := models.MailClaimDue(q.db, uuid.NewV4().String(), free, mailQueueLockDuration)
+		if err != nil {
+			slog.Error("Unable to claim queued emails", "err", err)
+			return
+		}
+		for _, m := range mails {
+			select {
+			case q.jobs <- m:
+			case <-q.ctx.Done():
+				m.Release(q.db)
+			}
+		}
+		if len(mails) < free {
+			return
+		}
+	}
+}
+
+func (q *mailQueueRunner) work() {
+	defer q.wg.Done()
+	for m := range q.jobs {
+		if q.ctx.Err() != nil {
+			m.Release(q.db)
+			continue
+		}
+		mailProcess(q.ctx, q.db, m)
+	}
+}
+
+// Sends the email and stores the result, failed emails are scheduled for another attempt
+func mailProcess(ctx context.Context, db *gorm.DB, m *models.Mail) error {
+	if !m.IgnoreSuppression && models.MailSuppressionExists(db, m.ToAddress) {
+		slog.Info("Not sending email to suppressed address", "id", m.ID)
+		if err := m.SetFailed(db, "", ErrMailSuppressed, 0); err != nil {
+			slog.Error("Unable to mark email as failed", "id", m.ID, "err", err)
+		}
+		return ErrMailSuppressed
+	}
+
+	provider, messageID, err := mailSendWithFailover(ctx, mailBackends, m)
+	if ctx.Err() != nil && provider == "" {
+		m.Release(db)
+		return ctx.Err()
+	}
+	if err == nil {
+		if err := m.SetSent(db, provider, messageID); err != nil {
+			slog.Error("Unable to mark email as sent", "id", m.ID, "err", err)
+		}
+		return nil
+	}
+
+	slog.Error("Unable to send email", "id", m.ID, "attempt", m.Attempts+1, "err", err)
+	retryIn := mailRetryIn(m, time.Now())
+	if errUpdate := m.SetFailed(db, provider, err, retryIn); errUpdate != nil {
+		slog.Error("Unable to mark email as failed", "id", m.ID, "err", errUpdate)
+	}
+
+	// the notification itself is sent to the sender address, never notify about it failing
+	if m.Status == models.MailStatusDead && MailOnDead != nil && m.ToAddress != Config.SMTP_SENDER {
+		MailOnDead(db, m)
+	}
+	return err
+}
+
+// Returns the time until the next attempt, or zero if the email should not be retried anymore
+func mailRetryIn(m *models.Mail, now time.Time) time.Duration {
+	attempts := m.Attempts + 1
+	if attempts >= models.MailMaxAttempts {
+		return 0
+	}
+	retryIn := mailBackoff(attempts)
+	if now.Add(retryIn).After(m.RetryDeadline()) {
+		return 0
+	}
+	return retryIn
+}
+
+// Exponential backoff with 20% jitter, so that emails failing together are not retried together
+func mailBackoff(attempts int) time.Duration {
+	d := mailBackoffBase
+	for i := 1; i < attempts && d < mailBackoffMax; i++ {
+		d *= 2
+	}
+	d = min(d, mailBackoffMax)
+
+	jitter := time.Duration((rand.Float64()*0.4 - 0.2) * float64(d))
+	return d + jitter
+}
+
+// Spreads out requests to a provider to at most one per interval
+type mailRateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newMailRateLimiter(perSecond int) *mailRateLimiter {
+	return &mailRateLimiter{interval: time.Second / time.Duration(perSecond)}
+}
+
+// Blocks until the next request is allowed
+func (l *mailRateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
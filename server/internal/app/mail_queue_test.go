@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+)
+
+func TestMailBackoff(t *testing.T) {
+	tests := []struct {
+		Attempts int
+		Expected time.Duration
+	}{
+		{Attempts: 1, Expected: 30 * time.Second},
+		{Attempts: 2, Expected: time.Minute},
+		{Attempts: 3, Expected: 2 * time.Minute},
+		{Attempts: 11, Expected: 6 * time.Hour},
+		{Attempts: 100, Expected: 6 * time.Hour},
+	}
+
+	for _, test := range tests {
+		d := mailBackoff(test.Attempts)
+		assert.GreaterOrEqual(t, d, test.Expected*8/10, test.Attempts)
+		assert.LessOrEqual(t, d, test.Expected*12/10, test.Attempts)
+	}
+}
+
+func TestMailRetryIn(t *testing.T) {
+	now := time.Now()
+
+	m := &models.Mail{MaxRetryAttempts: models.MAIL_RETRY_TWO_DAYS, CreatedAt: now}
+	assert.NotZero(t, mailRetryIn(m, now))
+
+	m.Attempts = models.MailMaxAttempts - 1
+	assert.Zero(t, mailRetryIn(m, now), "too many attempts")
+
+	m = &models.Mail{MaxRetryAttempts: models.MAIL_RETRY_SHORT, CreatedAt: now.Add(-14*time.Minute - 50*time.Second)}
+	assert.Zero(t, mailRetryIn(m, now), "after the retry window")
+}
+
+func TestMailRateLimiter(t *testing.T) {
+	l := newMailRateLimiter(20)
+
+	start := time.Now()
+	for range 3 {
+		err := l.Wait(context.Background())
+		assert.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := l.Wait(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
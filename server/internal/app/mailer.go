@@ -0,0 +1,344 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/pkg/dkim"
+	gomail "github.com/wneessen/go-mail"
+)
+
+const (
+	MailProviderBrevo = "brevo"
+	MailProviderSmtp  = "smtp"
+	MailProviderFile  = "file"
+)
+
+// After this many failures in a row a backend is tried last until the cooldown has passed
+const (
+	mailBackendMaxFailures = 3
+	mailBackendCooldown    = 5 * time.Minute
+)
+
+var (
+	ErrMailNoBackends = errors.New("No mail providers configured")
+	// The provider may have accepted the email before the error, for example on a timeout
+	ErrMailMaybeSent = errors.New("Mail provider may have accepted the email")
+)
+
+// Sends a single email, implemented by each mail provider
+type Mailer interface {
+	// Returns the message ID assigned to the email,
+	// errors that leave it unknown if the email was accepted wrap ErrMailMaybeSent
+	Send(ctx context.Context, m *models.Mail) (string, error)
+}
+
+// Statistics of a mail backend since the server started
+type MailBackendHealth struct {
+	Name                string     `json:"name"`
+	Priority            int        `json:"priority"`
+	Sent                int        `json:"sent"`
+	Failed              int        `json:"failed"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	AvgDurationMs       int64      `json:"avg_duration_ms"`
+	LastSentAt          *time.Time `json:"last_sent_at"`
+	LastFailedAt        *time.Time `json:"last_failed_at"`
+	LastError           string     `json:"last_error"`
+}
+
+type mailBackend struct {
+	name    string
+	mailer  Mailer
+	limiter *mailRateLimiter
+
+	mu            sync.Mutex
+	health        MailBackendHealth
+	totalDuration time.Duration
+}
+
+// In order of priority, set by MailInit
+var mailBackends []*mailBackend
+
+func newMailBackend(name string, priority int, mailer Mailer, perSecond int) *mailBackend {
+	return &mailBackend{
+		name:    name,
+		mailer:  mailer,
+		limiter: newMailRateLimiter(perSecond),
+		health:  MailBackendHealth{Name: name, Priority: priority},
+	}
+}
+
+func (b *mailBackend) record(err error, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.totalDuration += d
+	if err == nil {
+		b.health.Sent += 1
+		b.health.ConsecutiveFailures = 0
+		b.health.LastSentAt = &now
+	} else {
+		b.health.Failed += 1
+		b.health.ConsecutiveFailures += 1
+		b.health.LastFailedAt = &now
+		b.health.LastError = err.Error()
+	}
+	b.health.AvgDurationMs = b.totalDuration.Milliseconds() / int64(b.health.Sent+b.health.Failed)
+}
+
+func (b *mailBackend) isCoolingDown(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.health.ConsecutiveFailures >= mailBackendMaxFailures &&
+		b.health.LastFailedAt != nil && now.Sub(*b.health.LastFailedAt) < mailBackendCooldown
+}
+
+// Returns the health of each configured mail backend in order of priority
+func MailHealth() []MailBackendHealth {
+	list := []MailBackendHealth{}
+	for _, b := range mailBackends {
+		b.mu.Lock()
+		list = append(list, b.health)
+		b.mu.Unlock()
+	}
+	return list
+}
+
+// Orders backends by priority, backends that keep failing are moved to the end
+func mailBackendsOrdered(backends []*mailBackend, now time.Time) []*mailBackend {
+	ordered := slices.Clone(backends)
+	slices.SortStableFunc(ordered, func(a, b *mailBackend) int {
+		aCool, bCool := a.isCoolingDown(now), b.isCoolingDown(now)
+		switch {
+		case aCool && !bCool:
+			return 1
+		case !aCool && bCool:
+			return -1
+		}
+		return 0
+	})
+	return ordered
+}
+
+// Tries each backend until one accepts the email.
+// When a backend may have accepted the email the next one is not tried, to not deliver it twice.
+//
+// Once a backend started sending it is not interrupted by ctx,
+// only the wait for the rate limit is.
+func mailSendWithFailover(ctx context.Context, backends []*mailBackend, m *models.Mail) (provider, messageID string, err error) {
+	if len(backends) == 0 {
+		return "", "", ErrMailNoBackends
+	}
+
+	errs := []error{}
+	for _, b := range mailBackendsOrdered(backends, time.Now()) {
+		if err := b.limiter.Wait(ctx); err != nil {
+			return "", "", err
+		}
+
+		start := time.Now()
+		messageID, err := b.mailer.Send(context.WithoutCancel(ctx), m)
+		b.record(err, time.Since(start))
+		if err == nil {
+			return b.name, messageID, nil
+		}
+
+		slog.Warn("Mail provider failed", "provider", b.name, "id", m.ID, "err", err)
+		errs = append(errs, fmt.Errorf("%s: %w", b.name, err))
+		provider = b.name
+		if errors.Is(err, ErrMailMaybeSent) {
+			break
+		}
+	}
+	return provider, "", errors.Join(errs...)
+}
+
+type brevoMailer struct {
+	apiKey string
+	client *http.Client
+}
+
+func newBrevoMailer(apiKey string) Mailer {
+	return &brevoMailer{
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Returns the message ID assigned by Brevo
+func (b *brevoMailer) Send(ctx context.Context, m *models.Mail) (string, error) {
+	payload := map[string]any{
+		"sender": map[string]any{
+			"name":  m.SenderName,
+			"email": m.SenderAddress,
+		},
+		"to": []map[string]any{{
+			"name":  m.ToName,
+			"email": m.ToAddress,
+		}},
+		"subject":     m.Subject,
+		"htmlContent": m.Body,
+	}
+	if m.BodyText != "" {
+		payload["textContent"] = m.BodyText
+	}
+	if m.ReplyToAddress != "" {
+		payload["replyTo"] = map[string]any{
+			"name":  m.ReplyToName,
+			"email": m.ReplyToAddress,
+		}
+	}
+	if headers := mailHeaders(m); len(headers) > 0 {
+		payload["headers"] = headers
+	}
+	postBody, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.brevo.com/v3/smtp/email", bytes.NewBuffer(postBody))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header = http.Header{
+		"accept":       {"application/json"},
+		"api-key":      {b.apiKey},
+		"content-type": {"application/json"},
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		// only when no connection was made the email has certainly not reached brevo
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %w", ErrMailMaybeSent, err)
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode >= 300 {
+		return "", fmt.Errorf("brevo responded with status %d: %s", res.StatusCode, string(body))
+	}
+
+	var resBody struct {
+		MessageID string `json:"messageId"`
+	}
+	err = json.Unmarshal(body, &resBody)
+	if err != nil {
+		slog.Warn("Unable to read message id from brevo response", "err", err)
+	}
+	return resBody.MessageID, nil
+}
+
+type smtpMailer struct {
+	client *gomail.Client
+	// nil when emails are sent unsigned
+	dkim *dkim.Signer
+}
+
+// Without a password the connection is made without TLS and authentication, as used by mailpit
+func newSmtpMailer(host string, port int, user, pass string, signer *dkim.Signer) (Mailer, error) {
+	client, err := gomail.NewClient(host, gomail.WithPort(port), gomail.WithTimeout(30*time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	if pass == "" {
+		client.SetTLSPolicy(gomail.NoTLS)
+	} else {
+		client.SetSMTPAuth(gomail.SMTPAuthPlain)
+		client.SetUsername(user)
+		client.SetPassword(pass)
+	}
+	return &smtpMailer{client, signer}, nil
+}
+
+// Returns the generated Message-ID header
+func (s *smtpMailer) Send(ctx context.Context, m *models.Mail) (string, error) {
+	gm, err := mailBuildMsg(m)
+	if err != nil {
+		return "", err
+	}
+	if s.dkim != nil {
+		err = mailSignDkim(gm, s.dkim)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = s.client.DialAndSendWithContext(ctx, gm)
+	var sendErr *gomail.SendError
+	switch {
+	case err == nil:
+	case errors.As(err, &sendErr) && sendErr.Reason == gomail.ErrSMTPDataClose:
+		// the reply to the end of the data is missing or a rejection, it can not be told apart from here
+		return "", fmt.Errorf("%w: %w", ErrMailMaybeSent, err)
+	case gm.IsDelivered():
+		slog.Warn("Email accepted, but the smtp connection was not closed properly", "err", err)
+	default:
+		return "", err
+	}
+	return strings.Join(gm.GetGenHeader(gomail.HeaderMessageID), ""), nil
+}
+
+// Adds the DKIM-Signature header.
+// The message is written out once to sign it, so the multipart boundary is fixed beforehand
+// to have the same message written out again when sending.
+func mailSignDkim(gm *gomail.Msg, signer *dkim.Signer) error {
+	gm.SetBoundary(strings.ReplaceAll(uuid.NewV4().String(), "-", ""))
+
+	buf := bytes.Buffer{}
+	_, err := gm.WriteTo(&buf)
+	if err != nil {
+		return err
+	}
+	value, err := signer.Sign(buf.Bytes(), time.Now())
+	if err != nil {
+		return err
+	}
+	gm.SetGenHeaderPreformatted("DKIM-Signature", value)
+	return nil
+}
+
+// Writes each email as an .eml file to a directory, for developing without a mail server
+type fileMailer struct {
+	dir string
+}
+
+func newFileMailer(dir string) (Mailer, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &fileMailer{dir}, nil
+}
+
+func (f *fileMailer) Send(ctx context.Context, m *models.Mail) (string, error) {
+	gm, err := mailBuildMsg(m)
+	if err != nil {
+		return "", err
+	}
+
+	name := filepath.Join(f.dir, fmt.Sprintf("%d-%d.eml", time.Now().UnixNano(), m.ID))
+	err = gm.WriteToFile(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(gm.GetGenHeader(gomail.HeaderMessageID), ""), nil
+}
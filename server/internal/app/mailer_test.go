@@ -0,0 +1,273 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/pkg/dkim"
+)
+
+type mailerFailing struct{}
+
+func (mailerFailing) Send(ctx context.Context, m *models.Mail) (string, error) {
+	return "", errors.New("provider is down")
+}
+
+// Accepts every email and passes the DATA section to the returned channel
+func startSmtpStub(t *testing.T) (port int, received chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	received = make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				conn.Write([]byte("220 localhost ESMTP\r\n"))
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					cmd := strings.ToUpper(strings.TrimSpace(line))
+					switch {
+					case strings.HasPrefix(cmd, "EHLO"):
+						conn.Write([]byte("250-localhost\r\n250 8BITMIME\r\n"))
+					case cmd == "DATA":
+						conn.Write([]byte("354 End data with <CR><LF>.<CR><LF>\r\n"))
+						data := strings.Builder{}
+						for {
+							line, err := r.ReadString('\n')
+							if err != nil || line == ".\r\n" {
+								break
+							}
+							data.WriteString(strings.TrimPrefix(line, "."))
+						}
+						received <- data.String()
+						conn.Write([]byte("250 OK\r\n"))
+					case cmd == "QUIT":
+						conn.Write([]byte("221 Bye\r\n"))
+						return
+					default:
+						conn.Write([]byte("250 OK\r\n"))
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return l.Addr().(*net.TCPAddr).Port, received
+}
+
+func testMail() *models.Mail {
+	return &models.Mail{
+		ID:            1,
+		SenderName:    "The Clothing Loop",
+		SenderAddress: "sender@example.com",
+		ToName:        "Receiver",
+		ToAddress:     "receiver@example.com",
+		Subject:       "Test subject",
+		Body:          "<p>Test body</p>",
+	}
+}
+
+func TestMailSendWithFailover(t *testing.T) {
+	port, received := startSmtpStub(t)
+	smtp, err := newSmtpMailer("127.0.0.1", port, "", "", nil)
+	assert.NoError(t, err)
+
+	failing := newMailBackend("failing", 0, mailerFailing{}, 100)
+	backends := []*mailBackend{
+		failing,
+		newMailBackend(MailProviderSmtp, 1, smtp, 100),
+	}
+
+	provider, messageID, err := mailSendWithFailover(context.Background(), backends, testMail())
+	assert.NoError(t, err)
+	assert.Equal(t, MailProviderSmtp, provider)
+	assert.NotEmpty(t, messageID)
+
+	select {
+	case data := <-received:
+		assert.Contains(t, data, "Subject: Test subject")
+	case <-time.After(5 * time.Second):
+		t.Fatal("smtp stub did not receive the email")
+	}
+
+	assert.Equal(t, 1, failing.health.Failed)
+	assert.Equal(t, 1, failing.health.ConsecutiveFailures)
+	assert.Equal(t, 1, backends[1].health.Sent)
+}
+
+func TestMailSendWithFailoverAllFailing(t *testing.T) {
+	backends := []*mailBackend{
+		newMailBackend("a", 0, mailerFailing{}, 100),
+		newMailBackend("b", 1, mailerFailing{}, 100),
+	}
+
+	provider, _, err := mailSendWithFailover(context.Background(), backends, testMail())
+	assert.Error(t, err)
+	assert.Equal(t, "b", provider)
+
+	_, _, err = mailSendWithFailover(context.Background(), []*mailBackend{}, testMail())
+	assert.ErrorIs(t, err, ErrMailNoBackends)
+}
+
+type mailerTimeout struct{}
+
+func (mailerTimeout) Send(ctx context.Context, m *models.Mail) (string, error) {
+	return "", fmt.Errorf("%w: timeout awaiting response headers", ErrMailMaybeSent)
+}
+
+func TestMailSendWithFailoverMaybeSent(t *testing.T) {
+	next := newMailBackend("b", 1, mailerFailing{}, 100)
+	backends := []*mailBackend{
+		newMailBackend("a", 0, mailerTimeout{}, 100),
+		next,
+	}
+
+	provider, _, err := mailSendWithFailover(context.Background(), backends, testMail())
+	assert.ErrorIs(t, err, ErrMailMaybeSent)
+	assert.Equal(t, "a", provider)
+	assert.Zero(t, next.health.Failed, "the email may have been sent, so the next provider is not tried")
+}
+
+func TestMailProviders(t *testing.T) {
+	config := Config
+	t.Cleanup(func() { Config = config })
+
+	Config.MAIL_PROVIDERS = ""
+	Config.SENDINBLUE_API_KEY = ""
+	assert.Equal(t, []string{MailProviderSmtp}, mailProviders())
+
+	Config.SENDINBLUE_API_KEY = "key"
+	assert.Equal(t, []string{MailProviderBrevo}, mailProviders(), "failover is only used when configured")
+
+	Config.MAIL_PROVIDERS = "brevo, smtp"
+	assert.Equal(t, []string{MailProviderBrevo, MailProviderSmtp}, mailProviders())
+}
+
+func TestMailBackendsOrdered(t *testing.T) {
+	a := newMailBackend("a", 0, mailerFailing{}, 100)
+	b := newMailBackend("b", 1, mailerFailing{}, 100)
+	for range mailBackendMaxFailures {
+		a.record(errors.New("provider is down"), time.Millisecond)
+	}
+
+	now := time.Now()
+	ordered := mailBackendsOrdered([]*mailBackend{a, b}, now)
+	assert.Equal(t, []*mailBackend{b, a}, ordered)
+
+	ordered = mailBackendsOrdered([]*mailBackend{a, b}, now.Add(mailBackendCooldown))
+	assert.Equal(t, []*mailBackend{a, b}, ordered, "after the cooldown the priority is restored")
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	mailer, err := newFileMailer(dir)
+	assert.NoError(t, err)
+
+	messageID, err := mailer.Send(context.Background(), testMail())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, messageID)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if assert.Len(t, files, 1) {
+		b, _ := os.ReadFile(files[0])
+		assert.Contains(t, string(b), "Subject: Test subject")
+	}
+}
+
+func TestMailBuildMsg(t *testing.T) {
+	m := testMail()
+	m.BodyText = "Test body"
+	m.ReplyToName = "Anna"
+	m.ReplyToAddress = "anna@example.com"
+	m.Category = models.NotificationKindJoinRequest
+
+	gm, err := mailBuildMsg(m)
+	assert.NoError(t, err)
+	buf := strings.Builder{}
+	_, err = gm.WriteTo(&buf)
+	assert.NoError(t, err)
+	// unfold long headers
+	data := strings.ReplaceAll(buf.String(), "\r\n ", " ")
+
+	assert.Contains(t, data, "Content-Type: multipart/alternative")
+	assert.Contains(t, data, "Content-Type: text/plain")
+	assert.Contains(t, data, "Content-Type: text/html")
+	assert.Contains(t, data, `Reply-To: "Anna" <anna@example.com>`)
+	assert.Contains(t, data, "List-Unsubscribe: <"+MailUnsubscribeURL(m.ToAddress, m.Category)+">")
+	assert.Contains(t, data, "List-Unsubscribe-Post: List-Unsubscribe=One-Click")
+
+	t.Run("transactional emails have no unsubscribe headers", func(t *testing.T) {
+		gm, err := mailBuildMsg(testMail())
+		assert.NoError(t, err)
+		buf := strings.Builder{}
+		gm.WriteTo(&buf)
+		assert.NotContains(t, buf.String(), "List-Unsubscribe")
+		assert.NotContains(t, buf.String(), "multipart/alternative")
+	})
+}
+
+func TestMailUnsubscribeVerify(t *testing.T) {
+	Config.JWT_SECRET = "secret"
+	link := MailUnsubscribeURL("anna@example.com", models.NotificationKindJoinRequest)
+	assert.Contains(t, link, "/v2/mail/unsubscribe?")
+
+	sig := mailUnsubscribeSig("anna@example.com", models.NotificationKindJoinRequest)
+	assert.Contains(t, link, "sig="+sig)
+	assert.True(t, MailUnsubscribeVerify("anna@example.com", models.NotificationKindJoinRequest, sig))
+	assert.False(t, MailUnsubscribeVerify("bob@example.com", models.NotificationKindJoinRequest, sig))
+	assert.False(t, MailUnsubscribeVerify("anna@example.com", models.NotificationKindHostRecruitment, sig))
+}
+
+func TestSmtpMailerDkim(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	signer, err := dkim.NewSigner("example.com", "mail", string(keyPEM))
+	assert.NoError(t, err)
+
+	port, received := startSmtpStub(t)
+	smtp, err := newSmtpMailer("127.0.0.1", port, "", "", signer)
+	assert.NoError(t, err)
+
+	m := testMail()
+	m.BodyText = "Test body"
+	m.Category = models.NotificationKindJoinRequest
+	_, err = smtp.Send(context.Background(), m)
+	assert.NoError(t, err)
+
+	select {
+	case data := <-received:
+		assert.Contains(t, data, "DKIM-Signature: v=1;")
+		assert.NoError(t, dkim.Verify([]byte(data), &key.PublicKey), "signature should match the sent email")
+	case <-time.After(5 * time.Second):
+		t.Fatal("smtp stub did not receive the email")
+	}
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+const OidcMattermostClientID = "mattermost"
+
+type OidcClient struct {
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURIs []string `yaml:"redirect_uris"`
+	// Return the chat username based email address instead of the real email address
+	HideEmail bool `yaml:"hide_email"`
+}
+
+type OidcJwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+var OidcPrivateKey *rsa.PrivateKey
+var OidcKeyID string
+
+var ErrOidcClientNotFound = errors.New("Unknown client")
+
+func OidcInit() error {
+	var err error
+	if Config.OIDC_PRIVATE_KEY == "" {
+		if Config.ENV == EnvEnumProduction {
+			slog.Warn("oidc_private_key is not set, sessions of OpenID Connect clients end on restart")
+		}
+		OidcPrivateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return err
+		}
+	} else {
+		OidcPrivateKey, err = oidcParsePrivateKey(Config.OIDC_PRIVATE_KEY)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the key id is derived from the public key so that it only changes with the key
+	h := sha256.Sum256(OidcPrivateKey.PublicKey.N.Bytes())
+	OidcKeyID = base64.RawURLEncoding.EncodeToString(h[:8])
+	return nil
+}
+
+func oidcParsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, fmt.Errorf("oidc private key is not in pem format")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("oidc private key must be an rsa key")
+	}
+	return rsaKey, nil
+}
+
+func OidcIssuer() string {
+	return strings.TrimSuffix(Config.SITE_BASE_URL_API, "/")
+}
+
+func OidcJwks() []OidcJwk {
+	if OidcPrivateKey == nil {
+		return []OidcJwk{}
+	}
+	pub := OidcPrivateKey.PublicKey
+	return []OidcJwk{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: OidcKeyID,
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}
+}
+
+// Mattermost is registered as a client automatically when it is configured
+func OidcClients() []OidcClient {
+	clients := Config.OIDC_CLIENTS
+	if Config.MM_URL != "" && Config.MM_OIDC_CLIENT_SECRET != "" {
+		clients = append(clients, OidcClient{
+			ClientID:     OidcMattermostClientID,
+			ClientSecret: Config.MM_OIDC_CLIENT_SECRET,
+			RedirectURIs: []string{strings.TrimSuffix(Config.MM_URL, "/") + "/signup/gitlab/complete"},
+			HideEmail:    true,
+		})
+	}
+	return clients
+}
+
+func OidcClientGet(clientID string) (*OidcClient, error) {
+	client, ok := lo.Find(OidcClients(), func(c OidcClient) bool {
+		return c.ClientID == clientID
+	})
+	if !ok {
+		return nil, ErrOidcClientNotFound
+	}
+	return &client, nil
+}
+
+func (c *OidcClient) HasRedirectURI(uri string) bool {
+	return lo.Contains(c.RedirectURIs, uri)
+}
+
+func (c *OidcClient) CheckSecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(c.ClientSecret), []byte(secret)) == 1
+}
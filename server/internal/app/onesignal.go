@@ -3,16 +3,18 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
+	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/OneSignal/onesignal-go-api"
-	"github.com/cdfmlr/ellipsis"
 	"github.com/samber/lo"
 	uuid "github.com/satori/go.uuid"
+	"github.com/the-clothing-loop/website/server/internal/models"
 	"gorm.io/gorm"
 )
 
@@ -31,8 +33,6 @@ func (e OneSignalErrorResponse) GetInvalidExternalUserIds() []string {
 		if k == "invalid_external_user_ids" {
 			s := oneSignalReErr.FindString(v)
 			arr := []string{}
-			fmt.Printf("%v: %v\n", k, v)
-			fmt.Printf("%s", s)
 			json.Unmarshal([]byte(s), &arr)
 			return arr
 		}
@@ -51,35 +51,105 @@ func OneSignalInit() {
 	OneSignalClient = onesignal.NewAPIClient(configuration)
 }
 
-func oneSignalGetAuth() context.Context {
-	return context.WithValue(context.Background(), onesignal.AppAuth, Config.ONESIGNAL_REST_API_KEY)
+const notificationUserLimit = 15
+
+// Attempts per chunk of users, only transient errors are retried
+const oneSignalMaxAttempts = 3
+
+// Wait before the next attempt, multiplied by the amount of attempts so far
+var oneSignalRetryDelay = 2 * time.Second
+
+// The outcome of sending one chunk of users
+type oneSignalResult struct {
+	NotificationID string
+	Recipients     int
+	// Users without a valid subscription
+	InvalidUserUIDs []string
+	Attempts        int
+	Err             error
 }
 
-const notificationUserLimit = 15
+// Sends one chunk of users, transient is true when the error is worth another attempt.
+// The externalID is the same for every attempt, OneSignal uses it as idempotency key
+// so a retry of a notification it already accepted is not sent twice.
+type oneSignalSendFunc func(ctx context.Context, externalID string, userUIDs []string, title, content onesignal.StringMap) (res oneSignalResult, transient bool)
+
+// Sends push notifications to the apps through OneSignal, users are identified by their uid
+type oneSignalPusher struct{}
+
+func (*oneSignalPusher) Name() string { return "onesignal" }
 
-// Chunk notifications
-func OneSignalCreateNotification(db *gorm.DB, userUIDs []string, notificationTitle, notificationContent onesignal.StringMap) error {
+// Every chunk is sent even when an earlier one failed, the devices of invalid users are removed
+// and each chunk is logged as a PushDelivery
+func (p *oneSignalPusher) Push(ctx context.Context, db *gorm.DB, userUIDs []string, n PushNotification) error {
 	if OneSignalClient == nil {
-		slog.Info("Send onesignal notification", "userids", userUIDs, "title", *notificationTitle.En)
-		return nil
+		return fmt.Errorf("OneSignal is not initialized")
 	}
-	lenUserUIDs := len(userUIDs)
-	if lenUserUIDs == 0 {
-		return fmt.Errorf("No users to send a notification to")
+
+	// the receivers are selected by language, so the English field is used for all languages
+	title := onesignal.StringMap{En: onesignal.PtrString(n.Title)}
+	content := onesignal.StringMap{}
+	if n.Content != "" {
+		content.En = onesignal.PtrString(n.Content)
+	}
+
+	errs := []error{}
+	for _, userUIDs := range lo.Chunk(userUIDs, notificationUserLimit) {
+		res := oneSignalDeliver(ctx, oneSignalCreateNotificationSend, userUIDs, title, content)
+
+		if len(res.InvalidUserUIDs) > 0 {
+			err := models.UserOnesignalDeleteByUserUIDs(db, res.InvalidUserUIDs)
+			if err != nil {
+				slog.Error("Unable to remove invalid onesignal users", "err", err)
+			}
+		}
+
+		delivery := &models.PushDelivery{
+			Provider:       p.Name(),
+			NotificationID: res.NotificationID,
+			Title:          n.Title,
+			UserCount:      len(userUIDs),
+			Recipients:     res.Recipients,
+			InvalidCount:   len(res.InvalidUserUIDs),
+			Attempts:       res.Attempts,
+		}
+		if res.Err != nil {
+			delivery.Error = res.Err.Error()
+			errs = append(errs, res.Err)
+		}
+		if err := models.PushDeliveryAdd(db, delivery); err != nil {
+			slog.Error("Unable to log push delivery", "err", err)
+		}
 	}
-	userUIDchunks := lo.Chunk(userUIDs, notificationUserLimit)
+	return errors.Join(errs...)
+}
 
-	for _, userUIDs := range userUIDchunks {
-		err := oneSignalCreateNotificationSend(userUIDs, notificationTitle, notificationContent)
-		if err != nil {
-			return err
+// Sends one chunk of users, retrying transient errors with an increasing delay
+func oneSignalDeliver(ctx context.Context, send oneSignalSendFunc, userUIDs []string, title, content onesignal.StringMap) oneSignalResult {
+	var res oneSignalResult
+	externalID := uuid.NewV4().String()
+	for attempt := 1; attempt <= oneSignalMaxAttempts; attempt++ {
+		var transient bool
+		res, transient = send(ctx, externalID, userUIDs, title, content)
+		res.Attempts = attempt
+		if res.Err == nil || !transient || attempt == oneSignalMaxAttempts {
+			break
+		}
+
+		slog.Warn("Retry sending onesignal notification", "attempt", attempt, "err", res.Err)
+		select {
+		case <-ctx.Done():
+			res.Err = errors.Join(res.Err, ctx.Err())
+			return res
+		case <-time.After(oneSignalRetryDelay * time.Duration(attempt)):
 		}
 	}
-	return nil
+	return res
 }
-func oneSignalCreateNotificationSend(userUIDs []string, notificationTitle, notificationContent onesignal.StringMap) error {
+
+func oneSignalCreateNotificationSend(ctx context.Context, externalID string, userUIDs []string, notificationTitle, notificationContent onesignal.StringMap) (oneSignalResult, bool) {
 	notification := onesignal.NewNotification(Config.ONESIGNAL_APP_ID)
-	notification.SetId(uuid.NewV4().String())
+	notification.SetExternalId(externalID)
 	notification.SetIncludeExternalUserIds(userUIDs)
 	notification.SetIsAndroid(true)
 	notification.SetIsIos(true)
@@ -90,25 +160,42 @@ func oneSignalCreateNotificationSend(userUIDs []string, notificationTitle, notif
 		"user_uids": strings.Join(userUIDs, ","),
 	})
 
-	auth := oneSignalGetAuth()
-	_, resp, err := OneSignalClient.DefaultApi.CreateNotification(auth).Notification(*notification).Execute()
+	auth := context.WithValue(ctx, onesignal.AppAuth, Config.ONESIGNAL_REST_API_KEY)
+	resp, httpResp, err := OneSignalClient.DefaultApi.CreateNotification(auth).Notification(*notification).Execute()
 	if err != nil {
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
-		fmt.Printf("response error: %s\n", string(body))
-		slog.Error("Unable to send notification", "err", err)
-		return err
+		res := oneSignalResult{Err: err}
+		var apiErr *onesignal.GenericOpenAPIError
+		if errors.As(err, &apiErr) {
+			res.InvalidUserUIDs = oneSignalParseErrorBody(apiErr.Body())
+			res.Err = fmt.Errorf("%w: %s", err, apiErr.Body())
+		}
+		// without a response the request did not reach OneSignal
+		return res, httpResp == nil || oneSignalIsTransientStatus(httpResp.StatusCode)
 	}
-	return nil
+
+	return oneSignalParseSuccessResponse(resp), false
 }
 
-func OneSignalEllipsisContent(content string) onesignal.StringMap {
-	if content == "" {
-		return onesignal.StringMap{}
+func oneSignalParseSuccessResponse(resp *onesignal.CreateNotificationSuccessResponse) oneSignalResult {
+	res := oneSignalResult{
+		NotificationID: resp.Id,
+		Recipients:     int(resp.Recipients),
+	}
+	if resp.Errors != nil && resp.Errors.InvalidIdentifierError != nil {
+		res.InvalidUserUIDs = resp.Errors.InvalidIdentifierError.InvalidExternalUserIds
 	}
+	return res
+}
 
-	content = ellipsis.Ending(content, 15)
-	return onesignal.StringMap{
-		En: onesignal.PtrString(content),
+func oneSignalParseErrorBody(body []byte) []string {
+	var e OneSignalErrorResponse
+	if err := json.Unmarshal(body, &e); err != nil {
+		return []string{}
 	}
+	return e.GetInvalidExternalUserIds()
+}
+
+// Rate limits and server errors may succeed on a later attempt
+func oneSignalIsTransientStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
 }
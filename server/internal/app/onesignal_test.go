@@ -1,10 +1,15 @@
 package app
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/OneSignal/onesignal-go-api"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,3 +38,72 @@ func TestOneSignalCreateNotificationCheckByteSize(t *testing.T) {
 	str := strings.Join(arr, ",")
 	assert.Greater(t, len([]byte(str)), 1800)
 }
+
+func TestOneSignalDeliverRetries(t *testing.T) {
+	defer func(d time.Duration) { oneSignalRetryDelay = d }(oneSignalRetryDelay)
+	oneSignalRetryDelay = 0
+
+	t.Run("transient errors are retried", func(t *testing.T) {
+		calls := 0
+		ids := []string{}
+		res := oneSignalDeliver(context.Background(), func(ctx context.Context, externalID string, userUIDs []string, title, content onesignal.StringMap) (oneSignalResult, bool) {
+			calls++
+			ids = append(ids, externalID)
+			if calls < 2 {
+				return oneSignalResult{Err: errors.New("503 Service Unavailable")}, true
+			}
+			return oneSignalResult{NotificationID: "abc", Recipients: 2}, false
+		}, []string{"a", "b"}, onesignal.StringMap{}, onesignal.StringMap{})
+
+		assert.NoError(t, res.Err)
+		assert.Equal(t, 2, res.Attempts)
+		assert.Equal(t, "abc", res.NotificationID)
+		if assert.Len(t, ids, 2) {
+			assert.NotEmpty(t, ids[0])
+			assert.Equal(t, ids[0], ids[1], "a retry uses the same external id")
+		}
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		calls := 0
+		res := oneSignalDeliver(context.Background(), func(ctx context.Context, externalID string, userUIDs []string, title, content onesignal.StringMap) (oneSignalResult, bool) {
+			calls++
+			return oneSignalResult{Err: errors.New("timeout")}, true
+		}, []string{"a"}, onesignal.StringMap{}, onesignal.StringMap{})
+
+		assert.Error(t, res.Err)
+		assert.Equal(t, oneSignalMaxAttempts, calls)
+		assert.Equal(t, oneSignalMaxAttempts, res.Attempts)
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		calls := 0
+		res := oneSignalDeliver(context.Background(), func(ctx context.Context, externalID string, userUIDs []string, title, content onesignal.StringMap) (oneSignalResult, bool) {
+			calls++
+			return oneSignalResult{Err: errors.New("400 Bad Request")}, false
+		}, []string{"a"}, onesignal.StringMap{}, onesignal.StringMap{})
+
+		assert.Error(t, res.Err)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestOneSignalParseInvalidUserUIDs(t *testing.T) {
+	uids := []string{Faker.UUID().V4(), Faker.UUID().V4()}
+
+	resp := &onesignal.CreateNotificationSuccessResponse{}
+	err := json.Unmarshal([]byte(fmt.Sprintf(`{"id":"abc","recipients":1,"errors":{"invalid_external_user_ids":["%s","%s"]}}`, uids[0], uids[1])), resp)
+	assert.NoError(t, err)
+	res := oneSignalParseSuccessResponse(resp)
+	assert.Equal(t, "abc", res.NotificationID)
+	assert.Equal(t, 1, res.Recipients)
+	assert.Equal(t, uids, res.InvalidUserUIDs)
+
+	body := fmt.Sprintf(`{"errors":["Message Notifications must have English language content"],"warnings":{"invalid_external_user_ids":"The following external_ids have unsubscribed subscriptions attached: [\"%s\", \"%s\"]"}}`, uids[0], uids[1])
+	assert.Equal(t, uids, oneSignalParseErrorBody([]byte(body)))
+	assert.Empty(t, oneSignalParseErrorBody([]byte("not json")))
+
+	assert.True(t, oneSignalIsTransientStatus(429))
+	assert.True(t, oneSignalIsTransientStatus(502))
+	assert.False(t, oneSignalIsTransientStatus(400))
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// A push notification, already in the language of the receivers
+type PushNotification struct {
+	Title   string `json:"title"`
+	Content string `json:"content,omitempty"`
+}
+
+// Delivers push notifications through one provider
+type Pusher interface {
+	Name() string
+	// Sends to every device of the users that is registered with this provider
+	Push(ctx context.Context, db *gorm.DB, userUIDs []string, n PushNotification) error
+}
+
+// Set by PushInit
+var pushers []Pusher
+
+// Enables OneSignal for the apps and Web Push for the website, each when configured
+func PushInit() {
+	pushers = []Pusher{}
+
+	if Config.ONESIGNAL_APP_ID != "" && Config.ONESIGNAL_REST_API_KEY != "" {
+		OneSignalInit()
+		pushers = append(pushers, &oneSignalPusher{})
+	}
+
+	if Config.WEB_PUSH_VAPID_PRIVATE_KEY != "" {
+		p, err := newWebPushPusher(Config.WEB_PUSH_VAPID_PUBLIC_KEY, Config.WEB_PUSH_VAPID_PRIVATE_KEY, Config.WEB_PUSH_SUBJECT)
+		if err != nil {
+			slog.Error("Web Push is disabled", "err", err)
+		} else {
+			pushers = append(pushers, p)
+		}
+	}
+}
+
+// Sends a push notification to the users that receive this kind of notification by push.
+// chainID is the loop the notification is about, or zero.
+func PushCreateNotification(db *gorm.DB, kind string, chainID uint, userUIDs []string, n PushNotification) error {
+	if len(userUIDs) == 0 {
+		return fmt.Errorf("No users to send a notification to")
+	}
+	userUIDs, err := models.NotificationPreferenceFilterUserUIDs(db, userUIDs, chainID, kind, models.NotificationChannelPush)
+	if err != nil {
+		return err
+	}
+	if len(userUIDs) == 0 {
+		return nil
+	}
+	if len(pushers) == 0 {
+		slog.Info("Send push notification", "userids", userUIDs, "title", n.Title)
+		return nil
+	}
+
+	errs := []error{}
+	for _, p := range pushers {
+		err := p.Push(context.Background(), db, userUIDs, n)
+		if err != nil {
+			slog.Error("Unable to send push notification", "pusher", p.Name(), "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sends the push notification in the background, so a slow provider and its retries do not delay the request
+func PushCreateNotificationAsync(db *gorm.DB, kind string, chainID uint, userUIDs []string, n PushNotification) {
+	go func() {
+		err := PushCreateNotification(db, kind, chainID, userUIDs, n)
+		if err != nil {
+			slog.Error("Unable to send push notification", "kind", kind, "err", err)
+		}
+	}()
+}
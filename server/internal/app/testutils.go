@@ -16,6 +16,9 @@ func RunTestMain(m *testing.M, dbP **gorm.DB, configPath string) {
 	MailpitRemoveAllEmails()
 	*dbP = DatabaseInit()
 	MailInit()
+	// tests do not need a Mattermost server
+	Config.CHAT_PROVIDER = ChatProviderBuiltin
+	ChatInit(*dbP)
 
 	code := m.Run()
 	os.Exit(code)
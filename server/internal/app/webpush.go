@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/pkg/webpush"
+	"gorm.io/gorm"
+)
+
+// How long the push service keeps trying to deliver a notification to an offline browser
+const webPushTTL = 24 * time.Hour
+
+// Sends push notifications to the website through Web Push, without a third party
+type webPushPusher struct {
+	vapid  *webpush.VAPID
+	client *http.Client
+}
+
+func newWebPushPusher(publicKey, privateKey, subject string) (*webPushPusher, error) {
+	vapid, err := webpush.NewVAPID(publicKey, privateKey, subject)
+	if err != nil {
+		return nil, err
+	}
+	return &webPushPusher{
+		vapid: vapid,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// push services do not redirect, following one could leave the allowed hosts
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// Returns the key browsers need to subscribe, empty when Web Push is disabled
+func WebPushPublicKey() string {
+	for _, p := range pushers {
+		if p, ok := p.(*webPushPusher); ok {
+			return p.vapid.PublicKey
+		}
+	}
+	return ""
+}
+
+func (*webPushPusher) Name() string { return "webpush" }
+
+// Expired subscriptions and those of unknown push services are removed, other failures are returned after trying every subscription
+func (p *webPushPusher) Push(ctx context.Context, db *gorm.DB, userUIDs []string, n PushNotification) error {
+	subs, err := models.UserWebPushSubscriptionGetByUserUIDs(db, userUIDs)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, sub := range subs {
+		err := p.vapid.Send(ctx, p.client, sub.Subscription(), payload, webPushTTL)
+		if errors.Is(err, webpush.ErrSubscriptionExpired) || errors.Is(err, webpush.ErrInvalidEndpoint) {
+			slog.Debug("Remove web push subscription", "user_id", sub.UserID, "err", err)
+			if err := models.UserWebPushSubscriptionDelete(db, sub.Endpoint); err != nil {
+				errs = append(errs, err)
+			}
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
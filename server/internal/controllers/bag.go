@@ -11,9 +11,9 @@ import (
 	"github.com/samber/lo"
 
 	"github.com/gin-gonic/gin"
-	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/internal/views"
 )
 
@@ -39,6 +39,7 @@ SELECT
 	bags.id            AS id,
 	bags.%snumber%s    AS %snumber%s,
 	bags.color         AS color,
+	bags.note          AS note,
 	bags.user_chain_id AS user_chain_id,
 	c.uid              AS chain_uid,
 	u.uid              AS user_uid,
@@ -71,6 +72,7 @@ func BagPut(c *gin.Context) {
 		HolderUID string     `json:"holder_uid" binding:"required,uuid"`
 		Number    *string    `json:"number,omitempty"`
 		Color     *string    `json:"color,omitempty"`
+		Note      *string    `json:"note,omitempty" binding:"omitempty,max=255"`
 		UpdatedAt *time.Time `json:"updated_at,omitempty"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -78,7 +80,7 @@ func BagPut(c *gin.Context) {
 		return
 	}
 
-	ok, authUser, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, body.ChainUID)
+	ok, authUser, chain := auth.Authorize(c, db, auth.ActionBagPassOn, body.ChainUID)
 	if !ok {
 		return
 	}
@@ -93,11 +95,9 @@ func BagPut(c *gin.Context) {
 		`, body.BagID, chain.ID).Scan(&bag)
 	}
 
-	// if authUser is not host user can only set the bag holder
-	_, isChainAdmin := authUser.IsPartOfChain(chain.UID)
-	if !isChainAdmin {
-		isAllowed := bag.ID != 0 && body.Number == nil && body.Color == nil
-		if !isAllowed {
+	// without the bag.edit permission the user can only set the bag holder
+	if bag.ID == 0 || body.Number != nil || body.Color != nil {
+		if !auth.HasPermission(db, authUser, chain, auth.ActionBagEdit) {
 			c.AbortWithError(401, fmt.Errorf("As participant you are not allowed to change the bag colour or name"))
 			return
 		}
@@ -110,7 +110,7 @@ func BagPut(c *gin.Context) {
 	db.Raw(`
 SELECT uc.id AS user_chain_id, u.email AS email FROM user_chains AS uc
 LEFT JOIN users AS u ON u.id = uc.user_id
-WHERE u.uid = ? AND uc.chain_id = ?
+WHERE u.uid = ? AND uc.chain_id = ? AND u.purge_at IS NULL
 LIMIT 1
 	`, body.HolderUID, chain.ID).Scan(&holder)
 	if holder.UserChainID == 0 {
@@ -125,6 +125,11 @@ LIMIT 1
 	if body.Color != nil {
 		bag.Color = *(body.Color)
 	}
+	// any member that can pass on the bag can describe what is in it
+	noteChanged := body.Note != nil && bag.Note != *(body.Note)
+	if body.Note != nil {
+		bag.Note = *(body.Note)
+	}
 	if body.UpdatedAt != nil {
 		bag.UpdatedAt = *(body.UpdatedAt)
 	} else if bag.ID == 0 || bag.UserChainID != holder.UserChainID {
@@ -152,13 +157,18 @@ LIMIT 1
 	}
 
 	if body.UserUID != body.HolderUID {
-		err := app.OneSignalCreateNotification(db, []string{body.HolderUID},
-			*views.Notifications[views.NotificationEnumTitleBagAssignedYou],
-			app.OneSignalEllipsisContent(bag.Number))
+		err := views.NotificationSend(db, models.NotificationKindBagAssigned, chain.ID, []string{body.HolderUID}, gin.H{
+			"BagNumber": bag.Number,
+			"ChainName": chain.Name,
+		})
 		if err != nil {
 			slog.Error("Notification creation failed", "err", err)
 		}
 	}
+
+	if noteChanged && bag.Note != "" {
+		services.WishMatchBag(db, chain, body.HolderUID, &bag)
+	}
 }
 
 func BagRemove(c *gin.Context) {
@@ -173,7 +183,7 @@ func BagRemove(c *gin.Context) {
 		return
 	}
 
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+	ok, _, chain := auth.Authorize(c, db, auth.ActionBagDelete, query.ChainUID)
 	if !ok {
 		return
 	}
@@ -215,7 +225,7 @@ func BagsHistory(c *gin.Context) {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+	ok, _, chain := auth.Authorize(c, db, auth.ActionBagHistory, query.ChainUID)
 	if !ok {
 		return
 	}
@@ -6,10 +6,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/samber/lo"
-	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 )
 
 func BulkyGetAll(c *gin.Context) {
@@ -17,37 +18,48 @@ func BulkyGetAll(c *gin.Context) {
 	var query struct {
 		UserUID  string `form:"user_uid" binding:"required,uuid"`
 		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		// Expired items are left out by default
+		Status   []string `form:"status" binding:"dive,oneof=available reserved given expired"`
+		Category string   `form:"category"`
+		Tag      string   `form:"tag"`
+		Sizes    []string `form:"sizes"`
+		Genders  []string `form:"genders"`
+		Search   string   `form:"search" binding:"max=200"`
 	}
 	if err := c.ShouldBindQuery(&query); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
+	if query.Category != "" && !models.ValidateAllBulkyItemCategories([]string{query.Category}) {
+		c.String(http.StatusBadRequest, models.ErrBulkyItemCategoryInvalid.Error())
+		return
+	}
+	if ok := models.ValidateAllSizeEnum(query.Sizes); !ok {
+		c.String(http.StatusBadRequest, models.ErrSizeInvalid.Error())
+		return
+	}
+	if ok := models.ValidateAllGenderEnum(query.Genders); !ok {
+		c.String(http.StatusBadRequest, models.ErrGenderInvalid.Error())
+		return
+	}
 
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, query.ChainUID)
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChainRead, query.ChainUID)
 	if !ok {
 		return
 	}
 
-	bulkyItems := []models.BulkyItem{}
-	err := db.Raw(`
-	SELECT 
-	bulky_items.id            AS id,
-	bulky_items.title         AS title,
-	bulky_items.message       AS message,
-	bulky_items.image_url     AS image_url,
-	bulky_items.user_chain_id AS user_chain_id,
-	c.uid               AS chain_uid,
-	u.uid               AS user_uid,
-	bulky_items.created_at    AS created_at
- FROM bulky_items
-LEFT JOIN user_chains AS uc ON uc.id = bulky_items.user_chain_id
-LEFT JOIN chains AS c ON c.id = uc.chain_id
-LEFT JOIN users AS u ON u.id = uc.user_id
-WHERE user_chain_id IN (
-	SELECT uc2.id FROM user_chains AS uc2
-	WHERE uc2.chain_id = ?
-)
-	`, chain.ID).Scan(&bulkyItems).Error
+	filter := models.BulkyItemFilter{
+		Statuses: query.Status,
+		Category: query.Category,
+		Tag:      query.Tag,
+		Sizes:    query.Sizes,
+		Genders:  query.Genders,
+		Search:   query.Search,
+	}
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = lo.Without(sharedtypes.BulkyItemStatuses, sharedtypes.BulkyItemStatusExpired)
+	}
+	bulkyItems, err := models.GetAllBulkyItemsByChain(db, chain.ID, filter)
 	if err != nil {
 		slog.Error("Unable to find bulky items", "err", err)
 		c.String(http.StatusInternalServerError, "Unable to find bulky items")
@@ -60,47 +72,52 @@ WHERE user_chain_id IN (
 func BulkyPut(c *gin.Context) {
 	db := getDB(c)
 	var body struct {
-		ID       uint    `json:"id,omitempty"`
-		UserUID  string  `json:"user_uid" binding:"required,uuid"`
-		ChainUID string  `json:"chain_uid" binding:"required,uuid"`
-		Title    *string `json:"title,omitempty"`
-		Message  *string `json:"message,omitempty"`
-		ImageUrl *string `json:"image_url,omitempty"`
+		ID       uint      `json:"id,omitempty"`
+		UserUID  string    `json:"user_uid" binding:"required,uuid"`
+		ChainUID string    `json:"chain_uid" binding:"required,uuid"`
+		Title    *string   `json:"title,omitempty"`
+		Message  *string   `json:"message,omitempty"`
+		ImageUrl *string   `json:"image_url,omitempty"`
+		Category *string   `json:"category,omitempty"`
+		Tags     *[]string `json:"tags,omitempty" binding:"omitempty,max=10,dive,max=30"`
+		Sizes    *[]string `json:"sizes,omitempty"`
+		Genders  *[]string `json:"genders,omitempty"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
-
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, body.ChainUID)
-	if !ok {
+	if body.Category != nil && !models.ValidateAllBulkyItemCategories([]string{*(body.Category)}) {
+		c.String(http.StatusBadRequest, models.ErrBulkyItemCategoryInvalid.Error())
 		return
 	}
-
-	// Create a notification
-	if isNew := body.ID == 0; isNew {
-		userUIDs := []string{}
-		db.Raw(`
-			SELECT u.uid
-			FROM users AS u
-			JOIN user_chains AS uc ON uc.user_id = u.id
-			JOIN chains AS c ON c.id = uc.chain_id
-			WHERE c.uid = ? AND u.uid != ? AND uc.is_approved = TRUE`, body.ChainUID, body.UserUID).Scan(&userUIDs)
-
-		if len(userUIDs) > 0 {
-			err := app.OneSignalCreateNotification(db, userUIDs,
-				*views.Notifications[views.NotificationEnumTitleNewBulkyCreated],
-				app.OneSignalEllipsisContent(lo.FromPtr(body.Title)))
-			if err != nil {
-				slog.Error(err.Error())
-			}
+	if body.Sizes != nil {
+		if ok := models.ValidateAllSizeEnum(*(body.Sizes)); !ok {
+			c.String(http.StatusBadRequest, models.ErrSizeInvalid.Error())
+			return
+		}
+	}
+	if body.Genders != nil {
+		if ok := models.ValidateAllGenderEnum(*(body.Genders)); !ok {
+			c.String(http.StatusBadRequest, models.ErrGenderInvalid.Error())
+			return
 		}
 	}
 
+	ok, _, chain := auth.Authorize(c, db, auth.ActionBulkyWrite, body.ChainUID)
+	if !ok {
+		return
+	}
+
 	// Set the bulkyItem object
 	bulkyItem := &models.BulkyItem{}
 	if body.ID != 0 {
-		db.Raw(`SELECT * FROM bulky_items WHERE id = ? LIMIT 1`, body.ID).Scan(bulkyItem)
+		var err error
+		bulkyItem, err = models.BulkyItemGetByIDInChain(db, chain.ID, body.ID)
+		if err != nil {
+			bulkyError(c, err)
+			return
+		}
 	}
 	if body.Title != nil {
 		bulkyItem.Title = *(body.Title)
@@ -111,6 +128,20 @@ func BulkyPut(c *gin.Context) {
 	if body.ImageUrl != nil {
 		bulkyItem.ImageUrl = *(body.ImageUrl)
 	}
+	if body.Category != nil {
+		bulkyItem.Category = *(body.Category)
+	} else if bulkyItem.Category == "" {
+		bulkyItem.Category = sharedtypes.BulkyItemCategoryOther
+	}
+	if body.Tags != nil {
+		bulkyItem.Tags = models.BulkyItemNormalizeTags(*(body.Tags))
+	}
+	if body.Sizes != nil {
+		bulkyItem.Sizes = *(body.Sizes)
+	}
+	if body.Genders != nil {
+		bulkyItem.Genders = *(body.Genders)
+	}
 
 	ucID := uint(0)
 	db.Raw(`
@@ -129,13 +160,43 @@ LIMIT 1
 	if bulkyItem.ID == 0 {
 		err = db.Create(bulkyItem).Error
 	} else {
-		err = db.Updates(*bulkyItem).Error
+		// the status and receiver are only changed through SetStatus, which checks they did not change in the meantime
+		err = db.Omit("status", "receiver_user_chain_id").Updates(*bulkyItem).Error
 	}
 	if err != nil {
 		slog.Error("Unable to create or update bulky item", "err", err)
 		c.String(http.StatusInternalServerError, "Unable to create or update bulky item")
 		return
 	}
+	if body.ImageUrl != nil {
+		err = models.ImageReferenceSet(db, models.ImageReferenceKindBulkyItem, bulkyItem.ID, bulkyItem.ImageUrl)
+		if err != nil {
+			slog.Error("Unable to set image reference of bulky item", "err", err)
+		}
+	}
+	if body.ID != 0 {
+		err = models.BulkyItemRenew(db, bulkyItem.ID)
+		if err != nil {
+			slog.Error("Unable to renew bulky item", "err", err)
+		}
+		return
+	}
+
+	// Notify the members interested in the category of the new item
+	userUIDs, err := models.BulkyItemSubscriberUIDs(db, chain.ID, body.UserUID, bulkyItem.Category)
+	if err != nil {
+		slog.Error("Unable to find members to notify of new bulky item", "err", err)
+	} else if len(userUIDs) > 0 {
+		err := views.NotificationSend(db, models.NotificationKindBulkyItemNew, chain.ID, userUIDs, gin.H{
+			"Title":     bulkyItem.Title,
+			"ChainName": chain.Name,
+		})
+		if err != nil {
+			slog.Error(err.Error())
+		}
+	}
+
+	services.WishMatchBulkyItem(db, chain, body.UserUID, bulkyItem)
 }
 
 func BulkyRemove(c *gin.Context) {
@@ -150,21 +211,165 @@ func BulkyRemove(c *gin.Context) {
 		return
 	}
 
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, query.ChainUID)
+	ok, _, chain := auth.Authorize(c, db, auth.ActionBulkyWrite, query.ChainUID)
 	if !ok {
 		return
 	}
 
-	err := db.Exec(`
+	res := db.Exec(`
 DELETE FROM bulky_items
 WHERE id = ? AND user_chain_id IN (
 	SELECT id FROM user_chains
 	WHERE chain_id = ?
 )
-	`, query.ID, chain.ID).Error
-	if err != nil {
-		slog.Error("Bulky Item could not be removed", "err", err)
+	`, query.ID, chain.ID)
+	if res.Error != nil {
+		slog.Error("Bulky Item could not be removed", "err", res.Error)
 		c.String(http.StatusInternalServerError, "Bulky Item could not be removed")
 		return
 	}
+	if res.RowsAffected > 0 {
+		err := models.ImageReferenceRemove(db, models.ImageReferenceKindBulkyItem, query.ID)
+		if err != nil {
+			slog.Error("Unable to remove image reference of bulky item", "err", err)
+		}
+	}
+}
+
+func BulkyReserve(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.BulkyItemReserveRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, chain := auth.Authorize(c, db, auth.ActionBulkyWrite, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	bulkyItem, err := models.BulkyItemGetByIDInChain(db, chain.ID, body.ID)
+	if err == nil {
+		err = services.BulkyItemReserve(db, chain, user, bulkyItem)
+	}
+	if err != nil {
+		bulkyError(c, err)
+		return
+	}
+}
+
+func BulkyUnreserve(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		ID       uint   `form:"id" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, chain := auth.Authorize(c, db, auth.ActionBulkyWrite, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	bulkyItem, err := models.BulkyItemGetByIDInChain(db, chain.ID, query.ID)
+	if err == nil {
+		err = services.BulkyItemUnreserve(db, user, bulkyItem)
+	}
+	if err != nil {
+		bulkyError(c, err)
+		return
+	}
+}
+
+func BulkyGiven(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.BulkyItemGivenRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, chain := auth.Authorize(c, db, auth.ActionBulkyWrite, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	bulkyItem, err := models.BulkyItemGetByIDInChain(db, chain.ID, body.ID)
+	if err == nil {
+		err = services.BulkyItemGive(db, chain, user, bulkyItem, body.ReceiverUID)
+	}
+	if err != nil {
+		bulkyError(c, err)
+		return
+	}
+}
+
+func BulkySubscriptionsGet(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, chain := auth.Authorize(c, db, auth.ActionChainRead, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	categories, err := models.BulkyItemSubscriptionGetAll(db, user.ID, chain.ID)
+	if err != nil {
+		slog.Error("Unable to find bulky item subscriptions", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to find bulky item subscriptions")
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+func BulkySubscriptionsPut(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.BulkyItemSubscriptionsRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if !models.ValidateAllBulkyItemCategories(body.Categories) {
+		c.String(http.StatusBadRequest, models.ErrBulkyItemCategoryInvalid.Error())
+		return
+	}
+
+	ok, user, chain := auth.Authorize(c, db, auth.ActionChainRead, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	err := models.BulkyItemSubscriptionSet(db, user.ID, chain.ID, body.Categories)
+	if err != nil {
+		slog.Error("Unable to set bulky item subscriptions", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to set bulky item subscriptions")
+		return
+	}
+}
+
+func bulkyError(c *gin.Context, err error) {
+	switch err {
+	case models.ErrBulkyItemNotFound:
+		c.String(http.StatusNotFound, err.Error())
+	case models.ErrUserNotFound, services.ErrBulkyItemNoReceiver:
+		c.String(http.StatusBadRequest, err.Error())
+	case models.ErrBulkyItemNotAvailable:
+		c.String(http.StatusConflict, err.Error())
+	case services.ErrBulkyItemReserveOwn, services.ErrBulkyItemNotReserver, services.ErrBulkyItemNotAuthor:
+		c.String(http.StatusForbidden, err.Error())
+	default:
+		slog.Error("Unable to change bulky item", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to change bulky item")
+	}
 }
@@ -17,6 +17,7 @@ import (
 	"github.com/the-clothing-loop/website/server/sharedtypes"
 
 	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -31,7 +32,7 @@ const ErrAllowTOHFalse = "The Terms of the Hosts must be approved"
 
 func ChainCreate(c *gin.Context) {
 	db := getDB(c)
-	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	ok, user, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
 	if !ok {
 		return
 	}
@@ -125,7 +126,9 @@ func ChainGet(c *gin.Context) {
 	if query.AddIsAppDisabled {
 		sql += `,
 		chains.is_app_disabled,
-		chains.chat_room_ids`
+		chains.chat_room_ids,
+		chains.chat_retention_days,
+		chains.bulky_item_expiry_days`
 	}
 	if query.AddRoutePrivacy {
 		sql += `,
@@ -172,6 +175,8 @@ func ChainGet(c *gin.Context) {
 	if query.AddIsAppDisabled {
 		body.IsAppDisabled = &chain.IsAppDisabled
 		body.ChatRoomIDs = chain.ChatRoomIDs
+		body.ChatRetentionDays = &chain.ChatRetentionDays
+		body.BulkyItemExpiryDays = &chain.BulkyItemExpiryDays
 	}
 	if query.AddRoutePrivacy {
 		body.RoutePrivacy = &chain.RoutePrivacy
@@ -318,7 +323,7 @@ func ChainUpdate(c *gin.Context) {
 		}
 	}
 
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.UID)
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChainUpdate, body.UID)
 	if !ok {
 		return
 	}
@@ -387,10 +392,20 @@ func ChainUpdate(c *gin.Context) {
 	if body.IsAppDisabled != nil {
 		valuesToUpdate["is_app_disabled"] = *(body.IsAppDisabled)
 	}
+	if body.BulkyItemExpiryDays != nil {
+		valuesToUpdate["bulky_item_expiry_days"] = *(body.BulkyItemExpiryDays)
+	}
 	err := db.Model(chain).Updates(valuesToUpdate).Error
 	if err != nil {
 		slog.Error("Unable to update loop values", "err", err)
 		c.String(http.StatusInternalServerError, "Unable to update loop values")
+		return
+	}
+	if body.Image != nil {
+		err = models.ImageReferenceSet(db, models.ImageReferenceKindChain, chain.ID, *(body.Image))
+		if err != nil {
+			slog.Error("Unable to set image reference of loop", "err", err)
+		}
 	}
 }
 
@@ -405,7 +420,7 @@ func ChainDelete(c *gin.Context) {
 		return
 	}
 
-	ok, authUser, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+	ok, authUser, chain := auth.Authorize(c, db, auth.ActionChainDelete, query.ChainUID)
 	if !ok {
 		return
 	}
@@ -437,7 +452,7 @@ func ChainAddUser(c *gin.Context) {
 	var ok bool
 	var chain *models.Chain
 	if body.IsChainAdmin {
-		ok, _, chain = auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.ChainUID)
+		ok, _, chain = auth.Authorize(c, db, auth.ActionMembersSetHost, body.ChainUID)
 	} else {
 		ok, _, _, chain = auth.AuthenticateUserOfChain(c, db, body.ChainUID, body.UserUID)
 	}
@@ -468,7 +483,10 @@ LIMIT 1
 	if userChain.ID != 0 {
 		if (!userChain.IsChainAdmin && body.IsChainAdmin) || (userChain.IsChainAdmin && !body.IsChainAdmin) {
 			userChain.IsChainAdmin = body.IsChainAdmin
+			// the host role is derived from is_chain_admin, co-host is cleared on demotion
+			userChain.Role = ""
 			db.Save(userChain)
+			services.ChatSyncChainsLater(db, chain.ID)
 		}
 	} else {
 		if err := db.Create(&sharedtypes.UserChain{
@@ -505,11 +523,15 @@ func ChainRemoveUser(c *gin.Context) {
 	}
 
 	if authUser.ID == user.ID && !authUser.IsRootAdmin {
-		if _, isChainAdmin := user.IsPartOfChain(chain.UID); isChainAdmin {
-			amountChainAdmins := -1
-			err := db.Raw(`SELECT COUNT(*) FROM user_chains WHERE chain_id = ? AND is_chain_admin = TRUE`, chain.ID).Scan(&amountChainAdmins).Error
+		userChain, _ := lo.Find(user.Chains, func(uc sharedtypes.UserChain) bool {
+			return uc.ChainID == chain.ID
+		})
+		// co-hosts are stored as is_chain_admin as well, only hosts are counted
+		if auth.RoleOfUserChain(&userChain) == auth.RoleHost {
+			amountHosts := -1
+			err := db.Raw(`SELECT COUNT(*) FROM user_chains WHERE chain_id = ? AND is_chain_admin = TRUE AND role = ''`, chain.ID).Scan(&amountHosts).Error
 
-			if amountChainAdmins == 1 {
+			if amountHosts <= 1 {
 				slog.Warn("Unable to remove last host of loop", "err", err)
 				c.String(http.StatusConflict, "Unable to remove last host of loop")
 				return
@@ -525,6 +547,12 @@ func ChainRemoveUser(c *gin.Context) {
 	}
 
 	chain.ClearAllLastNotifiedIsUnapprovedAt(db)
+	services.ChatSyncChainsLater(db, chain.ID)
+
+	err = models.ChainLeaveAdd(db, user.Name, nil, chain.ID)
+	if err != nil {
+		slog.Error("Unable to record participant leaving loop", "err", err)
+	}
 
 	// send email to chain admins
 	services.EmailLoopAdminsOnUserLeft(db,
@@ -565,6 +593,9 @@ WHERE user_id = ? AND chain_id = ?
 	if user.Email != nil {
 		views.EmailAnAdminApprovedYourJoinRequest(db, user.I18n, user.Name, *user.Email, chain.Name)
 	}
+	views.NotificationInboxAdd(db, models.UserNotificationKindChainApproved, chain.ID, []string{user.UID}, gin.H{
+		"ChainName": chain.Name,
+	})
 }
 
 func ChainDeleteUnapproved(c *gin.Context) {
@@ -634,7 +665,7 @@ func ChainGetUserNote(c *gin.Context) {
 		return
 	}
 
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, query.ChainUID)
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChainRead, query.ChainUID)
 	if !ok {
 		return
 	}
@@ -661,7 +692,7 @@ func ChainChangeUserWarden(c *gin.Context) {
 		return
 	}
 
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.ChainUID)
+	ok, _, chain := auth.Authorize(c, db, auth.ActionMembersSetWarden, body.ChainUID)
 	if !ok {
 		return
 	}
@@ -675,13 +706,16 @@ func ChainChangeUserWarden(c *gin.Context) {
 		c.String(http.StatusInternalServerError, err.Error())
 		return
 	}
-	ok, isUserChainAdmin := user.IsPartOfChain(body.ChainUID)
+	userChain, ok := lo.Find(user.Chains, func(uc sharedtypes.UserChain) bool {
+		return uc.ChainID == chain.ID
+	})
 	if !ok {
 		c.String(http.StatusBadRequest, "User is not a member of this loop")
 		return
 	}
-	if isUserChainAdmin {
-		c.String(http.StatusInternalServerError, "Hosts cannot be assigned wardens")
+	// co-hosts are stored as is_chain_admin as well, clearing their role would make them a host
+	if userChain.IsChainAdmin {
+		c.String(http.StatusConflict, "Hosts and co-hosts cannot be assigned wardens")
 		return
 	}
 
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func ChainRoleGetAll(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChainRead, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	customRoles, err := models.ChainRoleGetAllByChain(db, chain.ID)
+	if err != nil {
+		slog.Error("Unable to retrieve loop roles", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to retrieve loop roles")
+		return
+	}
+
+	builtInRoles := []sharedtypes.ChainRole{}
+	for role, actions := range auth.RoleBuiltInAll() {
+		builtInRoles = append(builtInRoles, sharedtypes.ChainRole{
+			Name:      string(role),
+			Actions:   lo.Map(actions, func(a auth.Action, _ int) string { return string(a) }),
+			IsBuiltIn: true,
+		})
+	}
+	sort.Slice(builtInRoles, func(i, j int) bool {
+		return builtInRoles[i].Name < builtInRoles[j].Name
+	})
+
+	res := builtInRoles
+	for _, r := range customRoles {
+		res = append(res, sharedtypes.ChainRole{
+			Name:    r.Name,
+			Actions: r.Actions,
+		})
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func ChainRolePut(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.ChainRolePutRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authorize(c, db, auth.ActionRolesManage, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	if err := auth.ValidateCustomRole(body.Name, body.Actions); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	role := &models.ChainRole{
+		ChainID: chain.ID,
+		Name:    body.Name,
+		Actions: lo.Uniq(body.Actions),
+	}
+	err := role.Save(db)
+	if err != nil {
+		slog.Error("Unable to save loop role", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to save loop role")
+		return
+	}
+	services.ChatSyncChainsLater(db, chain.ID)
+}
+
+func ChainRoleDelete(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		Name     string `form:"name" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authorize(c, db, auth.ActionRolesManage, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	role, err := models.ChainRoleGetByName(db, chain.ID, query.Name)
+	if err != nil {
+		if errors.Is(err, models.ErrChainRoleNotFound) {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		slog.Error("Unable to retrieve loop role", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to retrieve loop role")
+		return
+	}
+
+	err = role.Delete(db)
+	if err != nil {
+		slog.Error("Unable to delete loop role", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to delete loop role")
+		return
+	}
+	services.ChatSyncChainsLater(db, chain.ID)
+}
+
+func ChainChangeUserRole(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.ChainChangeUserRoleRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, authUser, chain := auth.Authorize(c, db, auth.ActionRolesManage, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	user, err := models.UserGetByUID(db, body.UserUID, true)
+	if err == nil {
+		err = user.AddUserChainsToObject(db)
+	}
+	if err != nil {
+		c.String(http.StatusBadRequest, models.ErrUserNotFound.Error())
+		return
+	}
+	userChain, ok := lo.Find(user.Chains, func(uc sharedtypes.UserChain) bool {
+		return uc.ChainID == chain.ID
+	})
+	if !ok {
+		c.String(http.StatusBadRequest, "User is not a member of this loop")
+		return
+	}
+
+	role := auth.Role(body.Role)
+	if !auth.RoleIsBuiltIn(role) {
+		_, err := models.ChainRoleGetByName(db, chain.ID, body.Role)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if role == auth.RoleHost && !auth.HasPermission(db, authUser, chain, auth.ActionMembersSetHost) {
+		c.String(http.StatusUnauthorized, "User role not high enough")
+		return
+	}
+
+	// a loop must always keep at least one host
+	if auth.RoleOfUserChain(&userChain) == auth.RoleHost && role != auth.RoleHost {
+		amountHosts := -1
+		db.Raw(`SELECT COUNT(*) FROM user_chains WHERE chain_id = ? AND is_chain_admin = TRUE AND role = ''`, chain.ID).Scan(&amountHosts)
+		if amountHosts <= 1 {
+			c.String(http.StatusConflict, "Unable to remove last host of loop")
+			return
+		}
+	}
+
+	switch role {
+	case auth.RoleHost:
+		err = models.UserChainSetRole(db, user.ID, chain.ID, "", true, false)
+	case auth.RoleCoHost:
+		err = models.UserChainSetRole(db, user.ID, chain.ID, string(auth.RoleCoHost), true, false)
+	case auth.RoleWarden:
+		err = models.UserChainSetRole(db, user.ID, chain.ID, "", false, true)
+	case auth.RoleParticipant:
+		err = models.UserChainSetRole(db, user.ID, chain.ID, "", false, false)
+	default:
+		err = models.UserChainSetRole(db, user.ID, chain.ID, body.Role, false, false)
+	}
+	if err != nil {
+		slog.Error("Unable to change user role", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to change user role")
+		return
+	}
+	services.ChatSyncChainsLater(db, chain.ID)
+}
@@ -1,10 +1,16 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/samber/lo"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/services"
@@ -12,6 +18,22 @@ import (
 	"github.com/the-clothing-loop/website/server/sharedtypes"
 )
 
+// Responds with the status that belongs to the chat error
+func chatError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrChatUnavailable):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, services.ErrChatNotMember), errors.Is(err, services.ErrChatMuted):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrChatMuteNotAllowed):
+		status = http.StatusConflict
+	case errors.Is(err, app.ErrChatNotFound):
+		status = http.StatusNotFound
+	}
+	httperror.New(status, err).StatusWithError(c)
+}
+
 func ChatPatchUser(c *gin.Context) {
 	db := getDB(c)
 
@@ -21,25 +43,20 @@ func ChatPatchUser(c *gin.Context) {
 		return
 	}
 
-	ok, user, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, body.ChainUID)
+	ok, user, chain := auth.Authorize(c, db, auth.ActionChatJoin, body.ChainUID)
 	if !ok {
 		return
 	}
 
-	err := services.ChatPatchUser(db, c.Request.Context(), app.ChatTeamId, user)
+	chatToken, err := services.ChatPatchUser(db, c.Request.Context(), user)
 	if err != nil {
-		httperror.New(http.StatusInternalServerError, err).StatusWithError(c)
-		return
-	}
-	if user.ChatPass == nil {
-		c.AbortWithError(http.StatusTeapot, fmt.Errorf("password is not set"))
+		chatError(c, err)
 		return
 	}
 
 	// Create a new channel if none exists
 	if len(chain.ChatRoomIDs) == 0 {
-		_, isChainAdmin := user.IsPartOfChain(chain.UID)
-		if isChainAdmin {
+		if auth.HasPermission(db, user, chain, auth.ActionChatManage) {
 			_, err := services.ChatCreateChannel(db, c.Request.Context(), chain, *user.ChatUserID, "General", "#fff")
 			if err != nil {
 				c.String(http.StatusInternalServerError, err.Error())
@@ -49,9 +66,10 @@ func ChatPatchUser(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, sharedtypes.ChatPatchUserResponse{
-		ChatTeam:     app.ChatTeamId,
+		ChatProvider: app.Chat.Name(),
+		ChatTeam:     app.Chat.TeamID(),
 		ChatUserID:   *user.ChatUserID,
-		ChatPass:     *user.ChatPass,
+		ChatToken:    chatToken,
 		ChatUserName: *user.ChatUserName,
 	})
 }
@@ -65,19 +83,23 @@ func ChatCreateChannel(c *gin.Context) {
 		return
 	}
 
-	ok, user, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.ChainUID)
+	ok, user, chain := auth.Authorize(c, db, auth.ActionChatManage, body.ChainUID)
 	if !ok {
 		return
 	}
+	if user.ChatUserID == nil {
+		c.String(http.StatusBadRequest, "You must be registered on our chat server before creating a room")
+		return
+	}
 
-	mmChannel, err := services.ChatCreateChannel(db, c.Request.Context(), chain, *user.ChatUserID, body.Name, body.Color)
+	channelID, err := services.ChatCreateChannel(db, c.Request.Context(), chain, *user.ChatUserID, body.Name, body.Color)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		chatError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, sharedtypes.ChatCreateChannelResponse{
-		ChatChannel: mmChannel.Id,
+		ChatChannel: channelID,
 	})
 }
 
@@ -90,14 +112,14 @@ func ChatDeleteChannel(c *gin.Context) {
 		return
 	}
 
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.ChainUID)
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChatManage, body.ChainUID)
 	if !ok {
 		return
 	}
 
 	err := services.ChatDeleteChannel(db, c.Request.Context(), chain, body.ChannelID)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		chatError(c, err)
 		return
 	}
 
@@ -113,22 +135,214 @@ func ChatJoinChannels(c *gin.Context) {
 		return
 	}
 
-	ok, user, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, body.ChainUID)
+	ok, user, chain := auth.Authorize(c, db, auth.ActionChatJoin, body.ChainUID)
 	if !ok {
 		return
 	}
 
-	_, isChainAdmin := user.IsPartOfChain(chain.UID)
+	isChainAdmin := auth.HasPermission(db, user, chain, auth.ActionChatManage)
 	if chain.IsAppDisabled && !isChainAdmin {
 		c.String(http.StatusExpectationFailed, "The Loop host must first enable chat")
 		return
 	}
 
-	for _, mmChannelId := range chain.ChatRoomIDs {
-		err := services.ChatJoinChannel(db, c.Request.Context(), chain, user, isChainAdmin, mmChannelId)
+	for _, channelID := range chain.ChatRoomIDs {
+		err := services.ChatJoinChannel(db, c.Request.Context(), chain, user, isChainAdmin, channelID)
 		if err != nil {
-			httperror.New(http.StatusInternalServerError, err).StatusWithError(c)
+			chatError(c, err)
+			return
+		}
+	}
+}
+
+// Returns the messages of a channel of the built-in chat, newest first
+func ChatMessageGetAll(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID  string `form:"chain_uid" binding:"required,uuid"`
+		ChannelID string `form:"channel_id" binding:"required"`
+		// The oldest message already received, to get the page before it
+		BeforeID uint `form:"before_id"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, chain := auth.Authorize(c, db, auth.ActionChatJoin, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	messages, err := services.ChatMessageGetAll(db, chain, user, query.ChannelID, query.BeforeID)
+	if err != nil {
+		chatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+// Lists the differences between the loop members and the chat channel members without fixing them,
+// for root admins to check what the sync of the daily cron would change
+func ChatSyncReport(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		// Only this loop, otherwise all loops with chat channels
+		ChainUID string `form:"chain_uid" binding:"omitempty,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authorize(c, db, auth.ActionRootAdmin, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	if chain == nil {
+		report, err := services.ChatSyncAll(db, c.Request.Context(), true)
+		if err != nil {
+			chatError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	changes, err := services.ChatSyncChain(db, c.Request.Context(), chain, true)
+	if err != nil {
+		chatError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sharedtypes.ChatSyncReport{
+		DryRun:  true,
+		Chains:  1,
+		Changes: changes,
+		Errors:  []string{},
+	})
+}
+
+func ChatMessageCreate(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.ChatMessageCreateRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, chain := auth.Authorize(c, db, auth.ActionChatJoin, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	message, err := services.ChatMessageSend(db, chain, user, body.ChannelID, body.Message)
+	if err != nil {
+		chatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+const (
+	chatWebSocketWriteWait  = 10 * time.Second
+	chatWebSocketPongWait   = 60 * time.Second
+	chatWebSocketPingPeriod = chatWebSocketPongWait * 9 / 10
+)
+
+// The web views of the app, Capacitor serves it from localhost on Android and capacitor://localhost on iOS
+var chatWebSocketAppOrigins = []string{"capacitor://localhost", "https://localhost", "http://localhost"}
+
+var chatWebSocketUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     chatWebSocketCheckOrigin,
+}
+
+// Browsers send the cookie along with the connection from any website,
+// so only the website and the app may connect
+func chatWebSocketCheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// clients other than browsers send no origin and have no cookie of someone else to send along
+		return true
+	}
+	if lo.Contains(chatWebSocketAppOrigins, origin) {
+		return true
+	}
+	for _, baseURL := range []string{app.Config.SITE_BASE_URL_FE, app.Config.SITE_BASE_URL_API} {
+		u, err := url.Parse(baseURL)
+		if err == nil && u.Host != "" && strings.EqualFold(origin, u.Scheme+"://"+u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sends each new message of the built-in chat, of the channels the user is a member of, as json
+func ChatWebSocket(c *gin.Context) {
+	db := getDB(c)
+
+	builtin, ok := app.ChatBuiltin()
+	if !ok {
+		chatError(c, services.ErrChatUnavailable)
+		return
+	}
+
+	ok, user, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
+	if !ok {
+		return
+	}
+
+	conn, err := chatWebSocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		slog.Warn("Unable to upgrade chat connection", "err", err)
+		return
+	}
+	defer conn.Close()
+
+	messages, unsubscribe := builtin.Subscribe(user.ID)
+	defer unsubscribe()
+
+	// messages from the client are not used, reading is needed to receive pongs and the close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadDeadline(time.Now().Add(chatWebSocketPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(chatWebSocketPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(chatWebSocketPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
 			return
+		case m, ok := <-messages:
+			if !ok {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(chatWebSocketWriteWait))
+			if err := conn.WriteJSON(m); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(chatWebSocketWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
 		}
 	}
 }
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+func ChatMessageReportCreate(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.ChatMessageReportRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, chain := auth.Authorize(c, db, auth.ActionChatJoin, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	err := services.ChatMessageReport(db, c.Request.Context(), chain, user, body.ChannelID, body.MessageID, body.Reason)
+	if err != nil {
+		chatError(c, err)
+		return
+	}
+}
+
+func ChatMessageReportGetAll(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChatManage, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	reports, err := models.ChatMessageReportGetAll(db, chain.ID)
+	if err != nil {
+		slog.Error("Unable to retrieve chat reports", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to retrieve chat reports")
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// Removes the report once the hosts have handled it
+func ChatMessageReportDelete(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		ID       uint   `form:"id" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChatManage, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	err := models.ChatMessageReportDelete(db, chain.ID, query.ID)
+	if err != nil {
+		slog.Error("Unable to remove chat report", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to remove chat report")
+		return
+	}
+}
+
+func ChatMuteGetAll(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChatManage, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	mutes, err := models.ChatMuteGetAll(db, chain.ID)
+	if err != nil {
+		slog.Error("Unable to retrieve chat mutes", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to retrieve chat mutes")
+		return
+	}
+
+	c.JSON(http.StatusOK, mutes)
+}
+
+func ChatMutePut(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.ChatMuteRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChatManage, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	user, ok := chatModerationGetMember(c, db, chain, body.UserUID)
+	if !ok {
+		return
+	}
+
+	until := time.Now().Add(time.Duration(body.Minutes) * time.Minute)
+	err := services.ChatMute(db, c.Request.Context(), chain, user, until)
+	if err != nil {
+		chatError(c, err)
+		return
+	}
+}
+
+func ChatMuteDelete(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		UserUID  string `form:"user_uid" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChatManage, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	user, ok := chatModerationGetMember(c, db, chain, query.UserUID)
+	if !ok {
+		return
+	}
+
+	err := services.ChatUnmute(db, c.Request.Context(), chain, user)
+	if err != nil {
+		chatError(c, err)
+		return
+	}
+}
+
+func ChatRetentionUpdate(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.ChatRetentionRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChatManage, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	err := services.ChatRetentionSet(db, chain, body.Days)
+	if err != nil {
+		slog.Error("Unable to set chat retention", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to set chat retention")
+		return
+	}
+}
+
+func chatModerationGetMember(c *gin.Context, db *gorm.DB, chain *models.Chain, userUID string) (*models.User, bool) {
+	user, err := models.UserGetByUID(db, userUID, true)
+	if err == nil {
+		err = user.AddUserChainsToObject(db)
+	}
+	if err != nil {
+		c.String(http.StatusBadRequest, models.ErrUserNotFound.Error())
+		return nil, false
+	}
+	if ok, _ := user.IsPartOfChain(chain.UID); !ok {
+		c.String(http.StatusBadRequest, "User is not a member of this loop")
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+)
+
+func TestChatWebSocketCheckOrigin(t *testing.T) {
+	app.Config.SITE_BASE_URL_FE = "https://www.clothingloop.org"
+	app.Config.SITE_BASE_URL_API = "https://api.clothingloop.org/api"
+
+	tests := []struct {
+		Origin   string
+		Expected bool
+	}{
+		{"", true},
+		{"https://www.clothingloop.org", true},
+		{"https://api.clothingloop.org", true},
+		{"capacitor://localhost", true},
+		{"https://localhost", true},
+		{"https://evil.example.com", false},
+		{"https://www.clothingloop.org.evil.example.com", false},
+		{"http://www.clothingloop.org", false},
+		{"null", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/v2/chat/ws", nil)
+		if test.Origin != "" {
+			r.Header.Set("Origin", test.Origin)
+		}
+		assert.Equalf(t, test.Expected, chatWebSocketCheckOrigin(r), "origin: %q", test.Origin)
+	}
+}
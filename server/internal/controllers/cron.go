@@ -1,14 +1,16 @@
 package controllers
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"log/slog"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/internal/views"
 	"gorm.io/gorm"
 )
@@ -20,14 +22,30 @@ func CronMonthly(db *gorm.DB) {
 	emailHostsOldPendingParticipants(db)
 }
 
+func CronWeekly(db *gorm.DB) {
+	services.HostDigestRun(db, time.Now())
+}
+
 func CronDaily(db *gorm.DB) {
-	emailSendAgain(db)
+	mailDeleteOld(db)
+	models.ChainLeaveDeleteOld(db)
+	models.PushDeliveryDeleteOld(db)
+	models.UserNotificationDeleteOld(db)
 	emailAbandonedChainRecruitment(db)
 	auth.OtpDeleteOld(db)
+	models.OidcAuthCodeDeleteExpired(db)
+	services.UserDataExportDeleteOld(db)
+	services.UserPurgeScheduledRun(db)
+	chatSync(db)
+	services.ChatRetentionRun(db, context.Background())
+	imageGC(db)
 }
 
 func CronHourly(db *gorm.DB) {
 	notifyIfIsHoldingABagForTooLong(db)
+	services.BulkyItemExpiryRun(db)
+	services.ChatCleanupRun(db, context.Background())
+	services.ChatMuteEndedRun(db, context.Background())
 }
 
 // Email hosts about pending participants after 60 days.
@@ -132,7 +150,7 @@ ORDER BY u.email
 	for i := range emailValues {
 		email := emailValues[i]
 		slog.Info("Sending email approve reminder", "to", email.Email)
-		go views.EmailApproveReminder(db, email.I18n, email.Name, email.Email, email.Approvals)
+		views.EmailApproveReminder(db, email.I18n, email.Name, email.Email, email.Approvals)
 	}
 }
 
@@ -185,7 +203,8 @@ SELECT
 	users.name AS name,
 	users.email AS email,
 	users.i18n AS i18n,
-	chains.name AS chain_name
+	chains.name AS chain_name,
+	chains.id AS chain_id
 FROM user_chains AS uc
 JOIN users ON uc.user_id = users.id
 JOIN chains ON uc.chain_id = chains.id
@@ -201,7 +220,7 @@ WHERE chains.id IN ? AND uc.is_chain_admin = FALSE
 		if !u.Email.Valid {
 			continue
 		}
-		views.EmailDoYouWantToBeHost(db, u.I18n, u.Name, u.Email.String, u.ChainName)
+		views.EmailDoYouWantToBeHost(db, u.ChainID, u.I18n, u.Name, u.Email.String, u.ChainName)
 	}
 
 	// prevent duplicate emails
@@ -218,12 +237,15 @@ func notifyIfIsHoldingABagForTooLong(db *gorm.DB) {
 		UserUID   string `gorm:"user_uid"`
 		BagNumber string `gorm:"bag_number"`
 		BagID     uint   `gorm:"bag_id"`
+		ChainID   uint   `gorm:"chain_id"`
+		ChainName string `gorm:"chain_name"`
 	}{}
 	db.Raw(`
-SELECT b.number as bag_number, u.uid as user_uid, b.id as bag_id
+SELECT b.number as bag_number, u.uid as user_uid, b.id as bag_id, uc.chain_id as chain_id, c.name as chain_name
 FROM bags as b
 JOIN user_chains as uc ON b.user_chain_id = uc.id
 JOIN users as u ON uc.user_id = u.id
+JOIN chains as c ON uc.chain_id = c.id
 WHERE b.updated_at < (NOW() - INTERVAL 7 DAY)
 AND b.last_notified_at IS NULL
 	`).Scan(res)
@@ -233,7 +255,10 @@ AND b.last_notified_at IS NULL
 		for i := range *res {
 			item := (*res)[i]
 			slog.Info("Create notification", "user", item.UserUID, "holding_bag", item.BagNumber)
-			app.OneSignalCreateNotification(db, []string{item.UserUID}, *views.Notifications[views.NotificationEnumTitleBagTooOld], app.OneSignalEllipsisContent(item.BagNumber))
+			views.NotificationSend(db, models.NotificationKindBagTooOld, item.ChainID, []string{item.UserUID}, gin.H{
+				"BagNumber": item.BagNumber,
+				"ChainName": item.ChainName,
+			})
 
 			bagIDs = append(bagIDs, item.BagID)
 		}
@@ -242,28 +267,37 @@ AND b.last_notified_at IS NULL
 	}
 }
 
-func emailSendAgain(db *gorm.DB) {
-	slog.Info("Running emailSendAgain")
-	ms, err := models.MailGetDueForResend(db)
+func mailDeleteOld(db *gorm.DB) {
+	slog.Info("Running mailDeleteOld")
+	err := models.MailDeleteOld(db)
 	if err != nil {
-		return
+		slog.Error("Unable to remove old emails from the queue", "err", err)
 	}
+}
 
-	fmt.Printf("Error mail send: %++v", ms)
-	for _, m := range ms {
-		err := validate.Var(m.ToAddress, "email")
-		if err != nil {
-			m.Delete(db)
-			continue
-		}
-		err = app.MailSend(db, m)
-		// err = models.ErrMailLastRetry
-		errr := m.UpdateNextRetryAttempt(db, err)
+// Fixes chat channel members that no longer match the loop members
+func chatSync(db *gorm.DB) {
+	if app.Chat == nil {
+		return
+	}
+	slog.Info("Running chatSync")
+	report, err := services.ChatSyncAll(db, context.Background(), false)
+	if err != nil {
+		slog.Error("Unable to sync chat channel members", "err", err)
+		return
+	}
+	slog.Info("Synced chat of loops", "chains", report.Chains, "changes", len(report.Changes), "errors", len(report.Errors))
+}
 
-		if errr != nil {
-			if errors.Is(errr, models.ErrMailLastRetry) {
-				views.EmailRootAdminFailedLastRetry(db, m.ToAddress, m.Subject)
-			}
-		}
+func imageGC(db *gorm.DB) {
+	if app.Config.IMAGES_DIR == "" {
+		return
+	}
+	slog.Info("Running imageGC")
+	report, err := services.ImageGCRun(db, false)
+	if err != nil {
+		slog.Error("Unable to remove unused images", "err", err)
+		return
 	}
+	slog.Info("Ran image garbage collection", "kept", report.Kept, "removed", len(report.Removed), "bytes", report.Bytes, "errors", len(report.Errors))
 }
@@ -31,13 +31,11 @@ func EventCreate(c *gin.Context) {
 		return
 	}
 
-	var minimumAuthState int
-	if body.ChainUID == "" {
-		minimumAuthState = auth.AuthState1AnyUser
-	} else {
-		minimumAuthState = auth.AuthState3AdminChainUser
+	action := auth.ActionAnyUser
+	if body.ChainUID != "" {
+		action = auth.ActionEventManage
 	}
-	ok, user, chain := auth.Authenticate(c, db, minimumAuthState, body.ChainUID)
+	ok, user, chain := auth.Authorize(c, db, action, body.ChainUID)
 	if !ok {
 		return
 	}
@@ -71,6 +69,9 @@ func EventCreate(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, errors.New("Unable to create event"))
 		return
 	}
+	if err := models.ImageReferenceSet(db, models.ImageReferenceKindEvent, event.ID, event.ImageUrl); err != nil {
+		slog.Error("Unable to set image reference of event", "err", err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"uid": event.UID,
@@ -210,6 +211,10 @@ func EventDelete(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	err = models.ImageReferenceRemove(db, models.ImageReferenceKindEvent, event.ID)
+	if err != nil {
+		slog.Error("Unable to remove image reference of event", "err", err)
+	}
 }
 
 func EventUpdate(c *gin.Context) {
@@ -291,10 +296,11 @@ func EventUpdate(c *gin.Context) {
 		event.ChainID = &chainID
 	}
 	if body.ImageUrl != nil {
-		event.ImageUrl = *body.ImageUrl
 		if event.ImageUrl != *body.ImageUrl && event.ImageDeleteUrl != "" {
 			imgbb.DeleteAll([]string{event.ImageDeleteUrl})
+			event.ImageDeleteUrl = ""
 		}
+		event.ImageUrl = *body.ImageUrl
 		if body.ImageDeleteUrl != nil {
 			event.ImageDeleteUrl = *(body.ImageDeleteUrl)
 		}
@@ -318,6 +324,12 @@ func EventUpdate(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, errors.New("Unable to update loop values"))
 		return
 	}
+	if body.ImageUrl != nil {
+		err = models.ImageReferenceSet(db, models.ImageReferenceKindEvent, event.ID, event.ImageUrl)
+		if err != nil {
+			slog.Error("Unable to set image reference of event", "err", err)
+		}
+	}
 }
 
 func EventICal(c *gin.Context) {
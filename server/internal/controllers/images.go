@@ -1,123 +1,125 @@
 package controllers
 
 import (
-	"bytes"
-	"crypto"
 	"encoding/base64"
 	"errors"
-	"fmt"
 	"io"
-	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
 	"path"
-	"time"
 
-	"github.com/disintegration/imaging"
 	"github.com/gin-gonic/gin"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/sharedtypes"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func imagesRead(c *gin.Context) (imgRes *sharedtypes.ImageUploadResponse, err error) {
-	if app.Config.IMGBB_KEY == "" {
-		return nil, errors.New("Api key to found")
-	}
-
-	reader := base64.NewDecoder(base64.StdEncoding, c.Request.Body)
-	var reader1 bytes.Buffer
-	// split reader into to io.Reader's
-	reader2 := io.TeeReader(reader, &reader1)
-	reader2Contents, _ := io.ReadAll(reader2)
-	sha1 := crypto.SHA1.New()
-	sha1.Write([]byte(reader2Contents))
-	imageSha1 := base64.URLEncoding.EncodeToString(sha1.Sum(nil))
-	img, err := imaging.Decode(&reader1)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to decode image: %v", err)
+func ImageUpload(c *gin.Context) {
+	db := getDB(c)
+	ok, user, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
+	if !ok {
+		return
 	}
 
-	imgNRGBA := imaging.Fit(img, 800, 800, imaging.Lanczos)
-
-	buf := new(bytes.Buffer)
-	err = imaging.Encode(buf, imgNRGBA, imaging.JPEG, imaging.JPEGQuality(80))
+	data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, c.Request.Body))
 	if err != nil {
-		return nil, fmt.Errorf("Unable to encode to jpeg: %v", err)
+		c.String(http.StatusBadRequest, "Image must be base64 encoded")
+		return
 	}
 
-	now := time.Now()
-
-	jpgContents, _ := io.ReadAll(buf)
-	filename := fmt.Sprintf("uploads/%s_%s.jpg", now.Format(time.DateOnly), imageSha1)
-	slog.Debug("Writing image", "to", path.Join(app.Config.IMAGES_DIR, filename))
-	err = os.WriteFile(path.Join(app.Config.IMAGES_DIR, filename), jpgContents, 0644)
+	image, err := services.ImageCreate(db, user, data)
 	if err != nil {
-		// The sha1 name manages duplicate, thus no need to error out.
-		if !errors.Is(err, fs.ErrExist) {
-			return nil, err
+		if errors.Is(err, services.ErrImageDecode) {
+			c.String(http.StatusBadRequest, "Unable to upload image")
+			return
 		}
+		slog.Error("Unable to upload image", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to upload image")
+		return
 	}
 
-	bHash, _ := bcrypt.GenerateFromPassword([]byte(filename+app.Config.IMGBB_KEY), bcrypt.DefaultCost)
-	key := base64.URLEncoding.EncodeToString(bHash)
-
-	res := &sharedtypes.ImageUploadResponse{
-		Thumbnail: fmt.Sprintf("https://images.clothingloop.org/400x/%s", filename),
-		Image:     fmt.Sprintf("https://images.clothingloop.org/original/%s", filename),
-		Delete:    fmt.Sprintf("%s/v2/image_purge?path=%s&key=%s", app.Config.SITE_BASE_URL_API, filename, key),
-	}
-	return res, nil
+	c.JSON(http.StatusOK, sharedtypes.ImageUploadResponse{
+		ID:        image.ID,
+		Thumbnail: services.ImageURL(models.ImageSizeThumb, image.Name),
+		Image:     services.ImageURL(models.ImageSizeMedium, image.Name),
+		Original:  services.ImageURL(models.ImageSizeOriginal, image.Name),
+	})
 }
-func ImageUpload(c *gin.Context) {
-	db := getDB(c)
-	ok, _, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
-	if !ok {
+
+// The name contains the hash of the contents, so a size of an image never changes
+func ImageGet(c *gin.Context) {
+	size := c.Param("size")
+	name := c.Param("name")
+	if _, ok := models.ImageSizes[size]; !ok || !models.ImageValidName(name) {
+		c.String(http.StatusNotFound, models.ErrImageNotFound.Error())
 		return
 	}
 
-	res, err := imagesRead(c)
-	if err != nil {
-		slog.Warn("Unable to upload image: ", "err", err)
-		c.String(http.StatusBadRequest, "Unable to upload image")
+	p := services.ImagePath(size, name)
+	if _, err := os.Stat(p); err != nil {
+		c.String(http.StatusNotFound, models.ErrImageNotFound.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, res)
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.File(p)
 }
 
-func ImageDeleteDeprecated(c *gin.Context) {
+func ImageDelete(c *gin.Context) {
 	db := getDB(c)
 	var query struct {
-		Url string `form:"url" binding:"required,url"`
+		ID uint `form:"id" binding:"required"`
 	}
 	if err := c.ShouldBindQuery(&query); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
-	ok, _, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	ok, user, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
 	if !ok {
 		return
 	}
 
-	resp, err := http.DefaultClient.Get(query.Url)
+	image, err := models.ImageGetByID(db, query.ID)
 	if err != nil {
-		slog.Error("Unable to use default client on delete image url", "url", query.Url, "err", err)
-		c.String(http.StatusInternalServerError, "Unable to use default client on delete image url")
+		c.String(http.StatusNotFound, err.Error())
 		return
 	}
 
-	b, _ := io.ReadAll(resp.Body)
-	c.JSON(http.StatusOK, map[string]any{
-		"response": map[string]any{
-			"status": resp.StatusCode,
-			"body":   string(b),
-		},
-	})
+	err = services.ImageDelete(db, user, image)
+	if err != nil {
+		if errors.Is(err, services.ErrImageNotOwn) {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		slog.Error("Unable to delete image", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to delete image")
+		return
+	}
+}
+
+// Lists the images the garbage collection would remove, without removing them
+func ImageGCReport(c *gin.Context) {
+	db := getDB(c)
+
+	ok, _, _ := auth.Authorize(c, db, auth.ActionRootAdmin, "")
+	if !ok {
+		return
+	}
+
+	report, err := services.ImageGCRun(db, true)
+	if err != nil {
+		slog.Error("Unable to run image garbage collection", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to run image garbage collection")
+		return
+	}
+	c.JSON(http.StatusOK, report)
 }
 
+// Deletes images uploaded before ownership was recorded, using the link returned on upload
 func ImagePurge(c *gin.Context) {
 	var query struct {
 		Path string `form:"path" binding:"required"`
@@ -85,7 +85,7 @@ func LoginValidate(c *gin.Context) {
 	// Is the first time verifying the user account
 	if user.Email != nil && !user.IsEmailVerified {
 		db.Exec(`UPDATE chains SET published = TRUE WHERE id IN (
-			SELECT chain_id FROM user_chains WHERE user_id = ? AND is_chain_admin = TRUE
+			SELECT chain_id FROM user_chains WHERE user_id = ? AND is_chain_admin = TRUE AND role = ''
 	   )`, user.ID)
 
 		// Reset joined-at time
@@ -94,7 +94,7 @@ func LoginValidate(c *gin.Context) {
 		// Add all chains to be notified
 		chainIDs := []uint{}
 		for _, uc := range user.Chains {
-			if !uc.IsChainAdmin {
+			if auth.RoleOfUserChain(&uc) != auth.RoleHost {
 				chainIDs = append(chainIDs, uc.ChainID)
 			}
 		}
@@ -238,7 +238,7 @@ func RegisterChainAdmin(c *gin.Context) {
 		return
 	}
 
-	go views.EmailRegisterVerification(c, db, user.Name, *user.Email, token, chain.UID)
+	views.EmailRegisterVerification(c, db, user.Name, *user.Email, token, chain.UID)
 }
 
 func RegisterBasicUser(c *gin.Context) {
@@ -312,9 +312,16 @@ func RegisterBasicUser(c *gin.Context) {
 }
 
 func Logout(c *gin.Context) {
-	_, ok := auth.TokenReadFromRequest(c)
+	db := getDB(c)
+
+	token, ok := auth.TokenReadFromRequest(c)
 	if !ok {
 		c.String(http.StatusBadRequest, "No token received")
+	} else if user, _, err := auth.AuthenticateToken(db, token); err == nil {
+		err = services.ChatUserTokenRevoke(db, c.Request.Context(), user)
+		if err != nil {
+			slog.Error("Unable to revoke chat token", "err", err)
+		}
 	}
 
 	auth.CookieRemove(c)
@@ -323,7 +330,7 @@ func Logout(c *gin.Context) {
 func RefreshToken(c *gin.Context) {
 	db := getDB(c)
 
-	ok, authUser, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	ok, authUser, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
 	if !ok {
 		return
 	}
@@ -352,7 +359,7 @@ func LoginSuperAsGenerateLink(c *gin.Context) {
 		return
 	}
 
-	ok, _, _ := auth.Authenticate(c, db, auth.AuthState4RootUser, "")
+	ok, _, _ := auth.Authorize(c, db, auth.ActionRootAdmin, "")
 	if !ok {
 		return
 	}
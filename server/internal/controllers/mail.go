@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+)
+
+// Receives delivery events of transactional emails from Brevo
+func MailWebhook(c *gin.Context) {
+	db := getDB(c)
+
+	event, err := app.BrevoWebhookTransactional(c)
+	if err != nil {
+		if errors.Is(err, app.ErrBrevoWebhookUnauthorized) {
+			c.String(http.StatusUnauthorized, err.Error())
+		} else {
+			c.String(http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	deliveryStatus, ok := services.MailDeliveryStatusByBrevoEvent[event.Event]
+	if !ok {
+		c.JSON(200, gin.H{"received": true})
+		return
+	}
+
+	at := time.Now()
+	if event.TsEvent != 0 {
+		at = time.Unix(event.TsEvent, 0)
+	}
+	err = services.MailDeliveryEvent(db, event.MessageID, event.Email, deliveryStatus, at)
+	if err != nil {
+		slog.Error("Unable to process email delivery event", "event", event.Event, "err", err)
+		c.String(http.StatusInternalServerError, "Unable to process email delivery event")
+		return
+	}
+
+	c.JSON(200, gin.H{"received": true})
+}
+
+// Statistics of each mail provider, for root admins to check if emails are being sent
+func MailHealth(c *gin.Context) {
+	db := getDB(c)
+
+	ok, _, _ := auth.Authorize(c, db, auth.ActionRootAdmin, "")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, app.MailHealth())
+}
+
+// Unsubscribes from a kind of notification emails with the link of the List-Unsubscribe header or the email footer.
+// Email clients use POST for one-click unsubscribing, opening the link in a browser uses GET,
+// which only opens the confirmation page as link previews and scanners open the link as well.
+func MailUnsubscribe(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		Email string `form:"email" binding:"required,email"`
+		// The notification kind
+		Category string `form:"category" binding:"required"`
+		Sig      string `form:"sig" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	kind, ok := models.NotificationKindGet(query.Category)
+	if !ok || !lo.Contains(kind.Channels, models.NotificationChannelEmail) {
+		c.String(http.StatusBadRequest, "Unknown email category")
+		return
+	}
+	if !app.MailUnsubscribeVerify(query.Email, query.Category, query.Sig) {
+		c.String(http.StatusUnauthorized, "Invalid unsubscribe link")
+		return
+	}
+
+	if c.Request.Method == http.MethodGet {
+		c.Redirect(http.StatusFound, app.Config.SITE_BASE_URL_FE+"/unsubscribed?"+c.Request.URL.RawQuery)
+		return
+	}
+
+	// without an account there are no notification emails to stop,
+	// the link is not for a specific loop so the emails of every loop are stopped
+	user, err := models.UserGetByEmail(db, query.Email)
+	if err == nil {
+		err = models.NotificationPreferenceSetEveryChain(db, user.ID, kind.Kind, models.NotificationChannelNone)
+		if err != nil {
+			slog.Error("Unable to unsubscribe from emails", "err", err)
+			c.String(http.StatusInternalServerError, "Unable to unsubscribe from emails")
+			return
+		}
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+// Returns the channel of each notification kind for the authenticated user,
+// with chain_uid the choices for that loop are included
+func NotificationPreferencesGet(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"omitempty,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	action := auth.ActionAnyUser
+	if query.ChainUID != "" {
+		action = auth.ActionChainRead
+	}
+	ok, authUser, chain := auth.Authorize(c, db, action, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	userPreferences, err := models.NotificationPreferenceGetAll(db, authUser.ID, 0)
+	if err != nil {
+		slog.Error("Unable to get notification preferences", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to get notification preferences")
+		return
+	}
+	chainPreferences := []models.NotificationPreference{}
+	if chain != nil {
+		chainPreferences, err = models.NotificationPreferenceGetAll(db, authUser.ID, chain.ID)
+		if err != nil {
+			slog.Error("Unable to get notification preferences", "err", err)
+			c.String(http.StatusInternalServerError, "Unable to get notification preferences")
+			return
+		}
+	}
+
+	result := []sharedtypes.NotificationPreference{}
+	for _, k := range models.NotificationKinds {
+		p := sharedtypes.NotificationPreference{
+			Kind:     k.Kind,
+			Default:  k.Default,
+			Channels: k.Channels,
+			Channel:  k.Default,
+		}
+		findKind := func(np models.NotificationPreference) bool { return np.Kind == k.Kind }
+		if np, ok := lo.Find(chainPreferences, findKind); ok {
+			p.Channel = np.Channel
+			p.IsChainOverride = true
+		} else if np, ok := lo.Find(userPreferences, findKind); ok {
+			p.Channel = np.Channel
+		}
+		result = append(result, p)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Sets the channel of a notification kind for the authenticated user, for all loops or only the given loop
+func NotificationPreferenceUpdate(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.NotificationPreferenceUpdateRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	kind, ok := models.NotificationKindGet(body.Kind)
+	if !ok {
+		c.String(http.StatusBadRequest, "Unknown notification kind")
+		return
+	}
+	if body.Channel != "" && !lo.Contains(kind.Channels, body.Channel) {
+		c.String(http.StatusBadRequest, "Channel is not available for this notification kind")
+		return
+	}
+
+	action := auth.ActionAnyUser
+	if body.ChainUID != "" {
+		action = auth.ActionChainRead
+	}
+	ok, authUser, chain := auth.Authorize(c, db, action, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	chainID := uint(0)
+	if chain != nil {
+		chainID = chain.ID
+	}
+	err := models.NotificationPreferenceSet(db, authUser.ID, chainID, kind.Kind, body.Channel)
+	if err != nil {
+		slog.Error("Unable to update notification preference", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to update notification preference")
+		return
+	}
+
+	// collecting a notification in the digest only works when the digest is sent
+	if body.Channel == models.NotificationChannelDigest {
+		err = models.NotificationPreferenceSet(db, authUser.ID, chainID, models.NotificationKindHostDigest, models.NotificationChannelEmail)
+		if err != nil {
+			slog.Error("Unable to subscribe to host digest", "err", err)
+			c.String(http.StatusInternalServerError, "Unable to update notification preference")
+			return
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/GGP1/atoll"
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+)
+
+// OpenID Connect provider, see https://openid.net/specs/openid-connect-core-1_0.html
+//
+// Only the authorization code flow is supported, users log in with the existing email login.
+
+const oidcScopesSupported = "openid email profile"
+
+func OidcDiscovery(c *gin.Context) {
+	issuer := app.OidcIssuer()
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/v2/oidc/authorize",
+		"token_endpoint":                        issuer + "/v2/oidc/token",
+		"userinfo_endpoint":                     issuer + "/v2/oidc/userinfo",
+		"jwks_uri":                              issuer + "/v2/oidc/jwks",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"scopes_supported":                      strings.Fields(oidcScopesSupported),
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"code_challenge_methods_supported":      []string{"S256", "plain"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "nonce", "email", "email_verified", "name", "preferred_username", "locale"},
+	})
+}
+
+func OidcJwks(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"keys": app.OidcJwks(),
+	})
+}
+
+func OidcAuthorize(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ResponseType        string `form:"response_type" binding:"required"`
+		ClientID            string `form:"client_id" binding:"required"`
+		RedirectURI         string `form:"redirect_uri" binding:"required,url"`
+		Scope               string `form:"scope"`
+		State               string `form:"state"`
+		Nonce               string `form:"nonce"`
+		CodeChallenge       string `form:"code_challenge"`
+		CodeChallengeMethod string `form:"code_challenge_method" binding:"omitempty,oneof=S256 plain"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the redirect uri is only trusted after the client is verified
+	client, err := app.OidcClientGet(query.ClientID)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if !client.HasRedirectURI(query.RedirectURI) {
+		c.String(http.StatusBadRequest, "Invalid redirect_uri")
+		return
+	}
+
+	redirectWithError := func(errCode string) {
+		v := url.Values{}
+		v.Set("error", errCode)
+		if query.State != "" {
+			v.Set("state", query.State)
+		}
+		c.Redirect(http.StatusFound, oidcAppendQuery(query.RedirectURI, v))
+	}
+
+	if query.ResponseType != "code" {
+		redirectWithError("unsupported_response_type")
+		return
+	}
+
+	// let the user login first, the frontend returns here afterwards
+	var user *models.User
+	if token, ok := auth.TokenReadFromRequest(c); ok {
+		user, _, err = auth.AuthenticateToken(db, token)
+	}
+	if user == nil || err != nil {
+		authorizeURL := app.OidcIssuer() + "/v2/oidc/authorize?" + c.Request.URL.RawQuery
+		c.Redirect(http.StatusFound, app.Config.SITE_BASE_URL_FE+"/users/login/?redirect="+url.QueryEscape(authorizeURL))
+		return
+	}
+
+	codeB, err := atoll.NewPassword(32, []atoll.Level{atoll.Digit, atoll.Lower, atoll.Upper})
+	if err != nil {
+		slog.Error("Unable to create authorization code", "err", err)
+		redirectWithError("server_error")
+		return
+	}
+	code := string(codeB)
+	authCode := &models.OidcAuthCode{
+		ClientID:            client.ClientID,
+		UserID:              user.ID,
+		RedirectURI:         query.RedirectURI,
+		Scope:               query.Scope,
+		Nonce:               query.Nonce,
+		CodeChallenge:       query.CodeChallenge,
+		CodeChallengeMethod: query.CodeChallengeMethod,
+	}
+	err = authCode.Create(db, code)
+	if err != nil {
+		slog.Error("Unable to create authorization code", "err", err)
+		redirectWithError("server_error")
+		return
+	}
+
+	v := url.Values{}
+	v.Set("code", code)
+	if query.State != "" {
+		v.Set("state", query.State)
+	}
+	c.Redirect(http.StatusFound, oidcAppendQuery(query.RedirectURI, v))
+}
+
+func OidcToken(c *gin.Context) {
+	db := getDB(c)
+
+	var body struct {
+		GrantType    string `form:"grant_type" binding:"required"`
+		Code         string `form:"code" binding:"required"`
+		RedirectURI  string `form:"redirect_uri"`
+		ClientID     string `form:"client_id"`
+		ClientSecret string `form:"client_secret"`
+		CodeVerifier string `form:"code_verifier"`
+	}
+	if err := c.ShouldBind(&body); err != nil {
+		oidcTokenError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	if body.GrantType != "authorization_code" {
+		oidcTokenError(c, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	// client_secret_basic takes precedence over client_secret_post
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		body.ClientID, _ = url.QueryUnescape(clientID)
+		body.ClientSecret, _ = url.QueryUnescape(clientSecret)
+	}
+	client, err := app.OidcClientGet(body.ClientID)
+	if err != nil || !client.CheckSecret(body.ClientSecret) {
+		oidcTokenError(c, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	authCode, err := models.OidcAuthCodeUse(db, body.Code)
+	if err != nil {
+		oidcTokenError(c, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	if authCode.ClientID != client.ClientID || authCode.RedirectURI != body.RedirectURI ||
+		!auth.OidcCodeChallengeVerify(authCode.CodeChallenge, authCode.CodeChallengeMethod, body.CodeVerifier) {
+		oidcTokenError(c, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	user := &models.User{}
+	db.Raw(`SELECT * FROM users WHERE id = ? LIMIT 1`, authCode.UserID).Scan(user)
+	if user.ID == 0 {
+		oidcTokenError(c, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	accessToken, err := auth.OidcAccessTokenGenerate(user, client.ClientID, authCode.Scope)
+	if err != nil {
+		slog.Error("Unable to create access token", "err", err)
+		oidcTokenError(c, http.StatusInternalServerError, "server_error")
+		return
+	}
+	res := gin.H{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(auth.OidcAccessTokenDuration.Seconds()),
+		"scope":        authCode.Scope,
+	}
+	if strings.Contains(" "+authCode.Scope+" ", " openid ") {
+		idToken, err := auth.OidcIDTokenGenerate(user, client, authCode.Nonce)
+		if err != nil {
+			slog.Error("Unable to create id token", "err", err)
+			oidcTokenError(c, http.StatusInternalServerError, "server_error")
+			return
+		}
+		res["id_token"] = idToken
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, res)
+}
+
+func OidcUserInfo(c *gin.Context) {
+	db := getDB(c)
+
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.String(http.StatusUnauthorized, "Token not received")
+		return
+	}
+	claims, err := auth.OidcAccessTokenVerify(token)
+	if err != nil || len(claims.Audience) == 0 {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.String(http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	client, err := app.OidcClientGet(claims.Audience[0])
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.String(http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	user, err := models.UserGetByUID(db, claims.Subject, false)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.String(http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	c.JSON(http.StatusOK, auth.OidcUserInfoGet(user, client))
+}
+
+func oidcTokenError(c *gin.Context, status int, errCode string) {
+	c.JSON(status, gin.H{"error": errCode})
+}
+
+func oidcAppendQuery(uri string, v url.Values) string {
+	if strings.Contains(uri, "?") {
+		return uri + "&" + v.Encode()
+	}
+	return uri + "?" + v.Encode()
+}
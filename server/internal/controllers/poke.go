@@ -20,7 +20,7 @@ func Poke(c *gin.Context) {
 		return
 	}
 
-	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	ok, user, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
 	if !ok {
 		return
 	}
@@ -34,10 +34,11 @@ func Poke(c *gin.Context) {
 		Name      string `gorm:"name"`
 		Email     string `gorm:"email"`
 		ChainName string `gorm:"chain_name"`
+		ChainID   uint   `gorm:"chain_id"`
 		I18n      string `gorm:"i18n"`
 	}{}
 	db.Raw(`
-SELECT u.name AS name, u.email AS email, c.name AS chain_name FROM users AS u
+SELECT u.name AS name, u.email AS email, c.name AS chain_name, c.id AS chain_id FROM users AS u
 LEFT JOIN user_chains AS uc ON u.id = uc.user_id
 LEFT JOIN chains AS c ON c.id = uc.chain_id
 WHERE uc.is_chain_admin = TRUE AND u.email IS NOT NULL AND uc.chain_id IN (
@@ -55,7 +56,7 @@ WHERE uc.is_chain_admin = TRUE AND u.email IS NOT NULL AND uc.chain_id IN (
 	}
 
 	for _, v := range userAdmins {
-		go views.EmailPoke(db, v.I18n,
+		views.EmailPoke(db, v.ChainID, v.I18n,
 			v.Name,
 			v.Email,
 			user.Name,
@@ -28,7 +28,7 @@ func RouteOrderGet(c *gin.Context) {
 		return
 	}
 
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, query.ChainUID)
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChainRead, query.ChainUID)
 	if !ok {
 		return
 	}
@@ -51,7 +51,8 @@ func RouteOrderSet(c *gin.Context) {
 		return
 	}
 
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, query.ChainUID)
+	// every participant of the loop may change the route order
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChainRead, query.ChainUID)
 	if !ok {
 		return
 	}
@@ -74,8 +75,7 @@ func RouteOptimize(c *gin.Context) {
 		return
 	}
 
-	// the authenticated user should be a chain admin
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+	ok, _, chain := auth.Authorize(c, db, auth.ActionRouteWrite, query.ChainUID)
 	if !ok {
 		return
 	}
@@ -107,16 +107,15 @@ func GetRouteCoordinates(c *gin.Context) {
 	var chain *models.Chain
 	var authUser *models.User
 	if query.UserUID == "" {
-		// the authenticated user should be a chain admin
-		ok, authUser, chain = auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+		ok, authUser, chain = auth.Authorize(c, db, auth.ActionMembersReadPrivate, query.ChainUID)
 	} else {
 		ok, _, authUser, chain = auth.AuthenticateUserOfChain(c, db, query.ChainUID, query.UserUID)
 	}
 	if !ok {
 		return
 	}
-	_, isChainAdmin := authUser.IsPartOfChain(query.ChainUID)
-	if !(isChainAdmin || authUser.IsRootAdmin) && !chain.AllowMap {
+	canReadPrivate := auth.HasPermission(db, authUser, chain, auth.ActionMembersReadPrivate)
+	if !chain.AllowMap && !canReadPrivate {
 		c.String(http.StatusNotAcceptable, "Map is hidden by the loop host")
 		return
 	}
@@ -137,8 +136,8 @@ func GetRouteCoordinates(c *gin.Context) {
 		}
 		isCloseBy := lo.Contains(closeBy, item.UserUID)
 		isMe := authUser.UID == item.UserUID
-		if !(isMe || isChainAdmin || authUser.IsRootAdmin || isCloseBy) {
-			slog.Debug("Participant censorship", "uid", item.UserUID, "isChainAdmin", isChainAdmin, "isRootAdmin", authUser.IsRootAdmin, "isCloseBy", isCloseBy)
+		if !(isMe || canReadPrivate || isCloseBy) {
+			slog.Debug("Participant censorship", "uid", item.UserUID, "canReadPrivate", canReadPrivate, "isCloseBy", isCloseBy)
 			item.UserUID = ""
 
 			// Randomize the 5 & 6th decimal places
@@ -192,6 +191,7 @@ func retrieveChainUsersAsTspCities(db *gorm.DB, chainID uint) *ArrTspCityWithIsP
 	LEFT JOIN users ON user_chains.user_id = users.id
 	WHERE user_chains.chain_id = ? 
 	AND users.is_email_verified = TRUE 
+	AND users.purge_at IS NULL
 	AND user_chains.is_approved = TRUE
 	ORDER BY user_chains.route_order ASC`, "`", "`"), chainID).Scan(&allUserChains.Arr).Error
 	if err != nil {
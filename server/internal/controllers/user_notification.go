@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+// Returns the inbox of the authenticated user, newest first
+func UserNotificationGetAll(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		Page     int `form:"page" binding:"omitempty,min=0"`
+		PageSize int `form:"page_size" binding:"omitempty,min=1"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.PageSize == 0 {
+		query.PageSize = models.UserNotificationPageSize
+	} else if query.PageSize > models.UserNotificationPageSizeMax {
+		query.PageSize = models.UserNotificationPageSizeMax
+	}
+
+	ok, authUser, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
+	if !ok {
+		return
+	}
+
+	notifications, err := models.UserNotificationGetAll(db, authUser.ID, query.Page, query.PageSize)
+	if err != nil {
+		slog.Error("Unable to get notifications", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to get notifications")
+		return
+	}
+	unread, err := models.UserNotificationCountUnread(db, authUser.ID)
+	if err != nil {
+		slog.Error("Unable to count unread notifications", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to get notifications")
+		return
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.UserNotificationListResponse{
+		Notifications: notifications,
+		Unread:        unread,
+	})
+}
+
+func UserNotificationMarkRead(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.UserNotificationMarkReadRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, authUser, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
+	if !ok {
+		return
+	}
+
+	err := models.UserNotificationMarkRead(db, authUser.ID, body.IDs)
+	if err != nil {
+		slog.Error("Unable to mark notifications as read", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to mark notifications as read")
+		return
+	}
+}
+
+func UserNotificationMarkAllRead(c *gin.Context) {
+	db := getDB(c)
+
+	ok, authUser, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
+	if !ok {
+		return
+	}
+
+	err := models.UserNotificationMarkAllRead(db, authUser.ID)
+	if err != nil {
+		slog.Error("Unable to mark notifications as read", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to mark notifications as read")
+		return
+	}
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/samber/lo"
+	uuid "github.com/satori/go.uuid"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/models"
@@ -45,14 +47,14 @@ func UserGet(c *gin.Context) {
 	ok := false
 	var authUser *models.User
 	if query.ChainUID == "" {
-		ok, authUser, _ = auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+		ok, authUser, _ = auth.Authorize(c, db, auth.ActionAnyUser, "")
 
 		if !ok || query.UserUID != authUser.UID {
 			c.String(http.StatusUnauthorized, "For elevated privileges include a chain_uid")
 			return
 		}
 	} else {
-		ok, authUser, _ = auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+		ok, authUser, _ = auth.Authorize(c, db, auth.ActionMembersReadPrivate, query.ChainUID)
 	}
 	if !ok {
 		return
@@ -82,6 +84,11 @@ func UserGet(c *gin.Context) {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
+		err = user.AddNotificationUnread(db)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
 	}
 
 	if query.AddApprovedTOH {
@@ -102,13 +109,12 @@ func UserGetAllOfChain(c *gin.Context) {
 		return
 	}
 
-	ok, authUser, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, query.ChainUID)
+	ok, authUser, chain := auth.Authorize(c, db, auth.ActionChainRead, query.ChainUID)
 	if !ok {
 		return
 	}
 
-	_, isAuthUserChainAdmin := authUser.IsPartOfChain(chain.UID)
-	isAuthState3AdminChainUser := isAuthUserChainAdmin || authUser.IsRootAdmin
+	canReadPrivate := auth.HasPermission(db, authUser, chain, auth.ActionMembersReadPrivate)
 
 	// retrieve user from query
 	tx := db.Begin()
@@ -140,7 +146,12 @@ func UserGetAllOfChain(c *gin.Context) {
 	}
 
 	// omit user data from participants
-	if !isAuthState3AdminChainUser {
+	if !canReadPrivate {
+		for i := range users {
+			if users[i].ID != authUser.ID {
+				users[i].EmailBouncedAt = nil
+			}
+		}
 		users, err = models.UserOmitData(db, chain, users, authUser.ID)
 
 		if err != nil {
@@ -325,7 +336,7 @@ func UserPurge(c *gin.Context) {
 		return
 	}
 
-	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	ok, user, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
 	if !ok {
 		return
 	}
@@ -342,167 +353,54 @@ func UserPurge(c *gin.Context) {
 		}
 	}
 
-	amountOfBags, err := user.CountAttachedBags(db)
+	err := user.AddUserChainsToObject(db)
 	if err != nil {
-		slog.Error("Error getting bag count", "err", err)
-	}
-	if amountOfBags != 0 {
-		c.String(http.StatusConflict, "Please give your bags to someone else, or delete them from the app")
+		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
-	err = user.AddUserChainsToObject(db)
+	_, err = services.UserPurgeCheck(db, user, true)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		c.String(http.StatusConflict, err.Error())
 		return
 	}
 
-	// find chains where user is the last chain admin
-	chainIDsToDelete := []uint{}
-	db.Raw(`
-SELECT uc.chain_id
-FROM  user_chains AS uc
-WHERE uc.chain_id IN (
-	SELECT uc2.chain_id
-	FROM user_chains AS uc2
-	WHERE uc2.is_chain_admin = TRUE AND uc2.user_id = ?
-) AND uc.is_chain_admin = TRUE
-GROUP BY uc.chain_id
-HAVING COUNT(uc.id) = 1
-	`, user.ID).Scan(&chainIDsToDelete)
-	participantsToBeOrphaned := int64(0)
-	db.Raw("SELECT COUNT(id) FROM user_chains WHERE chain_id IN ? AND is_chain_admin = FALSE AND is_approved = TRUE AND user_id != ?", chainIDsToDelete, user.ID).Count(&participantsToBeOrphaned)
-	if participantsToBeOrphaned > 0 {
-		c.String(http.StatusConflict, "Set someone else as host or delete the loop first before deleting your account")
+	// the account is deleted by a cron job after the grace period
+	_, err = services.UserPurgeSchedule(db, user, reasonsForLeaving, otherExplanation, uuid.NewV4().String())
+	if errors.Is(err, services.ErrUserPurgeScheduled) {
+		c.String(http.StatusConflict, err.Error())
 		return
-	}
-
-	deletedUser := models.DeletedUser{
-		Email:            lo.FromPtr(user.Email),
-		UserCreatedAt:    user.CreatedAt,
-		UserDeletedAt:    time.Now(),
-		OtherExplanation: otherExplanation,
-	}
-
-	if err := deletedUser.SetReasons(reasonsForLeaving); err != nil {
-		c.String(http.StatusBadRequest, err.Error())
+	} else if err != nil {
+		slog.Error("Unable to schedule account deletion", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to schedule account deletion")
 		return
 	}
 
-	tx := db.Begin()
+	auth.CookieRemove(c)
+}
 
-	if err := tx.Create(&deletedUser).Error; err != nil {
-		tx.Rollback()
-		c.String(http.StatusInternalServerError, "Failed to add deleted user to database")
-		return
-	}
+// Cancels a scheduled account deletion from the confirmation page of the link in the email
+func UserPurgeCancel(c *gin.Context) {
+	db := getDB(c)
 
-	err = user.DeleteUserChainDependenciesAllChains(tx)
-	if err != nil {
-		tx.Rollback()
-		slog.Error("UserPurge", "err", err)
-		c.String(http.StatusInternalServerError, "Unable to disconnect bag connections")
+	var body sharedtypes.UserPurgeCancelRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
-	err = tx.Exec(`
-UPDATE events SET user_id = (
-	SELECT id FROM users WHERE is_root_admin = 1 LIMIT 1
-) WHERE user_id = ?
-	`, user.ID).Error
-	if err != nil {
-		tx.Rollback()
-		slog.Error("UserPurge: Unable to remove event connections", "err", err)
-		c.String(http.StatusInternalServerError, "Unable to remove event connections")
-		return
-	}
-	err = tx.Exec(`DELETE FROM user_chains WHERE user_id = ?`, user.ID).Error
-	if err != nil {
-		tx.Rollback()
-		slog.Error("UserPurge: Unable to remove loop connections", "err", err)
-		c.String(http.StatusInternalServerError, "Unable to remove loop connections")
-		return
-	}
-	err = tx.Exec(`DELETE FROM user_tokens WHERE user_id = ?`, user.ID).Error
-	if err != nil {
-		tx.Rollback()
-		slog.Error("UserPurge: Unable to remove token connections", "err", err)
-		c.String(http.StatusInternalServerError, "Unable to remove token connections")
-		return
-	}
-	err = tx.Exec(`DELETE FROM user_onesignals WHERE user_id = ?`, user.ID).Error
+	schedule, err := models.UserPurgeScheduleGetByToken(db, body.Token)
 	if err != nil {
-		tx.Rollback()
-		slog.Error("UserPurge: Unable to remove onesignal connections", "err", err)
-		c.String(http.StatusInternalServerError, "Unable to remove onesignal connections")
+		c.String(http.StatusNotFound, err.Error())
 		return
 	}
-	err = tx.Exec(`DELETE FROM users WHERE id = ?`, user.ID).Error
+
+	err = schedule.Cancel(db)
 	if err != nil {
-		tx.Rollback()
-		slog.Error("UserPurge: Unable to user", "err", err)
-		c.String(http.StatusInternalServerError, "Unable to user")
+		slog.Error("Unable to cancel account deletion", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to cancel account deletion")
 		return
 	}
-
-	slog.Info("Purging chains", "chainIDsToDelete", chainIDsToDelete)
-	if len(chainIDsToDelete) > 0 {
-		err := tx.Exec(`DELETE FROM bags WHERE user_chain_id IN (
-			SELECT id FROM user_chains WHERE chain_id IN ?
-		)`, chainIDsToDelete).Error
-		if err != nil {
-			tx.Rollback()
-			slog.Error("UserPurge", "err", err)
-			c.String(http.StatusInternalServerError, "Unable to disconnect all loop bag connections")
-			return
-		}
-		err = tx.Exec(`DELETE FROM user_chains WHERE chain_id IN ?`, chainIDsToDelete).Error
-		if err != nil {
-			tx.Rollback()
-			slog.Error("UserPurge: Unable to remove hosted loop connections", "err", err)
-			c.String(http.StatusInternalServerError, "Unable to remove hosted loop connections")
-			return
-		}
-		err = tx.Exec(`DELETE FROM chains WHERE id IN ?`, chainIDsToDelete).Error
-		if err != nil {
-			tx.Rollback()
-			slog.Error("UserPurge: Unable to remove hosted loop", "err", err)
-			c.String(http.StatusInternalServerError, "Unable to remove hosted loop")
-			return
-		}
-	}
-
-	if user.Email != nil {
-		err = tx.Exec(`DELETE FROM newsletters WHERE email = ?`, user.Email).Error
-		if err != nil {
-			tx.Rollback()
-			slog.Error("UserPurge: Unable to remove newsletter", "err", err)
-			c.String(http.StatusInternalServerError, "Unable to remove newsletter")
-			return
-		}
-
-		views.EmailAccountDeletedSuccessfully(db, user.I18n, user.Name, *user.Email)
-
-		if app.Brevo != nil {
-			app.Brevo.DeleteContact(c.Request.Context(), *user.Email)
-		}
-	}
-
-	tx.Commit()
-
-	// notify connected hosts, send email to chain admins
-	chainIDs := []uint{}
-	for _, uc := range user.Chains {
-		chainIDs = append(chainIDs, uc.ChainID)
-	}
-
-	services.EmailLoopAdminsOnUserLeft(db,
-		user.Name,
-		*user.Email,
-		*user.Email,
-		chainIDs...)
-
-	auth.CookieRemove(c)
 }
 
 func UserTransferChain(c *gin.Context) {
@@ -514,14 +412,15 @@ func UserTransferChain(c *gin.Context) {
 		return
 	}
 
-	ok, authUser, authChain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.FromChainUID)
+	ok, authUser, authChain := auth.Authorize(c, db, auth.ActionMembersManage, body.FromChainUID)
 	if !ok {
 		return
 	}
 
 	if !authUser.IsRootAdmin {
-		_, isChainAdmin := authUser.IsPartOfChain(body.ToChainUID)
-		if !isChainAdmin {
+		toChain := &models.Chain{}
+		db.Raw(`SELECT * FROM chains WHERE uid = ? AND deleted_at IS NULL LIMIT 1`, body.ToChainUID).Scan(toChain)
+		if toChain.ID == 0 || !auth.HasPermission(db, authUser, toChain, auth.ActionMembersManage) {
 			c.String(http.StatusUnauthorized, "you must be a host of both loops")
 			return
 		}
@@ -602,6 +501,10 @@ LIMIT 1
 		err = tx.Commit().Error
 		if err != nil {
 			handleError(tx, err)
+			return
+		}
+		if !body.IsCopy {
+			services.ChatSyncChainsLater(db, result.FromChainID)
 		}
 		return
 	} else if body.IsCopy {
@@ -634,6 +537,9 @@ LIMIT 1
 		handleError(tx, err)
 		return
 	}
+	if !body.IsCopy {
+		services.ChatSyncChainsLater(db, result.FromChainID)
+	}
 }
 
 func UserCheckIfEmailExists(c *gin.Context) {
@@ -654,3 +560,197 @@ func UserCheckIfEmailExists(c *gin.Context) {
 	}
 	c.JSON(200, found)
 }
+
+// Sends a one time password to the new email address, the email address is only changed after UserEmailChangeVerify
+func UserEmailChange(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.UserEmailChangeRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, _, _ := auth.AuthenticateUserOfChain(c, db, "", body.UserUID)
+	if !ok {
+		return
+	}
+
+	if user.Email != nil && *user.Email == body.NewEmail {
+		c.String(http.StatusBadRequest, "New email address is the same as the current email address")
+		return
+	}
+	_, found, err := models.UserCheckEmail(db, body.NewEmail)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error Checking user email")
+		return
+	}
+	if found {
+		c.String(http.StatusConflict, "Email address is already in use")
+		return
+	}
+
+	token, err := auth.OtpEmailChangeCreate(db, user.ID, body.NewEmail)
+	if err != nil {
+		slog.Error("Unable to create token", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to create token")
+		return
+	}
+
+	err = views.EmailChangeVerification(db, user.I18n, user.Name, body.NewEmail, token)
+	if err != nil {
+		slog.Error("Unable to send email", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to send email")
+		return
+	}
+}
+
+// Changes the email address of the user, this logs out all other sessions of the user
+func UserEmailChangeVerify(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.UserEmailChangeVerifyRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, authUser, _ := auth.AuthenticateUserOfChain(c, db, "", body.UserUID)
+	if !ok {
+		return
+	}
+
+	emailChange, err := models.UserEmailChangeGetByToken(db, user.ID, body.OTP)
+	if err != nil {
+		c.String(http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	oldEmail := ""
+	if user.Email != nil {
+		oldEmail = *user.Email
+	}
+
+	tx := db.Begin()
+	_, found, err := models.UserCheckEmail(tx, emailChange.NewEmail)
+	if err != nil || found {
+		tx.Rollback()
+		c.String(http.StatusConflict, "Email address is already in use")
+		return
+	}
+
+	hasNewsletter, err := user.ChangeEmail(tx, emailChange.NewEmail)
+	if err != nil {
+		tx.Rollback()
+		slog.Error("Unable to change email address", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to change email address")
+		return
+	}
+
+	// the Brevo contact is moved before committing so that a failure leaves everything as it was
+	if hasNewsletter && app.Brevo != nil {
+		ctx := c.Request.Context()
+		err = app.Brevo.CreateContact(ctx, emailChange.NewEmail)
+		if err != nil {
+			tx.Rollback()
+			slog.Error("Unable to create newsletter contact", "err", err)
+			c.String(http.StatusInternalServerError, "Unable to change email address")
+			return
+		}
+		if oldEmail != "" {
+			app.Brevo.DeleteContact(ctx, oldEmail)
+		}
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		if hasNewsletter && app.Brevo != nil {
+			ctx := c.Request.Context()
+			app.Brevo.DeleteContact(ctx, emailChange.NewEmail)
+			if oldEmail != "" {
+				app.Brevo.CreateContact(ctx, oldEmail)
+			}
+		}
+		slog.Error("Unable to change email address", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to change email address")
+		return
+	}
+
+	if oldEmail != "" {
+		views.EmailChanged(db, user.I18n, user.Name, oldEmail, emailChange.NewEmail)
+	}
+
+	// only renew the session if the user changed their own email address
+	if authUser.ID == user.ID {
+		token, err := auth.JwtGenerate(user)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Unable to create token")
+			return
+		}
+		auth.CookieSet(c, user.UID, token)
+		c.JSON(http.StatusOK, gin.H{
+			"user":  user,
+			"token": token,
+		})
+	}
+}
+
+// Starts an export of all data of the user, the download link is emailed when the export is ready
+func UserDataExport(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.UserDataExportRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, _, _ := auth.AuthenticateUserOfChain(c, db, "", body.UserUID)
+	if !ok {
+		return
+	}
+	if user.Email == nil {
+		c.String(http.StatusBadRequest, "An email address is required to receive the export")
+		return
+	}
+
+	if models.UserDataExportHasPending(db, user.ID) {
+		c.String(http.StatusConflict, "An export is already being created")
+		return
+	}
+
+	export := &models.UserDataExport{
+		UserID: user.ID,
+		Token:  uuid.NewV4().String(),
+		Status: models.UserDataExportStatusPending,
+	}
+	err := db.Create(export).Error
+	if err != nil {
+		slog.Error("Unable to create user data export", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to create export")
+		return
+	}
+
+	go services.UserDataExportRun(db, user, export)
+}
+
+func UserDataExportDownload(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		Token string `form:"token" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	export, err := models.UserDataExportGetByToken(db, query.Token)
+	if err != nil {
+		c.String(http.StatusNotFound, err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.FileAttachment(export.FilePath, fmt.Sprintf("clothingloop-data-%s.zip", export.CreatedAt.Format(time.DateOnly)))
+}
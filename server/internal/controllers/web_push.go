@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/pkg/webpush"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+// Returns the applicationServerKey the browser subscribes with
+func WebPushPublicKey(c *gin.Context) {
+	c.JSON(http.StatusOK, sharedtypes.WebPushPublicKeyResponse{
+		PublicKey: app.WebPushPublicKey(),
+	})
+}
+
+// Registers the browser of the authenticated user to receive push notifications
+func WebPushSubscriptionPut(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.WebPushSubscriptionRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, authUser, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
+	if !ok {
+		return
+	}
+
+	if err := webpush.ValidateEndpoint(body.Endpoint); err != nil {
+		c.String(http.StatusBadRequest, "Unsupported push service")
+		return
+	}
+
+	sub := webpush.Subscription{
+		Endpoint: body.Endpoint,
+		P256dh:   body.P256dh,
+		Auth:     body.Auth,
+	}
+	if _, err := webpush.Encrypt(sub, []byte{}); err != nil {
+		c.String(http.StatusBadRequest, "Invalid subscription keys")
+		return
+	}
+
+	err := models.UserWebPushSubscriptionPut(db, authUser.ID, sub)
+	if err != nil {
+		slog.Error("Unable to save web push subscription", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to save web push subscription")
+		return
+	}
+}
+
+// Stops push notifications to the browser, for example on logout
+func WebPushSubscriptionDelete(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.WebPushSubscriptionDeleteRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, authUser, _ := auth.Authorize(c, db, auth.ActionAnyUser, "")
+	if !ok {
+		return
+	}
+
+	err := db.Exec(`DELETE FROM user_web_push_subscriptions WHERE endpoint = ? AND user_id = ?`, body.Endpoint, authUser.ID).Error
+	if err != nil {
+		slog.Error("Unable to remove web push subscription", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to remove web push subscription")
+		return
+	}
+}
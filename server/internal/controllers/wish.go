@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func WishGetAll(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		ChainUID         string `form:"chain_uid" binding:"required,uuid"`
+		IncludeFulfilled bool   `form:"include_fulfilled"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authorize(c, db, auth.ActionChainRead, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	wishes, err := models.WishGetAllByChain(db, chain.ID, query.IncludeFulfilled)
+	if err != nil {
+		slog.Error("Unable to find wishes", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to find wishes")
+		return
+	}
+
+	c.JSON(http.StatusOK, wishes)
+}
+
+// Members can only create and change their own wishes
+func WishPut(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.WishPutRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Category != nil && *(body.Category) != "" && !models.ValidateAllBulkyItemCategories([]string{*(body.Category)}) {
+		c.String(http.StatusBadRequest, models.ErrBulkyItemCategoryInvalid.Error())
+		return
+	}
+	if body.Sizes != nil {
+		if ok := models.ValidateAllSizeEnum(*(body.Sizes)); !ok {
+			c.String(http.StatusBadRequest, models.ErrSizeInvalid.Error())
+			return
+		}
+	}
+	if body.Genders != nil {
+		if ok := models.ValidateAllGenderEnum(*(body.Genders)); !ok {
+			c.String(http.StatusBadRequest, models.ErrGenderInvalid.Error())
+			return
+		}
+	}
+
+	ok, user, chain := auth.Authorize(c, db, auth.ActionChainRead, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	wish := &models.Wish{
+		ChainID: chain.ID,
+		UserID:  user.ID,
+		Sizes:   []string{},
+		Genders: []string{},
+	}
+	if body.ID != 0 {
+		var err error
+		wish, err = models.WishGetByIDInChain(db, chain.ID, body.ID)
+		if err != nil {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if wish.UserID != user.ID {
+			c.String(http.StatusForbidden, "Only your own wishes can be changed")
+			return
+		}
+	} else if body.Title == nil {
+		c.String(http.StatusBadRequest, "A title is required")
+		return
+	}
+
+	if body.Title != nil {
+		wish.Title = *(body.Title)
+	}
+	if body.Message != nil {
+		wish.Message = *(body.Message)
+	}
+	if body.Category != nil {
+		wish.Category = *(body.Category)
+	}
+	if body.Sizes != nil {
+		wish.Sizes = *(body.Sizes)
+	}
+	if body.Genders != nil {
+		wish.Genders = *(body.Genders)
+	}
+	if body.Fulfilled != nil {
+		wish.Fulfilled = *(body.Fulfilled)
+	}
+
+	err := db.Save(wish).Error
+	if err != nil {
+		slog.Error("Unable to create or update wish", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to create or update wish")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": wish.ID})
+}
+
+// Hosts that manage the members can remove any wish of the loop
+func WishRemove(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		ID       uint   `form:"id" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, chain := auth.Authorize(c, db, auth.ActionChainRead, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	wish, err := models.WishGetByIDInChain(db, chain.ID, query.ID)
+	if err != nil {
+		c.String(http.StatusNotFound, err.Error())
+		return
+	}
+	if wish.UserID != user.ID && !auth.HasPermission(db, user, chain, auth.ActionMembersManage) {
+		c.String(http.StatusForbidden, "Only your own wishes can be removed")
+		return
+	}
+
+	err = db.Exec(`DELETE FROM wishes WHERE id = ?`, wish.ID).Error
+	if err != nil {
+		slog.Error("Unable to remove wish", "err", err)
+		c.String(http.StatusInternalServerError, "Unable to remove wish")
+		return
+	}
+}
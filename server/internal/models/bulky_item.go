@@ -1,27 +1,193 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/samber/lo"
 	"github.com/the-clothing-loop/website/server/sharedtypes"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrBulkyItemNotFound        = errors.New("Bulky item not found")
+	ErrBulkyItemCategoryInvalid = errors.New("Invalid bulky item category")
+	ErrBulkyItemNotAvailable    = errors.New("Bulky item is not available")
+)
+
+// Words shorter than the innodb_ft_min_token_size are not in the full-text index
+const bulkyItemSearchMinWordLength = 3
+
 type BulkyItem sharedtypes.BulkyItem
 
-func GetAllBulkyItemsByChain(db *gorm.DB, chainID uint) ([]BulkyItem, error) {
+const bulkyItemSQLSelect = `SELECT
+	bulky_items.id                     AS id,
+	bulky_items.title                  AS title,
+	bulky_items.message                AS message,
+	bulky_items.image_url              AS image_url,
+	bulky_items.user_chain_id          AS user_chain_id,
+	c.uid                              AS chain_uid,
+	u.uid                              AS user_uid,
+	bulky_items.status                 AS status,
+	bulky_items.category               AS category,
+	bulky_items.tags                   AS tags,
+	bulky_items.sizes                  AS sizes,
+	bulky_items.genders                AS genders,
+	bulky_items.receiver_user_chain_id AS receiver_user_chain_id,
+	ru.uid                             AS receiver_uid,
+	bulky_items.last_notified_at       AS last_notified_at,
+	bulky_items.created_at             AS created_at,
+	bulky_items.updated_at             AS updated_at
+FROM bulky_items
+LEFT JOIN user_chains AS uc ON uc.id = bulky_items.user_chain_id
+LEFT JOIN chains AS c ON c.id = uc.chain_id
+LEFT JOIN users AS u ON u.id = uc.user_id
+LEFT JOIN user_chains AS ruc ON ruc.id = bulky_items.receiver_user_chain_id
+LEFT JOIN users AS ru ON ru.id = ruc.user_id
+`
+
+type BulkyItemFilter struct {
+	// All statuses when empty
+	Statuses []string
+	Category string
+	Tag      string
+	// Items with any of the sizes
+	Sizes []string
+	// Items with any of the genders
+	Genders []string
+	// Full-text search of the title, message and tags
+	Search string
+}
+
+func GetAllBulkyItemsByChain(db *gorm.DB, chainID uint, filter BulkyItemFilter) ([]BulkyItem, error) {
 	res := []BulkyItem{}
-	err := db.Raw(`
-SELECT * FROM bulky_items
-WHERE user_chain_id IN (
-	SELECT UNIQUE(chain_id) FROM user_chains
-	WHERE chain_id = ?
-)
-	`, chainID).Scan(&res).Error
+	sql := bulkyItemSQLSelect + `WHERE bulky_items.user_chain_id IN (
+	SELECT uc2.id FROM user_chains AS uc2
+	WHERE uc2.chain_id = ?
+)`
+	args := []any{chainID}
+	if len(filter.Statuses) > 0 {
+		sql += ` AND bulky_items.status IN ?`
+		args = append(args, filter.Statuses)
+	}
+	if filter.Category != "" {
+		sql += ` AND bulky_items.category = ?`
+		args = append(args, filter.Category)
+	}
+	if tags := BulkyItemNormalizeTags([]string{filter.Tag}); len(tags) > 0 {
+		sql += ` AND JSON_CONTAINS(bulky_items.tags, JSON_QUOTE(?))`
+		args = append(args, tags[0])
+	}
+	if len(filter.Sizes) > 0 {
+		sql += ` AND (` + bulkyItemSQLLikeAny("bulky_items.sizes", len(filter.Sizes)) + `)`
+		for _, size := range filter.Sizes {
+			args = append(args, fmt.Sprintf(`%%"%s"%%`, size))
+		}
+	}
+	if len(filter.Genders) > 0 {
+		sql += ` AND (` + bulkyItemSQLLikeAny("bulky_items.genders", len(filter.Genders)) + `)`
+		for _, gender := range filter.Genders {
+			args = append(args, fmt.Sprintf(`%%"%s"%%`, gender))
+		}
+	}
+	if search := bulkyItemSearchQuery(filter.Search); search != "" {
+		sql += ` AND MATCH(bulky_items.title, bulky_items.message, bulky_items.tags) AGAINST(? IN BOOLEAN MODE)`
+		args = append(args, search)
+	}
+	sql += `
+ORDER BY bulky_items.created_at DESC`
+	err := db.Raw(sql, args...).Scan(&res).Error
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+func bulkyItemSQLLikeAny(column string, n int) string {
+	return strings.Join(lo.Times(n, func(_ int) string { return column + " LIKE ?" }), " OR ")
+}
+
+// Turns the words of the search into a boolean mode query where every word must be the start of a word in the item
+func bulkyItemSearchQuery(search string) string {
+	words := strings.FieldsFunc(search, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	query := []string{}
+	for _, w := range words {
+		if len([]rune(w)) < bulkyItemSearchMinWordLength {
+			continue
+		}
+		query = append(query, "+"+w+"*")
+	}
+	return strings.Join(query, " ")
+}
+
+// Lowercase, trimmed and without duplicates
+func BulkyItemNormalizeTags(tags []string) []string {
+	res := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !lo.Contains(res, tag) {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
+
+func ValidateAllBulkyItemCategories(arr []string) bool {
+	if err := validate.Var(arr, "unique"); err != nil {
+		return false
+	}
+	for _, s := range arr {
+		if !lo.Contains(sharedtypes.BulkyItemCategories, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func BulkyItemGetByIDInChain(db *gorm.DB, chainID, id uint) (*BulkyItem, error) {
+	bulkyItem := &BulkyItem{}
+	err := db.Raw(bulkyItemSQLSelect+`WHERE bulky_items.id = ? AND uc.chain_id = ? LIMIT 1`, id, chainID).Scan(bulkyItem).Error
+	if err != nil {
+		return nil, err
+	}
+	if bulkyItem.ID == 0 {
+		return nil, ErrBulkyItemNotFound
+	}
+	return bulkyItem, nil
+}
+
+// Does not renew the item, only the author does that by editing it.
+// Returns ErrBulkyItemNotAvailable when the status or receiver was changed by someone else since it was read.
+func (b *BulkyItem) SetStatus(db *gorm.DB, status string, receiverUserChainID *uint) error {
+	res := db.Exec(`
+UPDATE bulky_items SET status = ?, receiver_user_chain_id = ?
+WHERE id = ? AND status = ? AND receiver_user_chain_id <=> ?
+	`, status, receiverUserChainID, b.ID, b.Status, b.ReceiverUserChainID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrBulkyItemNotAvailable
+	}
+	b.Status = status
+	b.ReceiverUserChainID = receiverUserChainID
+	return nil
+}
+
+// Resets the expiry reminder and puts an expired item back up
+func BulkyItemRenew(db *gorm.DB, id uint) error {
+	return db.Exec(`
+UPDATE bulky_items SET
+	last_notified_at = NULL,
+	status = IF(status = ?, ?, status)
+WHERE id = ?
+	`, sharedtypes.BulkyItemStatusExpired, sharedtypes.BulkyItemStatusAvailable, id).Error
+}
+
 func RemoveSelectedBulkyItems(db *gorm.DB, bulkyItems []BulkyItem) error {
 	bulkyItemIDs := []uint{}
 	for _, bulkyItem := range bulkyItems {
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+type BulkyItemSubscription sharedtypes.BulkyItemSubscription
+
+func BulkyItemSubscriptionGetAll(db *gorm.DB, userID, chainID uint) ([]string, error) {
+	categories := []string{}
+	err := db.Raw(`
+SELECT category FROM bulky_item_subscriptions
+WHERE user_id = ? AND chain_id = ?
+ORDER BY category
+	`, userID, chainID).Scan(&categories).Error
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// Replaces the categories the user is subscribed to in the chain
+func BulkyItemSubscriptionSet(db *gorm.DB, userID, chainID uint, categories []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`DELETE FROM bulky_item_subscriptions WHERE user_id = ? AND chain_id = ?`, userID, chainID).Error
+		if err != nil || len(categories) == 0 {
+			return err
+		}
+		subscriptions := []BulkyItemSubscription{}
+		for _, category := range categories {
+			subscriptions = append(subscriptions, BulkyItemSubscription{
+				UserID:   userID,
+				ChainID:  chainID,
+				Category: category,
+			})
+		}
+		return tx.Create(&subscriptions).Error
+	})
+}
+
+// Approved members of the chain, except the author, that have no subscriptions or are subscribed to the category
+func BulkyItemSubscriberUIDs(db *gorm.DB, chainID uint, authorUID, category string) ([]string, error) {
+	userUIDs := []string{}
+	err := db.Raw(`
+SELECT u.uid
+FROM users AS u
+JOIN user_chains AS uc ON uc.user_id = u.id
+WHERE uc.chain_id = ? AND u.uid != ? AND uc.is_approved = TRUE
+	AND (
+		NOT EXISTS (
+			SELECT 1 FROM bulky_item_subscriptions AS bis
+			WHERE bis.user_id = u.id AND bis.chain_id = uc.chain_id
+		)
+		OR EXISTS (
+			SELECT 1 FROM bulky_item_subscriptions AS bis
+			WHERE bis.user_id = u.id AND bis.chain_id = uc.chain_id AND bis.category = ?
+		)
+	)
+	`, chainID, authorUID, category).Scan(&userUIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return userUIDs, nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestBulkyItemSearchQuery(t *testing.T) {
+	assert.Equal(t, "+blue* +sofa*", bulkyItemSearchQuery("blue sofa"))
+	assert.Equal(t, "+kinderwagen*", bulkyItemSearchQuery(`+kinderwagen -"a" (b)*`), "operators and short words are removed")
+	assert.Equal(t, "+café*", bulkyItemSearchQuery("café"))
+	assert.Empty(t, bulkyItemSearchQuery("tv"))
+}
+
+func TestBulkyItemNormalizeTags(t *testing.T) {
+	assert.Equal(t, []string{"wood", "ikea"}, BulkyItemNormalizeTags([]string{" Wood", "IKEA", "wood", ""}))
+	assert.Equal(t, []string{}, BulkyItemNormalizeTags(nil))
+}
+
+func TestValidateAllBulkyItemCategories(t *testing.T) {
+	assert.True(t, ValidateAllBulkyItemCategories(sharedtypes.BulkyItemCategories))
+	assert.True(t, ValidateAllBulkyItemCategories([]string{}))
+	assert.False(t, ValidateAllBulkyItemCategories([]string{"cars"}))
+	assert.False(t, ValidateAllBulkyItemCategories([]string{sharedtypes.BulkyItemCategoryKids, sharedtypes.BulkyItemCategoryKids}))
+}
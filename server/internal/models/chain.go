@@ -45,6 +45,8 @@ type Chain struct {
 	LastAbandonedAt               sql.NullTime
 	LastAbandonedRecruitmentEmail sql.NullTime
 	ChatRoomIDs                   []string `gorm:"column:chat_room_ids;serializer:json"`
+	ChatRetentionDays             int
+	BulkyItemExpiryDays           int
 }
 
 // Selects chain; id, uid, name, description, address, latitude, longitude, radius, sizes, genders, published, open_to_new_members
@@ -90,6 +92,7 @@ SELECT u.uid AS uid FROM user_chains AS uc
 JOIN users AS u ON u.id = uc.user_id
 WHERE uc.chain_id = ?
 AND uc.is_approved = TRUE
+AND u.purge_at IS NULL
 ORDER BY uc.route_order ASC
 	`, c.ID).Scan(&userUIDs).Error
 	if err != nil {
@@ -163,6 +166,15 @@ func (c *Chain) Delete(db *gorm.DB) error {
 		return err
 	}
 
+	err = tx.Exec(`DELETE FROM image_references WHERE kind = ? AND ref_id IN (
+		SELECT id FROM bulky_items WHERE user_chain_id IN (
+			SELECT id FROM user_chains WHERE chain_id = ?
+		)
+	)`, ImageReferenceKindBulkyItem, c.ID).Error
+	if err != nil {
+		return err
+	}
+
 	err = tx.Exec(`DELETE FROM bulky_items WHERE user_chain_id IN (
 		SELECT id FROM user_chains WHERE chain_id = ?
 	)`, c.ID).Error
@@ -170,11 +182,41 @@ func (c *Chain) Delete(db *gorm.DB) error {
 		return err
 	}
 
+	err = tx.Exec(`DELETE FROM bulky_item_subscriptions WHERE chain_id = ?`, c.ID).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Exec(`DELETE FROM wishes WHERE chain_id = ?`, c.ID).Error
+	if err != nil {
+		return err
+	}
+
 	err = tx.Exec(`DELETE FROM user_chains WHERE chain_id = ?`, c.ID).Error
 	if err != nil {
 		return err
 	}
 
+	err = ChatCleanupAddChannelsOfChains(tx, c.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Exec(`DELETE FROM chat_mutes WHERE chain_id = ?`, c.ID).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Exec(`DELETE FROM chat_message_reports WHERE chain_id = ?`, c.ID).Error
+	if err != nil {
+		return err
+	}
+
+	err = ImageReferenceRemove(tx, ImageReferenceKindChain, c.ID)
+	if err != nil {
+		return err
+	}
+
 	err = tx.Exec(`DELETE FROM chains WHERE id = ?`, c.ID).Error
 	if err != nil {
 		return err
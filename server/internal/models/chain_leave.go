@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// A participant that left a loop, kept for a few weeks to be listed in the weekly host digest
+type ChainLeave struct {
+	ID       uint
+	ChainID  uint `gorm:"index"`
+	UserName string
+	// Comma separated ReasonEnum values, empty when the participant gave no reason
+	Reasons   string
+	CreatedAt time.Time
+}
+
+func ChainLeaveAdd(db *gorm.DB, userName string, reasons []string, chainIDs ...uint) error {
+	if len(chainIDs) == 0 {
+		return nil
+	}
+	leaves := []ChainLeave{}
+	for _, chainID := range chainIDs {
+		leaves = append(leaves, ChainLeave{
+			ChainID:  chainID,
+			UserName: userName,
+			Reasons:  strings.Join(reasons, ","),
+		})
+	}
+	return db.Create(&leaves).Error
+}
+
+func ChainLeaveGetSince(db *gorm.DB, chainID uint, since time.Time) ([]ChainLeave, error) {
+	leaves := []ChainLeave{}
+	err := db.Raw(`
+SELECT * FROM chain_leaves
+WHERE chain_id = ? AND created_at > ?
+ORDER BY created_at ASC
+	`, chainID, since).Scan(&leaves).Error
+	return leaves, err
+}
+
+func ChainLeaveDeleteOld(db *gorm.DB) error {
+	return db.Exec(`DELETE FROM chain_leaves WHERE created_at < (NOW() - INTERVAL 30 DAY)`).Error
+}
+
+func (l *ChainLeave) GetReasons() []string {
+	if l.Reasons == "" {
+		return []string{}
+	}
+	return strings.Split(l.Reasons, ",")
+}
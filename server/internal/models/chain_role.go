@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrChainRoleNotFound = errors.New("Role not found")
+
+// A custom role defined by the hosts of a loop,
+// the built-in roles are defined in the auth package.
+type ChainRole struct {
+	ID      uint
+	ChainID uint     `gorm:"uniqueIndex:idx_chain_role_name"`
+	Name    string   `gorm:"uniqueIndex:idx_chain_role_name;size:50"`
+	Actions []string `gorm:"serializer:json"`
+}
+
+func ChainRoleGetAllByChain(db *gorm.DB, chainID uint) ([]ChainRole, error) {
+	roles := []ChainRole{}
+	err := db.Raw(`SELECT * FROM chain_roles WHERE chain_id = ? ORDER BY name`, chainID).Scan(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func ChainRoleGetByName(db *gorm.DB, chainID uint, name string) (*ChainRole, error) {
+	role := &ChainRole{}
+	err := db.Raw(`SELECT * FROM chain_roles WHERE chain_id = ? AND name = ? LIMIT 1`, chainID, name).Scan(role).Error
+	if err != nil {
+		return nil, err
+	}
+	if role.ID == 0 {
+		return nil, ErrChainRoleNotFound
+	}
+	return role, nil
+}
+
+func (r *ChainRole) Save(db *gorm.DB) error {
+	existing, err := ChainRoleGetByName(db, r.ChainID, r.Name)
+	if err == nil {
+		r.ID = existing.ID
+	} else if !errors.Is(err, ErrChainRoleNotFound) {
+		return err
+	}
+	return db.Save(r).Error
+}
+
+// Removes the role and sets all members with this role back to participant
+func (r *ChainRole) Delete(db *gorm.DB) error {
+	tx := db.Begin()
+	err := tx.Exec(`UPDATE user_chains SET role = '' WHERE chain_id = ? AND role = ?`, r.ChainID, r.Name).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Exec(`DELETE FROM chain_roles WHERE id = ?`, r.ID).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"time"
+
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// A channel of the built-in chat, the uid is stored in chains.chat_room_ids
+type ChatChannel struct {
+	ID        uint
+	UID       string `gorm:"uniqueIndex;size:36"`
+	ChainID   uint   `gorm:"index"`
+	Name      string
+	Color     string `gorm:"size:20"`
+	CreatedAt time.Time
+}
+
+type ChatChannelMember struct {
+	ID            uint
+	ChatChannelID uint `gorm:"uniqueIndex:uidx_chat_channel_member"`
+	UserID        uint `gorm:"uniqueIndex:uidx_chat_channel_member;index"`
+	IsAdmin       bool
+	CreatedAt     time.Time
+}
+
+type ChatMessage sharedtypes.ChatMessage
+
+// Largest amount of messages returned at once
+const ChatMessagePageSize = 50
+
+func ChatChannelGetByUID(db *gorm.DB, uid string) (*ChatChannel, error) {
+	channel := &ChatChannel{}
+	err := db.Raw(`SELECT * FROM chat_channels WHERE uid = ? LIMIT 1`, uid).Scan(channel).Error
+	if err != nil {
+		return nil, err
+	}
+	if channel.ID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return channel, nil
+}
+
+// Removes the channel with its members and messages
+func ChatChannelDelete(db *gorm.DB, channelID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`DELETE FROM chat_messages WHERE chat_channel_id = ?`, channelID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`DELETE FROM chat_channel_members WHERE chat_channel_id = ?`, channelID).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`DELETE FROM chat_channels WHERE id = ?`, channelID).Error
+	})
+}
+
+// Adds the user to the channel or updates the admin role when already a member
+func ChatChannelMemberPut(db *gorm.DB, channelID uint, userUID string, isAdmin bool) error {
+	return db.Exec(`
+INSERT INTO chat_channel_members (chat_channel_id, user_id, is_admin, created_at)
+SELECT ?, id, ?, NOW() FROM users WHERE uid = ?
+ON DUPLICATE KEY UPDATE is_admin = VALUES(is_admin)
+	`, channelID, isAdmin, userUID).Error
+}
+
+func ChatChannelMemberDelete(db *gorm.DB, channelID uint, userUID string) error {
+	return db.Exec(`
+DELETE FROM chat_channel_members
+WHERE chat_channel_id = ? AND user_id = (SELECT id FROM users WHERE uid = ?)
+	`, channelID, userUID).Error
+}
+
+func ChatChannelMemberExists(db *gorm.DB, channelID, userID uint) (bool, error) {
+	var count int
+	err := db.Raw(`SELECT COUNT(*) FROM chat_channel_members WHERE chat_channel_id = ? AND user_id = ?`, channelID, userID).Scan(&count).Error
+	return count > 0, err
+}
+
+func ChatChannelMemberGetUserIDs(db *gorm.DB, channelID uint) ([]uint, error) {
+	userIDs := []uint{}
+	err := db.Raw(`SELECT user_id FROM chat_channel_members WHERE chat_channel_id = ?`, channelID).Scan(&userIDs).Error
+	return userIDs, err
+}
+
+// Loops the user is an approved member of that have chat channels
+func ChatChainIDsOfUser(db *gorm.DB, userID uint) ([]uint, error) {
+	chainIDs := []uint{}
+	err := db.Raw(`
+SELECT c.id FROM chains AS c
+JOIN user_chains AS uc ON uc.chain_id = c.id
+WHERE uc.user_id = ? AND uc.is_approved = TRUE AND c.deleted_at IS NULL
+	AND c.chat_room_ids IS NOT NULL AND c.chat_room_ids NOT IN ('', '[]', 'null')
+	`, userID).Scan(&chainIDs).Error
+	return chainIDs, err
+}
+
+func ChatMessageAdd(db *gorm.DB, channel *ChatChannel, user *User, message string) (*sharedtypes.ChatMessage, error) {
+	m := &ChatMessage{
+		ChatChannelID: channel.ID,
+		ChannelUID:    channel.UID,
+		UserID:        user.ID,
+		UserUID:       user.UID,
+		UserName:      user.Name,
+		Message:       message,
+	}
+	err := db.Create(m).Error
+	if err != nil {
+		return nil, err
+	}
+	return (*sharedtypes.ChatMessage)(m), nil
+}
+
+// Newest first, beforeID is the oldest message already received or zero
+func ChatMessageGetAll(db *gorm.DB, channelID, beforeID uint) ([]sharedtypes.ChatMessage, error) {
+	messages := []sharedtypes.ChatMessage{}
+	err := db.Raw(`
+SELECT m.*, c.uid AS channel_uid, u.uid AS user_uid, u.name AS user_name
+FROM chat_messages AS m
+JOIN chat_channels AS c ON c.id = m.chat_channel_id
+JOIN users AS u ON u.id = m.user_id
+WHERE m.chat_channel_id = ? AND (? = 0 OR m.id < ?)
+ORDER BY m.id DESC
+LIMIT ?
+	`, channelID, beforeID, beforeID, ChatMessagePageSize).Scan(&messages).Error
+	return messages, err
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ChatCleanupKindChannel = "channel"
+	ChatCleanupKindUser    = "user"
+)
+
+// A channel or chat user of a deleted loop or user that still has to be removed from the chat server.
+// It is added in the same transaction as the deletion and kept until removing it succeeds.
+type ChatCleanup struct {
+	ID   uint
+	Kind string `gorm:"size:20"`
+	// The channel id or chat user id
+	ChatID        string `gorm:"size:64"`
+	Attempts      int
+	Error         string    `gorm:"type:text"`
+	NextAttemptAt time.Time `gorm:"index"`
+	CreatedAt     time.Time
+}
+
+func ChatCleanupAdd(db *gorm.DB, kind string, chatIDs ...string) error {
+	now := time.Now()
+	cleanups := []ChatCleanup{}
+	for _, chatID := range chatIDs {
+		if chatID == "" {
+			continue
+		}
+		cleanups = append(cleanups, ChatCleanup{
+			Kind:          kind,
+			ChatID:        chatID,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+	if len(cleanups) == 0 {
+		return nil
+	}
+	return db.Create(&cleanups).Error
+}
+
+// Adds the channels of the loops, to be called before the loops are deleted
+func ChatCleanupAddChannelsOfChains(db *gorm.DB, chainIDs ...uint) error {
+	if len(chainIDs) == 0 {
+		return nil
+	}
+	chains := []Chain{}
+	err := db.Raw(`SELECT id, chat_room_ids FROM chains WHERE id IN ?`, chainIDs).Scan(&chains).Error
+	if err != nil {
+		return err
+	}
+	for _, chain := range chains {
+		err = ChatCleanupAdd(db, ChatCleanupKindChannel, chain.ChatRoomIDs...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Oldest first
+func ChatCleanupGetDue(db *gorm.DB, limit int) ([]ChatCleanup, error) {
+	cleanups := []ChatCleanup{}
+	err := db.Raw(`
+SELECT * FROM chat_cleanups
+WHERE next_attempt_at <= ?
+ORDER BY id ASC
+LIMIT ?
+	`, time.Now(), limit).Scan(&cleanups).Error
+	return cleanups, err
+}
+
+func (c *ChatCleanup) Delete(db *gorm.DB) error {
+	return db.Exec(`DELETE FROM chat_cleanups WHERE id = ?`, c.ID).Error
+}
+
+func (c *ChatCleanup) SetFailed(db *gorm.DB, err error, nextAttemptAt time.Time) error {
+	c.Attempts += 1
+	c.Error = err.Error()
+	c.NextAttemptAt = nextAttemptAt
+	return db.Exec(`
+UPDATE chat_cleanups SET attempts = ?, error = ?, next_attempt_at = ? WHERE id = ?
+	`, c.Attempts, c.Error, c.NextAttemptAt, c.ID).Error
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+type ChatMessageReport sharedtypes.ChatMessageReport
+
+type ChatMute sharedtypes.ChatMute
+
+func ChatMessageReportAdd(db *gorm.DB, report *ChatMessageReport) error {
+	return db.Create(report).Error
+}
+
+// Newest first
+func ChatMessageReportGetAll(db *gorm.DB, chainID uint) ([]sharedtypes.ChatMessageReport, error) {
+	reports := []sharedtypes.ChatMessageReport{}
+	err := db.Raw(`
+SELECT r.*,
+	COALESCE(a.uid, '') AS author_uid, COALESCE(a.name, '') AS author_name,
+	COALESCE(u.uid, '') AS reporter_uid, COALESCE(u.name, '') AS reporter_name
+FROM chat_message_reports AS r
+LEFT JOIN users AS a ON a.chat_user_id = r.author_chat_user_id
+LEFT JOIN users AS u ON u.id = r.reporter_user_id
+WHERE r.chain_id = ?
+ORDER BY r.id DESC
+	`, chainID).Scan(&reports).Error
+	return reports, err
+}
+
+func ChatMessageReportDelete(db *gorm.DB, chainID, id uint) error {
+	return db.Exec(`DELETE FROM chat_message_reports WHERE chain_id = ? AND id = ?`, chainID, id).Error
+}
+
+// Mutes the member until the given time, replacing an earlier mute
+func ChatMutePut(db *gorm.DB, chainID, userID uint, until time.Time) error {
+	return db.Exec(`
+INSERT INTO chat_mutes (chain_id, user_id, until, created_at)
+VALUES (?, ?, ?, NOW())
+ON DUPLICATE KEY UPDATE until = VALUES(until)
+	`, chainID, userID, until).Error
+}
+
+// Adds to the channels the member is joined to again when the mute ends
+func ChatMuteAddChannelIDs(db *gorm.DB, chainID, userID uint, channelIDs []string) error {
+	if len(channelIDs) == 0 {
+		return nil
+	}
+	mute, err := ChatMuteGet(db, chainID, userID)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(lo.Union(mute.ChannelIDs, channelIDs))
+	if err != nil {
+		return err
+	}
+	return db.Exec(`UPDATE chat_mutes SET channel_ids = ? WHERE chain_id = ? AND user_id = ?`, string(b), chainID, userID).Error
+}
+
+// Returns an empty mute when the member is not muted
+func ChatMuteGet(db *gorm.DB, chainID, userID uint) (*ChatMute, error) {
+	mute := &ChatMute{}
+	err := db.Raw(`SELECT * FROM chat_mutes WHERE chain_id = ? AND user_id = ? LIMIT 1`, chainID, userID).Scan(mute).Error
+	return mute, err
+}
+
+func ChatMuteDelete(db *gorm.DB, chainID, userID uint) error {
+	return db.Exec(`DELETE FROM chat_mutes WHERE chain_id = ? AND user_id = ?`, chainID, userID).Error
+}
+
+func ChatMuteIsActive(db *gorm.DB, chainID, userID uint) (bool, error) {
+	var count int
+	err := db.Raw(`
+SELECT COUNT(*) FROM chat_mutes WHERE chain_id = ? AND user_id = ? AND until > ?
+	`, chainID, userID, time.Now()).Scan(&count).Error
+	return count > 0, err
+}
+
+// Only mutes that have not ended yet
+func ChatMuteGetAll(db *gorm.DB, chainID uint) ([]sharedtypes.ChatMute, error) {
+	mutes := []sharedtypes.ChatMute{}
+	err := db.Raw(`
+SELECT m.*, u.uid AS user_uid, u.name AS user_name
+FROM chat_mutes AS m
+JOIN users AS u ON u.id = m.user_id
+WHERE m.chain_id = ? AND m.until > ?
+ORDER BY m.until ASC
+	`, chainID, time.Now()).Scan(&mutes).Error
+	return mutes, err
+}
+
+func ChatMuteGetEnded(db *gorm.DB) ([]ChatMute, error) {
+	mutes := []ChatMute{}
+	err := db.Raw(`SELECT * FROM chat_mutes WHERE until <= ?`, time.Now()).Scan(&mutes).Error
+	return mutes, err
+}
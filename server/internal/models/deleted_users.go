@@ -98,3 +98,32 @@ func (d *DeletedUser) SetReasons(reasons []string) error {
 	}
 	return nil
 }
+
+// Returns the reasons as ReasonEnum values, the reverse of SetReasons
+func (d *DeletedUser) GetReasons() []string {
+	reasons := []string{}
+	for _, r := range []struct {
+		is     bool
+		reason string
+	}{
+		{d.IsMoved, ReasonEnumMoved},
+		{d.IsNotEnoughItemsILiked, ReasonEnumNotEnoughItemsILiked},
+		{d.IsAddressTooFar, ReasonEnumAddressTooFar},
+		{d.IsTooTimeConsuming, ReasonEnumTooTimeConsuming},
+		{d.IsDoneSwapping, ReasonEnumDoneSwapping},
+		{d.IsDidntFitIn, ReasonEnumDidntFitIn},
+		{d.IsOther, ReasonEnumOther},
+		{d.IsPlanToJoinNewLoop, ReasonEnumPlanToJoinNewLoop},
+		{d.IsPlanToStartNewLoop, ReasonEnumPlanToStartNewLoop},
+		{d.IsDontPlanToParticipate, ReasonEnumDontPlanToParticipate},
+		{d.IsQualityDidntMatch, ReasonEnumQualityDidntMatch},
+		{d.IsSizesDidntMatch, ReasonEnumSizesDidntMatch},
+		{d.IsStylesDidntMatch, ReasonEnumStylesDidntMatch},
+		{d.IsDontWantToShare, ReasonEnumStylesDontWantToShare},
+	} {
+		if r.is {
+			reasons = append(reasons, r.reason)
+		}
+	}
+	return reasons
+}
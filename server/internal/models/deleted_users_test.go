@@ -45,3 +45,12 @@ func TestValidateAllReasonsEnum(t *testing.T) {
 	f([]string{"7"}, "aaaaa", true)
 	f([]string{"7"}, "aaaaaa", true)
 }
+
+func TestDeletedUserGetReasons(t *testing.T) {
+	reasons := []string{ReasonEnumMoved, ReasonEnumOther, ReasonEnumStylesDontWantToShare}
+	d := &DeletedUser{}
+	assert.NoError(t, d.SetReasons(reasons))
+	assert.Equal(t, reasons, d.GetReasons())
+
+	assert.Empty(t, (&DeletedUser{}).GetReasons())
+}
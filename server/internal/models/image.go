@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrImageNotFound = errors.New("Image not found")
+
+const (
+	ImageSizeThumb    = "thumb"
+	ImageSizeMedium   = "medium"
+	ImageSizeOriginal = "original"
+)
+
+// The longest side in pixels of each size, smaller images are not enlarged
+var ImageSizes = map[string]int{
+	ImageSizeThumb:    400,
+	ImageSizeMedium:   800,
+	ImageSizeOriginal: 2048,
+}
+
+// Names of uploaded images, the date of the upload followed by a hash of the contents
+var imageNameRegexp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}_[A-Za-z0-9_=-]+\.jpg$`)
+
+// An uploaded image, every size is stored as a jpeg with the same name
+type Image struct {
+	ID        uint
+	UserID    uint   `gorm:"index"`
+	Name      string `gorm:"uniqueIndex;size:100"`
+	CreatedAt time.Time
+}
+
+func ImageGetByID(db *gorm.DB, id uint) (*Image, error) {
+	image := &Image{}
+	err := db.Raw(`SELECT * FROM images WHERE id = ? LIMIT 1`, id).Scan(image).Error
+	if err != nil {
+		return nil, err
+	}
+	if image.ID == 0 {
+		return nil, ErrImageNotFound
+	}
+	return image, nil
+}
+
+func ImageGetByName(db *gorm.DB, name string) (*Image, error) {
+	image := &Image{}
+	err := db.Raw(`SELECT * FROM images WHERE name = ? LIMIT 1`, name).Scan(image).Error
+	if err != nil {
+		return nil, err
+	}
+	if image.ID == 0 {
+		return nil, ErrImageNotFound
+	}
+	return image, nil
+}
+
+func ImageValidName(name string) bool {
+	return imageNameRegexp.MatchString(name)
+}
+
+// The name of the uploaded image at the end of the url in any size, empty for images hosted elsewhere
+func ImageNameFromURL(imageURL string) string {
+	u, err := url.Parse(imageURL)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(u.Path)
+	if !ImageValidName(name) {
+		return ""
+	}
+	return name
+}
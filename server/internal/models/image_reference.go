@@ -0,0 +1,117 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ImageReferenceKindBulkyItem = "bulky_item"
+	ImageReferenceKindEvent     = "event"
+	ImageReferenceKindChain     = "chain"
+)
+
+// The table of the row a reference of each kind points to
+var imageReferenceKindTables = map[string]string{
+	ImageReferenceKindBulkyItem: "bulky_items",
+	ImageReferenceKindEvent:     "events",
+	ImageReferenceKindChain:     "chains",
+}
+
+// An uploaded image in use by a bulky item, event or loop.
+// Images without references are removed by the image garbage collection.
+type ImageReference struct {
+	ID        uint
+	ImageName string `gorm:"size:100;index"`
+	Kind      string `gorm:"size:20;uniqueIndex:uidx_image_reference"`
+	RefID     uint   `gorm:"uniqueIndex:uidx_image_reference"`
+	CreatedAt time.Time
+}
+
+// Replaces the image used by a row, removing the reference when the url is not an uploaded image
+func ImageReferenceSet(db *gorm.DB, kind string, refID uint, imageURL string) error {
+	name := ImageNameFromURL(imageURL)
+	if name == "" {
+		return ImageReferenceRemove(db, kind, refID)
+	}
+
+	return db.Exec(`
+INSERT INTO image_references (image_name, kind, ref_id, created_at)
+VALUES (?, ?, ?, NOW())
+ON DUPLICATE KEY UPDATE image_name = VALUES(image_name), created_at = NOW()
+	`, name, kind, refID).Error
+}
+
+func ImageReferenceRemove(db *gorm.DB, kind string, refIDs ...uint) error {
+	if len(refIDs) == 0 {
+		return nil
+	}
+	return db.Exec(`DELETE FROM image_references WHERE kind = ? AND ref_id IN ?`, kind, refIDs).Error
+}
+
+// Removes references to rows that no longer exist, for rows that are deleted together with their loop or user
+func ImageReferenceDeleteStale(db *gorm.DB) error {
+	for kind, table := range imageReferenceKindTables {
+		err := db.Exec(`
+DELETE ir FROM image_references AS ir
+LEFT JOIN `+table+` AS t ON t.id = ir.ref_id
+WHERE ir.kind = ? AND t.id IS NULL
+		`, kind).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The names of the images in use, references to rows that no longer exist are left out without removing them
+func ImageReferenceGetAllNames(db *gorm.DB) ([]string, error) {
+	queries := []string{}
+	args := []any{}
+	for kind, table := range imageReferenceKindTables {
+		queries = append(queries, `
+SELECT ir.image_name FROM image_references AS ir
+JOIN `+table+` AS t ON t.id = ir.ref_id
+WHERE ir.kind = ?`)
+		args = append(args, kind)
+	}
+
+	names := []string{}
+	err := db.Raw(strings.Join(queries, "\nUNION"), args...).Scan(&names).Error
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// Adds the references of the images that were in use before references were recorded
+func ImageReferenceAddExisting(db *gorm.DB) error {
+	rows := []struct {
+		Kind     string
+		ID       uint
+		ImageURL string
+	}{}
+	err := db.Raw(`
+SELECT ? AS kind, id, image_url FROM bulky_items WHERE image_url != ''
+UNION ALL
+SELECT ? AS kind, id, image_url FROM events WHERE image_url != ''
+UNION ALL
+SELECT ? AS kind, id, image AS image_url FROM chains WHERE image IS NOT NULL AND image != ''
+	`, ImageReferenceKindBulkyItem, ImageReferenceKindEvent, ImageReferenceKindChain).Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if ImageNameFromURL(row.ImageURL) == "" {
+			continue
+		}
+		err = ImageReferenceSet(db, row.Kind, row.ID, row.ImageURL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
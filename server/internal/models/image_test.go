@@ -0,0 +1,23 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageValidName(t *testing.T) {
+	assert.True(t, ImageValidName("2026-10-19_k5pBfDnM1lVSn4dSCe0Yn6Ahz6o=.jpg"))
+	assert.False(t, ImageValidName("../../etc/passwd"))
+	assert.False(t, ImageValidName("2026-10-19_abc/../x.jpg"))
+	assert.False(t, ImageValidName("2026-10-19_abc.png"))
+}
+
+func TestImageNameFromURL(t *testing.T) {
+	name := "2026-10-19_k5pBfDnM1lVSn4dSCe0Yn6Ahz6o=.jpg"
+	assert.Equal(t, name, ImageNameFromURL("https://api.clothingloop.org/api/v2/image/medium/"+name))
+	assert.Equal(t, name, ImageNameFromURL("https://images.clothingloop.org/original/uploads/"+name))
+	assert.Equal(t, name, ImageNameFromURL("https://images.clothingloop.org/400x/uploads/"+name+"?v=1"))
+	assert.Equal(t, "", ImageNameFromURL("https://i.ibb.co/abc/photo.jpg"))
+	assert.Equal(t, "", ImageNameFromURL(""))
+}
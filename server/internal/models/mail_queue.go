@@ -0,0 +1,185 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+// How long an email is retried after it has been queued
+const (
+	// Only retried for a short while, for emails that lose their meaning quickly like login codes
+	MAIL_RETRY_SHORT    = 0
+	MAIL_RETRY_NEXT_DAY = 1
+	MAIL_RETRY_TWO_DAYS = 2
+)
+
+const (
+	MailStatusQueued  = "queued"
+	MailStatusSending = "sending"
+	MailStatusSent    = "sent"
+	MailStatusFailed  = "failed"
+	MailStatusDead    = "dead"
+)
+
+// After this many failed attempts an email is given up on, regardless of the retry window
+const MailMaxAttempts = 12
+
+// An email in the outgoing queue, rows are kept for a while after sending for debugging
+type Mail struct {
+	ID            uint
+	SenderName    string
+	SenderAddress string
+	ToName        string
+	ToAddress     string
+	Subject       string
+	Body          string
+	// Plain text alternative of Body
+	BodyText       string
+	ReplyToName    string
+	ReplyToAddress string
+	// The notification kind of emails that are not transactional, see NotificationKinds
+	Category string `gorm:"size:50"`
+	// The loop the email is about, to check the notification preferences of the receiver
+	ChainID          uint `gorm:"-"`
+	Err              null.String
+	MaxRetryAttempts int
+	// Sent even when the address is suppressed, for emails the user asked for
+	IgnoreSuppression bool
+	Status            string `gorm:"size:20;index:idx_mail_queue_due,priority:1"`
+	Attempts          int
+	NextAttemptAt     time.Time `gorm:"index:idx_mail_queue_due,priority:2"`
+	ClaimID           string    `gorm:"size:36;index"`
+	LockedUntil       *time.Time
+	Provider          string `gorm:"size:20"`
+	SentAt            *time.Time
+	// Set by the provider on sending and used to match delivery events
+	ProviderMessageID string `gorm:"size:255;index"`
+	DeliveryStatus    string `gorm:"size:20"`
+	DeliveryStatusAt  *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+func (m Mail) TableName() string {
+	return "mail_queue"
+}
+
+// The moment after which a failing email is no longer retried
+func (m *Mail) RetryDeadline() time.Time {
+	switch m.MaxRetryAttempts {
+	case MAIL_RETRY_SHORT:
+		return m.CreatedAt.Add(15 * time.Minute)
+	case MAIL_RETRY_NEXT_DAY:
+		return m.CreatedAt.Add(24 * time.Hour)
+	default:
+		return m.CreatedAt.Add(time.Duration(m.MaxRetryAttempts) * 24 * time.Hour)
+	}
+}
+
+// Stores the email as queued, to be picked up immediately
+func (m *Mail) Enqueue(db *gorm.DB) error {
+	now := time.Now()
+	m.Status = MailStatusQueued
+	m.Attempts = 0
+	m.NextAttemptAt = now
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now
+	}
+	return db.Create(m).Error
+}
+
+func (m *Mail) Delete(db *gorm.DB) error {
+	return db.Delete(m).Error
+}
+
+// Marks at most limit emails that are due as sending and returns them.
+//
+// Emails of which the lock expired, because the process stopped while sending, are claimed again.
+func MailClaimDue(db *gorm.DB, claimID string, limit int, lockFor time.Duration) ([]*Mail, error) {
+	now := time.Now()
+	err := db.Exec(`
+UPDATE mail_queue
+SET status = ?, claim_id = ?, locked_until = ?, updated_at = ?
+WHERE (status IN ? AND next_attempt_at <= ?)
+	OR (status = ? AND locked_until < ?)
+ORDER BY next_attempt_at ASC
+LIMIT ?
+	`, MailStatusSending, claimID, now.Add(lockFor), now,
+		[]string{MailStatusQueued, MailStatusFailed}, now,
+		MailStatusSending, now,
+		limit).Error
+	if err != nil {
+		return nil, err
+	}
+
+	mails := []*Mail{}
+	err = db.Raw(`SELECT * FROM mail_queue WHERE claim_id = ? AND status = ?`, claimID, MailStatusSending).Scan(&mails).Error
+	if err != nil {
+		return nil, err
+	}
+	return mails, nil
+}
+
+// Puts a claimed email back in the queue without counting it as an attempt
+func (m *Mail) Release(db *gorm.DB) error {
+	m.Status = MailStatusQueued
+	return db.Exec(`
+UPDATE mail_queue SET status = ?, claim_id = '', locked_until = NULL, updated_at = ?
+WHERE id = ? AND status = ?
+	`, MailStatusQueued, time.Now(), m.ID, MailStatusSending).Error
+}
+
+func (m *Mail) SetSent(db *gorm.DB, provider, providerMessageID string) error {
+	now := time.Now()
+	m.Status = MailStatusSent
+	m.Attempts += 1
+	m.Provider = provider
+	m.ProviderMessageID = providerMessageID
+	m.SentAt = &now
+	return db.Exec(`
+UPDATE mail_queue
+SET status = ?, attempts = ?, provider = ?, provider_message_id = ?, sent_at = ?, claim_id = '', locked_until = NULL, updated_at = ?
+WHERE id = ?
+	`, m.Status, m.Attempts, m.Provider, m.ProviderMessageID, m.SentAt, now, m.ID).Error
+}
+
+// Stores the latest delivery event reported by the provider, returns false if no email matches
+func MailSetDeliveryStatus(db *gorm.DB, providerMessageID, deliveryStatus string, at time.Time) (bool, error) {
+	if providerMessageID == "" {
+		return false, nil
+	}
+	res := db.Exec(`
+UPDATE mail_queue SET delivery_status = ?, delivery_status_at = ?
+WHERE provider_message_id = ? AND (delivery_status_at IS NULL OR delivery_status_at <= ?)
+	`, deliveryStatus, at, providerMessageID, at)
+	return res.RowsAffected > 0, res.Error
+}
+
+// Schedules the next attempt, or marks the email as dead when retryIn is zero
+func (m *Mail) SetFailed(db *gorm.DB, provider string, sendErr error, retryIn time.Duration) error {
+	m.Attempts += 1
+	m.Provider = provider
+	m.Err = null.StringFrom(sendErr.Error())
+	if retryIn == 0 {
+		m.Status = MailStatusDead
+	} else {
+		m.Status = MailStatusFailed
+		m.NextAttemptAt = time.Now().Add(retryIn)
+	}
+	return db.Exec(`
+UPDATE mail_queue
+SET status = ?, attempts = ?, provider = ?, err = ?, next_attempt_at = ?, claim_id = '', locked_until = NULL, updated_at = ?
+WHERE id = ?
+	`, m.Status, m.Attempts, m.Provider, m.Err, m.NextAttemptAt, time.Now(), m.ID).Error
+}
+
+// Removes sent emails after a week and dead emails after a month
+func MailDeleteOld(db *gorm.DB) error {
+	return db.Exec(`
+DELETE FROM mail_queue
+WHERE (status = ? AND sent_at < (NOW() - INTERVAL 7 DAY))
+	OR (status = ? AND updated_at < (NOW() - INTERVAL 30 DAY))
+	`, MailStatusSent, MailStatusDead).Error
+}
@@ -0,0 +1,122 @@
+//go:build !ci
+
+package models_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+func mailClaimDueContains(t *testing.T, id uint) (*models.Mail, bool) {
+	list, err := models.MailClaimDue(db, uuid.NewV4().String(), 1000, time.Minute)
+	assert.NoError(t, err)
+	return lo.Find(list, func(m *models.Mail) bool {
+		return m.ID == id
+	})
+}
+
+func TestMailClaimDue(t *testing.T) {
+	tests := []mocks.MockMailOptions{
+		{Status: models.MailStatusQueued},
+		{
+			Status:        models.MailStatusFailed,
+			Attempts:      1,
+			NextAttemptAt: time.Now().Add(-time.Minute),
+		},
+		{
+			Status:      models.MailStatusSending,
+			Attempts:    1,
+			LockedUntil: lo.ToPtr(time.Now().Add(-time.Minute)),
+		},
+	}
+
+	for _, o := range tests {
+		expected := mocks.MockMail(t, db, o)
+		t.Run(fmt.Sprintf("with status %s", o.Status), func(t *testing.T) {
+			found, ok := mailClaimDueContains(t, expected.ID)
+			assert.True(t, ok)
+			if ok {
+				assert.Equal(t, models.MailStatusSending, found.Status)
+				assert.NotEmpty(t, found.ClaimID)
+			}
+
+			_, ok = mailClaimDueContains(t, expected.ID)
+			assert.False(t, ok, "claimed email should not be claimed twice")
+		})
+	}
+}
+
+func TestMailClaimDueHidden(t *testing.T) {
+	tests := []mocks.MockMailOptions{
+		{
+			Status:        models.MailStatusFailed,
+			Attempts:      1,
+			NextAttemptAt: time.Now().Add(time.Hour),
+		},
+		{
+			Status:      models.MailStatusSending,
+			Attempts:    1,
+			LockedUntil: lo.ToPtr(time.Now().Add(time.Hour)),
+		},
+		{Status: models.MailStatusSent},
+		{Status: models.MailStatusDead},
+	}
+
+	for _, o := range tests {
+		expected := mocks.MockMail(t, db, o)
+		t.Run(fmt.Sprintf("with status %s", o.Status), func(t *testing.T) {
+			_, ok := mailClaimDueContains(t, expected.ID)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestMailSetFailed(t *testing.T) {
+	expected := mocks.MockMail(t, db, mocks.MockMailOptions{})
+	_, ok := mailClaimDueContains(t, expected.ID)
+	assert.True(t, ok)
+
+	newErr := fmt.Errorf("NewError: %v", faker.Pet().Dog())
+	err := expected.SetFailed(db, "smtp", newErr, time.Hour)
+	assert.NoError(t, err)
+
+	found := models.Mail{ID: expected.ID}
+	db.First(&found)
+	assert.Equal(t, models.MailStatusFailed, found.Status)
+	assert.Equal(t, 1, found.Attempts)
+	assert.Equal(t, newErr.Error(), found.Err.String)
+	assert.Empty(t, found.ClaimID)
+	assert.True(t, found.NextAttemptAt.After(time.Now().Add(50*time.Minute)))
+
+	t.Run("Without retry the email is dead", func(t *testing.T) {
+		err := expected.SetFailed(db, "smtp", newErr, 0)
+		assert.NoError(t, err)
+
+		found := models.Mail{ID: expected.ID}
+		db.First(&found)
+		assert.Equal(t, models.MailStatusDead, found.Status)
+		assert.Equal(t, 2, found.Attempts)
+	})
+}
+
+func TestMailRelease(t *testing.T) {
+	expected := mocks.MockMail(t, db, mocks.MockMailOptions{})
+	claimed, ok := mailClaimDueContains(t, expected.ID)
+	assert.True(t, ok)
+
+	err := claimed.Release(db)
+	assert.NoError(t, err)
+
+	found, ok := mailClaimDueContains(t, expected.ID)
+	assert.True(t, ok)
+	if ok {
+		assert.Equal(t, 0, found.Attempts)
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MailDeliveryStatusDelivered  = "delivered"
+	MailDeliveryStatusSoftBounce = "soft_bounce"
+	MailDeliveryStatusHardBounce = "hard_bounce"
+	MailDeliveryStatusComplaint  = "complaint"
+	// Refused by the provider itself, for example by its own blocklist, the address may still be valid
+	MailDeliveryStatusBlocked = "blocked"
+)
+
+// An address that hard bounced, emails to it are not sent anymore.
+// Emails requested by the user themselves, like login codes, are still sent
+// and the address is removed from this list once one of them is delivered.
+type MailSuppression struct {
+	ID        uint
+	Email     string `gorm:"uniqueIndex;size:255"`
+	Reason    string `gorm:"size:20"`
+	CreatedAt time.Time
+}
+
+func MailSuppressionAdd(db *gorm.DB, email, reason string) error {
+	return db.Exec(`
+INSERT INTO mail_suppressions (email, reason, created_at) VALUES (?, ?, NOW())
+ON DUPLICATE KEY UPDATE reason = VALUES(reason)
+	`, email, reason).Error
+}
+
+func MailSuppressionExists(db *gorm.DB, email string) bool {
+	count := 0
+	db.Raw(`SELECT COUNT(*) FROM mail_suppressions WHERE email = ?`, email).Scan(&count)
+	return count > 0
+}
+
+func MailSuppressionRemove(db *gorm.DB, email string) error {
+	return db.Exec(`DELETE FROM mail_suppressions WHERE email = ?`, email).Error
+}
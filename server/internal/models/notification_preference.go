@@ -0,0 +1,194 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// How a notification reaches the user
+const (
+	NotificationChannelEmail = "email"
+	NotificationChannelPush  = "push"
+	NotificationChannelNone  = "none"
+	// Collected in the weekly host digest instead of sent right away
+	NotificationChannelDigest = "digest"
+)
+
+// Notifications the user can choose to receive, transactional emails like login codes are always sent
+const (
+	NotificationKindJoinRequest       = "join_request"
+	NotificationKindPoke              = "poke"
+	NotificationKindApproveReminder   = "approve_reminder"
+	NotificationKindMemberLeft        = "member_left"
+	NotificationKindLoopInactive      = "loop_inactive"
+	NotificationKindHostRecruitment   = "host_recruitment"
+	NotificationKindBagAssigned       = "bag_assigned"
+	NotificationKindBagTooOld         = "bag_too_old"
+	NotificationKindBulkyItemNew      = "bulky_item_new"
+	NotificationKindBulkyItemReserved = "bulky_item_reserved"
+	NotificationKindBulkyItemExpiring = "bulky_item_expiring"
+	NotificationKindChatMessage       = "chat_message"
+	NotificationKindWishMatch         = "wish_match"
+	// Weekly summary of loop activity for hosts, opt-in
+	NotificationKindHostDigest = "host_digest"
+)
+
+type NotificationKind struct {
+	Kind string `json:"kind"`
+	// Used when the user has not chosen a channel
+	Default  string   `json:"default"`
+	Channels []string `json:"channels"`
+}
+
+// Each kind only offers the channels it is sent through. Join requests are sent both by email and as a push notification,
+// the other email kinds have no push notification text and the push kinds have no email template.
+// The digest only collects the kinds that are of interest to hosts.
+var NotificationKinds = []NotificationKind{
+	{NotificationKindJoinRequest, NotificationChannelEmail, []string{NotificationChannelEmail, NotificationChannelPush, NotificationChannelDigest, NotificationChannelNone}},
+	{NotificationKindPoke, NotificationChannelEmail, []string{NotificationChannelEmail, NotificationChannelNone}},
+	{NotificationKindApproveReminder, NotificationChannelEmail, []string{NotificationChannelEmail, NotificationChannelDigest, NotificationChannelNone}},
+	{NotificationKindMemberLeft, NotificationChannelEmail, []string{NotificationChannelEmail, NotificationChannelDigest, NotificationChannelNone}},
+	{NotificationKindLoopInactive, NotificationChannelEmail, []string{NotificationChannelEmail, NotificationChannelNone}},
+	{NotificationKindHostRecruitment, NotificationChannelEmail, []string{NotificationChannelEmail, NotificationChannelNone}},
+	{NotificationKindBagAssigned, NotificationChannelPush, []string{NotificationChannelPush, NotificationChannelNone}},
+	{NotificationKindBagTooOld, NotificationChannelPush, []string{NotificationChannelPush, NotificationChannelNone}},
+	{NotificationKindBulkyItemNew, NotificationChannelPush, []string{NotificationChannelPush, NotificationChannelNone}},
+	{NotificationKindBulkyItemReserved, NotificationChannelPush, []string{NotificationChannelPush, NotificationChannelNone}},
+	{NotificationKindBulkyItemExpiring, NotificationChannelPush, []string{NotificationChannelPush, NotificationChannelNone}},
+	{NotificationKindChatMessage, NotificationChannelPush, []string{NotificationChannelPush, NotificationChannelNone}},
+	{NotificationKindWishMatch, NotificationChannelPush, []string{NotificationChannelPush, NotificationChannelNone}},
+	{NotificationKindHostDigest, NotificationChannelNone, []string{NotificationChannelEmail, NotificationChannelNone}},
+}
+
+func NotificationKindGet(kind string) (NotificationKind, bool) {
+	return lo.Find(NotificationKinds, func(k NotificationKind) bool {
+		return k.Kind == kind
+	})
+}
+
+// The channel a user has chosen for a kind of notification.
+// A preference for a specific loop takes precedence over the preference for all loops.
+type NotificationPreference struct {
+	ID     uint
+	UserID uint `gorm:"uniqueIndex:uidx_notification_preference"`
+	// Zero for the preference of all loops of the user
+	ChainID   uint   `gorm:"uniqueIndex:uidx_notification_preference"`
+	Kind      string `gorm:"uniqueIndex:uidx_notification_preference;size:50"`
+	Channel   string `gorm:"size:20"`
+	UpdatedAt time.Time
+}
+
+// Removes the preference when channel is empty, to fall back to the preference of all loops or the default
+func NotificationPreferenceSet(db *gorm.DB, userID, chainID uint, kind, channel string) error {
+	if channel == "" {
+		return db.Exec(`DELETE FROM notification_preferences WHERE user_id = ? AND chain_id = ? AND kind = ?`, userID, chainID, kind).Error
+	}
+	return db.Exec(`
+INSERT INTO notification_preferences (user_id, chain_id, kind, channel, updated_at) VALUES (?, ?, ?, ?, NOW())
+ON DUPLICATE KEY UPDATE channel = VALUES(channel), updated_at = NOW()
+	`, userID, chainID, kind, channel).Error
+}
+
+// Sets the preference of all loops and removes the preferences of specific loops,
+// so the channel is used in every loop
+func NotificationPreferenceSetEveryChain(db *gorm.DB, userID uint, kind, channel string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`DELETE FROM notification_preferences WHERE user_id = ? AND chain_id != 0 AND kind = ?`, userID, kind).Error
+		if err != nil {
+			return err
+		}
+		return NotificationPreferenceSet(tx, userID, 0, kind, channel)
+	})
+}
+
+// Returns the preferences the user has set for the loop, or for all loops if chainID is zero
+func NotificationPreferenceGetAll(db *gorm.DB, userID, chainID uint) ([]NotificationPreference, error) {
+	list := []NotificationPreference{}
+	err := db.Raw(`SELECT * FROM notification_preferences WHERE user_id = ? AND chain_id = ?`, userID, chainID).Scan(&list).Error
+	return list, err
+}
+
+// The channel chosen by user u for @kind in the loop of chainExpr,
+// the kind default @default is used when the user has no preference.
+func sqlNotificationPreferenceChannel(chainExpr string) string {
+	return `
+COALESCE(
+	(SELECT np.channel FROM notification_preferences AS np WHERE np.user_id = u.id AND np.kind = @kind AND np.chain_id = ` + chainExpr + ` AND np.chain_id != 0),
+	(SELECT np.channel FROM notification_preferences AS np WHERE np.user_id = u.id AND np.kind = @kind AND np.chain_id = 0),
+	@default
+)`
+}
+
+// Returns the users that receive this kind of notification through the channel
+func NotificationPreferenceFilterUserUIDs(db *gorm.DB, userUIDs []string, chainID uint, kind, channel string) ([]string, error) {
+	k, ok := NotificationKindGet(kind)
+	if !ok || len(userUIDs) == 0 {
+		return userUIDs, nil
+	}
+
+	result := []string{}
+	err := db.Raw(`SELECT u.uid FROM users AS u WHERE u.uid IN @uids AND `+sqlNotificationPreferenceChannel("@chain_id")+` = @channel`, map[string]any{
+		"uids":     userUIDs,
+		"kind":     kind,
+		"chain_id": chainID,
+		"default":  k.Default,
+		"channel":  channel,
+	}).Scan(&result).Error
+	return result, err
+}
+
+// Returns the channel chosen by the user with this email address,
+// or the kind default if no user has this email address
+func NotificationPreferenceChannelByEmail(db *gorm.DB, email string, chainID uint, kind string) (string, error) {
+	k, ok := NotificationKindGet(kind)
+	if !ok {
+		return "", nil
+	}
+
+	channels := []string{}
+	err := db.Raw(`SELECT `+sqlNotificationPreferenceChannel("@chain_id")+` FROM users AS u WHERE u.email = @email LIMIT 1`, map[string]any{
+		"email":    email,
+		"kind":     kind,
+		"chain_id": chainID,
+		"default":  k.Default,
+	}).Scan(&channels).Error
+	if err != nil {
+		return "", err
+	}
+	if len(channels) == 0 {
+		return k.Default, nil
+	}
+	return channels[0], nil
+}
+
+// Returns the hosts, one row per loop, that receive this kind of notification through the channel
+func NotificationPreferenceGetChainAdmins(db *gorm.DB, kind, channel string) ([]UserContactData, error) {
+	k, ok := NotificationKindGet(kind)
+	if !ok {
+		return nil, fmt.Errorf("Unknown notification kind: %s", kind)
+	}
+
+	results := []UserContactData{}
+	err := db.Raw(`
+SELECT
+	u.name AS name,
+	u.email AS email,
+	u.i18n AS i18n,
+	c.name AS chain_name,
+	c.id AS chain_id
+FROM user_chains AS uc
+JOIN users AS u ON uc.user_id = u.id
+JOIN chains AS c ON uc.chain_id = c.id
+WHERE uc.is_chain_admin = TRUE
+	AND u.is_email_verified = TRUE
+	AND `+sqlNotificationPreferenceChannel("uc.chain_id")+` = @channel
+	`, map[string]any{
+		"kind":    kind,
+		"default": k.Default,
+		"channel": channel,
+	}).Scan(&results).Error
+	return results, err
+}
@@ -0,0 +1,44 @@
+//go:build !ci
+
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+func TestNotificationPreferenceChannel(t *testing.T) {
+	chain, user, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	kind := models.NotificationKindBulkyItemNew
+
+	getChannel := func(t *testing.T) string {
+		channel, err := models.NotificationPreferenceChannelByEmail(db, *user.Email, chain.ID, kind)
+		assert.NoError(t, err)
+
+		uids, err := models.NotificationPreferenceFilterUserUIDs(db, []string{user.UID}, chain.ID, kind, models.NotificationChannelPush)
+		assert.NoError(t, err)
+		assert.Equal(t, channel == models.NotificationChannelPush, len(uids) == 1)
+		return channel
+	}
+
+	assert.Equal(t, models.NotificationChannelPush, getChannel(t), "without preferences the default is used")
+
+	err := models.NotificationPreferenceSet(db, user.ID, 0, kind, models.NotificationChannelNone)
+	assert.NoError(t, err)
+	assert.Equal(t, models.NotificationChannelNone, getChannel(t))
+
+	err = models.NotificationPreferenceSet(db, user.ID, chain.ID, kind, models.NotificationChannelPush)
+	assert.NoError(t, err)
+	assert.Equal(t, models.NotificationChannelPush, getChannel(t), "the preference of the loop is used over the preference for all loops")
+
+	err = models.NotificationPreferenceSet(db, user.ID, chain.ID, kind, "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.NotificationChannelNone, getChannel(t))
+
+	list, err := models.NotificationPreferenceGetAll(db, user.ID, 0)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationKinds(t *testing.T) {
+	for _, k := range NotificationKinds {
+		assert.Containsf(t, k.Channels, k.Default, "kind: %s", k.Kind)
+		assert.Containsf(t, k.Channels, NotificationChannelNone, "kind: %s should be possible to turn off", k.Kind)
+	}
+
+	kinds := lo.Map(NotificationKinds, func(k NotificationKind, _ int) string { return k.Kind })
+	assert.Equal(t, lo.Uniq(kinds), kinds, "kinds should be unique")
+
+	_, ok := NotificationKindGet("unknown")
+	assert.False(t, ok)
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrOidcAuthCodeInvalid = errors.New("Authorization code is invalid or expired")
+
+const OidcAuthCodeDuration = 5 * time.Minute
+
+// An authorization code of the OpenID Connect authorization code flow,
+// only the hash of the code is stored.
+type OidcAuthCode struct {
+	ID                  uint
+	CodeHash            string `gorm:"uniqueIndex;size:64"`
+	ClientID            string
+	UserID              uint
+	RedirectURI         string
+	Scope               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	ExpiresAt           time.Time
+}
+
+func oidcAuthCodeHash(code string) string {
+	h := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(h[:])
+}
+
+func (a *OidcAuthCode) Create(db *gorm.DB, code string) error {
+	a.CodeHash = oidcAuthCodeHash(code)
+	a.ExpiresAt = time.Now().Add(OidcAuthCodeDuration)
+	return db.Create(a).Error
+}
+
+// Authorization codes can only be used once
+func OidcAuthCodeUse(db *gorm.DB, code string) (*OidcAuthCode, error) {
+	a := &OidcAuthCode{}
+	db.Raw(`SELECT * FROM oidc_auth_codes WHERE code_hash = ? LIMIT 1`, oidcAuthCodeHash(code)).Scan(a)
+	if a.ID == 0 {
+		return nil, ErrOidcAuthCodeInvalid
+	}
+
+	res := db.Exec(`DELETE FROM oidc_auth_codes WHERE id = ?`, a.ID)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 || a.ExpiresAt.Before(time.Now()) {
+		return nil, ErrOidcAuthCodeInvalid
+	}
+	return a, nil
+}
+
+func OidcAuthCodeDeleteExpired(db *gorm.DB) {
+	db.Exec(`DELETE FROM oidc_auth_codes WHERE expires_at < NOW()`)
+}
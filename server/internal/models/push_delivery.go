@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The result of sending a push notification to a group of users through one provider,
+// kept for a few weeks to look into missing notifications
+type PushDelivery struct {
+	ID       uint
+	Provider string `gorm:"size:20"`
+	// The id the provider returned, empty when sending failed
+	NotificationID string `gorm:"size:100"`
+	Title          string
+	// Amount of users the notification was sent to
+	UserCount int
+	// Amount of devices the provider delivered to
+	Recipients int
+	// Users without any valid device, their devices are removed
+	InvalidCount int
+	Attempts     int
+	// Empty when the notification was accepted
+	Error     string    `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func PushDeliveryAdd(db *gorm.DB, d *PushDelivery) error {
+	return db.Create(d).Error
+}
+
+func PushDeliveryDeleteOld(db *gorm.DB) error {
+	return db.Exec(`DELETE FROM push_deliveries WHERE created_at < (NOW() - INTERVAL 30 DAY)`).Error
+}
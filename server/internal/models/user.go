@@ -31,6 +31,8 @@ SELECT
 	user_chains.user_id        AS user_id,
 	users.uid                  AS user_uid,
 	user_chains.is_chain_admin AS is_chain_admin,
+	user_chains.is_chain_warden AS is_chain_warden,
+	user_chains.role           AS role,
 	user_chains.created_at     AS created_at,
 	user_chains.is_paused   AS is_paused,
 	user_chains.is_approved    AS is_approved
@@ -84,6 +86,16 @@ WHERE c.id IN ?
 	return nil
 }
 
+// Sets the badge count of the inbox
+func (u *User) AddNotificationUnread(db *gorm.DB) error {
+	count, err := UserNotificationCountUnread(db, u.ID)
+	if err != nil {
+		return err
+	}
+	u.NotificationUnread = &count
+	return nil
+}
+
 // This required user to have run AddUserChainsToObject before this
 func (u *User) IsPartOfChain(chainUID string) (ok, isChainAdmin bool) {
 	for _, c := range u.Chains {
@@ -135,6 +147,14 @@ func (u *User) SetLastPokeToNow(db *gorm.DB) error {
 	return db.Exec(`UPDATE users SET last_poke_at = NOW() WHERE id = ?`, u.ID).Error
 }
 
+// Flags or unflags the user with this email address, shown to hosts so they can ask for a working address
+func UserSetEmailBounced(db *gorm.DB, email string, bounced bool) error {
+	if bounced {
+		return db.Exec(`UPDATE users SET email_bounced_at = NOW() WHERE email = ? AND email_bounced_at IS NULL`, email).Error
+	}
+	return db.Exec(`UPDATE users SET email_bounced_at = NULL WHERE email = ?`, email).Error
+}
+
 func (u *User) FindLinkedEventByUID(db *gorm.DB, eventUID string) (e *Event, err error) {
 	e = &Event{}
 	err = db.Raw(`
@@ -162,10 +182,12 @@ func (u *User) AcceptLegal(db *gorm.DB) error {
 }
 
 type UserContactData struct {
+	UID        string      `gorm:"uid"`
 	Name       string      `gorm:"name"`
 	Email      zero.String `gorm:"email"`
 	I18n       string      `gorm:"i18n"`
 	ChainName  string      `gorm:"chain_name"`
+	ChainID    uint        `gorm:"chain_id"`
 	IsApproved bool        `gorm:"is_approved"`
 }
 
@@ -215,10 +237,12 @@ func UserGetAdminsByChain(db *gorm.DB, chainId ...uint) ([]UserContactData, erro
 	results := []UserContactData{}
 	err := db.Raw(`
 SELECT
+	users.uid AS uid,
 	users.name AS name,
 	users.email AS email,
 	users.i18n AS i18n,
-	chains.name AS chain_name
+	chains.name AS chain_name,
+	chains.id AS chain_id
 FROM user_chains AS uc
 JOIN users ON uc.user_id = users.id
 JOIN chains ON uc.chain_id = chains.id 
@@ -239,7 +263,7 @@ func UserGetAllUsersByChain(db *gorm.DB, chainID uint) ([]User, error) {
 SELECT users.*
 FROM users
 JOIN user_chains ON user_chains.user_id = users.id 
-WHERE user_chains.chain_id = ? AND users.is_email_verified = TRUE
+WHERE user_chains.chain_id = ? AND users.is_email_verified = TRUE AND users.purge_at IS NULL
 	`, chainID).Scan(&results).Error
 
 	if err != nil {
@@ -248,6 +272,28 @@ WHERE user_chains.chain_id = ? AND users.is_email_verified = TRUE
 	return results, nil
 }
 
+// Returns the user uids grouped by their language, users without a language are grouped under "en"
+func UserUIDsGroupByI18n(db *gorm.DB, userUIDs []string) (map[string][]string, error) {
+	rows := []struct {
+		UID  string `gorm:"uid"`
+		I18n string `gorm:"i18n"`
+	}{}
+	err := db.Raw(`SELECT uid, i18n FROM users WHERE uid IN ?`, userUIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string][]string{}
+	for _, row := range rows {
+		i18n := row.I18n
+		if i18n == "" {
+			i18n = "en"
+		}
+		result[i18n] = append(result[i18n], row.UID)
+	}
+	return result, nil
+}
+
 func UserCheckEmail(db *gorm.DB, userEmail string) (userID uint, found bool, err error) {
 	if userEmail == "" {
 		return 0, false, errors.New("Email is required")
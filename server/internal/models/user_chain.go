@@ -23,8 +23,21 @@ func UserChainGetNote(db *gorm.DB, userID, chainID uint) (string, error) {
 	return note.String, nil
 }
 
+// Hosts and co-hosts are left unchanged, their role is only changed through UserChainSetRole
 func UserChainSetWarden(db *gorm.DB, userID, chainID uint, warden bool) error {
-	return db.Exec(`UPDATE user_chains SET is_chain_warden = ? WHERE user_id = ? AND chain_id = ?`, warden, userID, chainID).Error
+	return db.Exec(`
+UPDATE user_chains SET is_chain_warden = ?, role = ''
+WHERE user_id = ? AND chain_id = ? AND is_chain_admin = FALSE
+	`, warden, userID, chainID).Error
+}
+
+// The is_chain_admin and is_chain_warden columns are kept in sync with the role,
+// as many queries select hosts by is_chain_admin.
+func UserChainSetRole(db *gorm.DB, userID, chainID uint, role string, isChainAdmin, isChainWarden bool) error {
+	return db.Exec(`
+UPDATE user_chains SET role = ?, is_chain_admin = ?, is_chain_warden = ?
+WHERE user_id = ? AND chain_id = ?
+	`, role, isChainAdmin, isChainWarden, userID, chainID).Error
 }
 
 func ValidateAllRouteUserUIDs(db *gorm.DB, chainID uint, userUIDs []string) bool {
@@ -108,6 +121,24 @@ DELETE FROM bulky_items WHERE user_chain_id IN (
 		return fmt.Errorf("Unable to delete bags from user in loop: %v", err)
 	}
 
+	err = bulkyItemsRemoveReceiver(tx, `SELECT id FROM user_chains WHERE user_id = ? AND chain_id = ?`, u.ID, chainID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Unable to remove user from reserved bulky items in loop: %v", err)
+	}
+
+	err = tx.Exec(`DELETE FROM bulky_item_subscriptions WHERE user_id = ? AND chain_id = ?`, u.ID, chainID).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Unable to remove bulky item subscriptions from user in loop: %v", err)
+	}
+
+	err = tx.Exec(`DELETE FROM wishes WHERE user_id = ? AND chain_id = ?`, u.ID, chainID).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Unable to remove wishes from user in loop: %v", err)
+	}
+
 	return tx.Commit().Error
 }
 
@@ -129,9 +160,39 @@ DELETE FROM bulky_items WHERE user_chain_id IN (
 		return fmt.Errorf("Unable to delete bulky items from user: %v", err)
 	}
 
+	err = bulkyItemsRemoveReceiver(db, `SELECT id FROM user_chains WHERE user_id = ?`, u.ID)
+	if err != nil {
+		return fmt.Errorf("Unable to remove user from reserved bulky items: %v", err)
+	}
+
+	err = db.Exec(`DELETE FROM bulky_item_subscriptions WHERE user_id = ?`, u.ID).Error
+	if err != nil {
+		return fmt.Errorf("Unable to remove bulky item subscriptions from user: %v", err)
+	}
+
+	err = db.Exec(`DELETE FROM wishes WHERE user_id = ?`, u.ID).Error
+	if err != nil {
+		return fmt.Errorf("Unable to remove wishes from user: %v", err)
+	}
+
 	return nil
 }
 
+// Reservations of the user chains are released, items given to them keep their status
+func bulkyItemsRemoveReceiver(db *gorm.DB, userChainIDsSQL string, args ...any) error {
+	err := db.Exec(`
+UPDATE bulky_items SET status = ?
+WHERE status = ? AND receiver_user_chain_id IN (`+userChainIDsSQL+`)
+	`, append([]any{sharedtypes.BulkyItemStatusAvailable, sharedtypes.BulkyItemStatusReserved}, args...)...).Error
+	if err != nil {
+		return err
+	}
+	return db.Exec(`
+UPDATE bulky_items SET receiver_user_chain_id = NULL
+WHERE receiver_user_chain_id IN (`+userChainIDsSQL+`)
+	`, args...).Error
+}
+
 func UserChainGetIndirectByChain(db *gorm.DB, chainID uint) ([]sharedtypes.UserChain, error) {
 	results := []sharedtypes.UserChain{}
 
@@ -144,6 +205,7 @@ func UserChainGetIndirectByChain(db *gorm.DB, chainID uint) ([]sharedtypes.UserC
 		users.uid                  AS user_uid,
 		user_chains.is_chain_admin AS is_chain_admin,
 		user_chains.is_chain_warden AS is_chain_warden,
+		user_chains.role           AS role,
 		user_chains.created_at     AS created_at,
 		user_chains.is_paused      AS is_paused,
 		user_chains.is_approved    AS is_approved
@@ -155,7 +217,7 @@ func UserChainGetIndirectByChain(db *gorm.DB, chainID uint) ([]sharedtypes.UserC
 		FROM user_chains
 		LEFT JOIN chains ON chains.id = user_chains.chain_id
 		WHERE chains.id = ?
-	)	
+	) AND users.purge_at IS NULL
 	`, chainID).Scan(&results).Error
 
 	if err != nil {
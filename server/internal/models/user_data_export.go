@@ -0,0 +1,87 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	UserDataExportStatusPending = "pending"
+	UserDataExportStatusReady   = "ready"
+	UserDataExportStatusFailed  = "failed"
+)
+
+// The download link of an export is valid for this long after the export is ready
+const UserDataExportDuration = 7 * 24 * time.Hour
+
+var ErrUserDataExportNotFound = errors.New("Export not found or expired")
+
+// An export of all data of a user (GDPR Article 20), the zip file is created in the background
+type UserDataExport struct {
+	ID        uint
+	UserID    uint   `gorm:"index"`
+	Token     string `gorm:"uniqueIndex;size:64"`
+	Status    string `gorm:"size:20"`
+	FilePath  string
+	CreatedAt time.Time
+	ExpiresAt *time.Time
+}
+
+func UserDataExportGetByToken(db *gorm.DB, token string) (*UserDataExport, error) {
+	export := &UserDataExport{}
+	db.Raw(`
+SELECT * FROM user_data_exports
+WHERE token = ? AND status = ? AND expires_at > NOW()
+LIMIT 1
+	`, token, UserDataExportStatusReady).Scan(export)
+	if export.ID == 0 {
+		return nil, ErrUserDataExportNotFound
+	}
+	return export, nil
+}
+
+// Only one export per user can be pending at a time
+func UserDataExportHasPending(db *gorm.DB, userID uint) bool {
+	count := 0
+	db.Raw(`
+SELECT COUNT(*) FROM user_data_exports
+WHERE user_id = ? AND status = ? AND created_at > (NOW() - INTERVAL 1 DAY)
+	`, userID, UserDataExportStatusPending).Scan(&count)
+	return count > 0
+}
+
+func (e *UserDataExport) SetReady(db *gorm.DB, filePath string) error {
+	expiresAt := time.Now().Add(UserDataExportDuration)
+	e.Status = UserDataExportStatusReady
+	e.FilePath = filePath
+	e.ExpiresAt = &expiresAt
+	return db.Exec(`UPDATE user_data_exports SET status = ?, file_path = ?, expires_at = ? WHERE id = ?`,
+		e.Status, e.FilePath, e.ExpiresAt, e.ID).Error
+}
+
+func (e *UserDataExport) SetFailed(db *gorm.DB) error {
+	e.Status = UserDataExportStatusFailed
+	return db.Exec(`UPDATE user_data_exports SET status = ? WHERE id = ?`, e.Status, e.ID).Error
+}
+
+// Returns expired exports and exports that never finished, the files must be removed by the caller
+func UserDataExportGetOld(db *gorm.DB) ([]UserDataExport, error) {
+	exports := []UserDataExport{}
+	err := db.Raw(`
+SELECT * FROM user_data_exports
+WHERE expires_at < NOW() OR (expires_at IS NULL AND created_at < (NOW() - INTERVAL 1 DAY))
+	`).Scan(&exports).Error
+	return exports, err
+}
+
+func UserDataExportDeleteByUserID(db *gorm.DB, userID uint) ([]UserDataExport, error) {
+	exports := []UserDataExport{}
+	err := db.Raw(`SELECT * FROM user_data_exports WHERE user_id = ?`, userID).Scan(&exports).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Exec(`DELETE FROM user_data_exports WHERE user_id = ?`, userID).Error
+	return exports, err
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrEmailChangeNotFound = errors.New("Email change request not found or expired")
+
+// Verifications allowed per token, after which the email change has to be requested again
+const UserEmailChangeMaxAttempts = 5
+
+// A pending change of email address, the change is applied when the token sent to NewEmail is verified
+type UserEmailChange struct {
+	ID        uint
+	UserID    uint `gorm:"index"`
+	NewEmail  string
+	Token     string
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// Every call uses up one of UserEmailChangeMaxAttempts before the token is compared,
+// so the token can not be guessed with many requests at the same time
+func UserEmailChangeGetByToken(db *gorm.DB, userID uint, token string) (*UserEmailChange, error) {
+	res := db.Exec(`
+UPDATE user_email_changes SET attempts = attempts + 1
+WHERE user_id = ? AND attempts < ? AND created_at > (NOW() - INTERVAL 2 DAY)
+	`, userID, UserEmailChangeMaxAttempts)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrEmailChangeNotFound
+	}
+
+	emailChange := &UserEmailChange{}
+	db.Raw(`
+SELECT * FROM user_email_changes
+WHERE user_id = ? AND token = ? AND attempts <= ? AND created_at > (NOW() - INTERVAL 2 DAY)
+LIMIT 1
+	`, userID, token, UserEmailChangeMaxAttempts).Scan(emailChange)
+	if emailChange.ID == 0 {
+		return nil, ErrEmailChangeNotFound
+	}
+	return emailChange, nil
+}
+
+func UserEmailChangeDeleteOld(db *gorm.DB) {
+	db.Exec(`DELETE FROM user_email_changes WHERE created_at < (NOW() - INTERVAL 2 DAY)`)
+}
+
+// Moves the user and their newsletter subscription to the new email address.
+// The jwt token pepper is incremented so that all existing sessions are logged out.
+//
+// This should be run inside a transaction.
+func (u *User) ChangeEmail(tx *gorm.DB, newEmail string) (hasNewsletter bool, err error) {
+	oldEmail := ""
+	if u.Email != nil {
+		oldEmail = *u.Email
+	}
+
+	err = tx.Exec(`
+UPDATE users
+SET email = ?, is_email_verified = TRUE, email_bounced_at = NULL, jwt_token_pepper = jwt_token_pepper + 1
+WHERE id = ?
+	`, newEmail, u.ID).Error
+	if err != nil {
+		return false, err
+	}
+
+	countNewsletters := 0
+	tx.Raw(`SELECT COUNT(*) FROM newsletters WHERE email = ?`, oldEmail).Scan(&countNewsletters)
+	hasNewsletter = countNewsletters > 0
+	if hasNewsletter {
+		err = tx.Exec(`DELETE FROM newsletters WHERE email = ?`, newEmail).Error
+		if err != nil {
+			return false, err
+		}
+		err = tx.Exec(`UPDATE newsletters SET email = ?, verified = TRUE WHERE email = ?`, newEmail, oldEmail).Error
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Exec(`DELETE FROM user_email_changes WHERE user_id = ?`, u.ID).Error
+	if err != nil {
+		return false, err
+	}
+
+	u.Email = &newEmail
+	u.IsEmailVerified = true
+	u.JwtTokenPepper++
+	return hasNewsletter, nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// Kinds that are only shown in the inbox, next to the NotificationKinds
+const (
+	UserNotificationKindChainApproved = "chain_approved"
+	UserNotificationKindChatReport    = "chat_report"
+)
+
+// Default and largest amount of notifications per page
+const (
+	UserNotificationPageSize    = 20
+	UserNotificationPageSizeMax = 100
+)
+
+// A notification in the inbox of the user, kept for 90 days
+type UserNotification sharedtypes.UserNotification
+
+// Stores the same notification in the inbox of each user
+func UserNotificationAdd(db *gorm.DB, userUIDs []string, chainID uint, kind, title, content string) error {
+	if len(userUIDs) == 0 {
+		return nil
+	}
+	return db.Exec(`
+INSERT INTO user_notifications (user_id, kind, chain_id, title, content, created_at)
+SELECT id, ?, ?, ?, ?, NOW() FROM users WHERE uid IN ?
+	`, kind, chainID, title, content, userUIDs).Error
+}
+
+// Newest first, page starts at zero
+func UserNotificationGetAll(db *gorm.DB, userID uint, page, pageSize int) ([]sharedtypes.UserNotification, error) {
+	notifications := []sharedtypes.UserNotification{}
+	err := db.Raw(`
+SELECT n.*, c.uid AS chain_uid
+FROM user_notifications AS n
+LEFT JOIN chains AS c ON c.id = n.chain_id
+WHERE n.user_id = ?
+ORDER BY n.created_at DESC, n.id DESC
+LIMIT ? OFFSET ?
+	`, userID, pageSize, page*pageSize).Scan(&notifications).Error
+	return notifications, err
+}
+
+func UserNotificationCountUnread(db *gorm.DB, userID uint) (int, error) {
+	var count int
+	err := db.Raw(`SELECT COUNT(*) FROM user_notifications WHERE user_id = ? AND read_at IS NULL`, userID).Scan(&count).Error
+	return count, err
+}
+
+// Only marks notifications of the user, ids of other users are ignored
+func UserNotificationMarkRead(db *gorm.DB, userID uint, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.Exec(`
+UPDATE user_notifications SET read_at = NOW()
+WHERE user_id = ? AND id IN ? AND read_at IS NULL
+	`, userID, ids).Error
+}
+
+func UserNotificationMarkAllRead(db *gorm.DB, userID uint) error {
+	return db.Exec(`UPDATE user_notifications SET read_at = NOW() WHERE user_id = ? AND read_at IS NULL`, userID).Error
+}
+
+func UserNotificationDeleteOld(db *gorm.DB) error {
+	return db.Exec(`DELETE FROM user_notifications WHERE created_at < (NOW() - INTERVAL 90 DAY)`).Error
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Time between requesting to delete an account and the account being deleted
+const UserPurgeGracePeriod = 14 * 24 * time.Hour
+
+var ErrUserPurgeScheduleNotFound = errors.New("Scheduled account deletion not found")
+
+// A requested account deletion, during the grace period the user is hidden from their loops.
+// The answers of the leaving survey are stored here until the account is deleted.
+type UserPurgeSchedule struct {
+	ID                uint
+	UserID            uint     `gorm:"uniqueIndex"`
+	Token             string   `gorm:"uniqueIndex;size:64"`
+	ReasonsForLeaving []string `gorm:"serializer:json"`
+	OtherExplanation  string
+	PurgeAt           time.Time
+	CreatedAt         time.Time
+}
+
+// Creates the schedule and sets purge_at on the user, which hides the user from their loops
+func (s *UserPurgeSchedule) Create(db *gorm.DB) error {
+	s.PurgeAt = time.Now().Add(UserPurgeGracePeriod)
+
+	tx := db.Begin()
+	err := tx.Create(s).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Exec(`UPDATE users SET purge_at = ? WHERE id = ?`, s.PurgeAt, s.UserID).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func UserPurgeScheduleGetByToken(db *gorm.DB, token string) (*UserPurgeSchedule, error) {
+	s := &UserPurgeSchedule{}
+	db.Raw(`SELECT * FROM user_purge_schedules WHERE token = ? LIMIT 1`, token).Scan(s)
+	if s.ID == 0 {
+		return nil, ErrUserPurgeScheduleNotFound
+	}
+	return s, nil
+}
+
+func UserPurgeScheduleGetDue(db *gorm.DB) ([]UserPurgeSchedule, error) {
+	schedules := []UserPurgeSchedule{}
+	err := db.Raw(`SELECT * FROM user_purge_schedules WHERE purge_at < NOW()`).Scan(&schedules).Error
+	return schedules, err
+}
+
+func (s *UserPurgeSchedule) Cancel(db *gorm.DB) error {
+	tx := db.Begin()
+	err := tx.Exec(`DELETE FROM user_purge_schedules WHERE id = ?`, s.ID).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Exec(`UPDATE users SET purge_at = NULL WHERE id = ?`, s.UserID).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/the-clothing-loop/website/server/pkg/webpush"
+	"gorm.io/gorm"
+)
+
+// A browser that receives push notifications through Web Push
+type UserWebPushSubscription struct {
+	ID       uint
+	UserID   uint   `gorm:"index"`
+	Endpoint string `gorm:"size:500;uniqueIndex"`
+	P256dh   string `gorm:"size:200"`
+	Auth     string `gorm:"size:100"`
+	// Updated each time the browser subscribes again
+	UpdatedAt time.Time
+	CreatedAt time.Time
+}
+
+// The same browser can move to another user, after logging out and in again
+func UserWebPushSubscriptionPut(db *gorm.DB, userID uint, sub webpush.Subscription) error {
+	return db.Exec(`
+INSERT INTO user_web_push_subscriptions (user_id, endpoint, p256dh, auth, updated_at, created_at)
+VALUES (?, ?, ?, ?, NOW(), NOW())
+ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), p256dh = VALUES(p256dh), auth = VALUES(auth), updated_at = NOW()
+	`, userID, sub.Endpoint, sub.P256dh, sub.Auth).Error
+}
+
+func UserWebPushSubscriptionGetByUserUIDs(db *gorm.DB, userUIDs []string) ([]UserWebPushSubscription, error) {
+	subs := []UserWebPushSubscription{}
+	err := db.Raw(`
+SELECT s.* FROM user_web_push_subscriptions AS s
+JOIN users AS u ON u.id = s.user_id
+WHERE u.uid IN ?
+	`, userUIDs).Scan(&subs).Error
+	return subs, err
+}
+
+func UserWebPushSubscriptionDelete(db *gorm.DB, endpoint string) error {
+	return db.Exec(`DELETE FROM user_web_push_subscriptions WHERE endpoint = ?`, endpoint).Error
+}
+
+func (s *UserWebPushSubscription) Subscription() webpush.Subscription {
+	return webpush.Subscription{
+		Endpoint: s.Endpoint,
+		P256dh:   s.P256dh,
+		Auth:     s.Auth,
+	}
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+var ErrWishNotFound = errors.New("Wish not found")
+
+type Wish sharedtypes.Wish
+
+const wishSQLSelect = `SELECT wishes.*, u.uid AS user_uid, u.name AS user_name
+FROM wishes
+JOIN users AS u ON u.id = wishes.user_id
+`
+
+func WishGetAllByChain(db *gorm.DB, chainID uint, includeFulfilled bool) ([]Wish, error) {
+	sql := wishSQLSelect + `WHERE wishes.chain_id = ?`
+	if !includeFulfilled {
+		sql += ` AND wishes.fulfilled = FALSE`
+	}
+	sql += `
+ORDER BY wishes.created_at DESC`
+
+	wishes := []Wish{}
+	err := db.Raw(sql, chainID).Scan(&wishes).Error
+	if err != nil {
+		return nil, err
+	}
+	return wishes, nil
+}
+
+func WishGetByIDInChain(db *gorm.DB, chainID, id uint) (*Wish, error) {
+	wish := &Wish{}
+	err := db.Raw(wishSQLSelect+`WHERE wishes.id = ? AND wishes.chain_id = ? LIMIT 1`, id, chainID).Scan(wish).Error
+	if err != nil {
+		return nil, err
+	}
+	if wish.ID == 0 {
+		return nil, ErrWishNotFound
+	}
+	return wish, nil
+}
+
+// Open wishes of the approved members of the chain that do not belong to the given user
+func WishGetOpenByChain(db *gorm.DB, chainID uint, exceptUserUID string) ([]Wish, error) {
+	wishes := []Wish{}
+	err := db.Raw(wishSQLSelect+`JOIN user_chains AS uc ON uc.user_id = wishes.user_id AND uc.chain_id = wishes.chain_id
+WHERE wishes.chain_id = ? AND wishes.fulfilled = FALSE AND u.uid != ? AND uc.is_approved = TRUE`, chainID, exceptUserUID).Scan(&wishes).Error
+	if err != nil {
+		return nil, err
+	}
+	return wishes, nil
+}
+
+// Every word of the wish title must be the start of a word in the text.
+// The category must be the same and sizes and genders have to overlap, each only when both have them,
+// so bag notes which have no category can match any wish.
+func (w *Wish) Matches(text, category string, sizes, genders []string) bool {
+	if w.Category != "" && category != "" && w.Category != category {
+		return false
+	}
+	if len(w.Sizes) > 0 && len(sizes) > 0 && !lo.Some(w.Sizes, sizes) {
+		return false
+	}
+	if len(w.Genders) > 0 && len(genders) > 0 && !lo.Some(w.Genders, genders) {
+		return false
+	}
+
+	titleWords := wishWords(w.Title)
+	if len(titleWords) == 0 {
+		return false
+	}
+	textWords := wishWords(text)
+	for _, ww := range titleWords {
+		found := lo.ContainsBy(textWords, func(tw string) bool {
+			return strings.HasPrefix(tw, ww)
+		})
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Lowercase words of at least 2 letters or numbers
+func wishWords(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return lo.Filter(words, func(w string, _ int) bool {
+		return len([]rune(w)) >= 2
+	})
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestWishMatches(t *testing.T) {
+	coat := &Wish{Title: "Winter coat", Sizes: []string{SizeEnum5_12YearsOld}}
+	assert.True(t, coat.Matches("Two winter coats, barely worn", "", nil, nil))
+	assert.True(t, coat.Matches("WINTER-COAT", "", []string{SizeEnum5_12YearsOld, SizeEnumBaby}, nil))
+	assert.False(t, coat.Matches("Winter boots", "", nil, nil), "every word must match")
+	assert.False(t, coat.Matches("Winter coat", "", []string{SizeEnumMenLarge}, nil), "sizes do not overlap")
+
+	crib := &Wish{Title: "crib", Category: sharedtypes.BulkyItemCategoryKids, Genders: []string{GenderEnumChildren}}
+	assert.True(t, crib.Matches("Wooden crib", sharedtypes.BulkyItemCategoryKids, nil, []string{GenderEnumChildren}))
+	assert.False(t, crib.Matches("Wooden crib", sharedtypes.BulkyItemCategoryFurniture, nil, nil), "other category")
+	assert.True(t, crib.Matches("Wooden crib", "", nil, nil), "bags have no category")
+	assert.False(t, crib.Matches("Wooden crib", sharedtypes.BulkyItemCategoryKids, nil, []string{GenderEnumWomen}))
+
+	assert.True(t, (&Wish{Title: "TV"}).Matches("Old tv with remote", sharedtypes.BulkyItemCategoryElectronics, nil, nil))
+	assert.False(t, (&Wish{Title: "?"}).Matches("anything", "", nil, nil))
+}
@@ -8,7 +8,10 @@ import (
 	cron "github.com/go-co-op/gocron"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
 	"github.com/the-clothing-loop/website/server/pkg/throttle"
+	"gorm.io/gorm"
 )
 
 var Scheduler *cron.Scheduler
@@ -18,17 +21,19 @@ func Routes() *gin.Engine {
 	// initialization
 	db := app.DatabaseInit()
 	app.MailInit()
+	if err := app.OidcInit(); err != nil {
+		panic(err)
+	}
 
-	app.ChatInit(app.Config.MM_URL, app.Config.MM_TOKEN)
-	app.ChatSetDefaultSettings(app.ChatClient)
+	if err := app.ChatInit(db); err != nil {
+		panic(err)
+	}
 
 	if app.Config.ENV == app.EnvEnumProduction || (app.Config.SENDINBLUE_API_KEY != "" && app.Config.ENV == app.EnvEnumDevelopment) {
 		app.BrevoInit()
 	}
 
-	if app.Config.ONESIGNAL_APP_ID != "" && app.Config.ONESIGNAL_REST_API_KEY != "" {
-		app.OneSignalInit()
-	}
+	app.PushInit()
 
 	// set gin mode
 	if app.Config.ENV == app.EnvEnumProduction || app.Config.ENV == app.EnvEnumAcceptance {
@@ -40,6 +45,11 @@ func Routes() *gin.Engine {
 	}
 
 	if app.Config.ENV != app.EnvEnumTesting {
+		app.MailOnDead = func(db *gorm.DB, m *models.Mail) {
+			views.EmailRootAdminFailedLastRetry(db, m.ToAddress, m.Subject)
+		}
+		app.MailQueueStart(db, app.Config.MAIL_QUEUE_WORKERS)
+
 		Scheduler = cron.NewScheduler(time.UTC)
 
 		// At 08:03 on day-of-month 1.
@@ -50,6 +60,10 @@ func Routes() *gin.Engine {
 		// https://crontab.guru/#31_*_*_*_*
 		Scheduler.Cron("31 * * * *").Do(controllers.CronHourly, db)
 
+		// At 09:05 on Monday.
+		// https://crontab.guru/#5_9_*_*_1
+		Scheduler.Cron("5 9 * * 1").Do(controllers.CronWeekly, db)
+
 		// At 08:08.
 		// https://crontab.guru/#8_8_*_*_*
 		Scheduler.Cron("8 8 * * *").Do(controllers.CronDaily, db)
@@ -121,14 +135,41 @@ func Routes() *gin.Engine {
 	v2.POST("/payment/initiate", controllers.PaymentsInitiate)
 	v2.POST("/payment/webhook", controllers.PaymentsWebhook)
 
+	// mail
+	v2.POST("/mail/webhook", controllers.MailWebhook)
+	v2.GET("/mail/health", controllers.MailHealth)
+	v2.GET("/mail/unsubscribe", controllers.MailUnsubscribe)
+	v2.POST("/mail/unsubscribe", controllers.MailUnsubscribe)
+
 	// user
 	v2.GET("/user", controllers.UserGet)
 	v2.GET("/user/all-chain", controllers.UserGetAllOfChain)
 	v2.GET("/user/newsletter", controllers.UserHasNewsletter)
 	v2.PATCH("/user", controllers.UserUpdate)
 	v2.DELETE("/user/purge", controllers.UserPurge)
+	v2.POST("/user/purge/cancel", controllers.UserPurgeCancel)
 	v2.POST("/user/transfer-chain", controllers.UserTransferChain)
 	v2.GET("/user/check-email", controllers.UserCheckIfEmailExists)
+	v2.POST("/user/email-change", controllers.UserEmailChange)
+	v2.POST("/user/email-change/verify", controllers.UserEmailChangeVerify)
+	v2.POST("/user/export", controllers.UserDataExport)
+	v2.GET("/user/export/download", controllers.UserDataExportDownload)
+	v2.GET("/user/notification-preferences", controllers.NotificationPreferencesGet)
+	v2.PATCH("/user/notification-preferences", controllers.NotificationPreferenceUpdate)
+	v2.GET("/user/web-push-subscription/public-key", controllers.WebPushPublicKey)
+	v2.PUT("/user/web-push-subscription", controllers.WebPushSubscriptionPut)
+	v2.DELETE("/user/web-push-subscription", controllers.WebPushSubscriptionDelete)
+	v2.GET("/user/notification/all", controllers.UserNotificationGetAll)
+	v2.PATCH("/user/notification/read", controllers.UserNotificationMarkRead)
+	v2.PATCH("/user/notification/read-all", controllers.UserNotificationMarkAllRead)
+
+	// openid connect
+	r.GET("/.well-known/openid-configuration", controllers.OidcDiscovery)
+	v2.GET("/oidc/authorize", controllers.OidcAuthorize)
+	v2.POST("/oidc/token", controllers.OidcToken)
+	v2.GET("/oidc/userinfo", controllers.OidcUserInfo)
+	v2.POST("/oidc/userinfo", controllers.OidcUserInfo)
+	v2.GET("/oidc/jwks", controllers.OidcJwks)
 
 	// chain
 	v2.GET("/chain", controllers.ChainGet)
@@ -145,12 +186,27 @@ func Routes() *gin.Engine {
 	v2.PATCH("/chain/user/note", controllers.ChainChangeUserNote)
 	v2.GET("/chain/user/note", controllers.ChainGetUserNote)
 	v2.PATCH("/chain/user/warden", controllers.ChainChangeUserWarden)
+	v2.PATCH("/chain/user/role", controllers.ChainChangeUserRole)
+	v2.GET("/chain/roles", controllers.ChainRoleGetAll)
+	v2.PUT("/chain/role", controllers.ChainRolePut)
+	v2.DELETE("/chain/role", controllers.ChainRoleDelete)
 
 	// chat
 	v2.PATCH("/chat/user", controllers.ChatPatchUser)
 	v2.POST("/chat/channel/create", controllers.ChatCreateChannel)
 	v2.POST("/chat/channel/join", controllers.ChatJoinChannels)
 	v2.POST("/chat/channel/delete", controllers.ChatDeleteChannel)
+	v2.GET("/chat/channel/messages", controllers.ChatMessageGetAll)
+	v2.POST("/chat/channel/message", controllers.ChatMessageCreate)
+	v2.GET("/chat/ws", controllers.ChatWebSocket)
+	v2.GET("/chat/sync", controllers.ChatSyncReport)
+	v2.POST("/chat/message/report", controllers.ChatMessageReportCreate)
+	v2.GET("/chat/reports", controllers.ChatMessageReportGetAll)
+	v2.DELETE("/chat/report", controllers.ChatMessageReportDelete)
+	v2.GET("/chat/mutes", controllers.ChatMuteGetAll)
+	v2.PUT("/chat/mute", controllers.ChatMutePut)
+	v2.DELETE("/chat/mute", controllers.ChatMuteDelete)
+	v2.PATCH("/chat/retention", controllers.ChatRetentionUpdate)
 
 	// bag
 	v2.GET("/bag/all", controllers.BagGetAll)
@@ -162,10 +218,22 @@ func Routes() *gin.Engine {
 	v2.GET("/bulky-item/all", controllers.BulkyGetAll)
 	v2.PUT("/bulky-item", controllers.BulkyPut)
 	v2.DELETE("/bulky-item", controllers.BulkyRemove)
-
-	// imgbb
+	v2.POST("/bulky-item/reserve", controllers.BulkyReserve)
+	v2.DELETE("/bulky-item/reserve", controllers.BulkyUnreserve)
+	v2.PATCH("/bulky-item/given", controllers.BulkyGiven)
+	v2.GET("/bulky-item/subscriptions", controllers.BulkySubscriptionsGet)
+	v2.PUT("/bulky-item/subscriptions", controllers.BulkySubscriptionsPut)
+
+	// wish
+	v2.GET("/wish/all", controllers.WishGetAll)
+	v2.PUT("/wish", controllers.WishPut)
+	v2.DELETE("/wish", controllers.WishRemove)
+
+	// image
 	v2.POST("/image", controllers.ImageUpload)
-	v2.DELETE("/image", controllers.ImageDeleteDeprecated)
+	v2.GET("/image/gc", controllers.ImageGCReport)
+	v2.GET("/image/:size/:name", controllers.ImageGet)
+	v2.DELETE("/image", controllers.ImageDelete)
 	v2.GET("/image_purge", controllers.ImagePurge)
 
 	// route
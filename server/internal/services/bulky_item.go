@@ -0,0 +1,171 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// The author is reminded this many days before their bulky item expires
+const BulkyItemReminderDays = 2
+
+var (
+	ErrBulkyItemReserveOwn  = errors.New("You can not reserve your own bulky item")
+	ErrBulkyItemNotReserver = errors.New("Only the author or the member that reserved the bulky item can do this")
+	ErrBulkyItemNotAuthor   = errors.New("Only the author of the bulky item can do this")
+	ErrBulkyItemNoReceiver  = errors.New("The member that received the bulky item is required")
+)
+
+func BulkyItemReserve(db *gorm.DB, chain *models.Chain, user *models.User, bulkyItem *models.BulkyItem) error {
+	if bulkyItem.Status != sharedtypes.BulkyItemStatusAvailable {
+		return models.ErrBulkyItemNotAvailable
+	}
+	if bulkyItem.UserUID == user.UID {
+		return ErrBulkyItemReserveOwn
+	}
+	ucID, err := bulkyItemUserChainID(db, chain.ID, user.UID)
+	if err != nil {
+		return err
+	}
+
+	err = bulkyItem.SetStatus(db, sharedtypes.BulkyItemStatusReserved, &ucID)
+	if err != nil {
+		return err
+	}
+
+	views.NotificationSend(db, models.NotificationKindBulkyItemReserved, chain.ID, []string{bulkyItem.UserUID}, gin.H{
+		"Title":     bulkyItem.Title,
+		"ChainName": chain.Name,
+	})
+	return nil
+}
+
+// Both the member that reserved the item and the author can cancel the reservation
+func BulkyItemUnreserve(db *gorm.DB, user *models.User, bulkyItem *models.BulkyItem) error {
+	if bulkyItem.Status != sharedtypes.BulkyItemStatusReserved {
+		return models.ErrBulkyItemNotAvailable
+	}
+	if bulkyItem.UserUID != user.UID && bulkyItem.ReceiverUID != user.UID {
+		return ErrBulkyItemNotReserver
+	}
+
+	return bulkyItem.SetStatus(db, sharedtypes.BulkyItemStatusAvailable, nil)
+}
+
+// Marks the item as given away, to the receiver or else to the member that reserved it
+func BulkyItemGive(db *gorm.DB, chain *models.Chain, user *models.User, bulkyItem *models.BulkyItem, receiverUID string) error {
+	if bulkyItem.UserUID != user.UID {
+		return ErrBulkyItemNotAuthor
+	}
+	if bulkyItem.Status == sharedtypes.BulkyItemStatusGiven {
+		return models.ErrBulkyItemNotAvailable
+	}
+
+	receiverUserChainID := bulkyItem.ReceiverUserChainID
+	if bulkyItem.Status != sharedtypes.BulkyItemStatusReserved {
+		receiverUserChainID = nil
+	}
+	if receiverUID != "" {
+		ucID, err := bulkyItemUserChainID(db, chain.ID, receiverUID)
+		if err != nil {
+			return err
+		}
+		receiverUserChainID = &ucID
+	}
+	if receiverUserChainID == nil {
+		return ErrBulkyItemNoReceiver
+	}
+
+	return bulkyItem.SetStatus(db, sharedtypes.BulkyItemStatusGiven, receiverUserChainID)
+}
+
+func bulkyItemUserChainID(db *gorm.DB, chainID uint, userUID string) (uint, error) {
+	ucID := uint(0)
+	err := db.Raw(`
+SELECT uc.id FROM user_chains AS uc
+JOIN users AS u ON u.id = uc.user_id
+WHERE u.uid = ? AND uc.chain_id = ? AND uc.is_approved = TRUE
+LIMIT 1
+	`, userUID, chainID).Scan(&ucID).Error
+	if err != nil {
+		return 0, err
+	}
+	if ucID == 0 {
+		return 0, models.ErrUserNotFound
+	}
+	return ucID, nil
+}
+
+// Reminds authors of bulky items that are about to expire and expires the items that were reminded long enough ago.
+// Only loops that have set expiry days are included.
+func BulkyItemExpiryRun(db *gorm.DB) {
+	slog.Info("Running BulkyItemExpiryRun")
+	statuses := []string{sharedtypes.BulkyItemStatusAvailable, sharedtypes.BulkyItemStatusReserved}
+
+	reminders := []struct {
+		ID        uint
+		Title     string
+		UserUID   string
+		ChainID   uint
+		ChainName string
+	}{}
+	err := db.Raw(`
+SELECT bi.id, bi.title, u.uid AS user_uid, c.id AS chain_id, c.name AS chain_name
+FROM bulky_items AS bi
+JOIN user_chains AS uc ON uc.id = bi.user_chain_id
+JOIN users AS u ON u.id = uc.user_id
+JOIN chains AS c ON c.id = uc.chain_id
+WHERE c.bulky_item_expiry_days > 0
+	AND bi.status IN ?
+	AND bi.last_notified_at IS NULL
+	AND bi.updated_at < DATE_SUB(NOW(), INTERVAL (c.bulky_item_expiry_days - ?) DAY)
+	`, statuses, BulkyItemReminderDays).Scan(&reminders).Error
+	if err != nil {
+		slog.Error("Unable to find bulky items that are about to expire", "err", err)
+		return
+	}
+	if len(reminders) > 0 {
+		ids := []uint{}
+		for _, r := range reminders {
+			views.NotificationSend(db, models.NotificationKindBulkyItemExpiring, r.ChainID, []string{r.UserUID}, gin.H{
+				"Title":     r.Title,
+				"ChainName": r.ChainName,
+			})
+			ids = append(ids, r.ID)
+		}
+		db.Exec(`UPDATE bulky_items SET last_notified_at = NOW() WHERE id IN ?`, ids)
+	}
+
+	// the reminder is always sent before the item expires, even when the expiry days were shortened
+	expiredIDs := []uint{}
+	err = db.Raw(`
+SELECT bi.id
+FROM bulky_items AS bi
+JOIN user_chains AS uc ON uc.id = bi.user_chain_id
+JOIN chains AS c ON c.id = uc.chain_id
+WHERE c.bulky_item_expiry_days > 0
+	AND bi.status IN ?
+	AND bi.updated_at < DATE_SUB(NOW(), INTERVAL c.bulky_item_expiry_days DAY)
+	AND bi.last_notified_at < DATE_SUB(NOW(), INTERVAL ? DAY)
+	`, statuses, BulkyItemReminderDays).Scan(&expiredIDs).Error
+	if err != nil {
+		slog.Error("Unable to find expired bulky items", "err", err)
+		return
+	}
+	if len(expiredIDs) > 0 {
+		err = db.Exec(`
+UPDATE bulky_items SET status = ?, receiver_user_chain_id = NULL
+WHERE id IN ?
+		`, sharedtypes.BulkyItemStatusExpired, expiredIDs).Error
+		if err != nil {
+			slog.Error("Unable to expire bulky items", "err", err)
+			return
+		}
+		slog.Info("Expired bulky items", "count", len(expiredIDs))
+	}
+}
@@ -22,6 +22,8 @@ func ChainDelete(db *gorm.DB, chain *models.Chain) *httperror.HttpError {
 		return httperror.New(http.StatusInternalServerError, "Unable to delete loop, please contact us.")
 	}
 
+	ChatCleanupRunLater(db)
+
 	emailLoopHasBeenDeleted(db, users, chain.Name)
 	return nil
 }
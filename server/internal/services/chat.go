@@ -2,110 +2,107 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"regexp"
 	"strings"
 
-	"github.com/GGP1/atoll"
-	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/samber/lo"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 	"gorm.io/gorm"
 )
 
-// Checks if the user is registered on the matrix server if not they are registered
-func ChatPatchUser(db *gorm.DB, ctx context.Context, mmTeamId string, user *models.User) error {
-	var mmUser *model.User
-	var err error
-	// check if user exists properly
-	if user.ChatUserID != nil {
-		// get the chat user
-		mmUser, _, err = app.ChatClient.GetUser(ctx, *user.ChatUserID, "")
-		if err == nil {
-			slog.Info("chat user exists", "id", mmUser.Id, "err", err)
-		}
-	}
-
-	if mmUser == nil {
-		slog.Info("create Chat user if does not exist")
+var ErrChatUnavailable = errors.New("Chat is not available")
 
-		username := func() string {
-			p, _ := atoll.NewPassword(10, []atoll.Level{atoll.Digit, atoll.Lower, atoll.Level("-_.")})
-			return "u" + string(p)
-		}()
-		password := func() string {
-			p, _ := atoll.NewPassword(16, []atoll.Level{atoll.Digit, atoll.Lower})
-			return string(p)
-		}()
-		if user.Email == nil {
-			return fmt.Errorf("Email is required")
-		}
-		mmUser, _, err = app.ChatClient.CreateUser(ctx, &model.User{
-			Nickname: user.Name,
-			Username: username,
-			Password: password,
-			Email:    username + "@example.com",
-		})
-		if err != nil {
-			return err
-		}
+// Checks if the user is registered on the chat server if not they are registered.
+// Returns the session token for the app, see app.ChatProvider.
+func ChatPatchUser(db *gorm.DB, ctx context.Context, user *models.User) (string, error) {
+	if app.Chat == nil {
+		return "", ErrChatUnavailable
+	}
 
-		_, _, err = app.ChatClient.AddTeamMember(ctx, mmTeamId, mmUser.Id)
-		if err != nil {
-			return err
-		}
+	chatUserID, chatUserName, err := app.Chat.UserPatch(ctx, user)
+	if err != nil {
+		return "", err
+	}
 
-		// Update database
-		user.ChatUserID = &mmUser.Id
-		user.ChatPass = &password
-		user.ChatUserName = &username
-		db.Exec(`UPDATE users SET chat_user_id = ?, chat_pass = ?, chat_user_name = ? WHERE id = ?`,
+	// Update database
+	if user.ChatUserID == nil || *user.ChatUserID != chatUserID || user.ChatUserName == nil || *user.ChatUserName != chatUserName {
+		user.ChatUserID = &chatUserID
+		user.ChatUserName = &chatUserName
+		db.Exec(`UPDATE users SET chat_user_id = ?, chat_user_name = ? WHERE id = ?`,
 			*user.ChatUserID,
-			*user.ChatPass,
 			*user.ChatUserName,
 			user.ID)
 	}
 
-	return nil
+	previousToken := lo.FromPtr(user.ChatToken)
+	token, err := app.Chat.UserToken(ctx, chatUserID, previousToken)
+	if err != nil {
+		return "", err
+	}
+	if token != previousToken {
+		user.ChatToken = &token
+		db.Exec(`UPDATE users SET chat_token = ? WHERE id = ?`, token, user.ID)
+	}
+	return token, nil
 }
 
-func ChatCreateChannel(db *gorm.DB, ctx context.Context, chain *models.Chain, mmUserId, name, color string) (*model.Channel, error) {
-	newChannel := &model.Channel{
-		TeamId:      app.ChatTeamId,
-		Name:        fmt.Sprintf("%dr%d%s", chain.ID, len(chain.ChatRoomIDs)+1, lo.RandomString(5, lo.LowerCaseLettersCharset)),
-		DisplayName: name,
-		Type:        model.ChannelTypePrivate,
-		Header:      color,
+// Revokes the chat session tokens of the app, the next ChatPatchUser creates a new one
+func ChatUserTokenRevoke(db *gorm.DB, ctx context.Context, user *models.User) error {
+	if app.Chat == nil || user.ChatUserID == nil {
+		return nil
 	}
 
-	newChannel, _, err := app.ChatClient.CreateChannel(ctx, newChannel)
+	err := app.Chat.UserTokenRevoke(ctx, *user.ChatUserID)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	user.ChatToken = nil
+	return db.Exec(`UPDATE users SET chat_token = NULL WHERE id = ?`, user.ID).Error
+}
+
+func ChatCreateChannel(db *gorm.DB, ctx context.Context, chain *models.Chain, chatUserID, name, color string) (string, error) {
+	if app.Chat == nil {
+		return "", ErrChatUnavailable
 	}
 
-	err = chatChannelAddUser(ctx, newChannel.Id, mmUserId, true)
+	channelID, err := app.Chat.ChannelCreate(ctx, chain.ID, name, color)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	chain.ChatRoomIDs = append(chain.ChatRoomIDs, newChannel.Id)
+	err = app.Chat.ChannelJoin(ctx, channelID, chatUserID, true)
+	if err != nil {
+		return "", err
+	}
+
+	chain.ChatRoomIDs = append(chain.ChatRoomIDs, channelID)
 	err = chain.SaveChannelIDs(db)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return newChannel, nil
+	return channelID, nil
 }
 
-func ChatDeleteChannel(db *gorm.DB, ctx context.Context, chain *models.Chain, mmChannelID string) error {
-	_, err := app.ChatClient.DeleteChannel(ctx, mmChannelID)
+func ChatDeleteChannel(db *gorm.DB, ctx context.Context, chain *models.Chain, channelID string) error {
+	if app.Chat == nil {
+		return ErrChatUnavailable
+	}
+	if !lo.Contains(chain.ChatRoomIDs, channelID) {
+		return fmt.Errorf("Channel does not exist in this Loop")
+	}
+
+	err := app.Chat.ChannelDelete(ctx, channelID)
 	if err != nil {
 		return err
 	}
 
 	chain.ChatRoomIDs = lo.Filter(chain.ChatRoomIDs, func(roomID string, _ int) bool {
-		return roomID != mmChannelID
+		return roomID != channelID
 	})
 	err = chain.SaveChannelIDs(db)
 	if err != nil {
@@ -114,73 +111,102 @@ func ChatDeleteChannel(db *gorm.DB, ctx context.Context, chain *models.Chain, mm
 	return nil
 }
 
-func chatChannelAddUser(ctx context.Context, mmChannelId string, mmUserId string, setRoleAdmin bool) error {
-	member, _, err := app.ChatClient.AddChannelMember(ctx, mmChannelId, mmUserId)
+func ChatJoinChannel(db *gorm.DB, ctx context.Context, chain *models.Chain, user *models.User, isChainAdmin bool, channelID string) error {
+	if app.Chat == nil {
+		return ErrChatUnavailable
+	}
+	if user.ChatUserID == nil {
+		return fmt.Errorf("You must be registered on our chat server before joining a room")
+	}
+
+	if len(chain.ChatRoomIDs) == 0 || !lo.Contains(chain.ChatRoomIDs, channelID) {
+		return fmt.Errorf("Channel does not exist in this Loop")
+	}
+
+	err := app.Chat.ChannelJoin(ctx, channelID, *user.ChatUserID, isChainAdmin)
 	if err != nil {
 		return err
 	}
-	err = chatChannelSetMemberRole(ctx, mmChannelId, member, setRoleAdmin)
+
+	isMuted, err := models.ChatMuteIsActive(db, chain.ID, user.ID)
 	if err != nil {
 		return err
 	}
-
-	return nil
-}
-
-func chatChannelSetMemberRole(ctx context.Context, mmChannelId string, member *model.ChannelMember, setRoleAdmin bool) error {
-	slog.Info("chatChannelSetMemberRole", "roles", member.Roles)
-	roles := strings.Split(member.Roles, " ")
-
-	isRolesContainsAdmin := lo.Contains(roles, model.ChannelAdminRoleId)
-	shouldUpdateRoles := isRolesContainsAdmin != setRoleAdmin
-	if shouldUpdateRoles {
-		if setRoleAdmin {
-			roles = append(roles, model.ChannelAdminRoleId)
-		} else {
-			roles = lo.Filter(roles, func(r string, i int) bool {
-				return r != model.ChannelAdminRoleId
-			})
-		}
-		_, err := app.ChatClient.UpdateChannelRoles(ctx, mmChannelId, member.UserId, strings.Join(roles, " "))
-		if err != nil {
+	if isMuted {
+		removed, err := app.Chat.MemberMute(ctx, channelID, *user.ChatUserID, true)
+		if err != nil || !removed {
 			return err
 		}
+		return models.ChatMuteAddChannelIDs(db, chain.ID, user.ID, []string{channelID})
 	}
 	return nil
 }
 
-func ChatJoinChannel(db *gorm.DB, ctx context.Context, chain *models.Chain, user *models.User, isChainAdmin bool, mmChannelId string) error {
-	if user.ChatUserID == nil {
-		return fmt.Errorf("You must be registered on our chat server before joining a room")
-	}
+var reChatValidateUniqueName = regexp.MustCompile("[^a-z0-9]")
 
-	if len(chain.ChatRoomIDs) == 0 || !lo.Contains(chain.ChatRoomIDs, mmChannelId) {
-		return fmt.Errorf("Channel does not exist in this Loop")
+func ChatValidateUniqueName(name string) string {
+	return reChatValidateUniqueName.ReplaceAllString(strings.ToLower(name), "")
+}
+
+// Stores a message of the built-in chat and delivers it to the members of the channel
+func ChatMessageSend(db *gorm.DB, chain *models.Chain, user *models.User, channelID, message string) (*sharedtypes.ChatMessage, error) {
+	builtin, ok := app.ChatBuiltin()
+	if !ok {
+		return nil, ErrChatUnavailable
+	}
+	channel, err := chatBuiltinChannelOfMember(db, chain, user, channelID)
+	if err != nil {
+		return nil, err
+	}
+	isMuted, err := models.ChatMuteIsActive(db, chain.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if isMuted {
+		return nil, ErrChatMuted
 	}
 
-	// Check if room already contains user
-	mmChannelMembers, _, _ := app.ChatClient.GetChannelMembersByIds(ctx, mmChannelId, []string{*user.ChatUserID})
-	if len(mmChannelMembers) != 0 {
-		member, ok := lo.Find(mmChannelMembers, func(member model.ChannelMember) bool {
-			return member.UserId == *user.ChatUserID
-		})
-		if ok {
-			chatChannelSetMemberRole(ctx, mmChannelId, &member, isChainAdmin)
-			return nil
-		}
+	m, err := models.ChatMessageAdd(db, channel, user, message)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add user if not already added to chat room
-	err := chatChannelAddUser(ctx, mmChannelId, *user.ChatUserID, isChainAdmin)
+	memberIDs, err := models.ChatChannelMemberGetUserIDs(db, channel.ID)
 	if err != nil {
-		return err
+		slog.Error("Unable to find chat channel members", "err", err)
 	}
+	builtin.Publish(memberIDs, *m)
+	return m, nil
+}
 
-	return nil
+// Returns the messages of a channel of the built-in chat, newest first
+func ChatMessageGetAll(db *gorm.DB, chain *models.Chain, user *models.User, channelID string, beforeID uint) ([]sharedtypes.ChatMessage, error) {
+	if _, ok := app.ChatBuiltin(); !ok {
+		return nil, ErrChatUnavailable
+	}
+	channel, err := chatBuiltinChannelOfMember(db, chain, user, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return models.ChatMessageGetAll(db, channel.ID, beforeID)
 }
 
-var reChatValidateUniqueName = regexp.MustCompile("[^a-z0-9]")
+var ErrChatNotMember = errors.New("You must join the channel first")
 
-func ChatValidateUniqueName(name string) string {
-	return reChatValidateUniqueName.ReplaceAllString(strings.ToLower(name), "")
+func chatBuiltinChannelOfMember(db *gorm.DB, chain *models.Chain, user *models.User, channelID string) (*models.ChatChannel, error) {
+	if !lo.Contains(chain.ChatRoomIDs, channelID) {
+		return nil, fmt.Errorf("Channel does not exist in this Loop")
+	}
+	channel, err := models.ChatChannelGetByUID(db, channelID)
+	if err != nil {
+		return nil, err
+	}
+	isMember, err := models.ChatChannelMemberExists(db, channel.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrChatNotMember
+	}
+	return channel, nil
 }
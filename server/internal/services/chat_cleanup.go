@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// Cleanups handled per run, the rest is picked up by the next run
+const chatCleanupBatchSize = 100
+
+// Prevents the cron and a deletion from removing the same resource at once
+var chatCleanupMu sync.Mutex
+
+// Removes the channels of deleted loops and deactivates the chat users of deleted users.
+// Failures are retried with an increasing delay by the hourly cron, cleanups are never given up on.
+func ChatCleanupRun(db *gorm.DB, ctx context.Context) {
+	if app.Chat == nil {
+		return
+	}
+	chatCleanupMu.Lock()
+	defer chatCleanupMu.Unlock()
+
+	cleanups, err := models.ChatCleanupGetDue(db, chatCleanupBatchSize)
+	if err != nil {
+		slog.Error("Unable to find chat cleanups", "err", err)
+		return
+	}
+
+	for i := range cleanups {
+		cleanup := &cleanups[i]
+		switch cleanup.Kind {
+		case models.ChatCleanupKindChannel:
+			err = app.Chat.ChannelDelete(ctx, cleanup.ChatID)
+		case models.ChatCleanupKindUser:
+			err = app.Chat.UserDeactivate(ctx, cleanup.ChatID)
+		}
+		if errors.Is(err, app.ErrChatNotFound) {
+			err = nil
+		}
+
+		if err != nil {
+			slog.Error("Unable to remove chat resource, trying again later",
+				"err", err,
+				"kind", cleanup.Kind,
+				"chat_id", cleanup.ChatID,
+				"attempts", cleanup.Attempts+1)
+			err = cleanup.SetFailed(db, err, time.Now().Add(chatCleanupBackoff(cleanup.Attempts+1)))
+		} else {
+			err = cleanup.Delete(db)
+		}
+		if err != nil {
+			slog.Error("Unable to update chat cleanup", "err", err, "id", cleanup.ID)
+		}
+	}
+}
+
+// Runs ChatCleanupRun in the background after a loop or user is deleted
+func ChatCleanupRunLater(db *gorm.DB) {
+	if app.Chat == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), chatSyncTimeout)
+		defer cancel()
+		ChatCleanupRun(db, ctx)
+	}()
+}
+
+// 5 minutes after the first failed attempt, doubling up to once a day
+func chatCleanupBackoff(attempts int) time.Duration {
+	delay := 5 * time.Minute
+	for i := 1; i < attempts && delay < 24*time.Hour; i++ {
+		delay *= 2
+	}
+	return min(delay, 24*time.Hour)
+}
@@ -0,0 +1,16 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatCleanupBackoff(t *testing.T) {
+	assert.Equal(t, 5*time.Minute, chatCleanupBackoff(1))
+	assert.Equal(t, 10*time.Minute, chatCleanupBackoff(2))
+	assert.Equal(t, 80*time.Minute, chatCleanupBackoff(5))
+	assert.Equal(t, 24*time.Hour, chatCleanupBackoff(10))
+	assert.Equal(t, 24*time.Hour, chatCleanupBackoff(1000))
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrChatMuted          = errors.New("You are muted in the chat of this Loop")
+	ErrChatMuteNotAllowed = errors.New("Members that manage the chat can not be muted")
+)
+
+// Stores the message as it is now for the hosts of the loop and lets them know in their inbox
+func ChatMessageReport(db *gorm.DB, ctx context.Context, chain *models.Chain, reporter *models.User, channelID, messageID, reason string) error {
+	if app.Chat == nil {
+		return ErrChatUnavailable
+	}
+	if !lo.Contains(chain.ChatRoomIDs, channelID) {
+		return fmt.Errorf("Channel does not exist in this Loop")
+	}
+
+	info, err := app.Chat.MessageGet(ctx, channelID, messageID)
+	if err != nil {
+		return err
+	}
+
+	err = models.ChatMessageReportAdd(db, &models.ChatMessageReport{
+		ChainID:          chain.ID,
+		ChannelID:        channelID,
+		MessageID:        messageID,
+		Message:          info.Message,
+		AuthorChatUserID: info.ChatUserID,
+		ReporterUserID:   reporter.ID,
+		Reason:           reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	hosts, err := models.UserGetAdminsByChain(db, chain.ID)
+	if err != nil {
+		slog.Error("Unable to find hosts to notify of chat report", "err", err)
+		return nil
+	}
+	hostUIDs := lo.Map(hosts, func(h models.UserContactData, _ int) string { return h.UID })
+	if len(hostUIDs) > 0 {
+		views.NotificationInboxAdd(db, models.UserNotificationKindChatReport, chain.ID, hostUIDs, gin.H{
+			"ChainName": chain.Name,
+			"Reason":    reason,
+		})
+	}
+	return nil
+}
+
+// Stops the member sending messages in all channels of the loop until the given time
+func ChatMute(db *gorm.DB, ctx context.Context, chain *models.Chain, user *models.User, until time.Time) error {
+	if app.Chat == nil {
+		return ErrChatUnavailable
+	}
+	if auth.HasPermission(db, user, chain, auth.ActionChatManage) {
+		return ErrChatMuteNotAllowed
+	}
+
+	err := models.ChatMutePut(db, chain.ID, user.ID, until)
+	if err != nil {
+		return err
+	}
+	if user.ChatUserID == nil {
+		return nil
+	}
+
+	removedChannelIDs := []string{}
+	errs := []error{}
+	for _, channelID := range chain.ChatRoomIDs {
+		removed, err := app.Chat.MemberMute(ctx, channelID, *user.ChatUserID, true)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if removed {
+			removedChannelIDs = append(removedChannelIDs, channelID)
+		}
+	}
+	errs = append(errs, models.ChatMuteAddChannelIDs(db, chain.ID, user.ID, removedChannelIDs))
+	return errors.Join(errs...)
+}
+
+func ChatUnmute(db *gorm.DB, ctx context.Context, chain *models.Chain, user *models.User) error {
+	if app.Chat == nil {
+		return ErrChatUnavailable
+	}
+
+	mute, err := models.ChatMuteGet(db, chain.ID, user.ID)
+	if err != nil {
+		return err
+	}
+	err = models.ChatMuteDelete(db, chain.ID, user.ID)
+	if err != nil {
+		return err
+	}
+	if user.ChatUserID == nil {
+		return nil
+	}
+
+	// only the channels the member was removed from, channels removed from the loop since are skipped
+	errs := []error{}
+	for _, channelID := range lo.Intersect(chain.ChatRoomIDs, mute.ChannelIDs) {
+		_, err := app.Chat.MemberMute(ctx, channelID, *user.ChatUserID, false)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Ends the mutes of which the time has passed
+func ChatMuteEndedRun(db *gorm.DB, ctx context.Context) {
+	mutes, err := models.ChatMuteGetEnded(db)
+	if err != nil {
+		slog.Error("Unable to find ended chat mutes", "err", err)
+		return
+	}
+
+	for _, mute := range mutes {
+		chain := &models.Chain{}
+		db.Raw(`SELECT * FROM chains WHERE id = ? LIMIT 1`, mute.ChainID).Scan(chain)
+		user := &models.User{}
+		db.Raw(`SELECT * FROM users WHERE id = ? LIMIT 1`, mute.UserID).Scan(user)
+
+		if chain.ID != 0 && user.ID != 0 {
+			err = ChatUnmute(db, ctx, chain, user)
+		} else {
+			err = models.ChatMuteDelete(db, mute.ChainID, mute.UserID)
+		}
+		if err != nil && !errors.Is(err, ErrChatUnavailable) {
+			slog.Error("Unable to end chat mute", "err", err, "chain_id", mute.ChainID, "user_id", mute.UserID)
+		}
+	}
+}
+
+// Removes the messages older than the retention days of each loop that has set them
+func ChatRetentionRun(db *gorm.DB, ctx context.Context) {
+	if app.Chat == nil {
+		return
+	}
+	chains := []models.Chain{}
+	err := db.Raw(`
+SELECT * FROM chains
+WHERE deleted_at IS NULL AND chat_retention_days > 0
+	AND chat_room_ids IS NOT NULL AND chat_room_ids NOT IN ('', '[]', 'null')
+	`).Scan(&chains).Error
+	if err != nil {
+		slog.Error("Unable to find loops with a chat retention policy", "err", err)
+		return
+	}
+
+	for _, chain := range chains {
+		before := time.Now().AddDate(0, 0, -chain.ChatRetentionDays)
+		for _, channelID := range chain.ChatRoomIDs {
+			n, err := app.Chat.ChannelDeleteMessagesBefore(ctx, channelID, before)
+			if err != nil {
+				slog.Error("Unable to remove old chat messages", "err", err, "chain_id", chain.ID, "channel_id", channelID)
+			}
+			if n > 0 {
+				slog.Info("Removed old chat messages", "chain_id", chain.ID, "channel_id", channelID, "count", n)
+			}
+		}
+	}
+}
+
+func ChatRetentionSet(db *gorm.DB, chain *models.Chain, days int) error {
+	chain.ChatRetentionDays = days
+	return db.Exec(`UPDATE chains SET chat_retention_days = ? WHERE id = ?`, days, chain.ID).Error
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// How long a sync started after a membership change may take
+const chatSyncTimeout = 2 * time.Minute
+
+type chatSyncMember struct {
+	UserUID    string
+	ChatUserID string
+	IsAdmin    bool
+}
+
+// Compares the channels of the loop with the approved members, members that left are removed
+// and the channel admin role is given to members that can manage the chat.
+// Members missing from a channel are not added, they join from the app.
+// On a dry run the changes are only returned.
+func ChatSyncChain(db *gorm.DB, ctx context.Context, chain *models.Chain, dryRun bool) ([]sharedtypes.ChatSyncChange, error) {
+	if app.Chat == nil {
+		return nil, ErrChatUnavailable
+	}
+	changes := []sharedtypes.ChatSyncChange{}
+	if len(chain.ChatRoomIDs) == 0 {
+		return changes, nil
+	}
+
+	expected, err := chatSyncExpectedMembers(db, chain.ID)
+	if err != nil {
+		return nil, err
+	}
+	rootAdmins, err := chatSyncRootAdmins(db)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, channelID := range chain.ChatRoomIDs {
+		members, err := app.Chat.ChannelMembers(ctx, channelID)
+		if err != nil {
+			return changes, fmt.Errorf("Unable to retrieve members of channel %s: %w", channelID, err)
+		}
+
+		for _, member := range members {
+			if _, ok := rootAdmins[member.ChatUserID]; ok {
+				continue
+			}
+			change := sharedtypes.ChatSyncChange{
+				ChainUID:   chain.UID,
+				ChannelID:  channelID,
+				ChatUserID: member.ChatUserID,
+			}
+			e, ok := expected[member.ChatUserID]
+			switch {
+			case !ok:
+				change.Action = sharedtypes.ChatSyncActionRemove
+			case e.IsAdmin && !member.IsAdmin:
+				change.Action = sharedtypes.ChatSyncActionSetAdmin
+			case !e.IsAdmin && member.IsAdmin:
+				change.Action = sharedtypes.ChatSyncActionUnsetAdmin
+			default:
+				continue
+			}
+			change.UserUID = e.UserUID
+
+			if !dryRun {
+				if change.Action == sharedtypes.ChatSyncActionRemove {
+					err = app.Chat.ChannelLeave(ctx, channelID, member.ChatUserID)
+				} else {
+					err = app.Chat.ChannelJoin(ctx, channelID, member.ChatUserID, e.IsAdmin)
+				}
+				if err != nil {
+					slog.Error("Unable to sync chat channel member", "err", err, "chain_id", chain.ID, "channel_id", channelID, "action", change.Action)
+					change.Error = err.Error()
+				}
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+// Syncs all loops with chat channels, see ChatSyncChain
+func ChatSyncAll(db *gorm.DB, ctx context.Context, dryRun bool) (*sharedtypes.ChatSyncReport, error) {
+	chains := []models.Chain{}
+	err := db.Raw(`
+SELECT * FROM chains
+WHERE deleted_at IS NULL AND chat_room_ids IS NOT NULL AND chat_room_ids NOT IN ('', '[]', 'null')
+	`).Scan(&chains).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return chatSyncChains(db, ctx, chains, dryRun)
+}
+
+// Runs ChatSyncChain in the background after the membership of the loops has changed
+func ChatSyncChainsLater(db *gorm.DB, chainIDs ...uint) {
+	if app.Chat == nil || len(chainIDs) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), chatSyncTimeout)
+		defer cancel()
+
+		chains := []models.Chain{}
+		err := db.Raw(`SELECT * FROM chains WHERE id IN ? AND deleted_at IS NULL`, chainIDs).Scan(&chains).Error
+		if err != nil {
+			slog.Error("Unable to find loops to sync chat", "err", err)
+			return
+		}
+		chatSyncChains(db, ctx, chains, false)
+	}()
+}
+
+func chatSyncChains(db *gorm.DB, ctx context.Context, chains []models.Chain, dryRun bool) (*sharedtypes.ChatSyncReport, error) {
+	report := &sharedtypes.ChatSyncReport{
+		DryRun:  dryRun,
+		Chains:  len(chains),
+		Changes: []sharedtypes.ChatSyncChange{},
+		Errors:  []string{},
+	}
+	for i := range chains {
+		changes, err := ChatSyncChain(db, ctx, &chains[i], dryRun)
+		report.Changes = append(report.Changes, changes...)
+		if err != nil {
+			if errors.Is(err, ErrChatUnavailable) {
+				return nil, err
+			}
+			slog.Error("Unable to sync chat of loop", "err", err, "chain_id", chains[i].ID)
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", chains[i].UID, err.Error()))
+		}
+	}
+	if len(report.Changes) > 0 {
+		slog.Info("Synced chat channel members", "dry_run", dryRun, "changes", len(report.Changes))
+	}
+	return report, nil
+}
+
+// Approved members registered on the chat server by chat user id
+func chatSyncExpectedMembers(db *gorm.DB, chainID uint) (map[string]chatSyncMember, error) {
+	rows := []struct {
+		UserUID       string
+		ChatUserID    string
+		IsChainAdmin  bool
+		IsChainWarden bool
+		Role          string
+	}{}
+	err := db.Raw(`
+SELECT u.uid AS user_uid, u.chat_user_id, uc.is_chain_admin, uc.is_chain_warden, uc.role
+FROM user_chains AS uc
+JOIN users AS u ON u.id = uc.user_id
+WHERE uc.chain_id = ? AND uc.is_approved = TRUE AND u.chat_user_id IS NOT NULL AND u.chat_user_id != ''
+	`, chainID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[string]chatSyncMember, len(rows))
+	for _, row := range rows {
+		role := auth.RoleOfUserChain(&sharedtypes.UserChain{
+			IsChainAdmin:  row.IsChainAdmin,
+			IsChainWarden: row.IsChainWarden,
+			Role:          row.Role,
+		})
+		members[row.ChatUserID] = chatSyncMember{
+			UserUID:    row.UserUID,
+			ChatUserID: row.ChatUserID,
+			IsAdmin:    auth.RoleHasPermission(db, chainID, role, auth.ActionChatManage),
+		}
+	}
+	return members, nil
+}
+
+// Root admins may be in any channel, their role is left alone
+func chatSyncRootAdmins(db *gorm.DB) (map[string]struct{}, error) {
+	chatUserIDs := []string{}
+	err := db.Raw(`SELECT chat_user_id FROM users WHERE is_root_admin = TRUE AND chat_user_id IS NOT NULL`).Scan(&chatUserIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	rootAdmins := make(map[string]struct{}, len(chatUserIDs))
+	for _, id := range chatUserIDs {
+		rootAdmins[id] = struct{}{}
+	}
+	return rootAdmins, nil
+}
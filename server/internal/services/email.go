@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
 	"github.com/the-clothing-loop/website/server/internal/models"
 	"github.com/the-clothing-loop/website/server/internal/views"
 	"gorm.io/gorm"
@@ -34,7 +36,7 @@ func EmailLoopAdminsOnUserJoin(db *gorm.DB, user *models.User, chainIDs ...uint)
 		if !result.Email.Valid {
 			continue
 		}
-		views.EmailSomeoneIsInterestedInJoiningYourLoop(db, result.I18n,
+		views.EmailSomeoneIsInterestedInJoiningYourLoop(db, result.ChainID, result.I18n,
 			result.Email.String,
 			result.Name,
 			result.ChainName,
@@ -46,6 +48,16 @@ func EmailLoopAdminsOnUserJoin(db *gorm.DB, user *models.User, chainIDs ...uint)
 		)
 	}
 
+	adminsByChain := lo.GroupBy(results, func(r models.UserContactData) uint { return r.ChainID })
+	for chainID, admins := range adminsByChain {
+		views.NotificationSend(db, models.NotificationKindJoinRequest, chainID, lo.Map(admins, func(r models.UserContactData, _ int) string {
+			return r.UID
+		}), gin.H{
+			"Name":      user.Name,
+			"ChainName": admins[0].ChainName,
+		})
+	}
+
 	return nil
 }
 
@@ -67,7 +79,7 @@ func EmailLoopAdminsOnUserLeft(db *gorm.DB, removedUserName, removedUserEmail, e
 		if !email.Valid || excludedEmail == email.String {
 			continue
 		}
-		views.EmailSomeoneLeftLoop(db, admin.I18n,
+		views.EmailSomeoneLeftLoop(db, admin.ChainID, admin.I18n,
 			admin.Name,
 			admin.Email.String,
 			admin.ChainName,
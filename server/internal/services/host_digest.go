@@ -0,0 +1,114 @@
+package services
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"gorm.io/gorm"
+)
+
+// Sends the weekly digest to each host that opted in, one email per loop.
+// Loops without any activity since the last digest are skipped.
+func HostDigestRun(db *gorm.DB, now time.Time) {
+	hosts, err := models.NotificationPreferenceGetChainAdmins(db, models.NotificationKindHostDigest, models.NotificationChannelEmail)
+	if err != nil {
+		slog.Error("Unable to find hosts for the weekly digest", "err", err)
+		return
+	}
+
+	since := now.Add(-7 * 24 * time.Hour)
+	digests := map[uint]*views.EmailHostDigestData{}
+	for _, host := range hosts {
+		if !host.Email.Valid {
+			continue
+		}
+		data, ok := digests[host.ChainID]
+		if !ok {
+			data, err = HostDigestGet(db, host.ChainID, since, now)
+			if err != nil {
+				slog.Error("Unable to collect the weekly digest", "chain_id", host.ChainID, "err", err)
+				continue
+			}
+			data.ChainName = host.ChainName
+			digests[host.ChainID] = data
+		}
+		if data.IsEmpty() {
+			continue
+		}
+
+		err = views.EmailHostDigest(db, host.ChainID, host.I18n, host.Name, host.Email.String, data)
+		if err != nil {
+			slog.Error("Unable to send the weekly digest", "chain_id", host.ChainID, "err", err)
+		}
+	}
+}
+
+// Collects the activity of the loop between since and now
+func HostDigestGet(db *gorm.DB, chainID uint, since, now time.Time) (*views.EmailHostDigestData, error) {
+	data := &views.EmailHostDigestData{}
+
+	err := db.Raw(`
+SELECT u.name AS name, uc.created_at AS created_at
+FROM user_chains AS uc
+JOIN users AS u ON u.id = uc.user_id
+WHERE uc.chain_id = ? AND uc.is_approved = FALSE AND u.is_email_verified = TRUE
+ORDER BY uc.created_at ASC
+	`, chainID).Scan(&data.PendingApprovals).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// approving a participant resets created_at, see ChainApproveUser
+	err = db.Raw(`
+SELECT u.name AS name, uc.created_at AS created_at
+FROM user_chains AS uc
+JOIN users AS u ON u.id = uc.user_id
+WHERE uc.chain_id = ? AND uc.is_approved = TRUE AND uc.is_chain_admin = FALSE AND uc.created_at > ?
+ORDER BY uc.created_at ASC
+	`, chainID, since).Scan(&data.NewMembers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	data.Leaves, err = models.ChainLeaveGetSince(db, chainID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Raw(`
+SELECT b.number AS number, u.name AS holder_name, b.updated_at AS updated_at
+FROM bags AS b
+JOIN user_chains AS uc ON uc.id = b.user_chain_id
+JOIN users AS u ON u.id = uc.user_id
+WHERE uc.chain_id = ? AND b.updated_at < ?
+ORDER BY b.updated_at ASC
+	`, chainID, now.Add(-7*24*time.Hour)).Scan(&data.BagsTooOld).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Raw(`
+SELECT name, date FROM events
+WHERE chain_id = ? AND date BETWEEN ? AND ?
+ORDER BY date ASC
+	`, chainID, now, now.Add(14*24*time.Hour)).Scan(&data.Events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Raw(`
+SELECT bi.title AS title, u.name AS user_name
+FROM bulky_items AS bi
+JOIN user_chains AS uc ON uc.id = bi.user_chain_id
+JOIN users AS u ON u.id = uc.user_id
+WHERE uc.chain_id = ? AND bi.created_at > ?
+ORDER BY bi.created_at ASC
+	`, chainID, since).Scan(&data.BulkyItems).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrImageDecode = errors.New("Unable to decode image")
+	ErrImageNotOwn = errors.New("Only your own images can be deleted")
+)
+
+func ImagePath(size, name string) string {
+	return filepath.Join(app.Config.IMAGES_DIR, "uploads", size, name)
+}
+
+func ImageURL(size, name string) string {
+	return fmt.Sprintf("%s/v2/image/%s/%s", app.Config.SITE_BASE_URL_API, size, name)
+}
+
+// Stores the image in every size and records the user as its owner.
+// Only the pixels are encoded again, so EXIF metadata like the GPS location is not kept.
+func ImageCreate(db *gorm.DB, user *models.User, data []byte) (*models.Image, error) {
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageDecode, err)
+	}
+
+	// the same upload by the same user on the same day results in the same image
+	sum := sha1.Sum(append([]byte(user.UID), data...))
+	name := fmt.Sprintf("%s_%s.jpg", time.Now().Format(time.DateOnly), base64.URLEncoding.EncodeToString(sum[:]))
+	if existing, err := models.ImageGetByName(db, name); err == nil {
+		return existing, nil
+	}
+
+	files, err := imageEncodeAll(img)
+	if err != nil {
+		return nil, err
+	}
+	for size, b := range files {
+		p := ImagePath(size, name)
+		err = os.MkdirAll(filepath.Dir(p), 0755)
+		if err == nil {
+			err = os.WriteFile(p, b, 0644)
+		}
+		if err != nil {
+			imageRemoveFiles(name)
+			return nil, fmt.Errorf("Unable to write image: %v", err)
+		}
+	}
+
+	image := &models.Image{UserID: user.ID, Name: name}
+	err = db.Create(image).Error
+	if err != nil {
+		imageRemoveFiles(name)
+		return nil, err
+	}
+	return image, nil
+}
+
+func imageEncodeAll(img image.Image) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for size, px := range models.ImageSizes {
+		quality := 80
+		if size == models.ImageSizeOriginal {
+			quality = 90
+		}
+
+		buf := new(bytes.Buffer)
+		err := imaging.Encode(buf, imaging.Fit(img, px, px, imaging.Lanczos), imaging.JPEG, imaging.JPEGQuality(quality))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to encode to jpeg: %v", err)
+		}
+		files[size] = buf.Bytes()
+	}
+	return files, nil
+}
+
+// Removes the files and the ownership of the image, only the owner or a root admin may do so
+func ImageDelete(db *gorm.DB, user *models.User, image *models.Image) error {
+	if image.UserID != user.ID && !user.IsRootAdmin {
+		return ErrImageNotOwn
+	}
+
+	err := imageRemoveFiles(image.Name)
+	if err != nil {
+		return err
+	}
+	return db.Exec(`DELETE FROM images WHERE id = ?`, image.ID).Error
+}
+
+func imageRemoveFiles(name string) error {
+	for size := range models.ImageSizes {
+		err := os.Remove(ImagePath(size, name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// An upload is only referenced once the form it is used in is saved
+const ImageGCGracePeriod = 7 * 24 * time.Hour
+
+// All files of one image, the sizes and the single file of uploads made before sizes were stored
+type imageGCFile struct {
+	Paths   []string
+	Bytes   int64
+	ModTime time.Time
+}
+
+// Removes the uploaded images that are not referenced by a bulky item, event or loop
+func ImageGCRun(db *gorm.DB, dryRun bool) (*sharedtypes.ImageGCReport, error) {
+	if app.Config.IMAGES_DIR == "" {
+		return nil, errors.New("No images directory configured")
+	}
+
+	// a dry run only reads, stale references are left out of the names either way
+	if !dryRun {
+		err := models.ImageReferenceDeleteStale(db)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to remove stale image references: %v", err)
+		}
+	}
+	names, err := models.ImageReferenceGetAllNames(db)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to find image references: %v", err)
+	}
+	referenced := map[string]bool{}
+	for _, name := range names {
+		referenced[name] = true
+	}
+
+	files, err := imageGCFindFiles(filepath.Join(app.Config.IMAGES_DIR, "uploads"))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &sharedtypes.ImageGCReport{
+		DryRun:  dryRun,
+		Removed: []string{},
+		Errors:  []string{},
+	}
+	cutoff := time.Now().Add(-ImageGCGracePeriod)
+	sortedNames := lo.Keys(files)
+	sort.Strings(sortedNames)
+	for _, name := range sortedNames {
+		file := files[name]
+		if referenced[name] || file.ModTime.After(cutoff) {
+			report.Kept++
+			continue
+		}
+
+		report.Removed = append(report.Removed, name)
+		report.Bytes += file.Bytes
+		if dryRun {
+			continue
+		}
+		for _, p := range file.Paths {
+			err := os.Remove(p)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				report.Errors = append(report.Errors, err.Error())
+			}
+		}
+		err := db.Exec(`DELETE FROM images WHERE name = ?`, name).Error
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+
+	if len(report.Removed) > 0 {
+		slog.Info("Removed unused images", "dry_run", dryRun, "images", len(report.Removed), "bytes", report.Bytes, "errors", len(report.Errors))
+	}
+	return report, nil
+}
+
+// Finds the uploaded images in the directory of each size and the legacy files directly in the uploads directory
+func imageGCFindFiles(uploadsDir string) (map[string]*imageGCFile, error) {
+	files := map[string]*imageGCFile{}
+	add := func(dir string, entry os.DirEntry) {
+		name := entry.Name()
+		if entry.IsDir() || !models.ImageValidName(name) {
+			return
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return
+		}
+		file, ok := files[name]
+		if !ok {
+			file = &imageGCFile{}
+			files[name] = file
+		}
+		file.Paths = append(file.Paths, filepath.Join(dir, name))
+		file.Bytes += info.Size()
+		if info.ModTime().After(file.ModTime) {
+			file.ModTime = info.ModTime()
+		}
+	}
+
+	entries, err := os.ReadDir(uploadsDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return files, nil
+		}
+		return nil, fmt.Errorf("Unable to read images directory: %v", err)
+	}
+	for _, entry := range entries {
+		if _, ok := models.ImageSizes[entry.Name()]; ok && entry.IsDir() {
+			dir := filepath.Join(uploadsDir, entry.Name())
+			sizeEntries, err := os.ReadDir(dir)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to read images directory: %v", err)
+			}
+			for _, sizeEntry := range sizeEntries {
+				add(dir, sizeEntry)
+			}
+			continue
+		}
+		add(uploadsDir, entry)
+	}
+	return files, nil
+}
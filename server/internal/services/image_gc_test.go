@@ -0,0 +1,41 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageGCFindFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(p string, size int) {
+		p = filepath.Join(dir, p)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, make([]byte, size), 0644)
+	}
+	write("thumb/2026-10-19_aaa=.jpg", 10)
+	write("medium/2026-10-19_aaa=.jpg", 20)
+	write("original/2026-10-19_aaa=.jpg", 30)
+	write("2024-01-01_legacy=.jpg", 5)
+	write("kirsten_en_rosan.jpg", 5)
+	write("other/2026-10-19_bbb=.jpg", 5)
+
+	files, err := imageGCFindFiles(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, files, 2, "only uploaded images in the uploads and size directories")
+	if assert.Contains(t, files, "2026-10-19_aaa=.jpg") {
+		assert.Len(t, files["2026-10-19_aaa=.jpg"].Paths, 3)
+		assert.Equal(t, int64(60), files["2026-10-19_aaa=.jpg"].Bytes)
+	}
+	if assert.Contains(t, files, "2024-01-01_legacy=.jpg") {
+		assert.Equal(t, []string{filepath.Join(dir, "2024-01-01_legacy=.jpg")}, files["2024-01-01_legacy=.jpg"].Paths)
+	}
+
+	files, err = imageGCFindFiles(filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
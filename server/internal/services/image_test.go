@@ -0,0 +1,62 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+)
+
+// A jpeg with an APP1 EXIF segment right after the start of image marker
+func imageTestJpegWithExif(t *testing.T, width, height int) []byte {
+	buf := new(bytes.Buffer)
+	err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b := buf.Bytes()
+
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00GPS 52.3676N 4.9041E")
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(exif)+2))
+	segment = append(segment, exif...)
+
+	res := append([]byte{}, b[:2]...)
+	res = append(res, segment...)
+	return append(res, b[2:]...)
+}
+
+func TestImageEncodeAllStripsExif(t *testing.T) {
+	data := imageTestJpegWithExif(t, 1600, 1000)
+	assert.Contains(t, string(data), "GPS 52.3676N")
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if !assert.NoError(t, err) {
+		return
+	}
+	files, err := imageEncodeAll(img)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expectedWidths := map[string]int{
+		models.ImageSizeThumb:    400,
+		models.ImageSizeMedium:   800,
+		models.ImageSizeOriginal: 1600,
+	}
+	assert.Len(t, files, len(expectedWidths))
+	for size, b := range files {
+		assert.NotContains(t, string(b), "Exif", size)
+		assert.NotContains(t, string(b), "GPS", size)
+
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(b))
+		if assert.NoError(t, err, size) {
+			assert.Equal(t, expectedWidths[size], cfg.Width, size)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"time"
+
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// Delivery status of each Brevo transactional webhook event, other events are ignored
+var MailDeliveryStatusByBrevoEvent = map[string]string{
+	"delivered":     models.MailDeliveryStatusDelivered,
+	"soft_bounce":   models.MailDeliveryStatusSoftBounce,
+	"deferred":      models.MailDeliveryStatusSoftBounce,
+	"hard_bounce":   models.MailDeliveryStatusHardBounce,
+	"invalid_email": models.MailDeliveryStatusHardBounce,
+	"blocked":       models.MailDeliveryStatusBlocked,
+	"spam":          models.MailDeliveryStatusComplaint,
+}
+
+// Stores the delivery status reported by the mail provider.
+//
+// Hard bounced addresses are suppressed and the user is flagged for their hosts,
+// a delivered email to the same address lifts both.
+// A spam complaint unsubscribes the address from the newsletter.
+func MailDeliveryEvent(db *gorm.DB, providerMessageID, email, deliveryStatus string, at time.Time) error {
+	_, err := models.MailSetDeliveryStatus(db, providerMessageID, deliveryStatus, at)
+	if err != nil {
+		return err
+	}
+
+	switch deliveryStatus {
+	case models.MailDeliveryStatusHardBounce:
+		err = models.MailSuppressionAdd(db, email, models.MailDeliveryStatusHardBounce)
+		if err != nil {
+			return err
+		}
+		return models.UserSetEmailBounced(db, email, true)
+	case models.MailDeliveryStatusDelivered:
+		err = models.MailSuppressionRemove(db, email)
+		if err != nil {
+			return err
+		}
+		return models.UserSetEmailBounced(db, email, false)
+	case models.MailDeliveryStatusComplaint:
+		return db.Exec(`DELETE FROM newsletters WHERE email = ?`, email).Error
+	}
+	return nil
+}
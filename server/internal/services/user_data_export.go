@@ -0,0 +1,520 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"gorm.io/gorm"
+)
+
+// Everything we hold about a user, written to data.json and split into csv files
+type UserDataExportData struct {
+	Profile              UserDataExportProfile               `json:"profile"`
+	Loops                []UserDataExportLoop                `json:"loops"`
+	Bags                 []UserDataExportBag                 `json:"bags"`
+	BagHistory           []UserDataExportBagHistory          `json:"bag_history"`
+	BulkyItems           []UserDataExportBulkyItem           `json:"bulky_items"`
+	Events               []UserDataExportEvent               `json:"events"`
+	Newsletter           *UserDataExportNewsletter           `json:"newsletter"`
+	Payments             []UserDataExportPayment             `json:"payments"`
+	PushRegistrations    []UserDataExportPushRegistration    `json:"push_registrations"`
+	WebPushSubscriptions []UserDataExportWebPushSubscription `json:"web_push_subscriptions"`
+	Notifications        []UserDataExportNotification        `json:"notifications"`
+}
+
+type UserDataExportProfile struct {
+	UID             string     `json:"uid"`
+	Email           string     `json:"email"`
+	IsEmailVerified bool       `json:"is_email_verified"`
+	Name            string     `json:"name"`
+	PhoneNumber     string     `json:"phone_number"`
+	Address         string     `json:"address"`
+	Latitude        float64    `json:"latitude"`
+	Longitude       float64    `json:"longitude"`
+	Sizes           []string   `json:"sizes"`
+	I18n            string     `json:"i18n"`
+	PausedUntil     *time.Time `json:"paused_until"`
+	AcceptedTOH     bool       `json:"accepted_toh"`
+	AcceptedDPA     bool       `json:"accepted_dpa"`
+	ChatUserName    string     `json:"chat_user_name"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastSignedInAt  *time.Time `json:"last_signed_in_at"`
+}
+
+type UserDataExportLoop struct {
+	ChainUID      string    `json:"chain_uid"`
+	ChainName     string    `json:"chain_name"`
+	IsChainAdmin  bool      `json:"is_chain_admin"`
+	IsChainWarden bool      `json:"is_chain_warden"`
+	Role          string    `json:"role"`
+	IsApproved    bool      `json:"is_approved"`
+	IsPaused      bool      `json:"is_paused"`
+	Note          string    `json:"note"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type UserDataExportBag struct {
+	Number    string    `json:"number"`
+	Color     string    `json:"color"`
+	ChainUID  string    `json:"chain_uid"`
+	ChainName string    `json:"chain_name"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// A moment the user held a bag, only the last few holders of a bag are stored
+type UserDataExportBagHistory struct {
+	Number    string `json:"number"`
+	ChainUID  string `json:"chain_uid"`
+	ChainName string `json:"chain_name"`
+	Date      string `json:"date"`
+}
+
+type UserDataExportBulkyItem struct {
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	ImageUrl  string    `json:"image_url"`
+	ChainUID  string    `json:"chain_uid"`
+	ChainName string    `json:"chain_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserDataExportEvent struct {
+	UID         string     `json:"uid"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Address     string     `json:"address"`
+	Link        string     `json:"link"`
+	Date        time.Time  `json:"date"`
+	DateEnd     *time.Time `json:"date_end"`
+	ImageUrl    string     `json:"image_url"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type UserDataExportNewsletter struct {
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Verified  bool      `json:"verified"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserDataExportPayment struct {
+	Amount      float32   `json:"amount"`
+	IsRecurring bool      `json:"is_recurring"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type UserDataExportPushRegistration struct {
+	PlayerID    string `json:"player_id"`
+	OnesignalID string `json:"onesignal_id"`
+}
+
+type UserDataExportNotification struct {
+	Kind      string     `json:"kind"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type UserDataExportWebPushSubscription struct {
+	Endpoint  string    `json:"endpoint"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func userDataExportDir() string {
+	if app.Config.EXPORTS_DIR != "" {
+		return app.Config.EXPORTS_DIR
+	}
+	return filepath.Join(os.TempDir(), "clothingloop-exports")
+}
+
+// Creates the zip file of the export and emails the download link to the user,
+// this is intended to run in a goroutine.
+func UserDataExportRun(db *gorm.DB, user *models.User, export *models.UserDataExport) {
+	filePath, err := UserDataExportCreateFile(db, user, export)
+	if err != nil {
+		slog.Error("Unable to create user data export", "user_id", user.ID, "err", err)
+		export.SetFailed(db)
+		return
+	}
+
+	err = export.SetReady(db, filePath)
+	if err != nil {
+		slog.Error("Unable to update user data export", "user_id", user.ID, "err", err)
+		os.Remove(filePath)
+		return
+	}
+
+	if user.Email == nil {
+		return
+	}
+	link := fmt.Sprintf("%s/v2/user/export/download?token=%s", app.Config.SITE_BASE_URL_API, export.Token)
+	err = views.EmailUserDataExportReady(db, user.I18n, user.Name, *user.Email, link)
+	if err != nil {
+		slog.Error("Unable to send user data export email", "user_id", user.ID, "err", err)
+	}
+}
+
+func UserDataExportCreateFile(db *gorm.DB, user *models.User, export *models.UserDataExport) (string, error) {
+	data, err := UserDataExportGetData(db, user)
+	if err != nil {
+		return "", err
+	}
+
+	dir := userDataExportDir()
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+	filePath := filepath.Join(dir, fmt.Sprintf("%s-%d.zip", user.UID, export.ID))
+
+	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	err = userDataExportWriteZip(f, data)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return "", err
+	}
+	return filePath, nil
+}
+
+func UserDataExportGetData(db *gorm.DB, user *models.User) (*UserDataExportData, error) {
+	email := ""
+	if user.Email != nil {
+		email = *user.Email
+	}
+	data := &UserDataExportData{
+		Profile: UserDataExportProfile{
+			UID:             user.UID,
+			Email:           email,
+			IsEmailVerified: user.IsEmailVerified,
+			Name:            user.Name,
+			PhoneNumber:     user.PhoneNumber,
+			Address:         user.Address,
+			Latitude:        user.Latitude,
+			Longitude:       user.Longitude,
+			Sizes:           user.Sizes,
+			I18n:            user.I18n,
+			PausedUntil:     user.PausedUntil,
+			AcceptedTOH:     user.AcceptedTOH,
+			AcceptedDPA:     user.AcceptedDPA,
+			CreatedAt:       user.CreatedAt,
+			LastSignedInAt:  user.LastSignedInAt,
+		},
+		Loops:                []UserDataExportLoop{},
+		Bags:                 []UserDataExportBag{},
+		BagHistory:           []UserDataExportBagHistory{},
+		BulkyItems:           []UserDataExportBulkyItem{},
+		Events:               []UserDataExportEvent{},
+		Payments:             []UserDataExportPayment{},
+		PushRegistrations:    []UserDataExportPushRegistration{},
+		WebPushSubscriptions: []UserDataExportWebPushSubscription{},
+		Notifications:        []UserDataExportNotification{},
+	}
+	if user.ChatUserName != nil {
+		data.Profile.ChatUserName = *user.ChatUserName
+	}
+
+	err := db.Raw(`
+SELECT c.uid AS chain_uid, c.name AS chain_name, uc.is_chain_admin, uc.is_chain_warden, uc.role,
+	uc.is_approved, uc.is_paused, IFNULL(uc.note, '') AS note, uc.created_at
+FROM user_chains AS uc
+LEFT JOIN chains AS c ON c.id = uc.chain_id
+WHERE uc.user_id = ?
+	`, user.ID).Scan(&data.Loops).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Raw(`
+SELECT b.number, b.color, c.uid AS chain_uid, c.name AS chain_name, b.updated_at
+FROM bags AS b
+LEFT JOIN user_chains AS uc ON uc.id = b.user_chain_id
+LEFT JOIN chains AS c ON c.id = uc.chain_id
+WHERE uc.user_id = ?
+	`, user.ID).Scan(&data.Bags).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if email != "" {
+		bags := []struct {
+			Number                string
+			ChainUID              string
+			ChainName             string
+			LastUserEmailToUpdate string
+			LastUserDateToUpdate  string
+		}{}
+		err = db.Raw(`
+SELECT b.number, c.uid AS chain_uid, c.name AS chain_name, b.last_user_email_to_update, b.last_user_date_to_update
+FROM bags AS b
+LEFT JOIN user_chains AS uc ON uc.id = b.user_chain_id
+LEFT JOIN chains AS c ON c.id = uc.chain_id
+WHERE FIND_IN_SET(?, b.last_user_email_to_update) > 0
+		`, email).Scan(&bags).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bags {
+			for _, date := range userDataExportBagHistoryDates(email, b.LastUserEmailToUpdate, b.LastUserDateToUpdate) {
+				data.BagHistory = append(data.BagHistory, UserDataExportBagHistory{
+					Number:    b.Number,
+					ChainUID:  b.ChainUID,
+					ChainName: b.ChainName,
+					Date:      date,
+				})
+			}
+		}
+	}
+
+	err = db.Raw(`
+SELECT bi.title, bi.message, bi.image_url, c.uid AS chain_uid, c.name AS chain_name, bi.created_at
+FROM bulky_items AS bi
+LEFT JOIN user_chains AS uc ON uc.id = bi.user_chain_id
+LEFT JOIN chains AS c ON c.id = uc.chain_id
+WHERE uc.user_id = ?
+	`, user.ID).Scan(&data.BulkyItems).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Raw(`
+SELECT uid, name, description, address, link, date, date_end, image_url, created_at
+FROM events
+WHERE user_id = ?
+	`, user.ID).Scan(&data.Events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if email != "" {
+		newsletter := &UserDataExportNewsletter{}
+		db.Raw(`SELECT email, name, verified, created_at FROM newsletters WHERE email = ? LIMIT 1`, email).Scan(newsletter)
+		if newsletter.Email != "" {
+			data.Newsletter = newsletter
+		}
+
+		err = db.Raw(`
+SELECT amount, is_recurring, status, created_at
+FROM payments
+WHERE email = ?
+		`, email).Scan(&data.Payments).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = db.Raw(`
+SELECT player_id, onesignal_id
+FROM user_onesignals
+WHERE user_id = ?
+	`, user.ID).Scan(&data.PushRegistrations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Raw(`
+SELECT endpoint, created_at
+FROM user_web_push_subscriptions
+WHERE user_id = ?
+	`, user.ID).Scan(&data.WebPushSubscriptions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Raw(`
+SELECT kind, title, content, read_at, created_at
+FROM user_notifications
+WHERE user_id = ?
+ORDER BY created_at ASC
+	`, user.ID).Scan(&data.Notifications).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// The emails and dates of the last holders of a bag are stored as comma separated lists of the same length
+func userDataExportBagHistoryDates(email, emails, dates string) []string {
+	emailList := strings.Split(emails, ",")
+	dateList := strings.Split(dates, ",")
+	result := []string{}
+	for i, e := range emailList {
+		if e != email {
+			continue
+		}
+		date := ""
+		if i < len(dateList) {
+			date = dateList[i]
+		}
+		result = append(result, date)
+	}
+	return result
+}
+
+func userDataExportWriteZip(f *os.File, data *UserDataExportData) error {
+	w := zip.NewWriter(f)
+
+	jw, err := w.Create("data.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(jw)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(data)
+	if err != nil {
+		return err
+	}
+
+	p := data.Profile
+	files := []userDataExportFile{
+		{"profile.csv", [][]string{
+			{"uid", "email", "is_email_verified", "name", "phone_number", "address", "latitude", "longitude", "sizes", "i18n", "paused_until", "accepted_toh", "accepted_dpa", "chat_user_name", "created_at", "last_signed_in_at"},
+			{p.UID, p.Email, csvBool(p.IsEmailVerified), p.Name, p.PhoneNumber, p.Address, csvFloat(p.Latitude), csvFloat(p.Longitude), strings.Join(p.Sizes, ";"), p.I18n, csvTimePtr(p.PausedUntil), csvBool(p.AcceptedTOH), csvBool(p.AcceptedDPA), p.ChatUserName, csvTime(p.CreatedAt), csvTimePtr(p.LastSignedInAt)},
+		}},
+		{"loops.csv", userDataExportRows(
+			[]string{"chain_uid", "chain_name", "is_chain_admin", "is_chain_warden", "role", "is_approved", "is_paused", "note", "created_at"},
+			data.Loops, func(l UserDataExportLoop) []string {
+				return []string{l.ChainUID, l.ChainName, csvBool(l.IsChainAdmin), csvBool(l.IsChainWarden), l.Role, csvBool(l.IsApproved), csvBool(l.IsPaused), l.Note, csvTime(l.CreatedAt)}
+			})},
+		{"bags.csv", userDataExportRows(
+			[]string{"number", "color", "chain_uid", "chain_name", "updated_at"},
+			data.Bags, func(b UserDataExportBag) []string {
+				return []string{b.Number, b.Color, b.ChainUID, b.ChainName, csvTime(b.UpdatedAt)}
+			})},
+		{"bag_history.csv", userDataExportRows(
+			[]string{"number", "chain_uid", "chain_name", "date"},
+			data.BagHistory, func(b UserDataExportBagHistory) []string {
+				return []string{b.Number, b.ChainUID, b.ChainName, b.Date}
+			})},
+		{"bulky_items.csv", userDataExportRows(
+			[]string{"title", "message", "image_url", "chain_uid", "chain_name", "created_at"},
+			data.BulkyItems, func(b UserDataExportBulkyItem) []string {
+				return []string{b.Title, b.Message, b.ImageUrl, b.ChainUID, b.ChainName, csvTime(b.CreatedAt)}
+			})},
+		{"events.csv", userDataExportRows(
+			[]string{"uid", "name", "description", "address", "link", "date", "date_end", "image_url", "created_at"},
+			data.Events, func(e UserDataExportEvent) []string {
+				return []string{e.UID, e.Name, e.Description, e.Address, e.Link, csvTime(e.Date), csvTimePtr(e.DateEnd), e.ImageUrl, csvTime(e.CreatedAt)}
+			})},
+		{"payments.csv", userDataExportRows(
+			[]string{"amount", "is_recurring", "status", "created_at"},
+			data.Payments, func(p UserDataExportPayment) []string {
+				return []string{strconv.FormatFloat(float64(p.Amount), 'f', -1, 32), csvBool(p.IsRecurring), p.Status, csvTime(p.CreatedAt)}
+			})},
+		{"push_registrations.csv", userDataExportRows(
+			[]string{"player_id", "onesignal_id"},
+			data.PushRegistrations, func(r UserDataExportPushRegistration) []string {
+				return []string{r.PlayerID, r.OnesignalID}
+			})},
+		{"web_push_subscriptions.csv", userDataExportRows(
+			[]string{"endpoint", "created_at"},
+			data.WebPushSubscriptions, func(s UserDataExportWebPushSubscription) []string {
+				return []string{s.Endpoint, csvTime(s.CreatedAt)}
+			})},
+		{"notifications.csv", userDataExportRows(
+			[]string{"kind", "title", "content", "read_at", "created_at"},
+			data.Notifications, func(n UserDataExportNotification) []string {
+				return []string{n.Kind, n.Title, n.Content, csvTimePtr(n.ReadAt), csvTime(n.CreatedAt)}
+			})},
+	}
+	newsletterRows := [][]string{{"email", "name", "verified", "created_at"}}
+	if n := data.Newsletter; n != nil {
+		newsletterRows = append(newsletterRows, []string{n.Email, n.Name, csvBool(n.Verified), csvTime(n.CreatedAt)})
+	}
+	files = append(files, userDataExportFile{"newsletter.csv", newsletterRows})
+
+	for _, file := range files {
+		fw, err := w.Create(file.name)
+		if err != nil {
+			return err
+		}
+		err = csv.NewWriter(fw).WriteAll(file.rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+type userDataExportFile struct {
+	name string
+	rows [][]string
+}
+
+func userDataExportRows[T any](header []string, list []T, row func(T) []string) [][]string {
+	rows := [][]string{header}
+	for _, item := range list {
+		rows = append(rows, row(item))
+	}
+	return rows
+}
+
+func csvBool(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+func csvFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func csvTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+func csvTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return csvTime(*t)
+}
+
+// Removes the files of exports, the database rows must already be removed
+func UserDataExportRemoveFiles(exports []models.UserDataExport) {
+	for _, export := range exports {
+		if export.FilePath == "" {
+			continue
+		}
+		err := os.Remove(export.FilePath)
+		if err != nil && !os.IsNotExist(err) {
+			slog.Warn("Unable to remove user data export", "path", export.FilePath, "err", err)
+		}
+	}
+}
+
+func UserDataExportDeleteOld(db *gorm.DB) {
+	exports, err := models.UserDataExportGetOld(db)
+	if err != nil {
+		slog.Error("Unable to find old user data exports", "err", err)
+		return
+	}
+	if len(exports) == 0 {
+		return
+	}
+	UserDataExportRemoveFiles(exports)
+
+	ids := []uint{}
+	for _, export := range exports {
+		ids = append(ids, export.ID)
+	}
+	db.Exec(`DELETE FROM user_data_exports WHERE id IN ?`, ids)
+}
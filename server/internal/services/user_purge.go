@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserPurgeHasBags   = errors.New("Please give your bags to someone else, or delete them from the app")
+	ErrUserPurgeLastHost  = errors.New("Set someone else as host or delete the loop first before deleting your account")
+	ErrUserPurgeScheduled = errors.New("Your account is already scheduled to be deleted")
+)
+
+// Checks if the user can be deleted and returns the loops where the user is the last host,
+// these loops are deleted together with the user.
+//
+// The user must have their chains added to the object.
+func UserPurgeCheck(db *gorm.DB, user *models.User, checkBags bool) (chainIDsToDelete []uint, err error) {
+	if checkBags {
+		amountOfBags, err := user.CountAttachedBags(db)
+		if err != nil {
+			slog.Error("Error getting bag count", "err", err)
+		}
+		if amountOfBags != 0 {
+			return nil, ErrUserPurgeHasBags
+		}
+	}
+
+	// find chains where user is the last chain admin
+	chainIDsToDelete = []uint{}
+	db.Raw(`
+SELECT uc.chain_id
+FROM  user_chains AS uc
+WHERE uc.chain_id IN (
+	SELECT uc2.chain_id
+	FROM user_chains AS uc2
+	WHERE uc2.is_chain_admin = TRUE AND uc2.user_id = ?
+) AND uc.is_chain_admin = TRUE
+GROUP BY uc.chain_id
+HAVING COUNT(uc.id) = 1
+	`, user.ID).Scan(&chainIDsToDelete)
+	participantsToBeOrphaned := int64(0)
+	db.Raw("SELECT COUNT(id) FROM user_chains WHERE chain_id IN ? AND is_chain_admin = FALSE AND is_approved = TRUE AND user_id != ?", chainIDsToDelete, user.ID).Count(&participantsToBeOrphaned)
+	if participantsToBeOrphaned > 0 {
+		return nil, ErrUserPurgeLastHost
+	}
+
+	return chainIDsToDelete, nil
+}
+
+// Schedules the deletion of the account after the grace period and emails a link to cancel it
+func UserPurgeSchedule(db *gorm.DB, user *models.User, reasonsForLeaving []string, otherExplanation string, token string) (*models.UserPurgeSchedule, error) {
+	if user.PurgeAt != nil {
+		return nil, ErrUserPurgeScheduled
+	}
+
+	schedule := &models.UserPurgeSchedule{
+		UserID:            user.ID,
+		Token:             token,
+		ReasonsForLeaving: reasonsForLeaving,
+		OtherExplanation:  otherExplanation,
+	}
+	err := schedule.Create(db)
+	if err != nil {
+		return nil, err
+	}
+	user.PurgeAt = &schedule.PurgeAt
+
+	if user.Email != nil {
+		cancelLink := fmt.Sprintf("%s/users/purge-cancel?token=%s", app.Config.SITE_BASE_URL_FE, schedule.Token)
+		err = views.EmailAccountDeletionScheduled(db, user.I18n, user.Name, *user.Email, schedule.PurgeAt, cancelLink)
+		if err != nil {
+			slog.Error("Unable to send account deletion scheduled email", "err", err)
+		}
+	}
+
+	return schedule, nil
+}
+
+// Deletes all accounts of which the grace period has ended.
+// When the user became the last host of a loop with participants in the meantime
+// the deletion is cancelled and the user is emailed, instead of retrying every run.
+func UserPurgeScheduledRun(db *gorm.DB) {
+	schedules, err := models.UserPurgeScheduleGetDue(db)
+	if err != nil {
+		slog.Error("Unable to find scheduled account deletions", "err", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		user := &models.User{}
+		db.Raw(`SELECT * FROM users WHERE id = ? LIMIT 1`, schedule.UserID).Scan(user)
+		if user.ID == 0 {
+			db.Exec(`DELETE FROM user_purge_schedules WHERE id = ?`, schedule.ID)
+			continue
+		}
+		err = user.AddUserChainsToObject(db)
+		if err != nil {
+			slog.Error("Unable to get loops of user to delete", "user_id", user.ID, "err", err)
+			continue
+		}
+
+		// bags that are still attached are passed on to a host
+		chainIDsToDelete, err := UserPurgeCheck(db, user, false)
+		if errors.Is(err, ErrUserPurgeLastHost) {
+			slog.Info("Cancel scheduled account deletion of last host", "user_id", user.ID)
+			err = schedule.Cancel(db)
+			if err != nil {
+				slog.Error("Unable to cancel scheduled account deletion", "user_id", user.ID, "err", err)
+			} else if user.Email != nil {
+				views.EmailAccountDeletionCancelled(db, user.I18n, user.Name, *user.Email)
+			}
+			continue
+		}
+		if err != nil {
+			slog.Error("Unable to delete scheduled account", "user_id", user.ID, "err", err)
+			continue
+		}
+
+		deletedUser := &models.DeletedUser{
+			Email:            lo.FromPtr(user.Email),
+			UserCreatedAt:    user.CreatedAt,
+			UserDeletedAt:    time.Now(),
+			OtherExplanation: schedule.OtherExplanation,
+		}
+		err = deletedUser.SetReasons(schedule.ReasonsForLeaving)
+		if err != nil {
+			slog.Error("Invalid reasons for leaving", "user_id", user.ID, "err", err)
+		}
+
+		err = UserPurge(db, user, deletedUser, chainIDsToDelete)
+		if err != nil {
+			slog.Error("Unable to delete scheduled account", "user_id", user.ID, "err", err)
+		}
+	}
+}
+
+// Deletes the user, their connections and the loops where they are the last host.
+//
+// The user must have their chains added to the object.
+func UserPurge(db *gorm.DB, user *models.User, deletedUser *models.DeletedUser, chainIDsToDelete []uint) error {
+	tx := db.Begin()
+
+	if err := tx.Create(deletedUser).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Failed to add deleted user to database")
+	}
+
+	err := user.DeleteUserChainDependenciesAllChains(tx)
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge", "err", err)
+		return fmt.Errorf("Unable to disconnect bag connections")
+	}
+
+	err = tx.Exec(`
+UPDATE events SET user_id = (
+	SELECT id FROM users WHERE is_root_admin = 1 LIMIT 1
+) WHERE user_id = ?
+	`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to remove event connections", "err", err)
+		return fmt.Errorf("Unable to remove event connections")
+	}
+	err = tx.Exec(`DELETE FROM user_chains WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to remove loop connections", "err", err)
+		return fmt.Errorf("Unable to remove loop connections")
+	}
+	err = tx.Exec(`DELETE FROM user_tokens WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to remove token connections", "err", err)
+		return fmt.Errorf("Unable to remove token connections")
+	}
+	err = tx.Exec(`DELETE FROM user_onesignals WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to remove onesignal connections", "err", err)
+		return fmt.Errorf("Unable to remove onesignal connections")
+	}
+	err = tx.Exec(`DELETE FROM user_web_push_subscriptions WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to remove web push subscriptions", "err", err)
+		return fmt.Errorf("Unable to remove web push subscriptions")
+	}
+	err = tx.Exec(`DELETE FROM user_notifications WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to remove notifications", "err", err)
+		return fmt.Errorf("Unable to remove notifications")
+	}
+	err = tx.Exec(`DELETE FROM chat_channel_members WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to remove chat channel memberships", "err", err)
+		return fmt.Errorf("Unable to remove chat channel memberships")
+	}
+	err = tx.Exec(`DELETE FROM chat_messages WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to remove chat messages", "err", err)
+		return fmt.Errorf("Unable to remove chat messages")
+	}
+	err = tx.Exec(`DELETE FROM chat_mutes WHERE user_id = ?`, user.ID).Error
+	if err == nil {
+		err = tx.Exec(`DELETE FROM chat_message_reports WHERE reporter_user_id = ?`, user.ID).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to remove chat moderation", "err", err)
+		return fmt.Errorf("Unable to remove chat moderation")
+	}
+	if user.ChatUserID != nil {
+		err = models.ChatCleanupAdd(tx, models.ChatCleanupKindUser, *user.ChatUserID)
+		if err != nil {
+			tx.Rollback()
+			slog.Error("UserPurge: Unable to schedule chat user removal", "err", err)
+			return fmt.Errorf("Unable to schedule chat user removal")
+		}
+	}
+	dataExports, err := models.UserDataExportDeleteByUserID(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to remove data exports", "err", err)
+		return fmt.Errorf("Unable to remove data exports")
+	}
+	err = tx.Exec(`DELETE FROM user_purge_schedules WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to remove scheduled deletion", "err", err)
+		return fmt.Errorf("Unable to remove scheduled deletion")
+	}
+	err = tx.Exec(`DELETE FROM users WHERE id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		slog.Error("UserPurge: Unable to user", "err", err)
+		return fmt.Errorf("Unable to user")
+	}
+
+	slog.Info("Purging chains", "chainIDsToDelete", chainIDsToDelete)
+	if len(chainIDsToDelete) > 0 {
+		err := tx.Exec(`DELETE FROM bags WHERE user_chain_id IN (
+			SELECT id FROM user_chains WHERE chain_id IN ?
+		)`, chainIDsToDelete).Error
+		if err != nil {
+			tx.Rollback()
+			slog.Error("UserPurge", "err", err)
+			return fmt.Errorf("Unable to disconnect all loop bag connections")
+		}
+		err = tx.Exec(`DELETE FROM bulky_item_subscriptions WHERE chain_id IN ?`, chainIDsToDelete).Error
+		if err != nil {
+			tx.Rollback()
+			slog.Error("UserPurge: Unable to remove hosted loop bulky item subscriptions", "err", err)
+			return fmt.Errorf("Unable to remove hosted loop bulky item subscriptions")
+		}
+		err = tx.Exec(`DELETE FROM wishes WHERE chain_id IN ?`, chainIDsToDelete).Error
+		if err != nil {
+			tx.Rollback()
+			slog.Error("UserPurge: Unable to remove hosted loop wishes", "err", err)
+			return fmt.Errorf("Unable to remove hosted loop wishes")
+		}
+		err = tx.Exec(`DELETE FROM user_chains WHERE chain_id IN ?`, chainIDsToDelete).Error
+		if err != nil {
+			tx.Rollback()
+			slog.Error("UserPurge: Unable to remove hosted loop connections", "err", err)
+			return fmt.Errorf("Unable to remove hosted loop connections")
+		}
+		err = models.ChatCleanupAddChannelsOfChains(tx, chainIDsToDelete...)
+		if err != nil {
+			tx.Rollback()
+			slog.Error("UserPurge: Unable to schedule hosted loop chat removal", "err", err)
+			return fmt.Errorf("Unable to schedule hosted loop chat removal")
+		}
+		err = tx.Exec(`DELETE FROM chains WHERE id IN ?`, chainIDsToDelete).Error
+		if err != nil {
+			tx.Rollback()
+			slog.Error("UserPurge: Unable to remove hosted loop", "err", err)
+			return fmt.Errorf("Unable to remove hosted loop")
+		}
+	}
+
+	if user.Email != nil {
+		err = tx.Exec(`DELETE FROM newsletters WHERE email = ?`, user.Email).Error
+		if err != nil {
+			tx.Rollback()
+			slog.Error("UserPurge: Unable to remove newsletter", "err", err)
+			return fmt.Errorf("Unable to remove newsletter")
+		}
+
+		views.EmailAccountDeletedSuccessfully(db, user.I18n, user.Name, *user.Email)
+
+		if app.Brevo != nil {
+			app.Brevo.DeleteContact(context.Background(), *user.Email)
+		}
+	}
+
+	tx.Commit()
+	UserDataExportRemoveFiles(dataExports)
+
+	// notify connected hosts, send email to chain admins
+	chainIDs := []uint{}
+	for _, uc := range user.Chains {
+		chainIDs = append(chainIDs, uc.ChainID)
+	}
+
+	ChatSyncChainsLater(db, lo.Without(chainIDs, chainIDsToDelete...)...)
+	ChatCleanupRunLater(db)
+
+	err = models.ChainLeaveAdd(db, user.Name, deletedUser.GetReasons(), lo.Without(chainIDs, chainIDsToDelete...)...)
+	if err != nil {
+		slog.Error("Unable to record participant leaving loops", "err", err)
+	}
+
+	if user.Email != nil {
+		EmailLoopAdminsOnUserLeft(db,
+			user.Name,
+			*user.Email,
+			*user.Email,
+			chainIDs...)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"gorm.io/gorm"
+)
+
+// Notifies the members with an open wish that matches the new bulky item, except the author
+func WishMatchBulkyItem(db *gorm.DB, chain *models.Chain, authorUID string, bulkyItem *models.BulkyItem) {
+	text := strings.Join(append([]string{bulkyItem.Title, bulkyItem.Message}, bulkyItem.Tags...), " ")
+	wishMatchNotify(db, chain, authorUID, bulkyItem.Title, func(w *models.Wish) bool {
+		return w.Matches(text, bulkyItem.Category, bulkyItem.Sizes, bulkyItem.Genders)
+	})
+}
+
+// Notifies the members with an open wish that matches the note of the bag, except the holder
+func WishMatchBag(db *gorm.DB, chain *models.Chain, holderUID string, bag *models.Bag) {
+	if bag.Note == "" {
+		return
+	}
+	wishMatchNotify(db, chain, holderUID, bag.Number, func(w *models.Wish) bool {
+		return w.Matches(bag.Note, "", nil, nil)
+	})
+}
+
+func wishMatchNotify(db *gorm.DB, chain *models.Chain, exceptUserUID string, title string, match func(w *models.Wish) bool) {
+	wishes, err := models.WishGetOpenByChain(db, chain.ID, exceptUserUID)
+	if err != nil {
+		slog.Error("Unable to find wishes to match", "err", err)
+		return
+	}
+
+	userUIDs := []string{}
+	for i := range wishes {
+		if match(&wishes[i]) && !lo.Contains(userUIDs, wishes[i].UserUID) {
+			userUIDs = append(userUIDs, wishes[i].UserUID)
+		}
+	}
+	if len(userUIDs) == 0 {
+		return
+	}
+	err = views.NotificationSend(db, models.NotificationKindWishMatch, chain.ID, userUIDs, gin.H{
+		"Title":     title,
+		"ChainName": chain.Name,
+	})
+	if err != nil {
+		slog.Error("Unable to notify members of a matching wish", "err", err)
+	}
+}
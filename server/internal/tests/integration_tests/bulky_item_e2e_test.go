@@ -0,0 +1,266 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestBulkyItemLifecycle(t *testing.T) {
+	chain, author, authorToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	member, memberToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	other, otherToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	otherChain, otherChainUser, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+
+	ucID := uint(0)
+	db.Raw(`SELECT id FROM user_chains WHERE user_id = ? AND chain_id = ?`, author.ID, chain.ID).Scan(&ucID)
+	bulkyItem := &models.BulkyItem{Title: "Sofa", UserChainID: ucID}
+	if !assert.NoError(t, db.Create(bulkyItem).Error) {
+		return
+	}
+
+	getItem := func(t *testing.T) *models.BulkyItem {
+		b, err := models.BulkyItemGetByIDInChain(db, chain.ID, bulkyItem.ID)
+		assert.NoError(t, err)
+		return b
+	}
+	reserve := func(t *testing.T, token string) int {
+		body := gin.H{"chain_uid": chain.UID, "id": bulkyItem.ID}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/bulky-item/reserve", &body, token)
+		controllers.BulkyReserve(c)
+		return resultFunc().Response.StatusCode
+	}
+	getAll := func(t *testing.T, status string) []sharedtypes.BulkyItem {
+		url := fmt.Sprintf("/v2/bulky-item/all?chain_uid=%s&user_uid=%s", chain.UID, author.UID)
+		if status != "" {
+			url += "&status=" + status
+		}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, authorToken)
+		controllers.BulkyGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode)
+		items := []sharedtypes.BulkyItem{}
+		json.Unmarshal([]byte(result.Body), &items)
+		return items
+	}
+
+	t.Run("get all by chain", func(t *testing.T) {
+		items, err := models.GetAllBulkyItemsByChain(db, chain.ID, models.BulkyItemFilter{})
+		assert.NoError(t, err)
+		if assert.Len(t, items, 1) {
+			assert.Equal(t, sharedtypes.BulkyItemStatusAvailable, items[0].Status)
+			assert.Equal(t, author.UID, items[0].UserUID)
+		}
+		items, _ = models.GetAllBulkyItemsByChain(db, otherChain.ID, models.BulkyItemFilter{})
+		assert.Empty(t, items)
+	})
+
+	t.Run("reserve", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, reserve(t, authorToken), "authors can not reserve their own item")
+		assert.Equal(t, http.StatusOK, reserve(t, memberToken))
+		assert.Equal(t, http.StatusConflict, reserve(t, otherToken), "already reserved")
+
+		b := getItem(t)
+		assert.Equal(t, sharedtypes.BulkyItemStatusReserved, b.Status)
+		assert.Equal(t, member.UID, b.ReceiverUID)
+
+		assert.Len(t, getAll(t, sharedtypes.BulkyItemStatusReserved), 1)
+		assert.Empty(t, getAll(t, sharedtypes.BulkyItemStatusAvailable))
+
+		url := fmt.Sprintf("/v2/bulky-item/reserve?chain_uid=%s&id=%d", chain.UID, bulkyItem.ID)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodDelete, url, nil, otherToken)
+		controllers.BulkyUnreserve(c)
+		assert.Equal(t, http.StatusForbidden, resultFunc().Response.StatusCode)
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodDelete, url, nil, memberToken)
+		controllers.BulkyUnreserve(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+		assert.Equal(t, sharedtypes.BulkyItemStatusAvailable, getItem(t).Status)
+	})
+
+	t.Run("reserve at the same time", func(t *testing.T) {
+		first, second := getItem(t), getItem(t)
+		assert.NoError(t, services.BulkyItemReserve(db, chain, member, first))
+		err := services.BulkyItemReserve(db, chain, other, second)
+		assert.ErrorIs(t, err, models.ErrBulkyItemNotAvailable)
+		assert.Equal(t, member.UID, getItem(t).ReceiverUID)
+
+		assert.NoError(t, services.BulkyItemUnreserve(db, member, getItem(t)))
+	})
+
+	t.Run("given", func(t *testing.T) {
+		body := gin.H{"chain_uid": chain.UID, "id": bulkyItem.ID}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/bulky-item/given", &body, authorToken)
+		controllers.BulkyGiven(c)
+		assert.Equal(t, http.StatusBadRequest, resultFunc().Response.StatusCode, "not reserved and no receiver")
+
+		assert.Equal(t, http.StatusOK, reserve(t, otherToken))
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPatch, "/v2/bulky-item/given", &body, otherToken)
+		controllers.BulkyGiven(c)
+		assert.Equal(t, http.StatusForbidden, resultFunc().Response.StatusCode, "only the author")
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPatch, "/v2/bulky-item/given", &body, authorToken)
+		controllers.BulkyGiven(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+		b := getItem(t)
+		assert.Equal(t, sharedtypes.BulkyItemStatusGiven, b.Status)
+		assert.Equal(t, other.UID, b.ReceiverUID, "defaults to the member that reserved it")
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		db.Exec(`UPDATE chains SET bulky_item_expiry_days = 10 WHERE id = ?`, chain.ID)
+		db.Exec(`UPDATE bulky_items SET status = ?, receiver_user_chain_id = NULL, updated_at = ? WHERE id = ?`,
+			sharedtypes.BulkyItemStatusAvailable, time.Now().AddDate(0, 0, -9), bulkyItem.ID)
+
+		services.BulkyItemExpiryRun(db)
+		b := getItem(t)
+		assert.NotNil(t, b.LastNotifiedAt, "the author is reminded")
+		assert.Equal(t, sharedtypes.BulkyItemStatusAvailable, b.Status)
+
+		db.Exec(`UPDATE bulky_items SET updated_at = ?, last_notified_at = ? WHERE id = ?`,
+			time.Now().AddDate(0, 0, -11), time.Now().AddDate(0, 0, -3), bulkyItem.ID)
+		services.BulkyItemExpiryRun(db)
+		assert.Equal(t, sharedtypes.BulkyItemStatusExpired, getItem(t).Status)
+		assert.Empty(t, getAll(t, ""), "expired items are left out by default")
+		assert.Len(t, getAll(t, sharedtypes.BulkyItemStatusExpired), 1)
+
+		body := gin.H{"id": bulkyItem.ID, "chain_uid": chain.UID, "user_uid": author.UID, "title": "Sofa, still here"}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/bulky-item", &body, authorToken)
+		controllers.BulkyPut(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+		b = getItem(t)
+		assert.Equal(t, sharedtypes.BulkyItemStatusAvailable, b.Status, "editing renews the item")
+		assert.Nil(t, b.LastNotifiedAt)
+	})
+
+	t.Run("edit", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, reserve(t, memberToken))
+		body := gin.H{"id": bulkyItem.ID, "chain_uid": chain.UID, "user_uid": author.UID, "title": "Sofa, reserved"}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/bulky-item", &body, authorToken)
+		controllers.BulkyPut(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+		b := getItem(t)
+		assert.Equal(t, sharedtypes.BulkyItemStatusReserved, b.Status, "editing keeps the reservation")
+		assert.Equal(t, member.UID, b.ReceiverUID)
+		assert.NoError(t, services.BulkyItemUnreserve(db, member, b))
+
+		otherUcID := uint(0)
+		db.Raw(`SELECT id FROM user_chains WHERE user_id = ? AND chain_id = ?`, otherChainUser.ID, otherChain.ID).Scan(&otherUcID)
+		otherItem := &models.BulkyItem{Title: "Chair", UserChainID: otherUcID}
+		if !assert.NoError(t, db.Create(otherItem).Error) {
+			return
+		}
+		body = gin.H{"id": otherItem.ID, "chain_uid": chain.UID, "user_uid": author.UID, "title": "Taken over"}
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPut, "/v2/bulky-item", &body, authorToken)
+		controllers.BulkyPut(c)
+		assert.Equal(t, http.StatusNotFound, resultFunc().Response.StatusCode, "items of other loops can not be edited")
+		b, err := models.BulkyItemGetByIDInChain(db, otherChain.ID, otherItem.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "Chair", b.Title)
+		}
+	})
+
+	t.Run("member leaves", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, reserve(t, memberToken))
+		err := (&models.User{ID: member.ID}).DeleteUserChainDependencies(db, chain.ID)
+		assert.NoError(t, err)
+		b := getItem(t)
+		assert.Equal(t, sharedtypes.BulkyItemStatusAvailable, b.Status)
+		assert.Empty(t, b.ReceiverUID)
+	})
+}
+
+func TestBulkyItemSearch(t *testing.T) {
+	chain, author, authorToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+
+	put := func(t *testing.T, body gin.H) {
+		body["chain_uid"] = chain.UID
+		body["user_uid"] = author.UID
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/bulky-item", &body, authorToken)
+		controllers.BulkyPut(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+	}
+	put(t, gin.H{"title": "Wooden bunk bed", "message": "Fits two kids", "category": sharedtypes.BulkyItemCategoryFurniture, "tags": []string{"Wood", " IKEA"}})
+	put(t, gin.H{"title": "Stroller", "message": "Foldable kinderwagen", "category": sharedtypes.BulkyItemCategoryKids, "sizes": []string{models.SizeEnumBaby}, "genders": []string{models.GenderEnumChildren}})
+	put(t, gin.H{"title": "Ski boots", "category": sharedtypes.BulkyItemCategorySports, "sizes": []string{models.SizeEnumMenLarge}})
+
+	getAll := func(t *testing.T, params string) []string {
+		url := fmt.Sprintf("/v2/bulky-item/all?chain_uid=%s&user_uid=%s&%s", chain.UID, author.UID, params)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, authorToken)
+		controllers.BulkyGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode)
+		items := []sharedtypes.BulkyItem{}
+		json.Unmarshal([]byte(result.Body), &items)
+		titles := []string{}
+		for _, item := range items {
+			titles = append(titles, item.Title)
+		}
+		return titles
+	}
+
+	assert.ElementsMatch(t, []string{"Stroller"}, getAll(t, "category=kids"))
+	assert.ElementsMatch(t, []string{"Wooden bunk bed"}, getAll(t, "tag=ikea"))
+	assert.ElementsMatch(t, []string{"Stroller", "Ski boots"}, getAll(t, fmt.Sprintf("sizes=%s&sizes=%s", models.SizeEnumBaby, models.SizeEnumMenLarge)))
+	assert.ElementsMatch(t, []string{"Stroller"}, getAll(t, "genders="+models.GenderEnumChildren))
+	assert.ElementsMatch(t, []string{"Stroller"}, getAll(t, "search=kinder"), "prefix search of the message")
+	assert.ElementsMatch(t, []string{"Wooden bunk bed"}, getAll(t, "search=wood+bed"))
+	assert.Empty(t, getAll(t, "search=wooden+stroller"), "all words must match")
+
+	c, resultFunc := mocks.MockGinContext(db, http.MethodGet, fmt.Sprintf("/v2/bulky-item/all?chain_uid=%s&user_uid=%s&category=cars", chain.UID, author.UID), nil, authorToken)
+	controllers.BulkyGetAll(c)
+	assert.Equal(t, http.StatusBadRequest, resultFunc().Response.StatusCode)
+}
+
+func TestBulkyItemSubscriptions(t *testing.T) {
+	chain, author, authorToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	subscriber, subscriberToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	everything, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+
+	body := gin.H{"chain_uid": chain.UID, "categories": []string{sharedtypes.BulkyItemCategoryKids, sharedtypes.BulkyItemCategoryBooks}}
+	c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/bulky-item/subscriptions", &body, subscriberToken)
+	controllers.BulkySubscriptionsPut(c)
+	assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+	c, resultFunc = mocks.MockGinContext(db, http.MethodGet, "/v2/bulky-item/subscriptions?chain_uid="+chain.UID, nil, subscriberToken)
+	controllers.BulkySubscriptionsGet(c)
+	result := resultFunc()
+	categories := []string{}
+	json.Unmarshal([]byte(result.Body), &categories)
+	assert.Equal(t, []string{sharedtypes.BulkyItemCategoryBooks, sharedtypes.BulkyItemCategoryKids}, categories)
+
+	uids, err := models.BulkyItemSubscriberUIDs(db, chain.ID, author.UID, sharedtypes.BulkyItemCategoryFurniture)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{everything.UID}, uids, "members without subscriptions receive all new items")
+
+	uids, _ = models.BulkyItemSubscriberUIDs(db, chain.ID, author.UID, sharedtypes.BulkyItemCategoryKids)
+	assert.ElementsMatch(t, []string{everything.UID, subscriber.UID}, uids)
+
+	body = gin.H{"chain_uid": chain.UID, "user_uid": author.UID, "title": "Bookshelf", "category": sharedtypes.BulkyItemCategoryFurniture}
+	c, resultFunc = mocks.MockGinContext(db, http.MethodPut, "/v2/bulky-item", &body, authorToken)
+	controllers.BulkyPut(c)
+	assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+	notifications, _ := models.UserNotificationGetAll(db, subscriber.ID, 0, models.UserNotificationPageSize)
+	for _, n := range notifications {
+		assert.NotEqual(t, models.NotificationKindBulkyItemNew, n.Kind, "not subscribed to furniture")
+	}
+
+	body = gin.H{"chain_uid": chain.UID, "categories": []string{"cars"}}
+	c, resultFunc = mocks.MockGinContext(db, http.MethodPut, "/v2/bulky-item/subscriptions", &body, subscriberToken)
+	controllers.BulkySubscriptionsPut(c)
+	assert.Equal(t, http.StatusBadRequest, resultFunc().Response.StatusCode)
+}
@@ -0,0 +1,89 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChainRemoveUserLastHostWithCoHost(t *testing.T) {
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	coHost, coHostToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsChainAdmin: true, ChainRole: string(auth.RoleCoHost)})
+
+	leave := func(t *testing.T, userUID, token string) int {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chain/remove-user", &gin.H{
+			"chain_uid": chain.UID,
+			"user_uid":  userUID,
+		}, token)
+		controllers.ChainRemoveUser(c)
+		return resultFunc().Response.StatusCode
+	}
+
+	assert.Equal(t, http.StatusConflict, leave(t, host.UID, hostToken), "a co-host does not count as another host")
+	assert.Equal(t, http.StatusOK, leave(t, coHost.UID, coHostToken), "a co-host may leave while a host remains")
+}
+
+func TestChainChangeUserWardenCoHost(t *testing.T) {
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	coHost, coHostToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsChainAdmin: true, ChainRole: string(auth.RoleCoHost)})
+	participant, participantToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+
+	setWarden := func(t *testing.T, userUID, token string) int {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/chain/user/warden", &gin.H{
+			"chain_uid": chain.UID,
+			"user_uid":  userUID,
+			"warden":    true,
+		}, token)
+		controllers.ChainChangeUserWarden(c)
+		return resultFunc().Response.StatusCode
+	}
+
+	getRole := func(t *testing.T, userID uint) auth.Role {
+		uc := &sharedtypes.UserChain{}
+		db.Raw(`SELECT * FROM user_chains WHERE user_id = ? AND chain_id = ?`, userID, chain.ID).Scan(uc)
+		return auth.RoleOfUserChain(uc)
+	}
+
+	assert.Equal(t, http.StatusConflict, setWarden(t, coHost.UID, coHostToken), "co-hosts can not make themselves a host")
+	assert.Equal(t, http.StatusConflict, setWarden(t, coHost.UID, hostToken))
+	assert.Equal(t, auth.RoleCoHost, getRole(t, coHost.ID))
+	assert.Equal(t, http.StatusConflict, setWarden(t, host.UID, coHostToken), "hosts cannot be assigned wardens")
+	assert.Equal(t, auth.RoleHost, getRole(t, host.ID))
+
+	assert.Equal(t, http.StatusUnauthorized, setWarden(t, participant.UID, participantToken))
+	assert.Equal(t, http.StatusOK, setWarden(t, participant.UID, coHostToken))
+	assert.Equal(t, auth.RoleWarden, getRole(t, participant.ID))
+}
+
+func TestRouteActions(t *testing.T) {
+	chain, host, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	participant, participantToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	_, coHostToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsChainAdmin: true, ChainRole: string(auth.RoleCoHost)})
+
+	t.Run("every participant can set the route order", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/route/order", &gin.H{
+			"chain_uid":   chain.UID,
+			"route_order": []string{participant.UID, host.UID},
+		}, participantToken)
+		controllers.RouteOrderSet(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+	})
+
+	t.Run("only hosts and co-hosts can optimize the route", func(t *testing.T) {
+		optimize := func(token string) int {
+			c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/route/optimize?chain_uid="+chain.UID, nil, token)
+			controllers.RouteOptimize(c)
+			return resultFunc().Response.StatusCode
+		}
+		assert.Equal(t, http.StatusUnauthorized, optimize(participantToken))
+		assert.Equal(t, http.StatusOK, optimize(coHostToken))
+	})
+}
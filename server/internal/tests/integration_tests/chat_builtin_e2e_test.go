@@ -0,0 +1,117 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChatBuiltin(t *testing.T) {
+	assert.Equal(t, app.ChatProviderBuiltin, app.Chat.Name())
+
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	participant, participantToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	_, outsiderToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsNotApproved: true})
+
+	patchUser := func(t *testing.T, token string) sharedtypes.ChatPatchUserResponse {
+		body := gin.H{"chain_uid": chain.UID}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/chat/user", &body, token)
+		controllers.ChatPatchUser(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+		res := sharedtypes.ChatPatchUserResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		return res
+	}
+
+	res := patchUser(t, hostToken)
+	assert.Equal(t, app.ChatProviderBuiltin, res.ChatProvider)
+	assert.Equal(t, host.UID, res.ChatUserID)
+	assert.Empty(t, res.ChatToken, "the built-in chat uses the api token")
+
+	db.First(chain, chain.ID)
+	if !assert.Len(t, chain.ChatRoomIDs, 1, "a General channel is created for the host") {
+		return
+	}
+	channelID := chain.ChatRoomIDs[0]
+
+	patchUser(t, participantToken)
+	body := gin.H{"chain_uid": chain.UID}
+	c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chat/channel/join", &body, participantToken)
+	controllers.ChatJoinChannels(c)
+	assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+	channel, err := models.ChatChannelGetByUID(db, channelID)
+	assert.NoError(t, err)
+	isMember, _ := models.ChatChannelMemberExists(db, channel.ID, participant.ID)
+	assert.True(t, isMember)
+
+	// listen as the participant
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("DB", db) })
+	r.GET("/v2/chat/ws", controllers.ChatWebSocket)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(server.URL, "http", "ws", 1)+"/v2/chat/ws", http.Header{
+		"Authorization": []string{"Bearer " + participantToken},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	// the subscription is made after the upgrade
+	time.Sleep(100 * time.Millisecond)
+
+	sendMessage := func(t *testing.T, token, message string) int {
+		body := gin.H{"chain_uid": chain.UID, "channel_id": channelID, "message": message}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chat/channel/message", &body, token)
+		controllers.ChatMessageCreate(c)
+		return resultFunc().Response.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, sendMessage(t, hostToken, "Welcome!"))
+	assert.Equal(t, http.StatusUnauthorized, sendMessage(t, outsiderToken, "Hi"), "not approved in the loop")
+
+	received := sharedtypes.ChatMessage{}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	err = conn.ReadJSON(&received)
+	assert.NoError(t, err)
+	assert.Equal(t, "Welcome!", received.Message)
+	assert.Equal(t, host.UID, received.UserUID)
+	assert.Equal(t, channelID, received.ChannelUID)
+
+	c, resultFunc = mocks.MockGinContext(db, http.MethodGet, "/v2/chat/channel/messages?chain_uid="+chain.UID+"&channel_id="+channelID, nil, participantToken)
+	controllers.ChatMessageGetAll(c)
+	result := resultFunc()
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode)
+	messages := []sharedtypes.ChatMessage{}
+	json.Unmarshal([]byte(result.Body), &messages)
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, "Welcome!", messages[0].Message)
+	}
+
+	t.Run("delete channel", func(t *testing.T) {
+		body := gin.H{"chain_uid": chain.UID, "channel_id": channelID}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chat/channel/delete", &body, hostToken)
+		controllers.ChatDeleteChannel(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+		_, err := models.ChatChannelGetByUID(db, channelID)
+		assert.Error(t, err)
+		var count int
+		db.Raw(`SELECT COUNT(*) FROM chat_messages WHERE chat_channel_id IN (SELECT id FROM chat_channels WHERE uid = ?)`, channelID).Scan(&count)
+		assert.Zero(t, count)
+	})
+}
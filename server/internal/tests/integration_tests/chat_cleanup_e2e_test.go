@@ -0,0 +1,69 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+// A chat server that is down
+type unavailableChat struct {
+	app.ChatProvider
+}
+
+func (unavailableChat) ChannelDelete(ctx context.Context, channelID string) error {
+	return errors.New("connection refused")
+}
+
+func TestChatCleanupChainDelete(t *testing.T) {
+	chain, _, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+
+	body := gin.H{"chain_uid": chain.UID}
+	c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/chat/user", &body, hostToken)
+	controllers.ChatPatchUser(c)
+	assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+	db.First(chain, chain.ID)
+	if !assert.Len(t, chain.ChatRoomIDs, 1) {
+		return
+	}
+	channelID := chain.ChatRoomIDs[0]
+
+	assert.NoError(t, chain.Delete(db))
+	getCleanup := func() *models.ChatCleanup {
+		cleanup := &models.ChatCleanup{}
+		db.Raw(`SELECT * FROM chat_cleanups WHERE kind = ? AND chat_id = ?`, models.ChatCleanupKindChannel, channelID).Scan(cleanup)
+		return cleanup
+	}
+	assert.NotZero(t, getCleanup().ID, "channel is scheduled for removal with the loop")
+
+	builtin := app.Chat
+	app.Chat = unavailableChat{builtin}
+	services.ChatCleanupRun(db, context.Background())
+	app.Chat = builtin
+
+	cleanup := getCleanup()
+	assert.Equal(t, 1, cleanup.Attempts)
+	assert.Equal(t, "connection refused", cleanup.Error)
+	_, err := models.ChatChannelGetByUID(db, channelID)
+	assert.NoError(t, err, "channel remains while the chat server is down")
+
+	services.ChatCleanupRun(db, context.Background())
+	assert.NotZero(t, getCleanup().ID, "not retried before the next attempt")
+
+	db.Exec(`UPDATE chat_cleanups SET next_attempt_at = NOW() WHERE id = ?`, cleanup.ID)
+	services.ChatCleanupRun(db, context.Background())
+	assert.Zero(t, getCleanup().ID)
+	_, err = models.ChatChannelGetByUID(db, channelID)
+	assert.Error(t, err)
+}
@@ -0,0 +1,197 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChatModeration(t *testing.T) {
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	participant, participantToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+
+	for _, token := range []string{hostToken, participantToken} {
+		body := gin.H{"chain_uid": chain.UID}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/chat/user", &body, token)
+		controllers.ChatPatchUser(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPost, "/v2/chat/channel/join", &body, token)
+		controllers.ChatJoinChannels(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+	}
+	db.First(chain, chain.ID)
+	if !assert.Len(t, chain.ChatRoomIDs, 1) {
+		return
+	}
+	channelID := chain.ChatRoomIDs[0]
+
+	sendMessage := func(t *testing.T, token, message string) (int, sharedtypes.ChatMessage) {
+		body := gin.H{"chain_uid": chain.UID, "channel_id": channelID, "message": message}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chat/channel/message", &body, token)
+		controllers.ChatMessageCreate(c)
+		result := resultFunc()
+		m := sharedtypes.ChatMessage{}
+		json.Unmarshal([]byte(result.Body), &m)
+		return result.Response.StatusCode, m
+	}
+
+	t.Run("report", func(t *testing.T) {
+		status, m := sendMessage(t, hostToken, "Something rude")
+		assert.Equal(t, http.StatusOK, status)
+
+		body := gin.H{"chain_uid": chain.UID, "channel_id": channelID, "message_id": fmt.Sprint(m.ID), "reason": "Not nice"}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chat/message/report", &body, participantToken)
+		controllers.ChatMessageReportCreate(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+		body["message_id"] = "999999999"
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPost, "/v2/chat/message/report", &body, participantToken)
+		controllers.ChatMessageReportCreate(c)
+		assert.Equal(t, http.StatusNotFound, resultFunc().Response.StatusCode)
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodGet, "/v2/chat/reports?chain_uid="+chain.UID, nil, hostToken)
+		controllers.ChatMessageReportGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode)
+		reports := []sharedtypes.ChatMessageReport{}
+		json.Unmarshal([]byte(result.Body), &reports)
+		if assert.Len(t, reports, 1) {
+			assert.Equal(t, "Something rude", reports[0].Message)
+			assert.Equal(t, host.UID, reports[0].AuthorUID)
+			assert.Equal(t, participant.UID, reports[0].ReporterUID)
+			assert.Equal(t, "Not nice", reports[0].Reason)
+		}
+
+		notifications, _ := models.UserNotificationGetAll(db, host.ID, 0, models.UserNotificationPageSize)
+		assert.True(t, len(notifications) > 0 && notifications[0].Kind == models.UserNotificationKindChatReport, "hosts are notified in their inbox")
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodDelete, fmt.Sprintf("/v2/chat/report?chain_uid=%s&id=%d", chain.UID, reports[0].ID), nil, hostToken)
+		controllers.ChatMessageReportDelete(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+		reports, _ = models.ChatMessageReportGetAll(db, chain.ID)
+		assert.Empty(t, reports)
+	})
+
+	t.Run("mute", func(t *testing.T) {
+		mute := func(t *testing.T, userUID string) int {
+			body := gin.H{"chain_uid": chain.UID, "user_uid": userUID, "minutes": 60}
+			c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/chat/mute", &body, hostToken)
+			controllers.ChatMutePut(c)
+			return resultFunc().Response.StatusCode
+		}
+		assert.Equal(t, http.StatusConflict, mute(t, host.UID), "hosts can not be muted")
+		assert.Equal(t, http.StatusOK, mute(t, participant.UID))
+
+		status, _ := sendMessage(t, participantToken, "Hello?")
+		assert.Equal(t, http.StatusForbidden, status)
+
+		mutes, _ := models.ChatMuteGetAll(db, chain.ID)
+		if assert.Len(t, mutes, 1) {
+			assert.Equal(t, participant.UID, mutes[0].UserUID)
+		}
+
+		c, resultFunc := mocks.MockGinContext(db, http.MethodDelete, "/v2/chat/mute?chain_uid="+chain.UID+"&user_uid="+participant.UID, nil, hostToken)
+		controllers.ChatMuteDelete(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+		status, _ = sendMessage(t, participantToken, "Hello again")
+		assert.Equal(t, http.StatusOK, status)
+
+		models.ChatMutePut(db, chain.ID, participant.ID, time.Now().Add(-time.Minute))
+		services.ChatMuteEndedRun(db, context.Background())
+		var count int
+		db.Raw(`SELECT COUNT(*) FROM chat_mutes WHERE chain_id = ?`, chain.ID).Scan(&count)
+		assert.Zero(t, count, "ended mutes are removed")
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		body := gin.H{"chain_uid": chain.UID, "days": 7}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/chat/retention", &body, participantToken)
+		controllers.ChatRetentionUpdate(c)
+		assert.Equal(t, http.StatusUnauthorized, resultFunc().Response.StatusCode)
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPatch, "/v2/chat/retention", &body, hostToken)
+		controllers.ChatRetentionUpdate(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+		_, old := sendMessage(t, hostToken, "Old news")
+		_, recent := sendMessage(t, hostToken, "Fresh news")
+		db.Exec(`UPDATE chat_messages SET created_at = ? WHERE id = ?`, time.Now().AddDate(0, 0, -8), old.ID)
+
+		services.ChatRetentionRun(db, context.Background())
+
+		channel, _ := models.ChatChannelGetByUID(db, channelID)
+		messages, _ := models.ChatMessageGetAll(db, channel.ID, 0)
+		ids := []uint{}
+		for _, m := range messages {
+			ids = append(ids, m.ID)
+		}
+		assert.NotContains(t, ids, old.ID)
+		assert.Contains(t, ids, recent.ID)
+	})
+}
+
+// A chat server that removes muted members from the channel, like Mattermost
+type removingMuteChat struct {
+	app.ChatProvider
+	memberOf map[string]bool
+	rejoined []string
+}
+
+func (r *removingMuteChat) MemberMute(ctx context.Context, channelID, chatUserID string, muted bool) (bool, error) {
+	if !muted {
+		r.memberOf[channelID] = true
+		r.rejoined = append(r.rejoined, channelID)
+		return false, nil
+	}
+	if !r.memberOf[channelID] {
+		return false, nil
+	}
+	delete(r.memberOf, channelID)
+	return true, nil
+}
+
+func TestChatMuteRejoinsOnlyRemovedChannels(t *testing.T) {
+	chain, _, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	participant, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	chatUserID := uuid.NewV4().String()
+	participant.ChatUserID = &chatUserID
+	db.Exec(`UPDATE users SET chat_user_id = ? WHERE id = ?`, chatUserID, participant.ID)
+	setChannels := func(channelIDs ...string) {
+		chain.ChatRoomIDs = channelIDs
+		b, _ := json.Marshal(channelIDs)
+		db.Exec(`UPDATE chains SET chat_room_ids = ? WHERE id = ?`, string(b), chain.ID)
+	}
+	setChannels("general", "sales", "events")
+
+	chat := &removingMuteChat{ChatProvider: app.Chat, memberOf: map[string]bool{"general": true, "events": true}}
+	builtin := app.Chat
+	app.Chat = chat
+	defer func() { app.Chat = builtin }()
+
+	ctx := context.Background()
+	assert.NoError(t, services.ChatMute(db, ctx, chain, participant, time.Now().Add(time.Hour)))
+	// extending the mute keeps the channels of the first mute
+	assert.NoError(t, services.ChatMute(db, ctx, chain, participant, time.Now().Add(2*time.Hour)))
+	mute, _ := models.ChatMuteGet(db, chain.ID, participant.ID)
+	assert.ElementsMatch(t, []string{"general", "events"}, mute.ChannelIDs)
+
+	setChannels("general", "sales")
+	db.Exec(`UPDATE chat_mutes SET until = ? WHERE id = ?`, time.Now().Add(-time.Minute), mute.ID)
+	services.ChatMuteEndedRun(db, ctx)
+	assert.Equal(t, []string{"general"}, chat.rejoined, "channels never joined or removed from the loop are not joined")
+}
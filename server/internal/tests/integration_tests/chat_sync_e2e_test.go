@@ -0,0 +1,78 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChatSync(t *testing.T) {
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	coHost, coHostToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	participant, participantToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	_, _, rootAdminToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsRootAdmin: true})
+
+	for _, token := range []string{hostToken, coHostToken, participantToken} {
+		body := gin.H{"chain_uid": chain.UID}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/chat/user", &body, token)
+		controllers.ChatPatchUser(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPost, "/v2/chat/channel/join", &body, token)
+		controllers.ChatJoinChannels(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+	}
+	db.First(chain, chain.ID)
+	if !assert.Len(t, chain.ChatRoomIDs, 1) {
+		return
+	}
+	channelID := chain.ChatRoomIDs[0]
+
+	changes, err := services.ChatSyncChain(db, context.Background(), chain, true)
+	assert.NoError(t, err)
+	assert.Empty(t, changes, "members match the loop")
+
+	// change the loop without triggering a sync
+	db.Exec(`DELETE FROM user_chains WHERE user_id = ? AND chain_id = ?`, participant.ID, chain.ID)
+	models.UserChainSetRole(db, coHost.ID, chain.ID, "", false, false)
+
+	c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/chat/sync?chain_uid="+chain.UID, nil, hostToken)
+	controllers.ChatSyncReport(c)
+	assert.Equal(t, http.StatusUnauthorized, resultFunc().Response.StatusCode, "only for root admins")
+
+	c, resultFunc = mocks.MockGinContext(db, http.MethodGet, "/v2/chat/sync?chain_uid="+chain.UID, nil, rootAdminToken)
+	controllers.ChatSyncReport(c)
+	result := resultFunc()
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+	report := sharedtypes.ChatSyncReport{}
+	json.Unmarshal([]byte(result.Body), &report)
+	assert.True(t, report.DryRun)
+	assert.ElementsMatch(t, []sharedtypes.ChatSyncChange{
+		{ChainUID: chain.UID, ChannelID: channelID, ChatUserID: coHost.UID, UserUID: coHost.UID, Action: sharedtypes.ChatSyncActionUnsetAdmin},
+		{ChainUID: chain.UID, ChannelID: channelID, ChatUserID: participant.UID, Action: sharedtypes.ChatSyncActionRemove},
+	}, report.Changes)
+
+	members, _ := app.Chat.ChannelMembers(context.Background(), channelID)
+	assert.Len(t, members, 3, "a dry run changes nothing")
+
+	changes, err = services.ChatSyncChain(db, context.Background(), chain, false)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+
+	members, _ = app.Chat.ChannelMembers(context.Background(), channelID)
+	assert.ElementsMatch(t, []app.ChatChannelMember{
+		{ChatUserID: host.UID, IsAdmin: true},
+		{ChatUserID: coHost.UID, IsAdmin: false},
+	}, members)
+}
@@ -0,0 +1,56 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/internal/views"
+)
+
+func TestHostDigest(t *testing.T) {
+	chain, host, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	pending, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsNotApproved: true})
+	member, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	event := mocks.MockEvent(t, db, host.ID, chain.ID)
+	db.Exec(`UPDATE events SET date = (NOW() + INTERVAL 3 DAY) WHERE id = ?`, event.ID)
+
+	err := models.ChainLeaveAdd(db, "Leaver", []string{models.ReasonEnumMoved}, chain.ID)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	data, err := services.HostDigestGet(db, chain.ID, now.Add(-7*24*time.Hour), now)
+	assert.NoError(t, err)
+	if assert.Len(t, data.PendingApprovals, 1) {
+		assert.Equal(t, pending.Name, data.PendingApprovals[0].Name)
+	}
+	assert.Contains(t, lo.Map(data.NewMembers, func(p views.EmailHostDigestParticipant, _ int) string {
+		return p.Name
+	}), member.Name)
+	if assert.Len(t, data.Leaves, 1) {
+		assert.Equal(t, []string{models.ReasonEnumMoved}, data.Leaves[0].GetReasons())
+	}
+	if assert.Len(t, data.Events, 1) {
+		assert.Equal(t, event.Name, data.Events[0].Name)
+	}
+	assert.False(t, data.IsEmpty())
+
+	countDigests := func(t *testing.T) (count int) {
+		db.Raw(`SELECT COUNT(*) FROM mails WHERE to_address = ? AND category = ?`, *host.Email, models.NotificationKindHostDigest).Scan(&count)
+		return count
+	}
+
+	services.HostDigestRun(db, now)
+	assert.Equal(t, 0, countDigests(t), "the digest is opt-in")
+
+	err = models.NotificationPreferenceSet(db, host.ID, 0, models.NotificationKindHostDigest, models.NotificationChannelEmail)
+	assert.NoError(t, err)
+	services.HostDigestRun(db, now)
+	assert.Equal(t, 1, countDigests(t))
+}
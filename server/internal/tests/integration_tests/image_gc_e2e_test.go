@@ -0,0 +1,95 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+func TestImageGC(t *testing.T) {
+	imagesDir := app.Config.IMAGES_DIR
+	app.Config.IMAGES_DIR = t.TempDir()
+	defer func() { app.Config.IMAGES_DIR = imagesDir }()
+
+	chain, author, authorToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+
+	suffix := time.Now().UnixNano()
+	used := fmt.Sprintf("2026-10-19_used%d=.jpg", suffix)
+	unused := fmt.Sprintf("2026-10-19_unused%d=.jpg", suffix)
+	recent := fmt.Sprintf("2026-10-19_recent%d=.jpg", suffix)
+	old := time.Now().Add(-services.ImageGCGracePeriod - time.Hour)
+	for _, name := range []string{used, unused, recent} {
+		for size := range models.ImageSizes {
+			p := services.ImagePath(size, name)
+			os.MkdirAll(filepath.Dir(p), 0755)
+			os.WriteFile(p, []byte("jpg"), 0644)
+			if name != recent {
+				os.Chtimes(p, old, old)
+			}
+		}
+	}
+
+	body := gin.H{"chain_uid": chain.UID, "user_uid": author.UID, "title": "Table", "image_url": services.ImageURL(models.ImageSizeMedium, used)}
+	c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/bulky-item", &body, authorToken)
+	controllers.BulkyPut(c)
+	assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+	// a reference to an event that was removed together with its loop
+	staleRefID := uint(suffix % 1_000_000_000)
+	db.Exec(`DELETE FROM events WHERE id = ?`, staleRefID)
+	assert.NoError(t, models.ImageReferenceSet(db, models.ImageReferenceKindEvent, staleRefID, services.ImageURL(models.ImageSizeMedium, unused)))
+	countStale := func() int {
+		count := 0
+		db.Raw(`SELECT COUNT(*) FROM image_references WHERE kind = ? AND ref_id = ?`, models.ImageReferenceKindEvent, staleRefID).Scan(&count)
+		return count
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		report, err := services.ImageGCRun(db, true)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, report.DryRun)
+		assert.Equal(t, []string{unused}, report.Removed, "stale references do not keep an image")
+		assert.Equal(t, 2, report.Kept)
+		assert.FileExists(t, services.ImagePath(models.ImageSizeThumb, unused))
+		assert.Equal(t, 1, countStale(), "a dry run does not change the database")
+	})
+
+	t.Run("removed bulky item", func(t *testing.T) {
+		items, _ := models.GetAllBulkyItemsByChain(db, chain.ID, models.BulkyItemFilter{})
+		if !assert.Len(t, items, 1) {
+			return
+		}
+		url := fmt.Sprintf("/v2/bulky-item?chain_uid=%s&user_uid=%s&id=%d", chain.UID, author.UID, items[0].ID)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodDelete, url, nil, authorToken)
+		controllers.BulkyRemove(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+		report, err := services.ImageGCRun(db, false)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.ElementsMatch(t, []string{used, unused}, report.Removed)
+		assert.Empty(t, report.Errors)
+		assert.Zero(t, countStale())
+		for size := range models.ImageSizes {
+			assert.NoFileExists(t, services.ImagePath(size, used))
+			assert.NoFileExists(t, services.ImagePath(size, unused))
+			assert.FileExists(t, services.ImagePath(size, recent), "within the grace period")
+		}
+	})
+}
@@ -151,7 +151,7 @@ func TestEmailContactReceived(t *testing.T) {
 
 func TestEmailDoYouWantToBeHost(t *testing.T) {
 	runOnAllLanguages(t, func(t *testing.T, c *gin.Context, lng string) {
-		err := views.EmailDoYouWantToBeHost(db, lng,
+		err := views.EmailDoYouWantToBeHost(db, 0, lng,
 			lng+" "+faker.Person().Name(),
 			faker.Person().Contact().Email,
 			faker.Company().Name(),
@@ -162,7 +162,7 @@ func TestEmailDoYouWantToBeHost(t *testing.T) {
 
 func TestEmailIsYourLoopStillActive(t *testing.T) {
 	runOnAllLanguages(t, func(t *testing.T, c *gin.Context, lng string) {
-		err := views.EmailIsYourLoopStillActive(db, lng,
+		err := views.EmailIsYourLoopStillActive(db, 0, lng,
 			lng+" "+faker.Person().Name(),
 			faker.Person().Contact().Email,
 			faker.Company().Name(),
@@ -207,7 +207,7 @@ func TestEmailLoopIsDeleted(t *testing.T) {
 
 func TestEmailPoke(t *testing.T) {
 	runOnAllLanguages(t, func(t *testing.T, c *gin.Context, lng string) {
-		err := views.EmailPoke(db, lng,
+		err := views.EmailPoke(db, 0, lng,
 			lng+" "+faker.Person().Name(),
 			faker.Person().Contact().Email,
 			faker.Person().Name(),
@@ -231,7 +231,7 @@ func TestEmailRegisterVerification(t *testing.T) {
 
 func TestEmailSomeoneIsInterestedInJoiningYourLoop(t *testing.T) {
 	runOnAllLanguages(t, func(t *testing.T, c *gin.Context, lng string) {
-		err := views.EmailSomeoneIsInterestedInJoiningYourLoop(db, lng,
+		err := views.EmailSomeoneIsInterestedInJoiningYourLoop(db, 0, lng,
 			faker.Person().Contact().Email,
 			lng+" "+faker.Person().Name(),
 			faker.Company().Name(),
@@ -247,7 +247,7 @@ func TestEmailSomeoneIsInterestedInJoiningYourLoop(t *testing.T) {
 
 func TestEmailSomeoneLeftLoop(t *testing.T) {
 	runOnAllLanguages(t, func(t *testing.T, c *gin.Context, lng string) {
-		err := views.EmailSomeoneLeftLoop(db, lng,
+		err := views.EmailSomeoneLeftLoop(db, 0, lng,
 			lng+" "+faker.Person().Name(),
 			faker.Person().Contact().Email,
 			faker.Company().Name(),
@@ -260,7 +260,7 @@ func TestEmailSomeoneLeftLoop(t *testing.T) {
 
 func TestEmailSomeoneWaitingToBeAccepted(t *testing.T) {
 	runOnAllLanguages(t, func(t *testing.T, c *gin.Context, lng string) {
-		err := views.EmailSomeoneWaitingToBeAccepted(db, lng,
+		err := views.EmailSomeoneWaitingToBeAccepted(db, 0, lng,
 			lng+" "+faker.Person().Name(),
 			faker.Person().Contact().Email,
 			faker.Company().Name(),
@@ -0,0 +1,84 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+func TestMailUnsubscribe(t *testing.T) {
+	chain, user, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	email := *user.Email
+
+	getChannel := func(t *testing.T, kind string) string {
+		channel, err := models.NotificationPreferenceChannelByEmail(db, email, chain.ID, kind)
+		assert.NoError(t, err)
+		return channel
+	}
+	unsubscribe := func(t *testing.T, method, link string) int {
+		u, _ := url.Parse(link)
+		c, resultFunc := mocks.MockGinContext(db, method, "/v2/mail/unsubscribe?"+u.RawQuery, nil, "")
+		controllers.MailUnsubscribe(c)
+		return resultFunc().Response.StatusCode
+	}
+
+	t.Run("Invalid signature", func(t *testing.T) {
+		link := app.MailUnsubscribeURL(faker.Internet().Email(), models.NotificationKindJoinRequest)
+		u, _ := url.Parse(link)
+		q := u.Query()
+		q.Set("email", email)
+		u.RawQuery = q.Encode()
+
+		assert.Equal(t, http.StatusUnauthorized, unsubscribe(t, http.MethodPost, u.String()))
+		assert.Equal(t, models.NotificationChannelEmail, getChannel(t, models.NotificationKindJoinRequest))
+	})
+
+	t.Run("Push notifications have no unsubscribe link", func(t *testing.T) {
+		link := app.MailUnsubscribeURL(email, models.NotificationKindBagAssigned)
+		assert.Equal(t, http.StatusBadRequest, unsubscribe(t, http.MethodPost, link))
+	})
+
+	t.Run("One-click unsubscribe", func(t *testing.T) {
+		link := app.MailUnsubscribeURL(email, models.NotificationKindJoinRequest)
+		assert.Equal(t, http.StatusOK, unsubscribe(t, http.MethodPost, link))
+		assert.Equal(t, models.NotificationChannelNone, getChannel(t, models.NotificationKindJoinRequest))
+		assert.Equal(t, models.NotificationChannelEmail, getChannel(t, models.NotificationKindMemberLeft))
+	})
+
+	t.Run("Unsubscribe overrides the choice of a loop", func(t *testing.T) {
+		err := models.NotificationPreferenceSet(db, user.ID, chain.ID, models.NotificationKindApproveReminder, models.NotificationChannelEmail)
+		assert.NoError(t, err)
+
+		link := app.MailUnsubscribeURL(email, models.NotificationKindApproveReminder)
+		assert.Equal(t, http.StatusOK, unsubscribe(t, http.MethodPost, link))
+		assert.Equal(t, models.NotificationChannelNone, getChannel(t, models.NotificationKindApproveReminder))
+	})
+
+	t.Run("Unsubscribe from browser", func(t *testing.T) {
+		link := app.MailUnsubscribeURL(email, models.NotificationKindMemberLeft)
+		assert.Equal(t, http.StatusFound, unsubscribe(t, http.MethodGet, link))
+		assert.Equal(t, models.NotificationChannelEmail, getChannel(t, models.NotificationKindMemberLeft), "opening the link only shows the confirmation page")
+
+		assert.Equal(t, http.StatusOK, unsubscribe(t, http.MethodPost, link), "confirmed on the page")
+		assert.Equal(t, models.NotificationChannelNone, getChannel(t, models.NotificationKindMemberLeft))
+	})
+
+	t.Run("Unsubscribed emails are not queued", func(t *testing.T) {
+		m := app.MailCreate()
+		m.ToAddress = email
+		m.Subject = "Unsubscribed"
+		m.Category = models.NotificationKindJoinRequest
+		m.ChainID = chain.ID
+		err := app.MailSend(db, m)
+		assert.NoError(t, err)
+		assert.Zero(t, m.ID)
+	})
+}
@@ -0,0 +1,90 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+func TestMailWebhook(t *testing.T) {
+	webhookToken := faker.UUID().V4()
+	app.Config.BREVO_WEBHOOK_TOKEN = webhookToken
+	t.Cleanup(func() {
+		app.Config.BREVO_WEBHOOK_TOKEN = ""
+	})
+
+	_, user, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	mail := mocks.MockMail(t, db, mocks.MockMailOptions{Status: models.MailStatusSent})
+	messageID := "<" + faker.UUID().V4() + "@smtp-relay.mailin.fr>"
+	db.Exec(`UPDATE mail_queue SET provider_message_id = ?, to_address = ? WHERE id = ?`, messageID, *user.Email, mail.ID)
+	t.Cleanup(func() {
+		models.MailSuppressionRemove(db, *user.Email)
+	})
+
+	postEvent := func(t *testing.T, event, token string) int {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/mail/webhook", &gin.H{
+			"event":      event,
+			"email":      *user.Email,
+			"message-id": messageID,
+		}, token)
+		controllers.MailWebhook(c)
+		return resultFunc().Response.StatusCode
+	}
+
+	t.Run("Invalid token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, postEvent(t, "hard_bounce", "invalid"))
+		assert.False(t, models.MailSuppressionExists(db, *user.Email))
+	})
+
+	t.Run("Blocked by the provider", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, postEvent(t, "blocked", webhookToken))
+		assert.False(t, models.MailSuppressionExists(db, *user.Email), "the address itself may still be valid")
+
+		found := &models.Mail{}
+		db.Raw(`SELECT * FROM mail_queue WHERE id = ?`, mail.ID).Scan(found)
+		assert.Equal(t, models.MailDeliveryStatusBlocked, found.DeliveryStatus)
+
+		updatedUser, err := models.UserGetByUID(db, user.UID, false)
+		assert.NoError(t, err)
+		assert.Nil(t, updatedUser.EmailBouncedAt)
+	})
+
+	t.Run("Hard bounce", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, postEvent(t, "hard_bounce", webhookToken))
+		assert.True(t, models.MailSuppressionExists(db, *user.Email))
+
+		found := &models.Mail{}
+		db.Raw(`SELECT * FROM mail_queue WHERE id = ?`, mail.ID).Scan(found)
+		assert.Equal(t, models.MailDeliveryStatusHardBounce, found.DeliveryStatus)
+
+		updatedUser, err := models.UserGetByUID(db, user.UID, false)
+		assert.NoError(t, err)
+		assert.NotNil(t, updatedUser.EmailBouncedAt)
+	})
+
+	t.Run("Suppressed address is not sent to", func(t *testing.T) {
+		m := app.MailCreate()
+		m.ToAddress = *user.Email
+		m.Subject = "Suppressed"
+		err := app.MailSend(db, m)
+		assert.ErrorIs(t, err, app.ErrMailSuppressed)
+		db.Exec(`DELETE FROM mail_queue WHERE id = ?`, m.ID)
+	})
+
+	t.Run("Delivered", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, postEvent(t, "delivered", webhookToken))
+		assert.False(t, models.MailSuppressionExists(db, *user.Email))
+
+		updatedUser, err := models.UserGetByUID(db, user.UID, false)
+		assert.NoError(t, err)
+		assert.Nil(t, updatedUser.EmailBouncedAt)
+	})
+}
@@ -0,0 +1,65 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	chain, _, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	otherChain, _, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+
+	update := func(t *testing.T, body gin.H) int {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/user/notification-preferences", &body, token)
+		controllers.NotificationPreferenceUpdate(c)
+		return resultFunc().Response.StatusCode
+	}
+	get := func(t *testing.T, url string) sharedtypes.NotificationPreference {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, token)
+		controllers.NotificationPreferencesGet(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode)
+
+		list := []sharedtypes.NotificationPreference{}
+		json.Unmarshal([]byte(result.Body), &list)
+		assert.Len(t, list, len(models.NotificationKinds))
+		p, _ := lo.Find(list, func(p sharedtypes.NotificationPreference) bool {
+			return p.Kind == models.NotificationKindJoinRequest
+		})
+		return p
+	}
+
+	p := get(t, "/v2/user/notification-preferences")
+	assert.Equal(t, models.NotificationChannelEmail, p.Channel)
+
+	assert.Equal(t, http.StatusBadRequest, update(t, gin.H{"kind": models.NotificationKindPoke, "channel": models.NotificationChannelPush}))
+	assert.Equal(t, http.StatusBadRequest, update(t, gin.H{"kind": "unknown", "channel": models.NotificationChannelNone}))
+	assert.Equal(t, http.StatusUnauthorized, update(t, gin.H{"kind": models.NotificationKindJoinRequest, "channel": models.NotificationChannelNone, "chain_uid": otherChain.UID}))
+
+	assert.Equal(t, http.StatusOK, update(t, gin.H{"kind": models.NotificationKindJoinRequest, "channel": models.NotificationChannelDigest}))
+	assert.Equal(t, http.StatusOK, update(t, gin.H{"kind": models.NotificationKindJoinRequest, "channel": models.NotificationChannelNone, "chain_uid": chain.UID}))
+
+	p = get(t, "/v2/user/notification-preferences")
+	assert.Equal(t, models.NotificationChannelDigest, p.Channel)
+	assert.False(t, p.IsChainOverride)
+
+	p = get(t, "/v2/user/notification-preferences?chain_uid="+chain.UID)
+	assert.Equal(t, models.NotificationChannelNone, p.Channel)
+	assert.True(t, p.IsChainOverride)
+
+	assert.Equal(t, http.StatusOK, update(t, gin.H{"kind": models.NotificationKindJoinRequest, "channel": "", "chain_uid": chain.UID}))
+	p = get(t, "/v2/user/notification-preferences?chain_uid="+chain.UID)
+	assert.Equal(t, models.NotificationChannelDigest, p.Channel)
+	assert.False(t, p.IsChainOverride)
+}
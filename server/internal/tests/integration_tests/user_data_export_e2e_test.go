@@ -0,0 +1,101 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+func TestUserDataExport(t *testing.T) {
+	chain, user, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	bag := mocks.MockBag(t, db, chain.ID, user.ID, mocks.MockBagOptions{})
+	event := mocks.MockEvent(t, db, user.ID, chain.ID)
+
+	t.Run("Request export", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/user/export", &gin.H{
+			"user_uid": user.UID,
+		}, token)
+		controllers.UserDataExport(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+	})
+
+	// the export created by the request runs in the background, create one here to test synchronously
+	export := &models.UserDataExport{
+		UserID: user.ID,
+		Token:  faker.UUID().V4(),
+		Status: models.UserDataExportStatusPending,
+	}
+	db.Create(export)
+	t.Cleanup(func() {
+		exports, _ := models.UserDataExportDeleteByUserID(db, user.ID)
+		services.UserDataExportRemoveFiles(exports)
+	})
+
+	filePath, err := services.UserDataExportCreateFile(db, user, export)
+	assert.NoError(t, err)
+	assert.NoError(t, export.SetReady(db, filePath))
+
+	r, err := zip.OpenReader(filePath)
+	assert.NoError(t, err)
+	defer r.Close()
+
+	fileNames := []string{}
+	for _, f := range r.File {
+		fileNames = append(fileNames, f.Name)
+	}
+	assert.Contains(t, fileNames, "data.json")
+	assert.Contains(t, fileNames, "profile.csv")
+	assert.Contains(t, fileNames, "bags.csv")
+
+	jf, err := r.Open("data.json")
+	assert.NoError(t, err)
+	data := &services.UserDataExportData{}
+	assert.NoError(t, json.NewDecoder(jf).Decode(data))
+	jf.Close()
+
+	assert.Equal(t, user.UID, data.Profile.UID)
+	assert.Equal(t, *user.Email, data.Profile.Email)
+	if assert.Len(t, data.Loops, 1) {
+		assert.Equal(t, chain.UID, data.Loops[0].ChainUID)
+	}
+	if assert.Len(t, data.Bags, 1) {
+		assert.Equal(t, bag.Number, data.Bags[0].Number)
+	}
+	if assert.Len(t, data.Events, 1) {
+		assert.Equal(t, event.UID, data.Events[0].UID)
+	}
+
+	t.Run("Download export", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/user/export/download?token="+export.Token, nil, "")
+		controllers.UserDataExportDownload(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+	})
+
+	t.Run("Download with wrong token", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/user/export/download?token="+faker.UUID().V4(), nil, "")
+		controllers.UserDataExportDownload(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusNotFound, result.Response.StatusCode, result.Body)
+	})
+
+	t.Run("Expired exports are removed", func(t *testing.T) {
+		db.Exec(`UPDATE user_data_exports SET expires_at = (NOW() - INTERVAL 1 HOUR) WHERE id = ?`, export.ID)
+		services.UserDataExportDeleteOld(db)
+
+		_, err := os.Stat(filePath)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,107 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+func TestUserEmailChange(t *testing.T) {
+	_, user, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	oldEmail := *user.Email
+	newEmail := "new-" + faker.Internet().Email()
+
+	db.Create(&models.Newsletter{Email: oldEmail, Name: user.Name, Verified: true})
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM newsletters WHERE email IN ?`, []string{oldEmail, newEmail})
+	})
+
+	t.Run("Request email change", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/user/email-change", &gin.H{
+			"user_uid":  user.UID,
+			"new_email": newEmail,
+		}, token)
+		controllers.UserEmailChange(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+	})
+
+	emailChange := &models.UserEmailChange{}
+	db.Raw(`SELECT * FROM user_email_changes WHERE user_id = ? LIMIT 1`, user.ID).Scan(emailChange)
+	assert.Equal(t, newEmail, emailChange.NewEmail)
+
+	t.Run("Verify with wrong token", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/user/email-change/verify", &gin.H{
+			"user_uid": user.UID,
+			"otp":      "wrong",
+		}, token)
+		controllers.UserEmailChangeVerify(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusUnauthorized, result.Response.StatusCode, result.Body)
+	})
+
+	t.Run("Verify email change", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/user/email-change/verify", &gin.H{
+			"user_uid": user.UID,
+			"otp":      emailChange.Token,
+		}, token)
+		controllers.UserEmailChangeVerify(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+	})
+
+	updatedUser, err := models.UserGetByUID(db, user.UID, true)
+	assert.NoError(t, err)
+	assert.Equal(t, newEmail, *updatedUser.Email)
+	assert.Equal(t, user.JwtTokenPepper+1, updatedUser.JwtTokenPepper)
+
+	countNewsletters := 0
+	db.Raw(`SELECT COUNT(*) FROM newsletters WHERE email = ?`, newEmail).Scan(&countNewsletters)
+	assert.Equal(t, 1, countNewsletters)
+
+	t.Run("Old session is invalid", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/user?user_uid="+user.UID, nil, token)
+		controllers.UserGet(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusUnauthorized, result.Response.StatusCode, result.Body)
+	})
+
+	t.Run("Token is invalid after too many wrong attempts", func(t *testing.T) {
+		newToken, err := auth.JwtGenerate(updatedUser)
+		assert.NoError(t, err)
+		otherEmail := "other-" + faker.Internet().Email()
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/user/email-change", &gin.H{
+			"user_uid":  user.UID,
+			"new_email": otherEmail,
+		}, newToken)
+		controllers.UserEmailChange(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+		emailChange := &models.UserEmailChange{}
+		db.Raw(`SELECT * FROM user_email_changes WHERE user_id = ? LIMIT 1`, user.ID).Scan(emailChange)
+		verify := func(otp string) int {
+			c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/user/email-change/verify", &gin.H{
+				"user_uid": user.UID,
+				"otp":      otp,
+			}, newToken)
+			controllers.UserEmailChangeVerify(c)
+			return resultFunc().Response.StatusCode
+		}
+		for range models.UserEmailChangeMaxAttempts {
+			assert.Equal(t, http.StatusUnauthorized, verify("wrong"))
+		}
+		assert.Equal(t, http.StatusUnauthorized, verify(emailChange.Token))
+
+		unchangedUser, err := models.UserGetByUID(db, user.UID, true)
+		assert.NoError(t, err)
+		assert.Equal(t, newEmail, *unchangedUser.Email)
+	})
+}
@@ -0,0 +1,89 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestUserNotificationInbox(t *testing.T) {
+	chain, user, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	_, otherUser, otherToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+
+	for i := 1; i <= 3; i++ {
+		err := views.NotificationSend(db, models.NotificationKindBagAssigned, chain.ID, []string{user.UID}, gin.H{
+			"BagNumber": fmt.Sprintf("Bag %d", i),
+			"ChainName": chain.Name,
+		})
+		assert.NoError(t, err)
+	}
+	views.NotificationInboxAdd(db, models.UserNotificationKindChainApproved, chain.ID, []string{otherUser.UID}, gin.H{
+		"ChainName": chain.Name,
+	})
+
+	list := func(t *testing.T, url, token string) sharedtypes.UserNotificationListResponse {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, token)
+		controllers.UserNotificationGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode)
+
+		res := sharedtypes.UserNotificationListResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		return res
+	}
+
+	res := list(t, "/v2/user/notification/all?page_size=2", token)
+	assert.Equal(t, 3, res.Unread)
+	if assert.Len(t, res.Notifications, 2) {
+		assert.Equal(t, models.NotificationKindBagAssigned, res.Notifications[0].Kind)
+		assert.Equal(t, "Bag 3 in "+chain.Name, res.Notifications[0].Content, "newest first")
+		assert.Equal(t, chain.UID, *res.Notifications[0].ChainUID)
+	}
+	res = list(t, "/v2/user/notification/all?page_size=2&page=1", token)
+	assert.Len(t, res.Notifications, 1)
+	oldestID := res.Notifications[0].ID
+
+	otherRes := list(t, "/v2/user/notification/all", otherToken)
+	assert.Len(t, otherRes.Notifications, 1)
+
+	t.Run("mark read", func(t *testing.T) {
+		// the notification of another user is ignored
+		body := gin.H{"ids": []uint{oldestID, otherRes.Notifications[0].ID}}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/user/notification/read", &body, token)
+		controllers.UserNotificationMarkRead(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+		assert.Equal(t, 2, list(t, "/v2/user/notification/all", token).Unread)
+		assert.Equal(t, 1, list(t, "/v2/user/notification/all", otherToken).Unread)
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPatch, "/v2/user/notification/read-all", nil, token)
+		controllers.UserNotificationMarkAllRead(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+		assert.Equal(t, 0, list(t, "/v2/user/notification/all", token).Unread)
+	})
+
+	t.Run("badge count in UserGet", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/user?add_notification=true&user_uid="+otherUser.UID, nil, otherToken)
+		controllers.UserGet(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode)
+
+		u := sharedtypes.User{}
+		json.Unmarshal([]byte(result.Body), &u)
+		if assert.NotNil(t, u.NotificationUnread) {
+			assert.Equal(t, 1, *u.NotificationUnread)
+		}
+	})
+}
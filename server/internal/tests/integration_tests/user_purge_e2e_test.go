@@ -0,0 +1,93 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+func TestUserPurgeGracePeriod(t *testing.T) {
+	chain, host, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	user, token := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+
+	requestPurge := func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodDelete, "/v2/user/purge?user_uid="+user.UID+"&rfl=1", nil, token)
+		controllers.UserPurge(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+	}
+
+	t.Run("Schedule deletion", requestPurge)
+
+	schedule := &models.UserPurgeSchedule{}
+	db.Raw(`SELECT * FROM user_purge_schedules WHERE user_id = ? LIMIT 1`, user.ID).Scan(schedule)
+	assert.NotZero(t, schedule.ID)
+	assert.Equal(t, []string{"1"}, schedule.ReasonsForLeaving)
+
+	t.Run("User is hidden from the loop", func(t *testing.T) {
+		users, err := models.UserGetAllUsersByChain(db, chain.ID)
+		assert.NoError(t, err)
+		uids := []string{}
+		for _, u := range users {
+			uids = append(uids, u.UID)
+		}
+		assert.Contains(t, uids, host.UID)
+		assert.NotContains(t, uids, user.UID)
+	})
+
+	t.Run("Cancel deletion", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/user/purge/cancel", &gin.H{"token": schedule.Token}, "")
+		controllers.UserPurgeCancel(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		updatedUser, err := models.UserGetByUID(db, user.UID, false)
+		assert.NoError(t, err)
+		assert.Nil(t, updatedUser.PurgeAt)
+	})
+
+	t.Run("Schedule deletion again", requestPurge)
+
+	t.Run("Cron deletes the account after the grace period", func(t *testing.T) {
+		db.Exec(`UPDATE user_purge_schedules SET purge_at = (NOW() - INTERVAL 1 HOUR) WHERE user_id = ?`, user.ID)
+		services.UserPurgeScheduledRun(db)
+
+		_, err := models.UserGetByUID(db, user.UID, false)
+		assert.Error(t, err)
+
+		countDeletedUsers := 0
+		db.Raw(`SELECT COUNT(*) FROM deleted_users WHERE email = ? AND is_moved = TRUE`, *user.Email).Scan(&countDeletedUsers)
+		assert.Equal(t, 1, countDeletedUsers)
+	})
+}
+
+func TestUserPurgeScheduledLastHost(t *testing.T) {
+	chain, host, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	participant, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+
+	_, err := services.UserPurgeSchedule(db, host, []string{"1"}, "", uuid.NewV4().String())
+	assert.NoError(t, err)
+	// a co-host left the loop during the grace period
+	db.Exec(`UPDATE user_purge_schedules SET purge_at = (NOW() - INTERVAL 1 HOUR) WHERE user_id = ?`, host.ID)
+	services.UserPurgeScheduledRun(db)
+
+	updatedHost, err := models.UserGetByUID(db, host.UID, false)
+	if assert.NoError(t, err, "the last host of a loop with participants is not deleted") {
+		assert.Nil(t, updatedHost.PurgeAt, "the deletion is cancelled instead of retried every run")
+	}
+	countSchedules := -1
+	db.Raw(`SELECT COUNT(*) FROM user_purge_schedules WHERE user_id = ?`, host.ID).Scan(&countSchedules)
+	assert.Zero(t, countSchedules)
+
+	_, err = models.UserGetByUID(db, participant.UID, false)
+	assert.NoError(t, err)
+}
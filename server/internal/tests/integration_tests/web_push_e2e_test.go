@@ -0,0 +1,118 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/pkg/webpush"
+)
+
+func mockWebPushSubscriptionBody(t *testing.T, endpoint string) gin.H {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return gin.H{
+		"endpoint": endpoint,
+		"p256dh":   base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		"auth":     base64.RawURLEncoding.EncodeToString(auth),
+	}
+}
+
+func TestWebPushExpiredSubscriptionIsRemoved(t *testing.T) {
+	pub, priv, _ := webpush.GenerateVAPIDKeys()
+	app.Config.WEB_PUSH_VAPID_PUBLIC_KEY = pub
+	app.Config.WEB_PUSH_VAPID_PRIVATE_KEY = priv
+	app.Config.WEB_PUSH_SUBJECT = "mailto:test@example.com"
+	app.PushInit()
+	defer func() {
+		app.Config.WEB_PUSH_VAPID_PUBLIC_KEY = ""
+		app.Config.WEB_PUSH_VAPID_PRIVATE_KEY = ""
+		app.PushInit()
+	}()
+
+	c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/user/web-push-subscription/public-key", nil, "")
+	controllers.WebPushPublicKey(c)
+	assert.Contains(t, resultFunc().Body, pub)
+
+	received := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+	// the pusher uses the default transport, which must trust the test server
+	hosts, transport := webpush.PushServiceHosts, http.DefaultTransport
+	webpush.PushServiceHosts = append([]string{"127.0.0.1"}, hosts...)
+	http.DefaultTransport = server.Client().Transport
+	defer func() {
+		webpush.PushServiceHosts, http.DefaultTransport = hosts, transport
+	}()
+
+	chain, user, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	endpoint := server.URL + "/push/" + user.UID
+	body := mockWebPushSubscriptionBody(t, endpoint)
+
+	c, resultFunc = mocks.MockGinContext(db, http.MethodPut, "/v2/user/web-push-subscription", &body, token)
+	controllers.WebPushSubscriptionPut(c)
+	assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+	subs, err := models.UserWebPushSubscriptionGetByUserUIDs(db, []string{user.UID})
+	assert.NoError(t, err)
+	assert.Len(t, subs, 1)
+
+	err = app.PushCreateNotification(db, models.NotificationKindBagAssigned, chain.ID, []string{user.UID}, app.PushNotification{
+		Title: "A bag has been assigned to you",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, received)
+
+	subs, err = models.UserWebPushSubscriptionGetByUserUIDs(db, []string{user.UID})
+	assert.NoError(t, err)
+	assert.Empty(t, subs, "the push service responded with 410 Gone")
+}
+
+func TestWebPushSubscriptionDelete(t *testing.T) {
+	_, user, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	endpoint := "https://fcm.googleapis.com/fcm/send/" + user.UID
+	body := mockWebPushSubscriptionBody(t, endpoint)
+
+	c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/user/web-push-subscription", &body, token)
+	controllers.WebPushSubscriptionPut(c)
+	assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+	invalid := gin.H{"endpoint": endpoint, "p256dh": "invalid", "auth": body["auth"]}
+	c, resultFunc = mocks.MockGinContext(db, http.MethodPut, "/v2/user/web-push-subscription", &invalid, token)
+	controllers.WebPushSubscriptionPut(c)
+	assert.Equal(t, http.StatusBadRequest, resultFunc().Response.StatusCode)
+
+	for _, internal := range []string{"http://fcm.googleapis.com/fcm/send/" + user.UID, "https://127.0.0.1/push/" + user.UID} {
+		internalBody := mockWebPushSubscriptionBody(t, internal)
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPut, "/v2/user/web-push-subscription", &internalBody, token)
+		controllers.WebPushSubscriptionPut(c)
+		assert.Equal(t, http.StatusBadRequest, resultFunc().Response.StatusCode, internal)
+	}
+
+	deleteBody := gin.H{"endpoint": endpoint}
+	c, resultFunc = mocks.MockGinContext(db, http.MethodDelete, "/v2/user/web-push-subscription", &deleteBody, token)
+	controllers.WebPushSubscriptionDelete(c)
+	assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+	subs, err := models.UserWebPushSubscriptionGetByUserUIDs(db, []string{user.UID})
+	assert.NoError(t, err)
+	assert.Empty(t, subs)
+}
@@ -0,0 +1,136 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestWishPutAndRemove(t *testing.T) {
+	chain, _, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	wisher, wisherToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	_, otherToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+
+	put := func(t *testing.T, token string, body gin.H) (int, uint) {
+		body["chain_uid"] = chain.UID
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/wish", &body, token)
+		controllers.WishPut(c)
+		result := resultFunc()
+		res := struct {
+			ID uint `json:"id"`
+		}{}
+		json.Unmarshal([]byte(result.Body), &res)
+		return result.Response.StatusCode, res.ID
+	}
+	remove := func(t *testing.T, token string, id uint) int {
+		url := fmt.Sprintf("/v2/wish?chain_uid=%s&id=%d", chain.UID, id)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodDelete, url, nil, token)
+		controllers.WishRemove(c)
+		return resultFunc().Response.StatusCode
+	}
+	getAll := func(t *testing.T, includeFulfilled bool) []sharedtypes.Wish {
+		url := fmt.Sprintf("/v2/wish/all?chain_uid=%s&include_fulfilled=%t", chain.UID, includeFulfilled)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, otherToken)
+		controllers.WishGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode)
+		wishes := []sharedtypes.Wish{}
+		json.Unmarshal([]byte(result.Body), &wishes)
+		return wishes
+	}
+
+	status, _ := put(t, wisherToken, gin.H{"message": "Without title"})
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = put(t, wisherToken, gin.H{"title": "Winter coat", "category": "cars"})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, id := put(t, wisherToken, gin.H{"title": "Winter coat", "sizes": []string{"4"}})
+	if !assert.Equal(t, http.StatusOK, status) || !assert.NotZero(t, id) {
+		return
+	}
+	wishes := getAll(t, false)
+	if assert.Len(t, wishes, 1) {
+		assert.Equal(t, "Winter coat", wishes[0].Title)
+		assert.Equal(t, wisher.UID, wishes[0].UserUID)
+	}
+
+	status, _ = put(t, otherToken, gin.H{"id": id, "title": "Summer coat"})
+	assert.Equal(t, http.StatusForbidden, status, "only your own wishes can be changed")
+
+	status, _ = put(t, wisherToken, gin.H{"id": id, "fulfilled": true})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, getAll(t, false))
+	assert.Len(t, getAll(t, true), 1)
+
+	assert.Equal(t, http.StatusForbidden, remove(t, otherToken, id))
+	assert.Equal(t, http.StatusOK, remove(t, hostToken, id), "hosts can remove any wish")
+	assert.Equal(t, http.StatusNotFound, remove(t, wisherToken, id))
+}
+
+func TestWishMatch(t *testing.T) {
+	chain, author, authorToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	wisher, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	categoryWisher, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	pending, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsNotApproved: true})
+	_ = db.Create(&models.Wish{ChainID: chain.ID, UserID: wisher.ID, Title: "Kids bike"}).Error
+	_ = db.Create(&models.Wish{ChainID: chain.ID, UserID: categoryWisher.ID, Title: "Helmet", Category: sharedtypes.BulkyItemCategoryKids}).Error
+	_ = db.Create(&models.Wish{ChainID: chain.ID, UserID: pending.ID, Title: "Kids bike"}).Error
+	_ = db.Create(&models.Wish{ChainID: chain.ID, UserID: author.ID, Title: "Kids bike"}).Error
+
+	countMatches := func(t *testing.T, userID uint) int {
+		notifications, err := models.UserNotificationGetAll(db, userID, 0, models.UserNotificationPageSize)
+		assert.NoError(t, err)
+		n := 0
+		for _, notification := range notifications {
+			if notification.Kind == models.NotificationKindWishMatch {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("bulky item", func(t *testing.T) {
+		body := gin.H{"chain_uid": chain.UID, "user_uid": author.UID, "title": "Bicycle for kids", "tags": []string{"bikes"}, "category": sharedtypes.BulkyItemCategoryKids}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/bulky-item", &body, authorToken)
+		controllers.BulkyPut(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+		assert.Equal(t, 1, countMatches(t, wisher.ID))
+		assert.Equal(t, 0, countMatches(t, author.ID), "the author is not notified of their own item")
+		assert.Equal(t, 0, countMatches(t, pending.ID), "members waiting for approval are not notified")
+	})
+
+	t.Run("bag note", func(t *testing.T) {
+		ucID := uint(0)
+		db.Raw(`SELECT id FROM user_chains WHERE user_id = ? AND chain_id = ?`, author.ID, chain.ID).Scan(&ucID)
+		bag := &models.Bag{Number: "Bag 1", Color: "#ff0000", UserChainID: ucID}
+		if !assert.NoError(t, db.Create(bag).Error) {
+			return
+		}
+
+		body := gin.H{"chain_uid": chain.UID, "user_uid": author.UID, "holder_uid": author.UID, "bag_id": bag.ID, "note": "Kids clothes and a bike helmet"}
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/bag", &body, authorToken)
+		controllers.BagPut(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+		assert.Equal(t, 2, countMatches(t, wisher.ID))
+		assert.Equal(t, 1, countMatches(t, categoryWisher.ID), "wishes with a category match bag notes")
+		assert.Equal(t, 0, countMatches(t, pending.ID))
+
+		// an unchanged note is not matched again
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPut, "/v2/bag", &body, authorToken)
+		controllers.BagPut(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+		assert.Equal(t, 2, countMatches(t, wisher.ID))
+	})
+}
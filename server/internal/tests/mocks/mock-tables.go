@@ -31,6 +31,8 @@ type MockChainAndUserOptions struct {
 	IsNotApproved      bool
 	IsRootAdmin        bool
 	IsChainAdmin       bool
+	IsChainWarden      bool
+	ChainRole          string
 	IsNotPublished     bool
 	IsOpenToNewMembers bool
 	IsPausedLoopOnly   bool
@@ -52,7 +54,10 @@ type MockMailOptions struct {
 	CreatedAt        time.Time
 	IsErr            bool
 	MaxRetryAttempts int
-	NextRetryAttempt int
+	Status           string
+	Attempts         int
+	NextAttemptAt    time.Time
+	LockedUntil      *time.Time
 }
 type MockBagOptions struct {
 	BagNameOverride string
@@ -78,11 +83,13 @@ func MockUser(t *testing.T, db *gorm.DB, chainID uint, o MockChainAndUserOptions
 	chains := []sharedtypes.UserChain{}
 	if chainID != 0 {
 		chains = append(chains, sharedtypes.UserChain{
-			ChainID:      chainID,
-			IsChainAdmin: o.IsChainAdmin,
-			IsApproved:   !o.IsNotApproved,
-			RouteOrder:   o.RouteOrderIndex,
-			IsPaused:     o.IsPausedLoopOnly,
+			ChainID:       chainID,
+			IsChainAdmin:  o.IsChainAdmin,
+			IsChainWarden: o.IsChainWarden,
+			Role:          o.ChainRole,
+			IsApproved:    !o.IsNotApproved,
+			RouteOrder:    o.RouteOrderIndex,
+			IsPaused:      o.IsPausedLoopOnly,
 		})
 	}
 
@@ -191,12 +198,14 @@ func MockChainAndUser(t *testing.T, db *gorm.DB, o MockChainAndUserOptions) (cha
 
 func MockAddUserToChain(t *testing.T, db *gorm.DB, chainID uint, user *models.User, o MockChainAndUserOptions) *sharedtypes.UserChain {
 	uc := &sharedtypes.UserChain{
-		UserID:       user.ID,
-		ChainID:      chainID,
-		IsChainAdmin: o.IsChainAdmin,
-		IsApproved:   !o.IsNotApproved,
-		RouteOrder:   o.RouteOrderIndex,
-		IsPaused:     o.IsPausedLoopOnly,
+		UserID:        user.ID,
+		ChainID:       chainID,
+		IsChainAdmin:  o.IsChainAdmin,
+		IsChainWarden: o.IsChainWarden,
+		Role:          o.ChainRole,
+		IsApproved:    !o.IsNotApproved,
+		RouteOrder:    o.RouteOrderIndex,
+		IsPaused:      o.IsPausedLoopOnly,
 	}
 	err := db.Create(uc).Error
 	if err != nil {
@@ -303,7 +312,16 @@ func MockMail(t *testing.T, db *gorm.DB, o MockMailOptions) (mail *models.Mail)
 		Subject:          faker.Lorem().Sentence(5),
 		Body:             template.HTMLEscapeString(faker.Lorem().Paragraph(3)),
 		MaxRetryAttempts: o.MaxRetryAttempts,
-		NextRetryAttempt: o.NextRetryAttempt,
+		Status:           o.Status,
+		Attempts:         o.Attempts,
+		NextAttemptAt:    o.NextAttemptAt,
+		LockedUntil:      o.LockedUntil,
+	}
+	if mail.Status == "" {
+		mail.Status = models.MailStatusQueued
+	}
+	if mail.NextAttemptAt.IsZero() {
+		mail.NextAttemptAt = time.Now()
 	}
 	if o.IsErr {
 		mail.Err = null.NewString("FakeError: Invalid "+faker.Pet().Cat(), true)
@@ -318,7 +336,7 @@ func MockMail(t *testing.T, db *gorm.DB, o MockMailOptions) (mail *models.Mail)
 	}
 
 	t.Cleanup(func() {
-		db.Exec(`DELETE FROM mail_retries WHERE id = ?`, mail.ID)
+		db.Exec(`DELETE FROM mail_queue WHERE id = ?`, mail.ID)
 	})
 
 	return mail
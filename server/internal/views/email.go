@@ -9,10 +9,12 @@ import (
 	"html/template"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/samber/lo"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/pkg/htmltotext"
 	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
@@ -36,6 +38,8 @@ type EmailLayoutData struct {
 	Subject string
 	Body    template.HTML
 	BaseURL string
+	// Only set on notification emails the receiver can unsubscribe from
+	UnsubscribeURL string
 
 	I18nMuchLoveComma       string
 	I18nTheClothingLoopTeam string
@@ -43,6 +47,7 @@ type EmailLayoutData struct {
 	I18nDonate              string
 	I18nAboutUs             string
 	I18nFAQ                 string
+	I18nUnsubscribe         string
 }
 
 //go:embed emails
@@ -94,7 +99,7 @@ func getI18n(i18n string) string {
 	return i18n
 }
 
-// Adds subject and body to message
+// Adds subject, body and the plain text version of the body to message
 func emailGenerateMessage(m *models.Mail, lng, templateName string, data any, subjectValues ...any) error {
 	// subject
 	var subject string
@@ -113,6 +118,11 @@ func emailGenerateMessage(m *models.Mail, lng, templateName string, data any, su
 		}
 	}
 
+	unsubscribeURL := ""
+	if m.Category != "" {
+		unsubscribeURL = app.MailUnsubscribeURL(m.ToAddress, m.Category)
+	}
+
 	// layout
 	{
 		buf := new(bytes.Buffer)
@@ -123,12 +133,14 @@ func emailGenerateMessage(m *models.Mail, lng, templateName string, data any, su
 			Subject:                 subject,
 			Body:                    template.HTML(bodyBuffer.String()),
 			BaseURL:                 baseURL,
+			UnsubscribeURL:          unsubscribeURL,
 			I18nMuchLoveComma:       emailsTranslations[lng]["layout_much_love_comma"],
 			I18nTheClothingLoopTeam: emailsTranslations[lng]["layout_the_clothing_loop_team"],
 			I18nEvents:              emailsTranslations[lng]["layout_events"],
 			I18nDonate:              emailsTranslations[lng]["layout_donate"],
 			I18nAboutUs:             emailsTranslations[lng]["layout_about_us"],
 			I18nFAQ:                 emailsTranslations[lng]["layout_faq"],
+			I18nUnsubscribe:         emailsTranslations[lng]["layout_unsubscribe"],
 		})
 		if err != nil {
 			return err
@@ -136,6 +148,16 @@ func emailGenerateMessage(m *models.Mail, lng, templateName string, data any, su
 		m.Body = buf.String()
 	}
 
+	// plain text, only the body and greeting of the layout are relevant here
+	m.BodyText = fmt.Sprintf("%s\n\n%s\n%s",
+		htmltotext.Convert(bodyBuffer.String()),
+		emailsTranslations[lng]["layout_much_love_comma"],
+		emailsTranslations[lng]["layout_the_clothing_loop_team"],
+	)
+	if unsubscribeURL != "" {
+		m.BodyText += fmt.Sprintf("\n\n%s: %s", emailsTranslations[lng]["layout_unsubscribe"], unsubscribeURL)
+	}
+
 	return nil
 }
 
@@ -159,6 +181,52 @@ func EmailAccountDeletedSuccessfully(db *gorm.DB, lng,
 	return app.MailSend(db, m)
 }
 
+// Sent when the user requests to delete their account, the account is deleted after the grace period
+func EmailAccountDeletionScheduled(db *gorm.DB, lng,
+	name,
+	email string,
+	purgeAt time.Time,
+	cancelLink string,
+) error {
+	lng = getI18n(lng)
+
+	m := app.MailCreate()
+	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.ToName = name
+	m.ToAddress = email
+	err := emailGenerateMessage(m, lng, "account_deletion_scheduled", gin.H{
+		"Name":       name,
+		"PurgeAt":    purgeAt.Format(time.DateOnly),
+		"CancelLink": cancelLink,
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
+// Sent when the scheduled deletion is cancelled because the user is the last host of a loop with participants
+func EmailAccountDeletionCancelled(db *gorm.DB, lng,
+	name,
+	email string,
+) error {
+	lng = getI18n(lng)
+
+	m := app.MailCreate()
+	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.ToName = name
+	m.ToAddress = email
+	err := emailGenerateMessage(m, lng, "account_deletion_cancelled", gin.H{
+		"Name": name,
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
 func EmailAnAdminApprovedYourJoinRequest(db *gorm.DB, lng,
 	name,
 	email,
@@ -219,6 +287,7 @@ func EmailApproveReminder(db *gorm.DB, lng,
 	lng = getI18n(lng)
 	m := app.MailCreate()
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.Category = models.NotificationKindApproveReminder
 	m.ToName = name
 	m.ToAddress = email
 	err := emailGenerateMessage(m, lng, "approve_reminder", gin.H{
@@ -233,6 +302,76 @@ func EmailApproveReminder(db *gorm.DB, lng,
 	return app.MailSend(db, m)
 }
 
+func EmailChangeVerification(db *gorm.DB, lng,
+	name,
+	newEmail,
+	token string,
+) error {
+	lng = getI18n(lng)
+
+	m := app.MailCreate()
+	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.IgnoreSuppression = true
+	m.ToName = name
+	m.ToAddress = newEmail
+	err := emailGenerateMessage(m, lng, "email_change_verification", gin.H{
+		"Name":     name,
+		"NewEmail": newEmail,
+		"Token":    token,
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
+// Sent to the old email address after the email address is changed
+func EmailChanged(db *gorm.DB, lng,
+	name,
+	oldEmail,
+	newEmail string,
+) error {
+	lng = getI18n(lng)
+
+	m := app.MailCreate()
+	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.ToName = name
+	m.ToAddress = oldEmail
+	err := emailGenerateMessage(m, lng, "email_changed", gin.H{
+		"Name":     name,
+		"NewEmail": newEmail,
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
+// Sent when the export of all data of a user is ready to be downloaded
+func EmailUserDataExportReady(db *gorm.DB, lng,
+	name,
+	email,
+	link string,
+) error {
+	lng = getI18n(lng)
+
+	m := app.MailCreate()
+	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.ToName = name
+	m.ToAddress = email
+	err := emailGenerateMessage(m, lng, "user_data_export_ready", gin.H{
+		"Name": name,
+		"Link": link,
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
 func EmailContactConfirmation(c *gin.Context, db *gorm.DB,
 	name,
 	email,
@@ -261,6 +400,8 @@ func EmailContactReceived(db *gorm.DB,
 	m := app.MailCreate()
 	m.ToName = "The Clothing Loop"
 	m.ToAddress = app.Config.SMTP_SENDER
+	m.ReplyToName = name
+	m.ReplyToAddress = email
 	err := emailGenerateMessage(m, "en", "contact_received", gin.H{
 		"Name":    name,
 		"Email":   email,
@@ -273,7 +414,7 @@ func EmailContactReceived(db *gorm.DB,
 	return app.MailSend(db, m)
 }
 
-func EmailDoYouWantToBeHost(db *gorm.DB, lng,
+func EmailDoYouWantToBeHost(db *gorm.DB, chainID uint, lng,
 	name,
 	email,
 	chainName string,
@@ -281,6 +422,8 @@ func EmailDoYouWantToBeHost(db *gorm.DB, lng,
 	lng = getI18n(lng)
 	m := app.MailCreate()
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.Category = models.NotificationKindHostRecruitment
+	m.ChainID = chainID
 	m.ToName = name
 	m.ToAddress = email
 	err := emailGenerateMessage(m, lng, "do_you_want_to_be_host", gin.H{
@@ -295,7 +438,7 @@ func EmailDoYouWantToBeHost(db *gorm.DB, lng,
 	return app.MailSend(db, m)
 }
 
-func EmailIsYourLoopStillActive(db *gorm.DB, lng,
+func EmailIsYourLoopStillActive(db *gorm.DB, chainID uint, lng,
 	name,
 	email,
 	chainName,
@@ -304,6 +447,8 @@ func EmailIsYourLoopStillActive(db *gorm.DB, lng,
 	lng = getI18n(lng)
 	m := app.MailCreate()
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.Category = models.NotificationKindLoopInactive
+	m.ChainID = chainID
 	m.ToName = name
 	m.ToAddress = email
 	err := emailGenerateMessage(m, lng, "is_your_loop_still_active", gin.H{
@@ -327,6 +472,7 @@ func EmailLoginVerification(c *gin.Context, db *gorm.DB,
 ) error {
 	i18n := getI18nGin(c)
 	m := app.MailCreate()
+	m.IgnoreSuppression = true
 	m.ToName = name
 	m.ToAddress = email
 
@@ -376,7 +522,7 @@ func EmailLoopIsDeleted(db *gorm.DB, lng,
 	return app.MailSend(db, m)
 }
 
-func EmailPoke(db *gorm.DB, lng,
+func EmailPoke(db *gorm.DB, chainID uint, lng,
 	name,
 	email,
 	participantName,
@@ -385,6 +531,8 @@ func EmailPoke(db *gorm.DB, lng,
 	lng = getI18n(lng)
 	m := app.MailCreate()
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.Category = models.NotificationKindPoke
+	m.ChainID = chainID
 	m.ToName = name
 	m.ToAddress = email
 	err := emailGenerateMessage(m, lng, "poke", gin.H{
@@ -408,6 +556,7 @@ func EmailRegisterVerification(c *gin.Context, db *gorm.DB,
 	i18n := getI18nGin(c)
 	m := app.MailCreate()
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.IgnoreSuppression = true
 	m.ToName = name
 	m.ToAddress = email
 
@@ -428,7 +577,7 @@ func EmailRegisterVerification(c *gin.Context, db *gorm.DB,
 	return app.MailSend(db, m)
 }
 
-func EmailSomeoneIsInterestedInJoiningYourLoop(db *gorm.DB, lng,
+func EmailSomeoneIsInterestedInJoiningYourLoop(db *gorm.DB, chainID uint, lng,
 	adminEmail,
 	adminName,
 	chainName,
@@ -442,6 +591,8 @@ func EmailSomeoneIsInterestedInJoiningYourLoop(db *gorm.DB, lng,
 
 	m := app.MailCreate()
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.Category = models.NotificationKindJoinRequest
+	m.ChainID = chainID
 	m.ToName = adminName
 	m.ToAddress = adminEmail
 
@@ -492,7 +643,7 @@ func EmailSomeoneIsInterestedInJoiningYourLoop(db *gorm.DB, lng,
 	return app.MailSend(db, m)
 }
 
-func EmailSomeoneLeftLoop(db *gorm.DB, lng,
+func EmailSomeoneLeftLoop(db *gorm.DB, chainID uint, lng,
 	name,
 	email,
 	chainName,
@@ -502,6 +653,8 @@ func EmailSomeoneLeftLoop(db *gorm.DB, lng,
 	lng = getI18n(lng)
 	m := app.MailCreate()
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.Category = models.NotificationKindMemberLeft
+	m.ChainID = chainID
 	m.ToName = name
 	m.ToAddress = email
 	err := emailGenerateMessage(m, lng, "someone_left_loop", gin.H{
@@ -516,7 +669,7 @@ func EmailSomeoneLeftLoop(db *gorm.DB, lng,
 	return app.MailSend(db, m)
 }
 
-func EmailSomeoneWaitingToBeAccepted(db *gorm.DB, lng,
+func EmailSomeoneWaitingToBeAccepted(db *gorm.DB, chainID uint, lng,
 	name,
 	email,
 	chainName,
@@ -525,6 +678,8 @@ func EmailSomeoneWaitingToBeAccepted(db *gorm.DB, lng,
 	lng = getI18n(lng)
 	m := app.MailCreate()
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.Category = models.NotificationKindJoinRequest
+	m.ChainID = chainID
 	m.ToName = name
 	m.ToAddress = email
 	err := emailGenerateMessage(m, lng, "someone_waiting_to_be_accepted", gin.H{
@@ -628,3 +783,90 @@ func EmailRootAdminFailedLastRetry(db *gorm.DB, email, subject string) error {
 
 	return app.MailSend(db, m)
 }
+
+type EmailHostDigestParticipant struct {
+	Name      string    `gorm:"name"`
+	CreatedAt time.Time `gorm:"created_at"`
+}
+
+type EmailHostDigestLeave struct {
+	Name string
+	// Translated reasons for leaving
+	Reasons []string
+}
+
+type EmailHostDigestBag struct {
+	Number     string    `gorm:"number"`
+	HolderName string    `gorm:"holder_name"`
+	UpdatedAt  time.Time `gorm:"updated_at"`
+}
+
+type EmailHostDigestEvent struct {
+	Name string    `gorm:"name"`
+	Date time.Time `gorm:"date"`
+}
+
+type EmailHostDigestBulkyItem struct {
+	Title    string `gorm:"title"`
+	UserName string `gorm:"user_name"`
+}
+
+type EmailHostDigestData struct {
+	ChainName        string
+	PendingApprovals []EmailHostDigestParticipant
+	NewMembers       []EmailHostDigestParticipant
+	Leaves           []models.ChainLeave
+	BagsTooOld       []EmailHostDigestBag
+	Events           []EmailHostDigestEvent
+	BulkyItems       []EmailHostDigestBulkyItem
+}
+
+func (d *EmailHostDigestData) IsEmpty() bool {
+	return len(d.PendingApprovals) == 0 && len(d.NewMembers) == 0 && len(d.Leaves) == 0 &&
+		len(d.BagsTooOld) == 0 && len(d.Events) == 0 && len(d.BulkyItems) == 0
+}
+
+func EmailHostDigest(db *gorm.DB, chainID uint, lng,
+	name,
+	email string,
+	data *EmailHostDigestData,
+) error {
+	lng = getI18n(lng)
+
+	leaves := []EmailHostDigestLeave{}
+	for _, l := range data.Leaves {
+		reasons := []string{}
+		for _, r := range l.GetReasons() {
+			if t, ok := emailsTranslations[lng]["reason_for_leaving_"+r]; ok {
+				reasons = append(reasons, t)
+			}
+		}
+		leaves = append(leaves, EmailHostDigestLeave{
+			Name:    l.UserName,
+			Reasons: reasons,
+		})
+	}
+
+	m := app.MailCreate()
+	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.Category = models.NotificationKindHostDigest
+	m.ChainID = chainID
+	m.ToName = name
+	m.ToAddress = email
+	err := emailGenerateMessage(m, lng, "host_digest", gin.H{
+		"Name":             name,
+		"BaseURL":          app.Config.SITE_BASE_URL_FE,
+		"ChainName":        data.ChainName,
+		"PendingApprovals": data.PendingApprovals,
+		"NewMembers":       data.NewMembers,
+		"Leaves":           leaves,
+		"BagsTooOld":       data.BagsTooOld,
+		"Events":           data.Events,
+		"BulkyItems":       data.BulkyItems,
+	}, data.ChainName)
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
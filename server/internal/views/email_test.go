@@ -6,11 +6,13 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/0xch4z/selectr"
 	"github.com/go-playground/validator/v10"
 	Faker "github.com/jaswdr/faker"
 	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/models"
 	BTagChecker "github.com/the-clothing-loop/website/server/pkg/btagchecker"
 )
@@ -34,6 +36,24 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 			DataExpected: []string{"Name"},
 			Args:         []any{},
 		},
+		{
+			Name: "account_deletion_cancelled",
+			Data: map[string]any{
+				"Name": faker.Person().Name(),
+			},
+			DataExpected: []string{"Name"},
+			Args:         []any{},
+		},
+		{
+			Name: "account_deletion_scheduled",
+			Data: map[string]any{
+				"Name":       faker.Person().Name(),
+				"PurgeAt":    "2024-06-01",
+				"CancelLink": "https://" + faker.Internet().Domain() + "/users/purge-cancel?token=" + faker.UUID().V4(),
+			},
+			DataExpected: []string{"Name", "PurgeAt", "CancelLink"},
+			Args:         []any{},
+		},
 		{
 			Name: "an_admin_approved_your_join_request",
 			Data: map[string]any{
@@ -115,6 +135,41 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 			DataExpected: []string{"Name", "ChainName"},
 			Args:         []any{},
 		},
+		{
+			Name: "email_change_verification",
+			Data: map[string]any{
+				"Name":     faker.Person().Name(),
+				"NewEmail": faker.Internet().Email(),
+				"Token":    "12345678",
+			},
+			DataExpected: []string{"Name", "NewEmail", "Token"},
+			Args:         []any{},
+		},
+		{
+			Name: "email_changed",
+			Data: map[string]any{
+				"Name":     faker.Person().Name(),
+				"NewEmail": faker.Internet().Email(),
+			},
+			DataExpected: []string{"Name", "NewEmail"},
+			Args:         []any{},
+		},
+		{
+			Name: "host_digest",
+			Data: map[string]any{
+				"Name":             faker.Person().Name(),
+				"ChainName":        faker.Company().Name(),
+				"BaseURL":          "https://" + faker.Internet().Domain(),
+				"PendingApprovals": []any{map[string]any{"Name": faker.Person().Name(), "CreatedAt": time.Now()}},
+				"NewMembers":       []any{map[string]any{"Name": faker.Person().Name(), "CreatedAt": time.Now()}},
+				"Leaves":           []any{map[string]any{"Name": faker.Person().Name(), "Reasons": []any{"I moved", "Other"}}},
+				"BagsTooOld":       []any{map[string]any{"Number": "Bag 3", "HolderName": faker.Person().Name(), "UpdatedAt": time.Now()}},
+				"Events":           []any{map[string]any{"Name": faker.Company().Name(), "Date": time.Now()}},
+				"BulkyItems":       []any{map[string]any{"Title": faker.Lorem().Word(), "UserName": faker.Person().Name()}},
+			},
+			DataExpected: []string{"Name", "ChainName", "PendingApprovals[0].Name", "NewMembers[0].Name", "Leaves[0].Name", "Leaves[0].Reasons[1]", "BagsTooOld[0].Number", "BagsTooOld[0].HolderName", "Events[0].Name", "BulkyItems[0].Title"},
+			Args:         []any{faker.Company().Name()},
+		},
 		{
 			Name: "is_your_loop_still_active",
 			Data: map[string]any{
@@ -219,6 +274,15 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 			DataExpected: []string{"Name"},
 			Args:         []any{},
 		},
+		{
+			Name: "user_data_export_ready",
+			Data: map[string]any{
+				"Name": faker.Person().Name(),
+				"Link": "https://" + faker.Internet().Domain() + "/v2/user/export/download?token=" + faker.UUID().V4(),
+			},
+			DataExpected: []string{"Name", "Link"},
+			Args:         []any{},
+		},
 		{
 			Name: "you_signed_up_for_loop",
 			Data: map[string]any{
@@ -268,6 +332,9 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 					assert.Nil(t, validate.Var(m.Body, "html"))
 					assert.True(t, BTagChecker.HasValidClosingTags(m.Body), "Check if the tags used within the provided string has any unclosed tags.")
 
+					assert.NotEmpty(t, m.BodyText)
+					assert.NotContains(t, m.BodyText, "</")
+
 					for _, v := range tpl.DataExpected {
 						s, _ := selectr.Parse(v)
 						value, err := s.Resolve(tpl.Data)
@@ -280,6 +347,39 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 	}
 }
 
+func TestEmailHostDigestReasonsTranslated(t *testing.T) {
+	leave := &models.ChainLeave{Reasons: "1,2,3,4,5,6,7,8,9,10,11,12,13,14"}
+	for _, lng := range lang {
+		for _, r := range leave.GetReasons() {
+			assert.NotEmptyf(t, emailsTranslations[lng]["reason_for_leaving_"+r], "lng: %s reason: %s", lng, r)
+		}
+	}
+}
+
+func TestEmailUnsubscribeLink(t *testing.T) {
+	m := &models.Mail{ToAddress: "host@example.com", Category: models.NotificationKindMemberLeft}
+	err := emailGenerateMessage(m, "en", "someone_left_loop", map[string]any{
+		"Name":             "Host",
+		"ParticipantName":  "Anna",
+		"ParticipantEmail": "anna@example.com",
+		"ChainName":        "Loop",
+	})
+	assert.NoError(t, err)
+
+	link := app.MailUnsubscribeURL(m.ToAddress, m.Category)
+	assert.Contains(t, m.Body, template.HTMLEscapeString(link))
+	assert.Contains(t, m.BodyText, link)
+
+	m = &models.Mail{ToAddress: "host@example.com"}
+	err = emailGenerateMessage(m, "en", "login_verification", map[string]any{
+		"Name":    "Host",
+		"BaseURL": "https://example.com",
+		"Token":   "123456",
+	})
+	assert.NoError(t, err)
+	assert.NotContains(t, m.Body, "/v2/mail/unsubscribe")
+}
+
 func TestGetI18n(t *testing.T) {
 	list := []struct {
 		Lng    string
@@ -1,35 +1,149 @@
 package views
 
 import (
-	"github.com/OneSignal/onesignal-go-api"
-)
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"text/template"
+	"unicode/utf8"
 
-const (
-	NotificationEnumTitleNewBulkyCreated = "NOTIFICATION_TITLE_NEW_BULKY_CREATED"
-	NotificationEnumTitleBagTooOld       = "NOTIFICATION_TITLE_BAG_TOO_OLD"
-	NotificationEnumTitleBagAssignedYou  = "NOTIFICATION_TITLE_BAG_ASSIGNED_YOU"
-	NotificationEnumTitleChatMessage     = "NOTIFICATION_TITLE_CHAT_MESSAGE"
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"gorm.io/gorm"
 )
 
-// TODO: Remove this and use json files instead
-var Notifications map[string]*onesignal.StringMap = map[string]*onesignal.StringMap{
-	NotificationEnumTitleNewBulkyCreated: {
-		En: onesignal.PtrString("A new bulky item has been created"),
-		Nl: onesignal.PtrString("Er is een nieuw groot voorwerp aangemaakt"),
-	},
-
-	NotificationEnumTitleBagTooOld: {
-		En: onesignal.PtrString("The bag you are holding has been in your possession for too long"),
-		Nl: onesignal.PtrString("De tas die u vasthoudt, is te lang in uw bezit geweest"),
-	},
-
-	NotificationEnumTitleBagAssignedYou: {
-		En: onesignal.PtrString("A bag has been assigned to you"),
-		Nl: onesignal.PtrString("Er is u een tas toegewezen"),
-	},
-
-	NotificationEnumTitleChatMessage: {
-		En: onesignal.PtrString("You have a message in chat"),
-		Nl: onesignal.PtrString("Je hebt een bericht in de chat"),
-	},
+// Longer contents of push notifications are cut off, to stay within their size limits.
+// The inbox keeps the whole text.
+const notificationContentMaxLength = 60
+
+// Push notification texts by language, loaded from emails/<lng>/notifications.json.
+// Each notification kind has a "<kind>_title" and a "<kind>_content" key,
+// both are text templates with the data passed to NotificationSend.
+var notificationsTranslations = map[string]map[string]string{}
+
+func init() {
+	files, _ := fs.Glob(emailsFS, "emails/*/notifications.json")
+	for _, file := range files {
+		b, err := emailsFS.ReadFile(file)
+		if err != nil {
+			slog.Error("Notification translations not found", "err", err)
+			os.Exit(1)
+			return
+		}
+		var data map[string]string
+		err = json.Unmarshal(b, &data)
+		if err != nil {
+			slog.Error("Notification translation invalid json", "file", file, "err", err)
+			os.Exit(1)
+			return
+		}
+		notificationsTranslations[path.Base(path.Dir(file))] = data
+	}
+}
+
+// Returns the title and content of the notification kind in the language, falls back to English
+func notificationGenerate(lng, kind string, data gin.H) (title, content string, err error) {
+	translations, ok := notificationsTranslations[lng]
+	if !ok {
+		translations = notificationsTranslations["en"]
+	}
+	get := func(key string) string {
+		if v, ok := translations[key]; ok {
+			return v
+		}
+		return notificationsTranslations["en"][key]
+	}
+
+	if get(kind+"_title") == "" {
+		return "", "", fmt.Errorf("No push notification text for kind: %s", kind)
+	}
+
+	title, err = notificationExecute(kind+"_title", get(kind+"_title"), data)
+	if err != nil {
+		return "", "", err
+	}
+	content, err = notificationExecute(kind+"_content", get(kind+"_content"), data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return title, content, nil
+}
+
+// Cuts off the text at max characters including the ellipsis, counting characters instead of bytes
+func notificationTruncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-3]) + "..."
+}
+
+func notificationExecute(name, text string, data gin.H) (string, error) {
+	t, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	err = t.Execute(buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Stores the notification in the inbox of the users and sends it as a push notification in the background,
+// each in their own language
+func NotificationSend(db *gorm.DB, kind string, chainID uint, userUIDs []string, data gin.H) error {
+	return notificationEachLanguage(db, kind, userUIDs, data, func(lng string, uids []string, title, content string) error {
+		err := models.UserNotificationAdd(db, uids, chainID, kind, title, content)
+		if err != nil {
+			slog.Error("Unable to add notification to inbox", "kind", kind, "err", err)
+		}
+
+		app.PushCreateNotificationAsync(db, kind, chainID, uids, app.PushNotification{
+			Title:   title,
+			Content: notificationTruncate(content, notificationContentMaxLength),
+		})
+		return err
+	})
+}
+
+// Only stores the notification in the inbox of the users, for notifications already sent by email
+func NotificationInboxAdd(db *gorm.DB, kind string, chainID uint, userUIDs []string, data gin.H) error {
+	return notificationEachLanguage(db, kind, userUIDs, data, func(lng string, uids []string, title, content string) error {
+		err := models.UserNotificationAdd(db, uids, chainID, kind, title, content)
+		if err != nil {
+			slog.Error("Unable to add notification to inbox", "kind", kind, "err", err)
+		}
+		return err
+	})
+}
+
+// Calls fn for each group of users with the same language, returns the last error of fn
+func notificationEachLanguage(db *gorm.DB, kind string, userUIDs []string, data gin.H, fn func(lng string, uids []string, title, content string) error) error {
+	if len(userUIDs) == 0 {
+		return fmt.Errorf("No users to send a notification to")
+	}
+
+	userUIDsByI18n, err := models.UserUIDsGroupByI18n(db, userUIDs)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for lng, uids := range userUIDsByI18n {
+		title, content, err := notificationGenerate(lng, kind, data)
+		if err != nil {
+			return err
+		}
+		if err := fn(lng, uids, title, content); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
 }
@@ -0,0 +1,106 @@
+package views
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+)
+
+func TestNotificationTranslations(t *testing.T) {
+	assert.GreaterOrEqual(t, len(notificationsTranslations), 17)
+
+	pushKinds := lo.Filter(models.NotificationKinds, func(k models.NotificationKind, _ int) bool {
+		return lo.Contains(k.Channels, models.NotificationChannelPush)
+	})
+	data := gin.H{
+		"BagNumber": "Bag 3",
+		"ChainName": "Loop",
+		"Title":     "Sofa",
+		"Sender":    "anna",
+		"Name":      "Anna",
+	}
+	for lng, translations := range notificationsTranslations {
+		for _, k := range pushKinds {
+			assert.NotEmptyf(t, translations[k.Kind+"_title"], "lng: %s kind: %s", lng, k.Kind)
+			_, ok := translations[k.Kind+"_content"]
+			assert.Truef(t, ok, "lng: %s kind: %s", lng, k.Kind)
+
+			_, content, err := notificationGenerate(lng, k.Kind, data)
+			assert.NoErrorf(t, err, "lng: %s kind: %s", lng, k.Kind)
+			assert.NotContainsf(t, content, "<no value>", "lng: %s kind: %s", lng, k.Kind)
+		}
+		for _, kind := range []string{models.UserNotificationKindChainApproved, models.UserNotificationKindChatReport} {
+			assert.NotEmptyf(t, translations[kind+"_title"], "lng: %s kind: %s", lng, kind)
+		}
+	}
+}
+
+func TestNotificationGenerate(t *testing.T) {
+	title, content, err := notificationGenerate("nl", models.NotificationKindBagAssigned, gin.H{
+		"BagNumber": "Bag 3",
+		"ChainName": "Loop",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Er is een tas aan je toegewezen", title)
+	assert.Equal(t, "Bag 3 in Loop", content)
+
+	title, _, err = notificationGenerate("xx", models.NotificationKindBagAssigned, gin.H{})
+	assert.NoError(t, err)
+	assert.Equal(t, "A bag has been assigned to you", title, "unknown languages fall back to English")
+
+	_, content, err = notificationGenerate("en", models.NotificationKindChatMessage, gin.H{})
+	assert.NoError(t, err)
+	assert.Empty(t, content, "optional values can be left out")
+
+	title, content, err = notificationGenerate("en", models.NotificationKindChatMessage, gin.H{
+		"Channel": "Loop",
+		"Sender":  "Anna",
+		"Preview": "Hi!",
+		"Count":   3,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Loop", title)
+	assert.Equal(t, "(3) Anna: Hi!", content)
+
+	longTitle := "A very long title of a bulky item that goes on and on and on"
+	_, content, err = notificationGenerate("en", models.NotificationKindBulkyItemNew, gin.H{
+		"Title":     longTitle,
+		"ChainName": "Loop",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, content, longTitle, "the inbox keeps the whole text")
+	assert.LessOrEqual(t, utf8.RuneCountInString(notificationTruncate(content, notificationContentMaxLength)), notificationContentMaxLength)
+
+	title, _, err = notificationGenerate("en", models.NotificationKindBagTooOld, gin.H{"BagNumber": "Bag 3", "ChainName": "Loop"})
+	assert.NoError(t, err)
+	assert.Equal(t, notificationsTranslations["en"]["bag_too_old_title"], title, "titles are never cut off")
+
+	title, _, err = notificationGenerate("ja", models.NotificationKindBulkyItemExpiring, gin.H{"Title": "Sofa", "ChainName": "Loop"})
+	assert.NoError(t, err)
+	assert.Equal(t, notificationsTranslations["ja"]["bulky_item_expiring_title"], title)
+
+	_, content, err = notificationGenerate("ja", models.NotificationKindBulkyItemNew, gin.H{
+		"Title":     "とても大きくて座り心地の良い三人掛けのソファ",
+		"ChainName": "アムステルダム",
+	})
+	assert.NoError(t, err)
+	assert.Greater(t, len(content), notificationContentMaxLength)
+	assert.Equal(t, content, notificationTruncate(content, notificationContentMaxLength), "only texts with more characters than the limit are cut off")
+
+	assert.Equal(t, "שלום", notificationTruncate("שלום", 4))
+	assert.Equal(t, "あい...", notificationTruncate("あいうえおか", 5))
+
+	_, _, err = notificationGenerate("en", models.NotificationKindPoke, gin.H{})
+	assert.Error(t, err, "email only kinds have no notification text")
+
+	title, content, err = notificationGenerate("en", models.UserNotificationKindChainApproved, gin.H{
+		"ChainName": "Loop",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "You have been approved to join a Loop", title)
+	assert.Equal(t, "Loop", content)
+}
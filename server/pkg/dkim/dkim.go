@@ -0,0 +1,237 @@
+// Signs emails with DKIM (RFC 6376) using rsa-sha256 and relaxed/relaxed canonicalization
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Headers that are signed when present in the message
+var DefaultHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID",
+	"MIME-Version", "Content-Type", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// Headers that are listed in h= once more than they appear, also when absent,
+// so they can not be added to the message after signing, see RFC 6376 section 8.15
+var DefaultOverSignHeaders = []string{
+	"From", "Reply-To", "Subject", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+var (
+	ErrInvalidKey     = errors.New("DKIM private key must be a PEM encoded RSA key")
+	ErrInvalidMessage = errors.New("Message has no header section")
+)
+
+var (
+	regexpWSP       = regexp.MustCompile(`[ \t]+`)
+	regexpFWS       = regexp.MustCompile(`\s+`)
+	regexpSignature = regexp.MustCompile(`(b=)[^;]*$`)
+)
+
+type Signer struct {
+	Domain   string
+	Selector string
+	Headers  []string
+	// Headers of Headers that are over-signed
+	OverSignHeaders []string
+	key             *rsa.PrivateKey
+}
+
+// Accepts PKCS #1 and PKCS #8 private keys
+func NewSigner(domain, selector, privateKeyPEM string) (*Signer, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	var key *rsa.PrivateKey
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key = k
+	} else if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		key = rsaKey
+	} else {
+		return nil, ErrInvalidKey
+	}
+
+	return &Signer{
+		Domain:          domain,
+		Selector:        selector,
+		Headers:         DefaultHeaders,
+		OverSignHeaders: DefaultOverSignHeaders,
+		key:             key,
+	}, nil
+}
+
+// Returns the value of the DKIM-Signature header for the message,
+// the message must not be changed after signing except for adding the header.
+func (s *Signer) Sign(msg []byte, now time.Time) (string, error) {
+	headers, body, err := splitMessage(msg)
+	if err != nil {
+		return "", err
+	}
+
+	bodyHash := sha256.Sum256(canonicalBody(body))
+
+	signed := []string{}
+	canonical := strings.Builder{}
+	used := map[string]int{}
+	for _, name := range s.Headers {
+		key := strings.ToLower(name)
+		overSign := slices.ContainsFunc(s.OverSignHeaders, func(h string) bool {
+			return strings.EqualFold(h, name)
+		})
+		// signed from the bottom up, each header that appears more than once is signed again
+		found := findHeaders(headers, key)
+		for i := len(found) - 1 - used[key]; i >= 0; i-- {
+			used[key]++
+			signed = append(signed, key)
+			canonical.WriteString(canonicalHeader(found[i]))
+			if !overSign {
+				break
+			}
+		}
+		// a listed header that is absent is signed as an empty string, so it can not be added
+		if overSign {
+			signed = append(signed, key)
+		}
+	}
+
+	value := fmt.Sprintf("v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.Domain, s.Selector, now.Unix(), strings.Join(signed, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+	// the signature header is signed without its trailing line break
+	canonical.WriteString(strings.TrimSuffix(canonicalHeader("DKIM-Signature: "+value), "\r\n"))
+
+	hash := sha256.Sum256([]byte(canonical.String()))
+	signature, err := rsa.SignPKCS1v15(nil, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	value += base64.StdEncoding.EncodeToString(signature)
+
+	// fold between tags to keep lines short, this does not change the relaxed canonical form
+	return strings.ReplaceAll(value, "; ", ";\r\n "), nil
+}
+
+// Splits the message in unfolded headers and the body, line endings are normalized to CRLF
+func splitMessage(msg []byte) (headers []string, body []byte, err error) {
+	msg = bytes.ReplaceAll(msg, []byte("\r\n"), []byte("\n"))
+	msg = bytes.ReplaceAll(msg, []byte("\n"), []byte("\r\n"))
+
+	i := bytes.Index(msg, []byte("\r\n\r\n"))
+	if i < 0 {
+		return nil, nil, ErrInvalidMessage
+	}
+	body = msg[i+4:]
+
+	for _, line := range strings.Split(string(msg[:i]), "\r\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(headers) > 0 {
+			headers[len(headers)-1] += "\r\n" + line
+		} else {
+			headers = append(headers, line)
+		}
+	}
+	return headers, body, nil
+}
+
+func findHeaders(headers []string, key string) []string {
+	found := []string{}
+	for _, h := range headers {
+		name, _, ok := strings.Cut(h, ":")
+		if ok && strings.ToLower(strings.TrimSpace(name)) == key {
+			found = append(found, h)
+		}
+	}
+	return found
+}
+
+// Relaxed header canonicalization, see RFC 6376 section 3.4.2
+func canonicalHeader(h string) string {
+	name, value, _ := strings.Cut(h, ":")
+	name = strings.ToLower(strings.TrimSpace(name))
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = regexpWSP.ReplaceAllString(value, " ")
+	value = strings.TrimSpace(value)
+	return name + ":" + value + "\r\n"
+}
+
+// Relaxed body canonicalization, see RFC 6376 section 3.4.4
+func canonicalBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		line = regexpWSP.ReplaceAllString(line, " ")
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return []byte{}
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// Checks the DKIM-Signature header of a signed message against the public key,
+// the DNS lookup of the key is left to the caller
+func Verify(msg []byte, pub *rsa.PublicKey) error {
+	headers, body, err := splitMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	sigHeaders := findHeaders(headers, "dkim-signature")
+	if len(sigHeaders) == 0 {
+		return errors.New("Message has no DKIM-Signature header")
+	}
+	sigHeader := sigHeaders[0]
+	_, sigValue, _ := strings.Cut(sigHeader, ":")
+	tags := map[string]string{}
+	for _, tag := range strings.Split(sigValue, ";") {
+		k, v, _ := strings.Cut(tag, "=")
+		tags[strings.TrimSpace(k)] = regexpFWS.ReplaceAllString(v, "")
+	}
+
+	bodyHash := sha256.Sum256(canonicalBody(body))
+	if tags["bh"] != base64.StdEncoding.EncodeToString(bodyHash[:]) {
+		return errors.New("Body hash does not match")
+	}
+
+	canonical := strings.Builder{}
+	used := map[string]int{}
+	for _, key := range strings.Split(tags["h"], ":") {
+		found := findHeaders(headers, key)
+		i := len(found) - 1 - used[key]
+		// over-signed headers are listed more often than they appear
+		if i < 0 {
+			continue
+		}
+		used[key]++
+		canonical.WriteString(canonicalHeader(found[i]))
+	}
+	// the signature is left out of its own header
+	emptied := regexpSignature.ReplaceAllString(strings.TrimRight(sigHeader, "\r\n"), "$1")
+	canonical.WriteString(strings.TrimSuffix(canonicalHeader(emptied), "\r\n"))
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(canonical.String()))
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], signature)
+}
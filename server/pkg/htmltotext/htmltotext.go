@@ -0,0 +1,139 @@
+// Converts html emails to a plain text version, used as the text/plain alternative part
+package htmltotext
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+type converter struct {
+	out strings.Builder
+	// newlines at the end of out, to avoid stacking empty lines
+	newlines int
+	// a space is written before the next word
+	space bool
+	// inside an element whose content is not shown
+	skip int
+	// open links, to write the url after the link text
+	links []string
+}
+
+func Convert(s string) string {
+	c := &converter{newlines: 2}
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return strings.TrimSpace(c.out.String())
+		case html.TextToken:
+			if c.skip == 0 {
+				c.text(string(z.Text()))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			c.start(tok, tt == html.SelfClosingTagToken)
+		case html.EndTagToken:
+			tok := z.Token()
+			c.end(tok)
+		}
+	}
+}
+
+func (c *converter) start(tok html.Token, selfClosing bool) {
+	switch tok.Data {
+	case "head", "style", "script", "title":
+		if !selfClosing {
+			c.skip++
+		}
+	case "br":
+		c.newline(1)
+	case "p", "div", "table", "tr", "h1", "h2", "h3", "h4", "h5", "h6", "ul", "ol", "blockquote":
+		c.newline(2)
+	case "li":
+		c.newline(1)
+		c.write("- ")
+	case "td", "th":
+		c.space = true
+	case "img":
+		for _, a := range tok.Attr {
+			if a.Key == "alt" && a.Val != "" {
+				c.text(a.Val)
+			}
+		}
+	case "a":
+		href := ""
+		for _, a := range tok.Attr {
+			if a.Key == "href" {
+				href = a.Val
+			}
+		}
+		if selfClosing {
+			return
+		}
+		c.links = append(c.links, href)
+	}
+}
+
+func (c *converter) end(tok html.Token) {
+	switch tok.Data {
+	case "head", "style", "script", "title":
+		if c.skip > 0 {
+			c.skip--
+		}
+	case "p", "div", "table", "tr", "h1", "h2", "h3", "h4", "h5", "h6", "ul", "ol", "blockquote":
+		c.newline(2)
+	case "li":
+		c.newline(1)
+	case "a":
+		if len(c.links) == 0 {
+			return
+		}
+		href := c.links[len(c.links)-1]
+		c.links = c.links[:len(c.links)-1]
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "mailto:") {
+			return
+		}
+		c.space = true
+		c.text("(" + href + ")")
+	}
+}
+
+// Writes text with html whitespace collapsed
+func (c *converter) text(s string) {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if s != "" {
+			c.space = true
+		}
+		return
+	}
+
+	isFirstSpace := s[0] == ' ' || s[0] == '\t' || s[0] == '\n' || s[0] == '\r'
+	if isFirstSpace {
+		c.space = true
+	}
+	for i, w := range words {
+		if (i > 0 || c.space) && c.newlines == 0 {
+			c.write(" ")
+		}
+		c.write(w)
+	}
+	last := s[len(s)-1]
+	c.space = last == ' ' || last == '\t' || last == '\n' || last == '\r'
+}
+
+func (c *converter) write(s string) {
+	c.out.WriteString(s)
+	c.newlines = 0
+}
+
+// Ends the current line and adds empty lines up to n line endings in a row
+func (c *converter) newline(n int) {
+	for c.newlines < n {
+		c.out.WriteString("\n")
+		c.newlines++
+	}
+	c.space = false
+}
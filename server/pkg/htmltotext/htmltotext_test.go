@@ -0,0 +1,55 @@
+package htmltotext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		Name, Html, Expected string
+	}{
+		{
+			Name:     "paragraphs",
+			Html:     "<p>Hi Anna,</p>\n<p>Welcome   to\n the <b>loop</b>!</p>",
+			Expected: "Hi Anna,\n\nWelcome to the loop!",
+		},
+		{
+			Name:     "line breaks",
+			Html:     "<p>Much love,<br/>The Clothing Loop team</p>",
+			Expected: "Much love,\nThe Clothing Loop team",
+		},
+		{
+			Name:     "links",
+			Html:     `<p>Click <a href="https://example.com/login">here</a> to log in</p>`,
+			Expected: "Click here (https://example.com/login) to log in",
+		},
+		{
+			Name:     "mailto links",
+			Html:     `<a href="mailto:hello@example.com">hello@example.com</a>`,
+			Expected: "hello@example.com",
+		},
+		{
+			Name:     "lists",
+			Html:     "<ul><li>One</li><li>Two</li></ul><p>After</p>",
+			Expected: "- One\n- Two\n\nAfter",
+		},
+		{
+			Name:     "hidden elements",
+			Html:     "<html><head><title>Subject</title><style>p { color: red; }</style></head><body><p>Body</p><script>alert(1)</script></body></html>",
+			Expected: "Body",
+		},
+		{
+			Name:     "entities",
+			Html:     "<p>Jack &amp; Jill&#39;s loop</p>",
+			Expected: "Jack & Jill's loop",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, Convert(test.Html))
+		})
+	}
+}
@@ -0,0 +1,277 @@
+// Sends Web Push messages (RFC 8030) with aes128gcm payload encryption (RFC 8291) and VAPID (RFC 8292)
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Size of each encrypted record, the payload always fits in one
+const recordSize = 4096
+
+// Largest payload that push services must accept, see RFC 8291 section 4
+const MaxPayloadSize = 3993
+
+var (
+	ErrInvalidKey = errors.New("Invalid Web Push key")
+	// The subscription is no longer valid and should be removed
+	ErrSubscriptionExpired = errors.New("Web Push subscription expired")
+	ErrPayloadTooLarge     = errors.New("Web Push payload too large")
+	ErrInvalidEndpoint     = errors.New("Web Push endpoint is not an https url of a known push service")
+)
+
+// Hosts of the push services of the browsers, a leading dot matches any subdomain.
+// Endpoints are chosen by the browser, so any other host is refused to keep the
+// server from sending requests to internal addresses.
+var PushServiceHosts = []string{
+	"fcm.googleapis.com",
+	"updates.push.services.mozilla.com",
+	".push.apple.com",
+	".notify.windows.com",
+}
+
+// The subscription created by the browser, the keys are base64url encoded
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	P256dh   string `json:"p256dh"`
+	Auth     string `json:"auth"`
+}
+
+type VAPID struct {
+	// Uncompressed public key, base64url encoded, passed to the browser as the applicationServerKey
+	PublicKey string
+	// A mailto: or https: url the push service can contact
+	Subject string
+	key     *ecdsa.PrivateKey
+}
+
+// Returns a new key pair, both base64url encoded
+func GenerateVAPIDKeys() (publicKey, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return b64.EncodeToString(key.PublicKey().Bytes()), b64.EncodeToString(key.Bytes()), nil
+}
+
+// The private key is the base64url encoded 32 byte scalar, as created by GenerateVAPIDKeys
+func NewVAPID(publicKey, privateKey, subject string) (*VAPID, error) {
+	d, err := decodeB64(privateKey)
+	if err != nil || len(d) != 32 {
+		return nil, ErrInvalidKey
+	}
+	ecdhKey, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	pub := ecdhKey.PublicKey().Bytes()
+	if publicKey != "" && publicKey != b64.EncodeToString(pub) {
+		return nil, fmt.Errorf("%w: public key does not match the private key", ErrInvalidKey)
+	}
+
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}
+	return &VAPID{
+		PublicKey: b64.EncodeToString(pub),
+		Subject:   subject,
+		key:       key,
+	}, nil
+}
+
+// Returns the Authorization header for the push service of the endpoint
+func (v *VAPID) authorization(endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(12 * time.Hour).Unix(),
+		"sub": v.Subject,
+	})
+	signed, err := token.SignedString(v.key)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", signed, v.PublicKey), nil
+}
+
+// Checks that the endpoint is an https url of one of the PushServiceHosts
+func ValidateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return ErrInvalidEndpoint
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range PushServiceHosts {
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return nil
+		}
+	}
+	return ErrInvalidEndpoint
+}
+
+// Encrypts the payload for the subscription, the result is the request body
+func Encrypt(sub Subscription, payload []byte) ([]byte, error) {
+	if len(payload) > MaxPayloadSize {
+		return nil, ErrPayloadTooLarge
+	}
+	uaPublicBytes, err := decodeB64(sub.P256dh)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	authSecret, err := decodeB64(sub.Auth)
+	if err != nil || len(authSecret) == 0 {
+		return nil, ErrInvalidKey
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return encrypt(asPrivate, uaPublic, authSecret, salt, payload)
+}
+
+func encrypt(asPrivate *ecdh.PrivateKey, uaPublic *ecdh.PublicKey, authSecret, salt, payload []byte) ([]byte, error) {
+	cek, nonce, err := deriveKeys(asPrivate, uaPublic, asPrivate.PublicKey(), uaPublic, authSecret, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// a single record, ended by the last record delimiter
+	plaintext := append(append([]byte{}, payload...), 0x02)
+
+	asPublic := asPrivate.PublicKey().Bytes()
+	body := bytes.NewBuffer(nil)
+	body.Write(salt)
+	binary.Write(body, binary.BigEndian, uint32(recordSize))
+	body.WriteByte(byte(len(asPublic)))
+	body.Write(asPublic)
+	body.Write(gcm.Seal(nil, nonce, plaintext, nil))
+	return body.Bytes(), nil
+}
+
+// Derives the content encryption key and nonce, see RFC 8291 section 3.4.
+// The shared secret is computed with private and peer, which are either the
+// application server private key and user agent public key or the reverse.
+func deriveKeys(private *ecdh.PrivateKey, peer, asPublic, uaPublic *ecdh.PublicKey, authSecret, salt []byte) (cek, nonce []byte, err error) {
+	ecdhSecret, err := private.ECDH(peer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic.Bytes()...)
+	keyInfo = append(keyInfo, asPublic.Bytes()...)
+	ikm := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ecdhSecret, authSecret, keyInfo), ikm); err != nil {
+		return nil, nil, err
+	}
+
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	cek = make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: aes128gcm\x00")), cek); err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, 12)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: nonce\x00")), nonce); err != nil {
+		return nil, nil, err
+	}
+	return cek, nonce, nil
+}
+
+// Sends the payload to the push service of the subscription.
+// Returns ErrInvalidEndpoint when the endpoint is not of a known push service
+// and ErrSubscriptionExpired when the push service responds with 404 or 410.
+func (v *VAPID) Send(ctx context.Context, client *http.Client, sub Subscription, payload []byte, ttl time.Duration) error {
+	if err := ValidateEndpoint(sub.Endpoint); err != nil {
+		return err
+	}
+	body, err := Encrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := v.authorization(sub.Endpoint, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return ErrSubscriptionExpired
+	case res.StatusCode >= 300:
+		return fmt.Errorf("Web Push service responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+var b64 = base64.RawURLEncoding
+
+// Browsers may add padding or use the standard alphabet
+func decodeB64(s string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{base64.RawURLEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.StdEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, ErrInvalidKey
+}
@@ -0,0 +1,177 @@
+package webpush
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// Acts as the browser of the subscription
+func newTestSubscription(t *testing.T, endpoint string) (Subscription, *ecdh.PrivateKey, []byte) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return Subscription{
+		Endpoint: endpoint,
+		P256dh:   b64.EncodeToString(key.PublicKey().Bytes()),
+		Auth:     b64.EncodeToString(auth),
+	}, key, auth
+}
+
+// Decrypts the body like the browser would
+func decrypt(t *testing.T, uaPrivate *ecdh.PrivateKey, authSecret, body []byte) []byte {
+	t.Helper()
+	salt := body[:16]
+	assert.Equal(t, uint32(recordSize), binary.BigEndian.Uint32(body[16:20]))
+	idLen := int(body[20])
+	asPublic, err := ecdh.P256().NewPublicKey(body[21 : 21+idLen])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cek, nonce, err := deriveKeys(uaPrivate, asPublic, asPublic, uaPrivate.PublicKey(), authSecret, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, byte(0x02), plaintext[len(plaintext)-1], "last record delimiter")
+	return plaintext[:len(plaintext)-1]
+}
+
+func TestEncrypt(t *testing.T) {
+	sub, uaPrivate, auth := newTestSubscription(t, "https://push.example.com/abc")
+	payload := []byte(`{"title":"A bag has been assigned to you"}`)
+
+	body, err := Encrypt(sub, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, payload, decrypt(t, uaPrivate, auth, body))
+
+	_, err = Encrypt(sub, make([]byte, MaxPayloadSize+1))
+	assert.ErrorIs(t, err, ErrPayloadTooLarge)
+
+	_, err = Encrypt(Subscription{P256dh: "invalid", Auth: sub.Auth}, payload)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestNewVAPID(t *testing.T) {
+	pub, priv, err := GenerateVAPIDKeys()
+	assert.NoError(t, err)
+
+	v, err := NewVAPID(pub, priv, "mailto:hello@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, pub, v.PublicKey)
+
+	otherPub, _, _ := GenerateVAPIDKeys()
+	_, err = NewVAPID(otherPub, priv, "mailto:hello@example.com")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = NewVAPID("", "not a key", "mailto:hello@example.com")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+// Adds the host of httptest servers to the push services
+func allowTestServer(t *testing.T) {
+	hosts := PushServiceHosts
+	PushServiceHosts = append([]string{"127.0.0.1"}, hosts...)
+	t.Cleanup(func() { PushServiceHosts = hosts })
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	for _, endpoint := range []string{
+		"https://fcm.googleapis.com/fcm/send/abc",
+		"https://updates.push.services.mozilla.com/wpush/v2/abc",
+		"https://web.push.apple.com/abc",
+		"https://wns2-db5p.notify.windows.com/w/?token=abc",
+	} {
+		assert.NoError(t, ValidateEndpoint(endpoint), endpoint)
+	}
+	for _, endpoint := range []string{
+		"http://fcm.googleapis.com/fcm/send/abc",
+		"https://127.0.0.1/push",
+		"https://localhost/push",
+		"https://169.254.169.254/latest/meta-data",
+		"https://push.apple.com.example.com/abc",
+		"https://evilnotify.windows.com/abc",
+		"https://user@fcm.googleapis.com/fcm/send/abc",
+		"not a url",
+	} {
+		assert.ErrorIs(t, ValidateEndpoint(endpoint), ErrInvalidEndpoint, endpoint)
+	}
+}
+
+func TestSend(t *testing.T) {
+	pub, priv, _ := GenerateVAPIDKeys()
+	v, err := NewVAPID(pub, priv, "mailto:hello@example.com")
+	assert.NoError(t, err)
+
+	var received *http.Request
+	var receivedBody []byte
+	status := http.StatusCreated
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	allowTestServer(t)
+
+	sub, uaPrivate, auth := newTestSubscription(t, server.URL+"/push/abc")
+	err = v.Send(context.Background(), server.Client(), sub, []byte("hello"), time.Hour)
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, received) {
+		assert.Equal(t, "aes128gcm", received.Header.Get("Content-Encoding"))
+		assert.Equal(t, "3600", received.Header.Get("TTL"))
+		assert.Equal(t, []byte("hello"), decrypt(t, uaPrivate, auth, receivedBody))
+
+		authorization := received.Header.Get("Authorization")
+		assert.True(t, strings.HasPrefix(authorization, "vapid t="))
+		assert.True(t, strings.HasSuffix(authorization, ", k="+pub))
+		tokenString := strings.TrimSuffix(strings.TrimPrefix(authorization, "vapid t="), ", k="+pub)
+		token, err := jwt.Parse(tokenString, func(*jwt.Token) (any, error) {
+			return &v.key.PublicKey, nil
+		}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience(server.URL))
+		assert.NoError(t, err)
+		assert.True(t, token.Valid)
+	}
+
+	t.Run("expired subscriptions", func(t *testing.T) {
+		for _, s := range []int{http.StatusNotFound, http.StatusGone} {
+			status = s
+			err := v.Send(context.Background(), server.Client(), sub, []byte("hello"), time.Hour)
+			assert.ErrorIs(t, err, ErrSubscriptionExpired)
+		}
+		status = http.StatusTooManyRequests
+		err := v.Send(context.Background(), server.Client(), sub, []byte("hello"), time.Hour)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrSubscriptionExpired)
+	})
+
+	t.Run("unknown push services", func(t *testing.T) {
+		received = nil
+		PushServiceHosts = PushServiceHosts[1:]
+		err := v.Send(context.Background(), server.Client(), sub, []byte("hello"), time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidEndpoint)
+		assert.Nil(t, received)
+	})
+}
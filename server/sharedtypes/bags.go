@@ -5,12 +5,14 @@ import (
 )
 
 type Bag struct {
-	ID                    uint       `json:"id"`
-	Number                string     `json:"number"`
-	Color                 string     `json:"color"`
-	UserChainID           uint       `json:"-"`
-	ChainUID              string     `json:"chain_uid" gorm:"-:migration;<-:false"`
-	UserUID               string     `json:"user_uid" gorm:"-:migration;<-:false"`
+	ID          uint   `json:"id"`
+	Number      string `json:"number"`
+	Color       string `json:"color"`
+	UserChainID uint   `json:"-"`
+	ChainUID    string `json:"chain_uid" gorm:"-:migration;<-:false"`
+	UserUID     string `json:"user_uid" gorm:"-:migration;<-:false"`
+	// What is in the bag, matched against the wishes of the loop
+	Note                  string     `json:"note" gorm:"size:255"`
 	UpdatedAt             time.Time  `json:"updated_at" gorm:"autoUpdateTime:false"`
 	LastNotifiedAt        *time.Time `json:"-"`
 	LastUserEmailToUpdate string     `json:"-"`
@@ -2,13 +2,84 @@ package sharedtypes
 
 import "time"
 
+const (
+	BulkyItemStatusAvailable = "available"
+	BulkyItemStatusReserved  = "reserved"
+	BulkyItemStatusGiven     = "given"
+	// Not renewed by the author within the expiry days of the loop
+	BulkyItemStatusExpired = "expired"
+)
+
+var BulkyItemStatuses = []string{BulkyItemStatusAvailable, BulkyItemStatusReserved, BulkyItemStatusGiven, BulkyItemStatusExpired}
+
+const (
+	BulkyItemCategoryFurniture   = "furniture"
+	BulkyItemCategoryKids        = "kids"
+	BulkyItemCategoryKitchen     = "kitchen"
+	BulkyItemCategorySports      = "sports"
+	BulkyItemCategoryElectronics = "electronics"
+	BulkyItemCategoryGarden      = "garden"
+	BulkyItemCategoryBooks       = "books"
+	BulkyItemCategoryHousehold   = "household"
+	BulkyItemCategoryOther       = "other"
+)
+
+var BulkyItemCategories = []string{
+	BulkyItemCategoryFurniture,
+	BulkyItemCategoryKids,
+	BulkyItemCategoryKitchen,
+	BulkyItemCategorySports,
+	BulkyItemCategoryElectronics,
+	BulkyItemCategoryGarden,
+	BulkyItemCategoryBooks,
+	BulkyItemCategoryHousehold,
+	BulkyItemCategoryOther,
+}
+
 type BulkyItem struct {
-	ID          uint      `json:"id"`
-	Title       string    `json:"title"`
-	Message     string    `json:"message"`
-	ImageUrl    string    `json:"image_url"`
-	UserChainID uint      `json:"-"`
-	ChainUID    string    `json:"chain_uid" gorm:"-:migration;<-:false"`
-	UserUID     string    `json:"user_uid" gorm:"-:migration;<-:false"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          uint     `json:"id"`
+	Title       string   `json:"title" gorm:"index:idx_bulky_items_search,class:FULLTEXT"`
+	Message     string   `json:"message" gorm:"index:idx_bulky_items_search,class:FULLTEXT"`
+	ImageUrl    string   `json:"image_url"`
+	UserChainID uint     `json:"-"`
+	ChainUID    string   `json:"chain_uid" gorm:"-:migration;<-:false"`
+	UserUID     string   `json:"user_uid" gorm:"-:migration;<-:false"`
+	Status      string   `json:"status" gorm:"size:20;not null;default:'available';index"`
+	Category    string   `json:"category" gorm:"size:20;not null;default:'other';index"`
+	Tags        []string `json:"tags" gorm:"type:text;serializer:json;index:idx_bulky_items_search,class:FULLTEXT"`
+	Sizes       []string `json:"sizes" gorm:"serializer:json"`
+	Genders     []string `json:"genders" gorm:"serializer:json"`
+	// The member that reserved the item, or received it once given away
+	ReceiverUserChainID *uint      `json:"-"`
+	ReceiverUID         string     `json:"receiver_uid,omitempty" gorm:"-:migration;<-:false"`
+	LastNotifiedAt      *time.Time `json:"-"`
+	CreatedAt           time.Time  `json:"created_at"`
+	// Changes by the author renew the item
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type BulkyItemReserveRequest struct {
+	ChainUID string `json:"chain_uid" binding:"required,uuid"`
+	ID       uint   `json:"id" binding:"required"`
+}
+
+type BulkyItemGivenRequest struct {
+	ChainUID string `json:"chain_uid" binding:"required,uuid"`
+	ID       uint   `json:"id" binding:"required"`
+	// Defaults to the member that reserved the item
+	ReceiverUID string `json:"receiver_uid,omitempty" binding:"omitempty,uuid"`
+}
+
+type BulkyItemSubscriptionsRequest struct {
+	ChainUID string `json:"chain_uid" binding:"required,uuid"`
+	// Replaces the categories the member is subscribed to, none receives all new items of the loop
+	Categories []string `json:"categories"`
+}
+
+// Members with subscriptions in a loop are only notified of new items in those categories
+type BulkyItemSubscription struct {
+	ID       uint   `json:"-"`
+	UserID   uint   `json:"-" gorm:"uniqueIndex:uidx_bulky_item_subscription"`
+	ChainID  uint   `json:"-" gorm:"uniqueIndex:uidx_bulky_item_subscription;index"`
+	Category string `json:"category" gorm:"uniqueIndex:uidx_bulky_item_subscription;size:20"`
 }
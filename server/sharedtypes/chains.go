@@ -22,6 +22,10 @@ type ChainResponse struct {
 	RoutePrivacy     *int     `json:"route_privacy,omitempty" gorm:"chains.route_privacy"`
 	AllowMap         *bool    `json:"allow_map,omitempty" gorm:"chains.allow_map"`
 	ChatRoomIDs      []string `json:"chat_room_ids,omitempty" gorm:"chains.chat_room_ids"`
+	// Days after which chat messages are removed, zero keeps them forever
+	ChatRetentionDays *int `json:"chat_retention_days,omitempty" gorm:"chains.chat_retention_days"`
+	// Days after which bulky items expire when not renewed, zero keeps them forever
+	BulkyItemExpiryDays *int `json:"bulky_item_expiry_days,omitempty" gorm:"chains.bulky_item_expiry_days"`
 }
 
 type ChainCreateRequest struct {
@@ -58,6 +62,8 @@ type ChainUpdateRequest struct {
 	RoutePrivacy     *int      `json:"route_privacy"`
 	AllowMap         *bool     `json:"allow_map,omitempty"`
 	IsAppDisabled    *bool     `json:"is_app_disabled,omitempty"`
+	// Zero turns expiry off, the author is reminded 2 days before
+	BulkyItemExpiryDays *int `json:"bulky_item_expiry_days,omitempty" binding:"omitempty,min=3,max=365"`
 }
 
 type ChainAddUserRequest struct {
@@ -81,3 +87,21 @@ type ChainApproveUserRequest struct {
 	UserUID  string `json:"user_uid" binding:"required,uuid"`
 	ChainUID string `json:"chain_uid" binding:"required,uuid"`
 }
+
+type ChainRole struct {
+	Name      string   `json:"name"`
+	Actions   []string `json:"actions"`
+	IsBuiltIn bool     `json:"is_built_in"`
+}
+
+type ChainRolePutRequest struct {
+	ChainUID string   `json:"chain_uid" binding:"required,uuid"`
+	Name     string   `json:"name" binding:"required,max=50"`
+	Actions  []string `json:"actions"`
+}
+
+type ChainChangeUserRoleRequest struct {
+	UserUID  string `json:"user_uid" binding:"required,uuid"`
+	ChainUID string `json:"chain_uid" binding:"required,uuid"`
+	Role     string `json:"role" binding:"required,max=50"`
+}
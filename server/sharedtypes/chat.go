@@ -1,13 +1,18 @@
 package sharedtypes
 
+import "time"
+
 type ChatPatchUserRequest struct {
 	ChainUID string `json:"chain_uid" binding:"required,uuid"`
 }
 
 type ChatPatchUserResponse struct {
+	// mattermost or builtin
+	ChatProvider string `json:"chat_provider"`
 	ChatTeam     string `json:"chat_team"`
 	ChatUserID   string `json:"chat_user_id"`
-	ChatPass     string `json:"chat_pass"`
+	// Empty for the built-in chat, which uses the api token
+	ChatToken    string `json:"chat_token"`
 	ChatUserName string `json:"chat_user_name"`
 }
 
@@ -29,3 +34,98 @@ type ChatDeleteChannelRequest struct {
 type ChatJoinChannelsRequest struct {
 	ChainUID string `json:"chain_uid" binding:"required,uuid"`
 }
+
+// A message of the built-in chat
+type ChatMessage struct {
+	ID            uint      `json:"id"`
+	ChatChannelID uint      `json:"-" gorm:"index"`
+	ChannelUID    string    `json:"channel_id" gorm:"-:migration;<-:false"`
+	UserID        uint      `json:"-"`
+	UserUID       string    `json:"user_uid" gorm:"-:migration;<-:false"`
+	UserName      string    `json:"user_name" gorm:"-:migration;<-:false"`
+	Message       string    `json:"message" gorm:"type:text"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ChatMessageCreateRequest struct {
+	ChainUID  string `json:"chain_uid" binding:"required,uuid"`
+	ChannelID string `json:"channel_id" binding:"required"`
+	Message   string `json:"message" binding:"required,max=4000"`
+}
+
+const (
+	ChatSyncActionRemove     = "remove"
+	ChatSyncActionSetAdmin   = "set_admin"
+	ChatSyncActionUnsetAdmin = "unset_admin"
+)
+
+// A difference between the members of a loop and the members of one of its channels
+type ChatSyncChange struct {
+	ChainUID   string `json:"chain_uid"`
+	ChannelID  string `json:"channel_id"`
+	ChatUserID string `json:"chat_user_id"`
+	// Empty when the chat user does not belong to any user
+	UserUID string `json:"user_uid"`
+	Action  string `json:"action"`
+	// Not set on a dry run or when the change is applied
+	Error string `json:"error,omitempty"`
+}
+
+type ChatSyncReport struct {
+	DryRun  bool             `json:"dry_run"`
+	Chains  int              `json:"chains"`
+	Changes []ChatSyncChange `json:"changes"`
+	// Loops of which the channel members could not be retrieved
+	Errors []string `json:"errors"`
+}
+
+type ChatMessageReportRequest struct {
+	ChainUID  string `json:"chain_uid" binding:"required,uuid"`
+	ChannelID string `json:"channel_id" binding:"required"`
+	MessageID string `json:"message_id" binding:"required"`
+	Reason    string `json:"reason" binding:"max=500"`
+}
+
+// A message a member reported to the hosts of the loop
+type ChatMessageReport struct {
+	ID        uint   `json:"id"`
+	ChainID   uint   `json:"-" gorm:"index"`
+	ChannelID string `json:"channel_id" gorm:"size:64"`
+	MessageID string `json:"message_id" gorm:"size:64"`
+	// The message at the moment it was reported
+	Message          string    `json:"message" gorm:"type:text"`
+	AuthorChatUserID string    `json:"-" gorm:"size:64"`
+	AuthorUID        string    `json:"author_uid" gorm:"-:migration;<-:false"`
+	AuthorName       string    `json:"author_name" gorm:"-:migration;<-:false"`
+	ReporterUserID   uint      `json:"-"`
+	ReporterUID      string    `json:"reporter_uid" gorm:"-:migration;<-:false"`
+	ReporterName     string    `json:"reporter_name" gorm:"-:migration;<-:false"`
+	Reason           string    `json:"reason"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type ChatMuteRequest struct {
+	ChainUID string `json:"chain_uid" binding:"required,uuid"`
+	UserUID  string `json:"user_uid" binding:"required,uuid"`
+	// Up to 30 days
+	Minutes int `json:"minutes" binding:"required,min=1,max=43200"`
+}
+
+// A member that can not send messages in the channels of the loop until the mute ends
+type ChatMute struct {
+	ID        uint      `json:"-"`
+	ChainID   uint      `json:"-" gorm:"uniqueIndex:uidx_chat_mute"`
+	UserID    uint      `json:"-" gorm:"uniqueIndex:uidx_chat_mute"`
+	UserUID   string    `json:"user_uid" gorm:"-:migration;<-:false"`
+	UserName  string    `json:"user_name" gorm:"-:migration;<-:false"`
+	Until     time.Time `json:"until" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+	// Channels the member was removed from to mute them, joined again when the mute ends
+	ChannelIDs []string `json:"-" gorm:"serializer:json"`
+}
+
+type ChatRetentionRequest struct {
+	ChainUID string `json:"chain_uid" binding:"required,uuid"`
+	// Messages older than this are removed by the daily cron, zero keeps messages forever
+	Days int `json:"days" binding:"min=0,max=3650"`
+}
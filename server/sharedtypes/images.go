@@ -1,7 +1,21 @@
 package sharedtypes
 
 type ImageUploadResponse struct {
-	Delete    string `json:"delete"`
+	// Used to delete the image
+	ID        uint   `json:"id"`
 	Thumbnail string `json:"thumbnail"`
-	Image     string `json:"image"`
+	// 800px, the size shown on the page of an event or bulky item
+	Image    string `json:"image"`
+	Original string `json:"original"`
+}
+
+type ImageGCReport struct {
+	DryRun bool `json:"dry_run"`
+	// Images that are in use or were uploaded within the grace period
+	Kept int `json:"kept"`
+	// Names of the images without references that are removed, or would be on a dry run
+	Removed []string `json:"removed"`
+	Bytes   int64    `json:"bytes"`
+	// Files that could not be removed
+	Errors []string `json:"errors"`
 }
@@ -0,0 +1,59 @@
+package sharedtypes
+
+import "time"
+
+type NotificationPreference struct {
+	Kind string `json:"kind"`
+	// The channel used when the user has not chosen one
+	Default  string   `json:"default"`
+	Channels []string `json:"channels"`
+	Channel  string   `json:"channel"`
+	// The channel is chosen for this loop instead of for all loops
+	IsChainOverride bool `json:"is_chain_override"`
+}
+
+type NotificationPreferenceUpdateRequest struct {
+	ChainUID string `json:"chain_uid,omitempty" binding:"omitempty,uuid"`
+	Kind     string `json:"kind" binding:"required"`
+	// Empty to remove the choice and use the choice for all loops or the default
+	Channel string `json:"channel"`
+}
+
+type WebPushPublicKeyResponse struct {
+	// Empty when Web Push is disabled
+	PublicKey string `json:"public_key"`
+}
+
+// The PushSubscription of the browser, keys are base64url encoded
+type WebPushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" binding:"required,url,max=500"`
+	P256dh   string `json:"p256dh" binding:"required,max=200"`
+	Auth     string `json:"auth" binding:"required,max=100"`
+}
+
+type WebPushSubscriptionDeleteRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+}
+
+type UserNotification struct {
+	ID     uint   `json:"id"`
+	UserID uint   `json:"-" gorm:"index:idx_user_notification_user_created"`
+	Kind   string `json:"kind" gorm:"size:50"`
+	// Zero when the notification is not about a loop
+	ChainID   uint       `json:"-"`
+	ChainUID  *string    `json:"chain_uid,omitempty" gorm:"-:migration;<-:false"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"index:idx_user_notification_user_created"`
+}
+
+type UserNotificationListResponse struct {
+	Notifications []UserNotification `json:"notifications"`
+	// Amount of unread notifications of all pages
+	Unread int `json:"unread"`
+}
+
+type UserNotificationMarkReadRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1,max=100"`
+}
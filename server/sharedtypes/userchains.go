@@ -10,6 +10,7 @@ type UserChain struct {
 	ChainUID                   string      `json:"chain_uid" gorm:"-:migration;<-:false"`
 	IsChainAdmin               bool        `json:"is_chain_admin"`
 	IsChainWarden              bool        `json:"is_chain_warden"`
+	Role                       string      `json:"role" gorm:"size:50;not null;default:''"`
 	CreatedAt                  time.Time   `json:"created_at"`
 	IsApproved                 bool        `json:"is_approved"`
 	LastNotifiedIsUnapprovedAt *time.Time  `json:"-"`
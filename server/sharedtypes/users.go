@@ -12,6 +12,8 @@ type User struct {
 	IsEmailVerified       bool            `json:"is_email_verified"`
 	IsRootAdmin           bool            `json:"is_root_admin"`
 	PausedUntil           *time.Time      `json:"paused_until"`
+	PurgeAt               *time.Time      `json:"purge_at"`
+	EmailBouncedAt        *time.Time      `json:"email_bounced_at"`
 	Name                  string          `json:"name"`
 	PhoneNumber           string          `json:"phone_number"`
 	Address               string          `json:"address"`
@@ -33,9 +35,11 @@ type User struct {
 	AcceptedTOHJSON       *bool           `json:"accepted_toh,omitempty" gorm:"-:migration;<-:false"`
 	AcceptedDPAJSON       *bool           `json:"accepted_dpa,omitempty" gorm:"-:migration;<-:false"`
 	NotificationChainUIDs []string        `json:"notification_chain_uids,omitempty" gorm:"-"`
+	NotificationUnread    *int            `json:"notification_unread,omitempty" gorm:"-"`
 	ChatUserID            *string         `json:"chat_id"`
-	ChatPass              *string         `json:"-"`
 	ChatUserName          *string         `json:"chat_user_name"`
+	// Session token of the app on the chat server, reused until the user logs out
+	ChatToken *string `json:"-"`
 }
 
 type UserCreateRequest struct {
@@ -71,3 +75,21 @@ type UserTransferChainRequest struct {
 	ToChainUID      string `json:"to_chain_uid" binding:"required,uuid"`
 	IsCopy          bool   `json:"is_copy"`
 }
+
+type UserEmailChangeRequest struct {
+	UserUID  string `json:"user_uid" binding:"required,uuid"`
+	NewEmail string `json:"new_email" binding:"required,email"`
+}
+
+type UserEmailChangeVerifyRequest struct {
+	UserUID string `json:"user_uid" binding:"required,uuid"`
+	OTP     string `json:"otp" binding:"required"`
+}
+
+type UserDataExportRequest struct {
+	UserUID string `json:"user_uid" binding:"required,uuid"`
+}
+
+type UserPurgeCancelRequest struct {
+	Token string `json:"token" binding:"required,uuid"`
+}
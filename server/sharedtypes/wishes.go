@@ -0,0 +1,34 @@
+package sharedtypes
+
+import "time"
+
+// Something a member of the loop is looking for
+type Wish struct {
+	ID       uint   `json:"id"`
+	ChainID  uint   `json:"-" gorm:"index"`
+	UserID   uint   `json:"-" gorm:"index"`
+	UserUID  string `json:"user_uid" gorm:"-:migration;<-:false"`
+	UserName string `json:"user_name" gorm:"-:migration;<-:false"`
+	Title    string `json:"title" gorm:"size:100"`
+	Message  string `json:"message"`
+	// One of the bulky item categories, empty for clothing
+	Category string   `json:"category" gorm:"size:20"`
+	Sizes    []string `json:"sizes" gorm:"serializer:json"`
+	Genders  []string `json:"genders" gorm:"serializer:json"`
+	// Fulfilled wishes are no longer matched
+	Fulfilled bool      `json:"fulfilled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WishPutRequest struct {
+	ChainUID string `json:"chain_uid" binding:"required,uuid"`
+	// Creates a new wish when empty
+	ID        uint      `json:"id,omitempty"`
+	Title     *string   `json:"title,omitempty" binding:"omitempty,min=2,max=100"`
+	Message   *string   `json:"message,omitempty" binding:"omitempty,max=1000"`
+	Category  *string   `json:"category,omitempty"`
+	Sizes     *[]string `json:"sizes,omitempty"`
+	Genders   *[]string `json:"genders,omitempty"`
+	Fulfilled *bool     `json:"fulfilled,omitempty"`
+}